@@ -15,21 +15,35 @@ import (
 )
 
 type Dem struct {
-	TileMap   [][][]*image_ext.Gray32f // m.TileMap[level][col][row]
 	TileSize  image.Point
 	Rect      image.Rectangle
 	ZeroValue color_ext.Gray32f
+	store     TileStore
+	levels    []image.Point // tiles across and down of the levels
+	err       *firstError   // the first store error of GetTile and SetGray32f
+	ver       *tileVersion
 }
 
 func NewDem(r image.Rectangle, tileSize image.Point, zeroValue color_ext.Gray32f) *Dem {
+	return NewDemWithStore(r, tileSize, zeroValue, NewMemoryTileStore())
+}
+
+// NewDemWithStore returns a Dem whose tiles are kept in store.
+// The tiles already in store are used. A store other than a MemoryTileStore
+// or a LRUTileCache is used behind a LRUTileCache of DefaultTileCacheSize
+// tiles, and the modified tiles are written to it on eviction or Flush.
+func NewDemWithStore(r image.Rectangle, tileSize image.Point, zeroValue color_ext.Gray32f, store TileStore) *Dem {
 	if r.Empty() || tileSize.X <= 0 || tileSize.Y <= 0 {
 		panic(fmt.Sprintf("image/big: NewDem, bad arguments: r = %v, tileSize = %v", r, tileSize))
 	}
 	return &Dem{
-		TileMap:   makeDemTileMap(r, tileSize),
 		TileSize:  tileSize,
 		Rect:      r,
 		ZeroValue: zeroValue,
+		store:     cachedTileStore(store),
+		levels:    makeTileLevels(r, tileSize),
+		err:       new(firstError),
+		ver:       new(tileVersion),
 	}
 }
 
@@ -42,10 +56,13 @@ func (p *Dem) SubLevels(levels int) *Dem {
 		r.Max.Y /= 2
 	}
 	return &Dem{
-		TileMap:   p.TileMap[:levels],
 		TileSize:  p.TileSize,
 		Rect:      r,
 		ZeroValue: p.ZeroValue,
+		store:     p.store,
+		levels:    p.levels[:levels],
+		err:       p.err,
+		ver:       p.ver,
 	}
 }
 
@@ -59,32 +76,29 @@ func (p *Dem) At(x, y int) color.Color {
 
 func (p *Dem) Gray32fAt(x, y int) color_ext.Gray32f {
 	level, col, row := p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y
-	if m := p.TileMap[level][col][row]; m != nil {
-		return m.At(x%p.TileSize.X, y%p.TileSize.Y).(color_ext.Gray32f)
+	if m := p.lookupTile(level, col, row); m != nil {
+		return m.Gray32fAt(x%p.TileSize.X, y%p.TileSize.Y)
 	}
-	return color_ext.Gray32f{}
+	return p.ZeroValue
 }
 
 func (p *Dem) Set(x, y int, c color.Color) {
-	if !(image.Point{x, y}.In(p.Rect)) {
-		return
-	}
-	m := p.GetTile(p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y)
-	m.Set(x%p.TileSize.X, y%p.TileSize.Y, c)
-	return
+	p.SetGray32f(x, y, color_ext.Gray32fModel.Convert(c).(color_ext.Gray32f))
 }
 
 func (p *Dem) SetGray32f(x, y int, c color_ext.Gray32f) {
 	if !(image.Point{x, y}.In(p.Rect)) {
 		return
 	}
-	m := p.GetTile(p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y)
+	level, col, row := p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y
+	m := p.GetTile(level, col, row)
 	m.SetGray32f(x%p.TileSize.X, y%p.TileSize.Y, c)
+	p.putTile(level, col, row, m)
 	return
 }
 
 func (p *Dem) Levels() int {
-	return len(p.TileMap)
+	return len(p.levels)
 }
 
 func (p *Dem) adjustLevel(level int) int {
@@ -96,23 +110,25 @@ func (p *Dem) adjustLevel(level int) int {
 
 func (p *Dem) TilesAcross(level int) int {
 	level = p.adjustLevel(level)
-	v := len(p.TileMap[level])
+	v := p.levels[level].X
 	return v
 }
 
 func (p *Dem) TilesDown(level int) int {
 	level = p.adjustLevel(level)
-	v := len(p.TileMap[level][0])
+	v := p.levels[level].Y
 	return v
 }
 
+// GetTile returns the tile, a new tile is stored if needed.
+// The tile may be evicted from the cache of the store at any time, so the
+// changes made to it in place must be stored again with SetTile.
 func (p *Dem) GetTile(level, col, row int) (m *image_ext.Gray32f) {
 	level = p.adjustLevel(level)
-	if m = p.TileMap[level][col][row]; m != nil {
-		return
+	if m = p.lookupTile(level, col, row); m == nil {
+		m = newDemTile(p.TileSize, p.ZeroValue)
+		p.putTile(level, col, row, m)
 	}
-	m = newDemTile(p.TileSize, p.ZeroValue)
-	p.TileMap[level][col][row] = m
 	return
 }
 
@@ -122,10 +138,49 @@ func (p *Dem) SetTile(level, col, row int, m *image_ext.Gray32f) (err error) {
 		err = fmt.Errorf("image/big: Dem.SetTile, bad bound size: %v", m.Bounds())
 		return
 	}
-	p.TileMap[level][col][row] = m
+	if err = p.store.Put(level, col, row, m); err != nil {
+		return
+	}
+	p.ver.Add()
 	return
 }
 
+// Flush writes the buffered tiles of the store.
+// It returns the first store error of GetTile and SetGray32f if there is one.
+func (p *Dem) Flush() (err error) {
+	if err = p.Err(); err != nil {
+		return
+	}
+	return p.store.Flush()
+}
+
+// Err returns the first store error of GetTile and SetGray32f.
+func (p *Dem) Err() error {
+	return p.err.Get()
+}
+
+// lookupTile returns the stored tile or nil.
+func (p *Dem) lookupTile(level, col, row int) *image_ext.Gray32f {
+	m, err := p.store.Get(level, col, row)
+	if err != nil {
+		p.err.Set(err)
+		return nil
+	}
+	if m == nil {
+		return nil
+	}
+	tile, ok := m.(*image_ext.Gray32f)
+	if !ok {
+		p.err.Set(fmt.Errorf("image/big: Dem, bad tile type: %T", m))
+	}
+	return tile
+}
+
+func (p *Dem) putTile(level, col, row int, m *image_ext.Gray32f) {
+	p.err.Set(p.store.Put(level, col, row, m))
+	p.ver.Add()
+}
+
 func (p *Dem) ReadRect(level int, r image.Rectangle, buf *image_ext.Gray32f) (m *image_ext.Gray32f, err error) {
 	level = p.adjustLevel(level)
 	if !r.In(p.Bounds()) {
@@ -152,9 +207,13 @@ func (p *Dem) ReadRect(level int, r image.Rectangle, buf *image_ext.Gray32f) (m
 	m = newDemTile(r.Size(), p.ZeroValue)
 	for col := tMinX; col < tMaxX; col++ {
 		for row := tMinY; row < tMaxY; row++ {
-			p.readRectFromTile(m, p.GetTile(level, col, row), r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+			// the buffer is filled with the ZeroValue of the tiles not stored
+			if tile := p.lookupTile(level, col, row); tile != nil {
+				p.readRectFromTile(m, tile, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+			}
 		}
 	}
+	err = p.Err()
 	return
 }
 
@@ -181,7 +240,7 @@ func (p *Dem) readRectFromTile(dst, tile *image_ext.Gray32f, x, y, dx, dy, col,
 	draw_ext.Draw(
 		dst, image.Rect(
 			zMinX-bMinX,
-			zMinY-bMinX,
+			zMinY-bMinY,
 			zMaxX-bMinX,
 			zMaxY-bMinY,
 		),
@@ -214,11 +273,16 @@ func (p *Dem) WriteRect(level int, r image.Rectangle, m *image_ext.Gray32f) (err
 
 	for col := tMinX; col < tMaxX; col++ {
 		for row := tMinY; row < tMaxY; row++ {
-			p.writeRectToTile(m, p.GetTile(level, col, row), r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+			tile := p.GetTile(level, col, row)
+			p.writeRectToTile(m, tile, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+			p.putTile(level, col, row, tile)
 		}
 	}
 
-	err = p.updateRectPyramid(level, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	if err = p.updateRectPyramid(level, r.Min.X, r.Min.Y, r.Dx(), r.Dy()); err != nil {
+		return
+	}
+	err = p.Err()
 	return
 }
 
@@ -281,40 +345,64 @@ func (p *Dem) updateRectPyramid(level, x, y, dx, dy int) (err error) {
 			}
 		}
 
-		x, dx = minX/2, maxX/2-minX/2
-		y, dy = minY/2, maxY/2-minY/2
+		x, dx = minX/2, maxX/2-minX/2+1
+		y, dy = minY/2, maxY/2-minY/2+1
 		level--
 	}
 	return
 }
 
 func (p *Dem) updateParentTile(level, col, row int) (err error) {
-	parent, child := p.GetTile(level-1, col/2, row/2), p.GetTile(level, col, row)
+	parent, child := p.GetTile(level-1, col/2, row/2), p.lookupTile(level, col, row)
+	if child == nil {
+		child = newDemTile(p.TileSize, p.ZeroValue)
+	}
 	switch {
 	case col%2 == 0 && row%2 == 0:
 		draw_ext.DrawPyrDown(
-			parent, image.Rect((p.TileSize.X/2)*0, (p.TileSize.Y/2)*0, p.TileSize.X/2, p.TileSize.Y/2),
+			parent, image.Rect(
+				(p.TileSize.X/2)*0,
+				(p.TileSize.Y/2)*0,
+				(p.TileSize.X/2)*0+p.TileSize.X/2,
+				(p.TileSize.Y/2)*0+p.TileSize.Y/2,
+			),
 			child, image.Pt(0, 0),
 			draw_ext.Filter_Average,
 		)
 	case col%2 == 0 && row%2 == 1:
 		draw_ext.DrawPyrDown(
-			parent, image.Rect((p.TileSize.X/2)*0, (p.TileSize.Y/2)*1, p.TileSize.X/2, p.TileSize.Y/2),
+			parent, image.Rect(
+				(p.TileSize.X/2)*0,
+				(p.TileSize.Y/2)*1,
+				(p.TileSize.X/2)*0+p.TileSize.X/2,
+				(p.TileSize.Y/2)*1+p.TileSize.Y/2,
+			),
 			child, image.Pt(0, 0),
 			draw_ext.Filter_Average,
 		)
 	case col%2 == 1 && row%2 == 1:
 		draw_ext.DrawPyrDown(
-			parent, image.Rect((p.TileSize.X/2)*1, (p.TileSize.Y/2)*1, p.TileSize.X/2, p.TileSize.Y/2),
+			parent, image.Rect(
+				(p.TileSize.X/2)*1,
+				(p.TileSize.Y/2)*1,
+				(p.TileSize.X/2)*1+p.TileSize.X/2,
+				(p.TileSize.Y/2)*1+p.TileSize.Y/2,
+			),
 			child, image.Pt(0, 0),
 			draw_ext.Filter_Average,
 		)
 	case col%2 == 1 && row%2 == 0:
 		draw_ext.DrawPyrDown(
-			parent, image.Rect((p.TileSize.X/2)*1, (p.TileSize.Y/2)*0, p.TileSize.X/2, p.TileSize.Y/2),
+			parent, image.Rect(
+				(p.TileSize.X/2)*1,
+				(p.TileSize.Y/2)*0,
+				(p.TileSize.X/2)*1+p.TileSize.X/2,
+				(p.TileSize.Y/2)*0+p.TileSize.Y/2,
+			),
 			child, image.Pt(0, 0),
 			draw_ext.Filter_Average,
 		)
 	}
+	p.putTile(level-1, col/2, row/2, parent)
 	return
 }
@@ -0,0 +1,414 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// D8 flow directions (the ESRI codes), 0 is a sink or a flat.
+const (
+	FlowDir_E  = 1
+	FlowDir_SE = 2
+	FlowDir_S  = 4
+	FlowDir_SW = 8
+	FlowDir_W  = 16
+	FlowDir_NW = 32
+	FlowDir_N  = 64
+	FlowDir_NE = 128
+)
+
+var flowDirs = [8]struct {
+	code   uint8
+	dx, dy int
+}{
+	{FlowDir_E, 1, 0},
+	{FlowDir_SE, 1, 1},
+	{FlowDir_S, 0, 1},
+	{FlowDir_SW, -1, 1},
+	{FlowDir_W, -1, 0},
+	{FlowDir_NW, -1, -1},
+	{FlowDir_N, 0, -1},
+	{FlowDir_NE, 1, -1},
+}
+
+// HydroOptions are the parameters of the hydrological operators.
+type HydroOptions struct {
+	// Epsilon is the height added per cell along the filled areas, so
+	// that the flats drain to their outlets. 0 fills the depressions
+	// to flats, which have no D8 flow direction.
+	Epsilon float32
+
+	// The NoData pixels are outside of the terrain: the cells next to
+	// them are outlets.
+	NoData    float32
+	HasNoData bool
+
+	// NewStore returns the store of the result or of a scratch image of
+	// an operator, the name is one of "filled", "closed", "dir",
+	// "inflow", "acc" and "streams". The scratch images ("closed" and
+	// "inflow") are not used after the operator returns. The images are
+	// kept in memory if NewStore is nil, the Dems larger than the memory
+	// need the stores of files (like a FileTileStore per name).
+	NewStore func(name string) (TileStore, error)
+}
+
+func (p *HydroOptions) adjust() HydroOptions {
+	if p == nil {
+		return HydroOptions{}
+	}
+	return *p
+}
+
+func (p *HydroOptions) newStore(name string) (TileStore, error) {
+	if p.NewStore == nil {
+		return NewMemoryTileStore(), nil
+	}
+	return p.NewStore(name)
+}
+
+// newImage returns a GrayModel Image with the store of name.
+func (p *HydroOptions) newImage(name string, r image.Rectangle, tileSize image.Point) (m *Image, err error) {
+	store, err := p.newStore(name)
+	if err != nil {
+		return
+	}
+	m = NewImageWithStore(r, tileSize, color.GrayModel, store)
+	return
+}
+
+func (p *HydroOptions) isNoData(v float32) bool {
+	return p.HasNoData && v == p.NoData
+}
+
+// FillDepressions fills the depressions of the last level with the
+// priority-flood algorithm, and returns the filled Dem (the pyramid is
+// updated).
+//
+// The pixels are accessed tile by tile: the filled Dem and the closed
+// cells are kept in the stores of opt.NewStore, only the flood front (the
+// cells around the flooded area) is kept in memory.
+func (p *Dem) FillDepressions(opt *HydroOptions) (m *Dem, err error) {
+	o := opt.adjust()
+	store, err := o.newStore("filled")
+	if err != nil {
+		return
+	}
+	if m, err = p.copyLastLevel(store); err != nil {
+		return
+	}
+
+	r := m.Rect
+	closed, err := o.newImage("closed", r, m.TileSize)
+	if err != nil {
+		return
+	}
+
+	// the flood starts from the edges of the terrain
+	var front hydroCellHeap
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			z := m.Gray32fAt(x, y).Y
+			if o.isNoData(z) {
+				setPix(closed, x, y, 1)
+				continue
+			}
+			if m.isOutlet(&o, x, y) {
+				setPix(closed, x, y, 1)
+				front = append(front, hydroCell{x, y, z})
+			}
+		}
+	}
+	heap.Init(&front)
+
+	for front.Len() > 0 {
+		c := heap.Pop(&front).(hydroCell)
+		for _, d := range flowDirs {
+			x, y := c.x+d.dx, c.y+d.dy
+			if !(image.Point{x, y}.In(r)) || pixAt(closed, x, y) != 0 {
+				continue
+			}
+			setPix(closed, x, y, 1)
+			z := m.Gray32fAt(x, y).Y
+			if z <= c.z {
+				z = c.z + o.Epsilon
+				m.SetGray32f(x, y, color_ext.Gray32f{Y: z})
+			}
+			heap.Push(&front, hydroCell{x, y, z})
+		}
+	}
+	if err = closed.Err(); err != nil {
+		return
+	}
+
+	if err = m.updateRectPyramid(m.Levels()-1, r.Min.X, r.Min.Y, r.Dx(), r.Dy()); err != nil {
+		return
+	}
+	err = m.Err()
+	return
+}
+
+// FlowDirection computes the D8 flow directions of the last level: every
+// cell drains to the neighbour of the steepest descent. The outlet cells
+// without a lower neighbour drain out of the terrain, the other cells
+// without a lower neighbour (sinks and flats) and the NoData cells are 0.
+//
+// The returned Image has the GrayModel and the store "dir" of
+// opt.NewStore, its pyramid is not updated.
+func (p *Dem) FlowDirection(opt *HydroOptions) (m *Image, err error) {
+	o := opt.adjust()
+	if m, err = o.newImage("dir", p.Rect, p.TileSize); err != nil {
+		return
+	}
+
+	level := p.Levels() - 1
+	for col := 0; col < p.TilesAcross(level); col++ {
+		for row := 0; row < p.TilesDown(level); row++ {
+			r := image.Rect(
+				col*p.TileSize.X, row*p.TileSize.Y,
+				(col+1)*p.TileSize.X, (row+1)*p.TileSize.Y,
+			).Intersect(p.Rect)
+			if r.Empty() {
+				continue
+			}
+
+			// the tile with a one pixel halo
+			hr := r.Inset(-1).Intersect(p.Rect)
+			var halo *image_ext.Gray32f
+			if halo, err = p.ReadRect(-1, hr, nil); err != nil {
+				return
+			}
+			dst := m.GetTile(level, col, row).(*image.Gray)
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					z := halo.Gray32fAt(x-hr.Min.X, y-hr.Min.Y).Y
+					if o.isNoData(z) {
+						continue
+					}
+					var code, outCode uint8
+					var maxDrop float64
+					for _, d := range flowDirs {
+						xx, yy := x+d.dx, y+d.dy
+						if !(image.Point{xx, yy}.In(hr)) {
+							if outCode == 0 {
+								outCode = d.code
+							}
+							continue
+						}
+						zz := halo.Gray32fAt(xx-hr.Min.X, yy-hr.Min.Y).Y
+						if o.isNoData(zz) {
+							if outCode == 0 {
+								outCode = d.code
+							}
+							continue
+						}
+						drop := float64(z-zz) / math.Hypot(float64(d.dx), float64(d.dy))
+						if drop > maxDrop {
+							code, maxDrop = d.code, drop
+						}
+					}
+					if code == 0 {
+						code = outCode
+					}
+					dst.Pix[(y-r.Min.Y)*dst.Stride+(x-r.Min.X)] = code
+				}
+			}
+			if err = m.SetTile(level, col, row, dst); err != nil {
+				return
+			}
+		}
+	}
+	err = m.Err()
+	return
+}
+
+// FlowAccumulation computes the number of the upstream cells of every cell
+// from the D8 flow directions (the cell itself is not counted). The result
+// has the store "acc" of opt.NewStore.
+//
+// The cells are walked downstream from the cells without an inflow, and
+// the inflow counts are kept in the scratch Image "inflow", so no cell
+// list is kept in memory. The counts are exact up to 1<<24 cells.
+func FlowAccumulation(dir *Image, opt *HydroOptions) (acc *Dem, err error) {
+	o := opt.adjust()
+	if dir.Model != color.GrayModel {
+		err = fmt.Errorf("image/big: FlowAccumulation, bad color model: %T", dir.Model)
+		return
+	}
+	r := dir.Rect
+	store, err := o.newStore("acc")
+	if err != nil {
+		return
+	}
+	acc = NewDemWithStore(r, dir.TileSize, color_ext.Gray32f{}, store)
+
+	downstream := func(x, y int) (xx, yy int, ok bool) {
+		code := pixAt(dir, x, y)
+		for _, d := range flowDirs {
+			if d.code == code {
+				xx, yy = x+d.dx, y+d.dy
+				ok = image.Point{xx, yy}.In(r)
+				return
+			}
+		}
+		return
+	}
+
+	// the inflow counts, done cells are marked with 0xFF
+	const done = 0xFF
+	inflow, err := o.newImage("inflow", r, dir.TileSize)
+	if err != nil {
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if xx, yy, ok := downstream(x, y); ok {
+				setPix(inflow, xx, yy, pixAt(inflow, xx, yy)+1)
+			}
+		}
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if pixAt(inflow, x, y) != 0 {
+				continue
+			}
+			for cx, cy := x, y; ; {
+				setPix(inflow, cx, cy, done)
+				xx, yy, ok := downstream(cx, cy)
+				if !ok {
+					break
+				}
+				v := acc.Gray32fAt(xx, yy).Y + acc.Gray32fAt(cx, cy).Y + 1
+				acc.SetGray32f(xx, yy, color_ext.Gray32f{Y: v})
+
+				n := pixAt(inflow, xx, yy) - 1
+				setPix(inflow, xx, yy, n)
+				if n != 0 {
+					break
+				}
+				cx, cy = xx, yy
+			}
+		}
+	}
+
+	if err = inflow.Err(); err != nil {
+		return
+	}
+
+	if err = acc.updateRectPyramid(acc.Levels()-1, r.Min.X, r.Min.Y, r.Dx(), r.Dy()); err != nil {
+		return
+	}
+	err = acc.Err()
+	return
+}
+
+// ExtractStreams returns the stream cells, whose flow accumulation is not
+// less than threshold, as 1 in an Image with the GrayModel and the store
+// "streams" of opt.NewStore.
+func ExtractStreams(acc *Dem, threshold float32, opt *HydroOptions) (m *Image, err error) {
+	o := opt.adjust()
+	if m, err = o.newImage("streams", acc.Rect, acc.TileSize); err != nil {
+		return
+	}
+
+	level := acc.Levels() - 1
+	for col := 0; col < acc.TilesAcross(level); col++ {
+		for row := 0; row < acc.TilesDown(level); row++ {
+			src := acc.lookupTile(level, col, row)
+			if src == nil {
+				if acc.ZeroValue.Y < threshold {
+					continue
+				}
+				src = newDemTile(acc.TileSize, acc.ZeroValue)
+			}
+			dst := m.GetTile(level, col, row).(*image.Gray)
+			for y := 0; y < acc.TileSize.Y; y++ {
+				for x := 0; x < acc.TileSize.X; x++ {
+					if src.Gray32fAt(x, y).Y >= threshold {
+						dst.Pix[y*dst.Stride+x] = 1
+					}
+				}
+			}
+			if err = m.SetTile(level, col, row, dst); err != nil {
+				return
+			}
+		}
+	}
+	if err = acc.Err(); err != nil {
+		return
+	}
+	err = m.Err()
+	return
+}
+
+// copyLastLevel returns a Dem in store with a copy of the last level of p.
+func (p *Dem) copyLastLevel(store TileStore) (m *Dem, err error) {
+	m = NewDemWithStore(p.Rect, p.TileSize, p.ZeroValue, store)
+	level := p.Levels() - 1
+	for col := 0; col < p.TilesAcross(level); col++ {
+		for row := 0; row < p.TilesDown(level); row++ {
+			if src := p.lookupTile(level, col, row); src != nil {
+				tile := image_ext.NewGray32f(src.Bounds())
+				copy(tile.Pix, src.Pix)
+				if err = m.SetTile(level, col, row, tile); err != nil {
+					return
+				}
+			}
+		}
+	}
+	err = p.Err()
+	return
+}
+
+// isOutlet reports whether the cell (x, y) is on the edge of the terrain.
+func (p *Dem) isOutlet(opt *HydroOptions, x, y int) bool {
+	for _, d := range flowDirs {
+		xx, yy := x+d.dx, y+d.dy
+		if !(image.Point{xx, yy}.In(p.Rect)) || opt.isNoData(p.Gray32fAt(xx, yy).Y) {
+			return true
+		}
+	}
+	return false
+}
+
+// pixAt returns the pixel of a GrayModel Image, without storing the tile.
+func pixAt(m *Image, x, y int) uint8 {
+	tile := m.readTile(m.Levels()-1, x/m.TileSize.X, y/m.TileSize.Y).(*image.Gray)
+	return tile.Pix[(y%m.TileSize.Y)*tile.Stride+x%m.TileSize.X]
+}
+
+func setPix(m *Image, x, y int, v uint8) {
+	level, col, row := m.Levels()-1, x/m.TileSize.X, y/m.TileSize.Y
+	tile := m.GetTile(level, col, row).(*image.Gray)
+	tile.Pix[(y%m.TileSize.Y)*tile.Stride+x%m.TileSize.X] = v
+	m.putTile(level, col, row, tile)
+}
+
+type hydroCell struct {
+	x, y int
+	z    float32
+}
+
+// hydroCellHeap is a min-heap of the cells by height.
+type hydroCellHeap []hydroCell
+
+func (p hydroCellHeap) Len() int            { return len(p) }
+func (p hydroCellHeap) Less(i, j int) bool  { return p[i].z < p[j].z }
+func (p hydroCellHeap) Swap(i, j int)       { p[i], p[j] = p[j], p[i] }
+func (p *hydroCellHeap) Push(x interface{}) { *p = append(*p, x.(hydroCell)) }
+func (p *hydroCellHeap) Pop() interface{} {
+	old := *p
+	c := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return c
+}
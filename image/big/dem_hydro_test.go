@@ -0,0 +1,155 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func TestDem_Hydro(t *testing.T) {
+	// a valley draining to the south, with a pit at (2,2)
+	heights := [][]float32{
+		{9, 8, 7, 8, 9},
+		{9, 7, 6, 7, 9},
+		{9, 6, 3, 6, 9},
+		{9, 5, 4, 5, 9},
+		{9, 4, 3, 4, 9},
+		{9, 9, 2, 9, 9},
+	}
+	m := NewDem(image.Rect(0, 0, 5, 6), image.Pt(2, 2), color_ext.Gray32f{})
+	for y, row := range heights {
+		for x, z := range row {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: z})
+		}
+	}
+
+	filled, err := m.FillDepressions(&HydroOptions{Epsilon: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := filled.Gray32fAt(2, 2).Y; v <= 4 || v > 4.1 {
+		t.Fatalf("pit not filled: %v", v)
+	}
+	if v := filled.Gray32fAt(0, 0).Y; v != 9 {
+		t.Fatalf("edge changed: %v", v)
+	}
+
+	dir, err := filled.FlowDirection(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 5; y++ {
+		if v := pixAt(dir, 2, y); v != FlowDir_S {
+			t.Fatalf("(2,%d): expect flow to the south, got = %d", y, v)
+		}
+	}
+	if v := pixAt(dir, 2, 5); v == 0 {
+		t.Fatalf("(2,5): expect flow out of the terrain")
+	}
+
+	acc, err := FlowAccumulation(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// all the other cells drain through the outlet (2,5)
+	if v := acc.Gray32fAt(2, 5).Y; v != 29 {
+		t.Fatalf("outlet: expect = 29, got = %v", v)
+	}
+
+	streams, err := ExtractStreams(acc, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 5; x++ {
+			expect := acc.Gray32fAt(x, y).Y >= 5
+			if got := pixAt(streams, x, y) == 1; got != expect {
+				t.Fatalf("(%d,%d): expect stream = %v, got = %v", x, y, expect, got)
+			}
+		}
+	}
+}
+
+func TestDem_Hydro_fileStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := make(map[string]bool)
+	opt := &HydroOptions{
+		Epsilon: 0.01,
+		NewStore: func(name string) (TileStore, error) {
+			names[name] = true
+			return NewFileTileStore(filepath.Join(dir, name), false)
+		},
+	}
+
+	m := NewDem(image.Rect(0, 0, 9, 7), image.Pt(4, 4), color_ext.Gray32f{})
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 9; x++ {
+			// a bowl draining to the east, with a pit at (4,3)
+			z := float32((x-4)*(x-4)+(y-3)*(y-3)) - float32(x)/2
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: z})
+		}
+	}
+
+	filled, err := m.FillDepressions(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow, err := filled.FlowDirection(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, err := FlowAccumulation(flow, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams, err := ExtractStreams(acc, 3, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"filled", "closed", "dir", "inflow", "acc", "streams"} {
+		if !names[name] {
+			t.Fatalf("the store %q is not used", name)
+		}
+	}
+
+	// the same results in memory
+	filled0, _ := m.FillDepressions(&HydroOptions{Epsilon: 0.01})
+	flow0, _ := filled0.FlowDirection(nil)
+	acc0, _ := FlowAccumulation(flow0, nil)
+	streams0, _ := ExtractStreams(acc0, 3, nil)
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 9; x++ {
+			if a, b := filled.Gray32fAt(x, y), filled0.Gray32fAt(x, y); a != b {
+				t.Fatalf("(%d,%d): filled, expect = %v, got = %v", x, y, b, a)
+			}
+			if a, b := acc.Gray32fAt(x, y), acc0.Gray32fAt(x, y); a != b {
+				t.Fatalf("(%d,%d): acc, expect = %v, got = %v", x, y, b, a)
+			}
+			if a, b := pixAt(streams, x, y), pixAt(streams0, x, y); a != b {
+				t.Fatalf("(%d,%d): streams, expect = %v, got = %v", x, y, b, a)
+			}
+		}
+	}
+	var n int
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 9; x++ {
+			n += int(pixAt(streams, x, y))
+		}
+	}
+	if n == 0 {
+		t.Fatalf("no stream cell")
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// TerrainOp is a terrain operator of Dem.Terrain and Dem.TerrainGray,
+// computed from the 3x3 window of every pixel.
+type TerrainOp int
+
+const (
+	TerrainOp_Slope     TerrainOp = iota // degrees, 0-90
+	TerrainOp_Aspect                     // degrees clockwise from north, 0-360, -1 for flat
+	TerrainOp_Hillshade                  // 0-255
+	TerrainOp_Curvature                  // total curvature (1/100 z unit), positive is convex
+	TerrainOp_Roughness                  // max - min of the 3x3 window
+)
+
+// TerrainOptions are the parameters of the terrain operators.
+type TerrainOptions struct {
+	CellSizeX float64 // pixel size in the x direction, default 1
+	CellSizeY float64 // pixel size in the y direction, default 1
+	ZFactor   float64 // z unit / xy unit, default 1
+	Azimuth   float64 // light direction of Hillshade, degrees clockwise from north, default 315
+	Altitude  float64 // light altitude of Hillshade, degrees, default 45
+
+	// The NoData pixels produce NoData, and are replaced by the center
+	// pixel value when they are neighbours.
+	NoData    float32
+	HasNoData bool
+
+	// NewStore returns the store of the Dem of Terrain, the name is
+	// "terrain". The Dem is kept in memory if NewStore is nil.
+	NewStore func(name string) (TileStore, error)
+}
+
+func (p *TerrainOptions) adjust() TerrainOptions {
+	var opt TerrainOptions
+	if p != nil {
+		opt = *p
+	}
+	if opt.CellSizeX <= 0 {
+		opt.CellSizeX = 1
+	}
+	if opt.CellSizeY <= 0 {
+		opt.CellSizeY = 1
+	}
+	if opt.ZFactor == 0 {
+		opt.ZFactor = 1
+	}
+	if opt.Azimuth == 0 && opt.Altitude == 0 {
+		opt.Azimuth, opt.Altitude = 315, 45
+	}
+	return opt
+}
+
+// Terrain computes the terrain operator for the last level and returns
+// a Dem with the same bounds and tile size (the pyramid is updated), in
+// the store of opt.NewStore.
+func (p *Dem) Terrain(op TerrainOp, opt *TerrainOptions) (m *Dem, err error) {
+	o := opt.adjust()
+	zero := p.ZeroValue
+	if o.HasNoData {
+		zero.Y = o.NoData
+	}
+	store := TileStore(NewMemoryTileStore())
+	if o.NewStore != nil {
+		if store, err = o.NewStore("terrain"); err != nil {
+			return
+		}
+	}
+	m = NewDemWithStore(p.Rect, p.TileSize, zero, store)
+
+	level := p.Levels() - 1
+	for col := 0; col < p.TilesAcross(level); col++ {
+		for row := 0; row < p.TilesDown(level); row++ {
+			var tile *image_ext.Gray32f
+			if tile, err = p.terrainTile(op, &o, col, row); err != nil {
+				return
+			}
+			dst := m.GetTile(level, col, row)
+			b := tile.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					dst.SetGray32f(x-b.Min.X, y-b.Min.Y, tile.Gray32fAt(x, y))
+				}
+			}
+			if err = m.SetTile(level, col, row, dst); err != nil {
+				return
+			}
+		}
+	}
+	err = m.updateRectPyramid(level, p.Rect.Min.X, p.Rect.Min.Y, p.Rect.Dx(), p.Rect.Dy())
+	return
+}
+
+// TerrainGray computes the terrain operator for the last level as an
+// 8-bit image (for the map previews). The values are scaled to 0-255:
+// Slope by 255/90, Aspect by 255/360 (flat is 0), Curvature is offset by
+// 128, Hillshade and Roughness are clamped. The NoData pixels are 0.
+func (p *Dem) TerrainGray(op TerrainOp, opt *TerrainOptions) (m *image.Gray, err error) {
+	o := opt.adjust()
+	m = image.NewGray(p.Rect)
+
+	level := p.Levels() - 1
+	for col := 0; col < p.TilesAcross(level); col++ {
+		for row := 0; row < p.TilesDown(level); row++ {
+			var tile *image_ext.Gray32f
+			if tile, err = p.terrainTile(op, &o, col, row); err != nil {
+				return
+			}
+			b := tile.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					v := float64(tile.Gray32fAt(x, y).Y)
+					if o.HasNoData && float32(v) == o.NoData {
+						continue
+					}
+					switch op {
+					case TerrainOp_Slope:
+						v = v * 255 / 90
+					case TerrainOp_Aspect:
+						v = math.Max(v, 0) * 255 / 360
+					case TerrainOp_Curvature:
+						v = v + 128
+					}
+					m.Pix[m.PixOffset(x, y)] = uint8(math.Max(0, math.Min(255, v+0.5)))
+				}
+			}
+		}
+	}
+	return
+}
+
+// terrainTile computes the operator for the pixels of the tile (col, row)
+// of the last level, the returned image has the bounds of the tile
+// (clipped by the Dem bounds). The pixels out of the Dem are replicated
+// from the edge pixels.
+func (p *Dem) terrainTile(op TerrainOp, opt *TerrainOptions, col, row int) (m *image_ext.Gray32f, err error) {
+	r := image.Rect(
+		col*p.TileSize.X, row*p.TileSize.Y,
+		(col+1)*p.TileSize.X, (row+1)*p.TileSize.Y,
+	).Intersect(p.Rect)
+	if r.Empty() {
+		err = fmt.Errorf("image/big: Dem.terrainTile, bad tile: col = %d, row = %d", col, row)
+		return
+	}
+
+	// the tile with a one pixel halo
+	hr := r.Inset(-1).Intersect(p.Rect)
+	halo, err := p.ReadRect(-1, hr, nil)
+	if err != nil {
+		return
+	}
+	at := func(x, y int) float64 {
+		x = minInt(maxInt(x, hr.Min.X), hr.Max.X-1)
+		y = minInt(maxInt(y, hr.Min.Y), hr.Max.Y-1)
+		return float64(halo.Gray32fAt(x-hr.Min.X, y-hr.Min.Y).Y)
+	}
+
+	m = image_ext.NewGray32f(r)
+	var w [9]float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for i := 0; i < 9; i++ {
+				w[i] = at(x+i%3-1, y+i/3-1)
+			}
+			if opt.HasNoData {
+				if float32(w[4]) == opt.NoData {
+					m.SetGray32f(x, y, color_ext.Gray32f{Y: opt.NoData})
+					continue
+				}
+				for i := 0; i < 9; i++ {
+					if float32(w[i]) == opt.NoData {
+						w[i] = w[4]
+					}
+				}
+			}
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(terrainValue(op, opt, &w))})
+		}
+	}
+	return
+}
+
+// terrainValue computes the operator for the 3x3 window:
+//
+//	w[0] w[1] w[2]
+//	w[3] w[4] w[5]
+//	w[6] w[7] w[8]
+//
+// The first row is the north.
+func terrainValue(op TerrainOp, opt *TerrainOptions, w *[9]float64) float64 {
+	if op == TerrainOp_Roughness {
+		min, max := w[0], w[0]
+		for _, v := range w[1:] {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		return max - min
+	}
+	if op == TerrainOp_Curvature {
+		d := ((w[3]+w[5])/2 - w[4]) / (opt.CellSizeX * opt.CellSizeX)
+		e := ((w[1]+w[7])/2 - w[4]) / (opt.CellSizeY * opt.CellSizeY)
+		return -2 * (d + e) * opt.ZFactor * 100
+	}
+
+	// Horn's gradient, dzdx to the east, dzdy to the north
+	dzdx := ((w[2] + 2*w[5] + w[8]) - (w[0] + 2*w[3] + w[6])) / (8 * opt.CellSizeX) * opt.ZFactor
+	dzdy := ((w[0] + 2*w[1] + w[2]) - (w[6] + 2*w[7] + w[8])) / (8 * opt.CellSizeY) * opt.ZFactor
+
+	switch op {
+	case TerrainOp_Slope:
+		return math.Atan(math.Hypot(dzdx, dzdy)) * 180 / math.Pi
+	case TerrainOp_Aspect:
+		if dzdx == 0 && dzdy == 0 {
+			return -1
+		}
+		// the downslope direction
+		aspect := math.Atan2(-dzdx, -dzdy) * 180 / math.Pi
+		if aspect < 0 {
+			aspect += 360
+		}
+		return aspect
+	case TerrainOp_Hillshade:
+		az := opt.Azimuth * math.Pi / 180
+		alt := opt.Altitude * math.Pi / 180
+		// the dot product of the surface normal and the light direction
+		v := (math.Sin(alt) - math.Cos(alt)*(dzdx*math.Sin(az)+dzdy*math.Cos(az))) /
+			math.Sqrt(1+dzdx*dzdx+dzdy*dzdy)
+		return math.Max(v, 0) * 255
+	}
+	return 0
+}
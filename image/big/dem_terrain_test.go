@@ -0,0 +1,130 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// tPlaneDem returns a Dem of the plane z = ax*x + ay*y (y to the south).
+func tPlaneDem(r image.Rectangle, tileSize image.Point, ax, ay float32) *Dem {
+	m := NewDem(r, tileSize, color_ext.Gray32f{})
+	src := image_ext.NewGray32f(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			src.SetGray32f(x, y, color_ext.Gray32f{Y: ax*float32(x) + ay*float32(y)})
+		}
+	}
+	if err := m.WriteRect(-1, r, src); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestDem_Terrain(t *testing.T) {
+	// rises 1 per pixel to the east: slope 45, facing west
+	m := tPlaneDem(image.Rect(0, 0, 10, 7), image.Pt(4, 4), 1, 0)
+
+	tests := []struct {
+		op     TerrainOp
+		expect float32
+	}{
+		{TerrainOp_Slope, 45},
+		{TerrainOp_Aspect, 270},
+		{TerrainOp_Curvature, 0},
+		{TerrainOp_Roughness, 2},
+	}
+	for _, tt := range tests {
+		d, err := m.Terrain(tt.op, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Bounds() != m.Bounds() {
+			t.Fatalf("op %d: bad bounds: %v", tt.op, d.Bounds())
+		}
+		// the pixels next to the tile borders need the halos
+		for y := 1; y < 6; y++ {
+			for x := 1; x < 9; x++ {
+				if v := d.Gray32fAt(x, y).Y; math.Abs(float64(v-tt.expect)) > 1e-4 {
+					t.Fatalf("op %d: (%d,%d): expect = %v, got = %v", tt.op, x, y, tt.expect, v)
+				}
+			}
+		}
+	}
+
+	// the light from the west lights the slope facing west
+	shade, err := m.TerrainGray(TerrainOp_Hillshade, &TerrainOptions{Azimuth: 270, Altitude: 45})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := shade.GrayAt(4, 3).Y; v != 255 {
+		t.Fatalf("hillshade: expect = 255, got = %v", v)
+	}
+	shade, err = m.TerrainGray(TerrainOp_Hillshade, &TerrainOptions{Azimuth: 90, Altitude: 45})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := shade.GrayAt(4, 3).Y; v != 0 {
+		t.Fatalf("hillshade: expect = 0, got = %v", v)
+	}
+}
+
+func TestDem_Terrain_noData(t *testing.T) {
+	m := tPlaneDem(image.Rect(0, 0, 8, 8), image.Pt(4, 4), 0, 0)
+	m.SetGray32f(3, 3, color_ext.Gray32f{Y: -9999})
+
+	opt := &TerrainOptions{NoData: -9999, HasNoData: true}
+	d, err := m.Terrain(TerrainOp_Slope, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Gray32fAt(3, 3).Y; v != -9999 {
+		t.Fatalf("expect no data, got = %v", v)
+	}
+	if v := d.Gray32fAt(4, 4).Y; v != 0 {
+		t.Fatalf("expect = 0, got = %v", v)
+	}
+}
+
+func TestDem_Terrain_store(t *testing.T) {
+	dir, err := ioutil.TempDir("", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var names []string
+	opt := &TerrainOptions{
+		NewStore: func(name string) (TileStore, error) {
+			names = append(names, name)
+			return NewFileTileStore(filepath.Join(dir, name), false)
+		},
+	}
+	m := tPlaneDem(image.Rect(0, 0, 8, 8), image.Pt(4, 4), 1, 0)
+	d, err := m.Terrain(TerrainOp_Slope, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "terrain" {
+		t.Fatalf("bad stores: %v", names)
+	}
+	if v := d.Gray32fAt(4, 4).Y; math.Abs(float64(v)-45) > 1e-4 {
+		t.Fatalf("expect = 45, got = %v", v)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "terrain", "*")); len(files) == 0 {
+		t.Fatalf("no tile files")
+	}
+}
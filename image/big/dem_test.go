@@ -5,9 +5,53 @@
 package big
 
 import (
+	"image"
 	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
 )
 
 func TestDem(t *testing.T) {
-	//
+	m := NewDem(image.Rect(0, 0, 8, 16), image.Pt(4, 4), color_ext.Gray32f{})
+	if n := m.Levels(); n != 3 {
+		t.Fatalf("bad levels: %d", n)
+	}
+
+	src := image_ext.NewGray32f(image.Rect(0, 0, 8, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 8; x++ {
+			src.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*8 + x)})
+		}
+	}
+	if err := m.WriteRect(-1, m.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := m.ReadRect(-1, image.Rect(3, 5, 7, 12), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 5; y < 12; y++ {
+		for x := 3; x < 7; x++ {
+			if v := dst.Gray32fAt(x-3, y-5).Y; v != float32(y*8+x) {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, y*8+x, v)
+			}
+		}
+	}
+
+	// every quarter of the top tile is updated
+	top := m.GetTile(0, 0, 0)
+	for _, pt := range []image.Point{{0, 0}, {1, 0}, {0, 3}, {1, 3}} {
+		if v := top.Gray32fAt(pt.X, pt.Y).Y; v == 0 {
+			t.Fatalf("top tile (%d,%d) not updated", pt.X, pt.Y)
+		}
+	}
+
+	// reading a stored tile does not change the version of the tiles
+	ver := m.ver.Get()
+	m.GetTile(0, 0, 0)
+	if v := m.ver.Get(); v != ver {
+		t.Fatalf("expect version = %d, got = %d", ver, v)
+	}
 }
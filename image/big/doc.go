@@ -4,5 +4,57 @@
 
 /*
 Package big implements big pyramid image support.
+
+The tiles are kept in a TileStore, in memory by default. The images
+larger than the memory keep their tiles in files, behind a LRU cache:
+
+	store, err := big.NewFileTileStore("mosaic.tiles", true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := big.NewImageWithStore(image.Rect(0, 0, 100000, 100000), image.Pt(256, 256),
+		color.RGBAModel, big.NewLRUTileCache(store, 1024),
+	)
+	for _, part := range parts {
+		if err := m.WriteRect(-1, part.Bounds(), part); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := m.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+The stores which keep the tiles out of memory are always used behind a
+LRUTileCache, so Set and SetGray32f only modify the cached tile, which is
+written back when it is evicted or when the image is flushed.
+
+The tiles are read and written with GetTile, SetTile, ReadRect and
+WriteRect. The tile returned by GetTile may be evicted from the cache at
+any time, so the changes made to it in place must be stored again with
+SetTile:
+
+	tile := m.GetTile(level, col, row)
+	tile.SetGray32f(x, y, c)
+	err := m.SetTile(level, col, row, tile)
+
+WriteRect updates the lower levels of the pyramid with the Filter of the
+image. If DeferPyramid is set, the lower levels are only updated by
+BuildPyramid, which rebuilds the parents of the modified tiles once:
+
+	m.Filter, m.DeferPyramid = draw.Filter_Gaussian, true
+	for _, part := range parts {
+		if err := m.WriteRect(-1, part.Bounds(), part); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := m.BuildPyramid(); err != nil {
+		log.Fatal(err)
+	}
+
+The tiles are served over HTTP as the XYZ tiles by a TileHandler:
+
+	http.Handle("/tiles/", big.NewImageTileHandler(m))
+
+The package image/big/leveldb provides a TileStore in a LevelDB database.
 */
 package big
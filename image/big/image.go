@@ -19,11 +19,30 @@ type Image struct {
 	TileSize image.Point
 	Model    color.Model // Gray/Gray16/Gray32f/RGBA/RGBA64/RGBA128f
 	Rect     image.Rectangle
-	tileMap  [][][]draw.Image // m.tileMap[level][col][row]
-	mu       sync.Mutex
+
+	// Filter is the resampling filter of the lower levels.
+	Filter draw_ext.Filter
+
+	// DeferPyramid makes WriteRect leave the lower levels to BuildPyramid.
+	DeferPyramid bool
+
+	store  TileStore
+	levels []image.Point // tiles across and down of the levels
+	mu     *sync.Mutex
+	err    *firstError // the first store error of GetTile and Set
+	dirty  *tileSet    // the modified tiles, whose parents are not updated
+	ver    *tileVersion
 }
 
 func NewImage(r image.Rectangle, tileSize image.Point, model color.Model) *Image {
+	return NewImageWithStore(r, tileSize, model, NewMemoryTileStore())
+}
+
+// NewImageWithStore returns an Image whose tiles are kept in store.
+// The tiles already in store are used. A store other than a MemoryTileStore
+// or a LRUTileCache is used behind a LRUTileCache of DefaultTileCacheSize
+// tiles, and the modified tiles are written to it on eviction or Flush.
+func NewImageWithStore(r image.Rectangle, tileSize image.Point, model color.Model, store TileStore) *Image {
 	if r.Empty() || tileSize.X <= 0 || tileSize.Y <= 0 {
 		panic(fmt.Errorf("image/big: NewImage, bad arguments: r = %v, tileSize = %v", r, tileSize))
 	}
@@ -31,10 +50,15 @@ func NewImage(r image.Rectangle, tileSize image.Point, model color.Model) *Image
 		panic(fmt.Errorf("image/big: NewImage, bad color model: %T", model))
 	}
 	return &Image{
-		tileMap:  makeImageTileMap(r, tileSize),
 		TileSize: tileSize,
-		Rect:     r,
 		Model:    model,
+		Rect:     r,
+		store:    cachedTileStore(store),
+		levels:   makeTileLevels(r, tileSize),
+		mu:       new(sync.Mutex),
+		err:      new(firstError),
+		dirty:    newTileSet(),
+		ver:      new(tileVersion),
 	}
 }
 
@@ -47,11 +71,17 @@ func (p *Image) SubLevels(levels int) *Image {
 		r.Max.Y /= 2
 	}
 	return &Image{
-		tileMap:  p.tileMap[:levels],
-		TileSize: p.TileSize,
-		Rect:     r,
-		Model:    p.Model,
-		mu:       p.mu,
+		TileSize:     p.TileSize,
+		Model:        p.Model,
+		Rect:         r,
+		Filter:       p.Filter,
+		DeferPyramid: p.DeferPyramid,
+		store:        p.store,
+		levels:       p.levels[:levels],
+		mu:           p.mu,
+		err:          p.err,
+		dirty:        p.dirty,
+		ver:          p.ver,
 	}
 }
 
@@ -63,7 +93,7 @@ func (p *Image) At(x, y int) color.Color {
 	if !(image.Point{x, y}.In(p.Rect)) {
 		return color.Gray{}
 	}
-	m := p.GetTile(p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y)
+	m := p.readTile(p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y)
 	c := m.At(x%p.TileSize.X, y%p.TileSize.Y)
 	return c
 }
@@ -72,13 +102,16 @@ func (p *Image) Set(x, y int, c color.Color) {
 	if !(image.Point{x, y}.In(p.Rect)) {
 		return
 	}
-	m := p.GetTile(p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y)
+	level, col, row := p.Levels()-1, x/p.TileSize.X, y/p.TileSize.Y
+	m := p.GetTile(level, col, row)
 	m.Set(x%p.TileSize.X, y%p.TileSize.Y, c)
+	p.putTile(level, col, row, m)
+	p.dirty.Add(level, col, row)
 	return
 }
 
 func (p *Image) Levels() int {
-	return len(p.tileMap)
+	return len(p.levels)
 }
 
 func (p *Image) adjustLevel(level int) int {
@@ -90,31 +123,36 @@ func (p *Image) adjustLevel(level int) int {
 
 func (p *Image) TilesAcross(level int) int {
 	level = p.adjustLevel(level)
-	v := len(p.tileMap[level])
+	v := p.levels[level].X
 	return v
 }
 
 func (p *Image) TilesDown(level int) int {
 	level = p.adjustLevel(level)
-	v := len(p.tileMap[level][0])
+	v := p.levels[level].Y
 	return v
 }
 
+// GetTile returns the tile, a new tile is stored if needed.
+// The tile may be evicted from the cache of the store at any time, so the
+// changes made to it in place must be stored again with SetTile.
 func (p *Image) GetTile(level, col, row int) (m draw.Image) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	level = p.adjustLevel(level)
-	if m = p.tileMap[level][col][row]; m != nil {
-		return
+	if m = p.lookupTile(level, col, row); m == nil {
+		m = newImageTile(p.TileSize, p.Model)
+		p.putTile(level, col, row, m)
 	}
-	m = newImageTile(p.TileSize, p.Model)
-	p.tileMap[level][col][row] = m
 	return
 }
 
+// LookupTile returns the stored tile, or nil if the tile is not stored.
+func (p *Image) LookupTile(level, col, row int) draw.Image {
+	return p.lookupTile(p.adjustLevel(level), col, row)
+}
+
 func (p *Image) SetTile(level, col, row int, m draw.Image) (err error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
 	level = p.adjustLevel(level)
 	if m.Bounds() != image.Rect(0, 0, p.TileSize.X, p.TileSize.Y) {
 		err = fmt.Errorf("image/big: Image.SetTile, bad bound size: %v", m.Bounds())
@@ -124,10 +162,40 @@ func (p *Image) SetTile(level, col, row int, m draw.Image) (err error) {
 		err = fmt.Errorf("image/big: Image.SetTile, bad color model: %T", m.ColorModel())
 		return
 	}
-	p.tileMap[level][col][row] = m
+	if err = p.store.Put(level, col, row, m); err != nil {
+		return
+	}
+	p.ver.Add()
+	p.dirty.Add(level, col, row)
 	return
 }
 
+// Flush writes the buffered tiles of the store.
+// It returns the first store error of GetTile and Set if there is one.
+func (p *Image) Flush() (err error) {
+	if err = p.Err(); err != nil {
+		return
+	}
+	return p.store.Flush()
+}
+
+// Err returns the first store error of GetTile and Set.
+func (p *Image) Err() error {
+	return p.err.Get()
+}
+
+// lookupTile returns the stored tile or nil.
+func (p *Image) lookupTile(level, col, row int) draw.Image {
+	m, err := p.store.Get(level, col, row)
+	p.err.Set(err)
+	return m
+}
+
+func (p *Image) putTile(level, col, row int, m draw.Image) {
+	p.err.Set(p.store.Put(level, col, row, m))
+	p.ver.Add()
+}
+
 func (p *Image) ReadRect(level int, r image.Rectangle, buf image_ext.ImageBuffer) (m image.Image, err error) {
 	level = p.adjustLevel(level)
 	if level < 0 || level >= p.Levels() {
@@ -156,16 +224,26 @@ func (p *Image) ReadRect(level int, r image.Rectangle, buf image_ext.ImageBuffer
 		for row := tMinY; row < tMaxY; row++ {
 			wg.Add(1)
 			go func(level, col, row int) {
-				p.readRectFromTile(buf, p.GetTile(level, col, row), r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+				p.readRectFromTile(buf, p.readTile(level, col, row), r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
 				wg.Done()
 			}(level, col, row)
 		}
 	}
 	wg.Wait()
 	m = buf.SubImage(r)
+	err = p.Err()
 	return
 }
 
+// readTile returns the tile, or a blank tile (which is not stored) if
+// the tile is not stored.
+func (p *Image) readTile(level, col, row int) draw.Image {
+	if m := p.lookupTile(level, col, row); m != nil {
+		return m
+	}
+	return newImageTile(p.TileSize, p.Model)
+}
+
 func (p *Image) readRectFromTile(dst, tile draw.Image, x, y, dx, dy, col, row int) {
 	bMinX := x
 	bMinY := y
@@ -229,14 +307,22 @@ func (p *Image) WriteRect(level int, r image.Rectangle, m image.Image) (err erro
 		for row := tMinY; row < tMaxY; row++ {
 			wg.Add(1)
 			go func(level, col, row int) {
-				p.writeRectToTile(p.GetTile(level, col, row), m, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+				tile := p.GetTile(level, col, row)
+				p.writeRectToTile(tile, m, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), col, row)
+				p.putTile(level, col, row, tile)
+				if p.DeferPyramid {
+					p.dirty.Add(level, col, row)
+				}
 				wg.Done()
 			}(level, col, row)
 		}
 	}
 	wg.Wait()
 
-	p.updateRectPyramid(level, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	if !p.DeferPyramid {
+		p.updateRectPyramid(level, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	}
+	err = p.Err()
 	return
 }
 
@@ -274,95 +360,3 @@ func (p *Image) writeRectToTile(tile draw.Image, src image.Image, x, y, dx, dy,
 	)
 	return
 }
-
-func (p *Image) updateRectPyramid(level, x, y, dx, dy int) {
-	for level > 0 && dx > 0 && dy > 0 {
-		minX, minY := x, y
-		maxX, maxY := x+dx-1, y+dy-1
-
-		tMinCol := minX / p.TileSize.X
-		tMinRow := minY / p.TileSize.Y
-		tMaxCol := maxX / p.TileSize.X
-		tMaxRow := maxY / p.TileSize.Y
-
-		var wg sync.WaitGroup
-		for row := tMinRow; row <= tMaxRow; row++ {
-			if row >= p.TilesDown(level) {
-				continue
-			}
-			for col := tMinCol; col <= tMaxCol; col++ {
-				if col >= p.TilesAcross(level) {
-					continue
-				}
-				wg.Add(1)
-				go func(level, col, row int) {
-					p.updateParentTile(level, col, row)
-					wg.Done()
-				}(level, col, row)
-			}
-		}
-		wg.Wait()
-
-		x, dx = (minX+1)/2, (maxX-minX+1)/2
-		y, dy = (minY+1)/2, (maxY-minY+1)/2
-		level--
-	}
-	return
-}
-
-func (p *Image) updateParentTile(level, col, row int) {
-	switch {
-	case col%2 == 0 && row%2 == 0:
-		draw_ext.DrawPyrDown(
-			p.GetTile(level-1, col/2, row/2),
-			image.Rect(
-				(p.TileSize.X/2)*0,
-				(p.TileSize.Y/2)*0,
-				(p.TileSize.X/2)*0+p.TileSize.X/2,
-				(p.TileSize.Y/2)*0+p.TileSize.Y/2,
-			),
-			p.GetTile(level, col, row),
-			image.Pt(0, 0),
-			draw_ext.Filter_Average,
-		)
-	case col%2 == 0 && row%2 == 1:
-		draw_ext.DrawPyrDown(
-			p.GetTile(level-1, col/2, row/2),
-			image.Rect(
-				(p.TileSize.X/2)*0,
-				(p.TileSize.Y/2)*1,
-				(p.TileSize.X/2)*0+p.TileSize.X/2,
-				(p.TileSize.Y/2)*1+p.TileSize.Y/2,
-			),
-			p.GetTile(level, col, row),
-			image.Pt(0, 0),
-			draw_ext.Filter_Average,
-		)
-	case col%2 == 1 && row%2 == 1:
-		draw_ext.DrawPyrDown(
-			p.GetTile(level-1, col/2, row/2),
-			image.Rect(
-				(p.TileSize.X/2)*1,
-				(p.TileSize.Y/2)*1,
-				(p.TileSize.X/2)*1+p.TileSize.X/2,
-				(p.TileSize.Y/2)*1+p.TileSize.Y/2,
-			),
-			p.GetTile(level, col, row),
-			image.Pt(0, 0),
-			draw_ext.Filter_Average,
-		)
-	case col%2 == 1 && row%2 == 0:
-		draw_ext.DrawPyrDown(
-			p.GetTile(level-1, col/2, row/2),
-			image.Rect(
-				(p.TileSize.X/2)*1,
-				(p.TileSize.Y/2)*0,
-				(p.TileSize.X/2)*1+p.TileSize.X/2,
-				(p.TileSize.Y/2)*0+p.TileSize.Y/2,
-			),
-			p.GetTile(level, col, row),
-			image.Pt(0, 0),
-			draw_ext.Filter_Average,
-		)
-	}
-}
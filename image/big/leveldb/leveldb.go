@@ -0,0 +1,203 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package leveldb provides a big.TileStore in a LevelDB database.
+
+All the levels of a pyramid are kept in one database directory, so a big
+Image (or Dem) written once can be opened again later without rebuilding:
+
+	store, err := leveldb.Open("/path/to/db", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	m := big.NewImageWithStore(rect, tileSize, color.RGBAModel, big.NewLRUTileCache(store, 64))
+	...
+	if err := m.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+The tiles are encoded with a format registered with image_ext.RegisterFormat
+(rawp with snappy by default). The png and rawp formats are imported by this
+package, other formats (like webp) must be imported by the program:
+
+	import _ "github.com/chai2010/gopkg/image/webp"
+
+	store, err := leveldb.Open("/path/to/db", &leveldb.Options{
+		Codec:      "webp",
+		ColorModel: color.RGBAModel,
+	})
+*/
+package leveldb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	db "github.com/chai2010/gopkg/database/leveldb"
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+	_ "github.com/chai2010/gopkg/image/png"
+	"github.com/chai2010/gopkg/image/rawp"
+)
+
+// Options are the parameters of a TileStore.
+type Options struct {
+	// Codec is the name of the tile format, "rawp" if empty.
+	Codec string
+
+	// CodecOptions are passed to the encoder of the Codec. If nil, the
+	// rawp tiles are compressed with snappy.
+	CodecOptions interface{}
+
+	// ColorModel is the color model of the tiles: the decoded tiles are
+	// converted to it if they have an other model (like the NRGBA tiles
+	// of the png format). If nil, the tiles are not converted.
+	ColorModel color.Model
+
+	// CacheSize is the size of the LevelDB block cache in bytes, 8MB if 0.
+	CacheSize int
+}
+
+func (p *Options) adjust() Options {
+	var o Options
+	if p != nil {
+		o = *p
+	}
+	if o.Codec == "" {
+		o.Codec = "rawp"
+	}
+	if o.Codec == "rawp" && o.CodecOptions == nil {
+		o.CodecOptions = &rawp.Options{UseSnappy: true}
+	}
+	if o.CacheSize <= 0 {
+		o.CacheSize = 8 << 20
+	}
+	return o
+}
+
+// TileStore keeps the encoded tiles in a LevelDB database, the key of a
+// tile is its level, col and row as big-endian uint32.
+//
+// The tiles are written when they are stored, so it is usually used
+// behind a big.LRUTileCache.
+type TileStore struct {
+	opt Options
+
+	mu    sync.RWMutex // Close locks, the tile accesses read lock
+	db    *db.DB
+	cache *db.Cache
+	ro    *db.ReadOptions
+	wo    *db.WriteOptions
+}
+
+// Open opens the store in the database directory dir, which is created
+// if needed. The tiles already in the database are used.
+func Open(dir string, opt *Options) (p *TileStore, err error) {
+	o := opt.adjust()
+
+	dbOpt := db.NewOptions()
+	defer dbOpt.Close()
+	cache := db.NewLRUCache(o.CacheSize)
+	dbOpt.SetCache(cache)
+	dbOpt.SetCreateIfMissing(true)
+
+	d, err := db.Open(dir, dbOpt)
+	if err != nil {
+		cache.Close()
+		return
+	}
+	p = &TileStore{
+		opt:   o,
+		db:    d,
+		cache: cache,
+		ro:    db.NewReadOptions(),
+		wo:    db.NewWriteOptions(),
+	}
+	return
+}
+
+// Codec returns the name of the tile format.
+func (p *TileStore) Codec() string {
+	return p.opt.Codec
+}
+
+func (p *TileStore) Get(level, col, row int) (m draw.Image, err error) {
+	data, err := p.get(tileKey(level, col, row))
+	if err != nil {
+		if err == db.ErrNotFound {
+			err = nil
+		}
+		return
+	}
+
+	tile, _, err := image_ext.Decode(bytes.NewReader(data), nil)
+	if err != nil {
+		return
+	}
+	if p.opt.ColorModel != nil && tile.ColorModel() != p.opt.ColorModel {
+		tile = convert.ColorModel(tile, p.opt.ColorModel)
+	}
+	m, ok := tile.(draw.Image)
+	if !ok {
+		err = fmt.Errorf("image/big/leveldb: TileStore.Get, bad tile type: %T", tile)
+	}
+	return
+}
+
+func (p *TileStore) Put(level, col, row int, m draw.Image) (err error) {
+	var buf bytes.Buffer
+	if err = image_ext.Encode(p.opt.Codec, &buf, m, p.opt.CodecOptions); err != nil {
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.db == nil {
+		return fmt.Errorf("image/big/leveldb: TileStore.Put, store is closed")
+	}
+	return p.db.Put(p.wo, tileKey(level, col, row), buf.Bytes())
+}
+
+func (p *TileStore) Flush() error {
+	return nil
+}
+
+// Close closes the database. The store can not be used after Close.
+func (p *TileStore) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.db == nil {
+		return nil
+	}
+	p.db.Close()
+	p.cache.Close()
+	p.ro.Close()
+	p.wo.Close()
+	p.db, p.cache, p.ro, p.wo = nil, nil, nil, nil
+	return nil
+}
+
+func (p *TileStore) get(key []byte) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.db == nil {
+		return nil, fmt.Errorf("image/big/leveldb: TileStore.Get, store is closed")
+	}
+	return p.db.Get(p.ro, key)
+}
+
+func tileKey(level, col, row int) []byte {
+	var key [12]byte
+	binary.BigEndian.PutUint32(key[0:], uint32(level))
+	binary.BigEndian.PutUint32(key[4:], uint32(col))
+	binary.BigEndian.PutUint32(key[8:], uint32(row))
+	return key[:]
+}
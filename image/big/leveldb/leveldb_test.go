@@ -0,0 +1,83 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package leveldb
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/chai2010/gopkg/image/big"
+)
+
+func tTestReopen(t *testing.T, opt *Options) {
+	dir, err := ioutil.TempDir("", "leveldb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, tileSize := image.Rect(0, 0, 16, 16), image.Pt(4, 4)
+	src := image.NewRGBA(r)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 0, 255})
+		}
+	}
+
+	store, err := Open(dir, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := big.NewImageWithStore(r, tileSize, color.RGBAModel, big.NewLRUTileCache(store, 2))
+	if err := m.WriteRect(-1, r, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pyramid is reopened without rebuilding
+	if store, err = Open(dir, opt); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if tile, err := store.Get(0, 1, 0); tile != nil || err != nil {
+		t.Fatalf("expect no tile, got = %v, %v", tile, err)
+	}
+	m = big.NewImageWithStore(r, tileSize, color.RGBAModel, store)
+	dst, err := m.ReadRect(-1, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if a, b := src.At(x, y), dst.At(x, y); a != b {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, a, b)
+			}
+		}
+	}
+	top := m.GetTile(0, 0, 0)
+	if _, ok := top.(*image.RGBA); !ok {
+		t.Fatalf("bad tile type: %T", top)
+	}
+	if _, _, _, a := top.At(3, 3).RGBA(); a == 0 {
+		t.Fatalf("top tile not stored")
+	}
+}
+
+func TestTileStore_rawp(t *testing.T) {
+	tTestReopen(t, nil)
+}
+
+func TestTileStore_png(t *testing.T) {
+	tTestReopen(t, &Options{Codec: "png", ColorModel: color.RGBAModel})
+}
@@ -0,0 +1,185 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"image"
+	"runtime"
+	"sync"
+
+	draw_ext "github.com/chai2010/gopkg/image/draw"
+)
+
+// BuildPyramid updates the lower levels from the tiles modified since the
+// last BuildPyramid (by Set, SetTile, and WriteRect if DeferPyramid is
+// set), with the Filter of p. Only the parent tiles of the modified tiles
+// are rebuilt, in parallel.
+func (p *Image) BuildPyramid() error {
+	for level := p.Levels() - 1; level > 0; level-- {
+		parents := make(map[tileKey]bool)
+		for _, k := range p.dirty.Take(level) {
+			r := image.Rect(
+				k.col*p.TileSize.X, k.row*p.TileSize.Y,
+				(k.col+1)*p.TileSize.X, (k.row+1)*p.TileSize.Y,
+			).Intersect(p.levelRect(level))
+			p.forEachTile(level-1, p.parentRect(level, r), func(col, row int) {
+				parents[tileKey{level - 1, col, row}] = true
+			})
+		}
+		keys := make([]tileKey, 0, len(parents))
+		for k := range parents {
+			keys = append(keys, k)
+			p.dirty.Add(k.level, k.col, k.row)
+		}
+		p.buildParentTiles(level, keys)
+	}
+	p.dirty.Take(0)
+	return p.Err()
+}
+
+// RebuildPyramid rebuilds the lower levels from the stored tiles of the
+// last level, with the Filter of p. The parents of the tiles which are
+// not stored are not built, so a sparse pyramid stays sparse.
+func (p *Image) RebuildPyramid() error {
+	level := p.Levels() - 1
+	for col := 0; col < p.TilesAcross(level); col++ {
+		for row := 0; row < p.TilesDown(level); row++ {
+			if p.lookupTile(level, col, row) != nil {
+				p.dirty.Add(level, col, row)
+			}
+		}
+	}
+	return p.BuildPyramid()
+}
+
+func (p *Image) updateRectPyramid(level, x, y, dx, dy int) {
+	r := image.Rect(x, y, x+dx, y+dy)
+	for ; level > 0 && !r.Empty(); level-- {
+		r = p.parentRect(level, r)
+
+		var keys []tileKey
+		p.forEachTile(level-1, r, func(col, row int) {
+			keys = append(keys, tileKey{level - 1, col, row})
+		})
+		p.buildParentTiles(level, keys)
+	}
+	return
+}
+
+// buildParentTiles rebuilds the tiles of the level-1, keys, from the
+// level. The tiles of the store may be evicted and reloaded, so every
+// parent tile is built by one goroutine.
+func (p *Image) buildParentTiles(level int, keys []tileKey) {
+	var wg sync.WaitGroup
+	sem := make(chan bool, runtime.NumCPU())
+	for _, k := range keys {
+		wg.Add(1)
+		sem <- true
+		go func(col, row int) {
+			p.buildParentTile(level, col, row)
+			<-sem
+			wg.Done()
+		}(k.col, k.row)
+	}
+	wg.Wait()
+}
+
+// buildParentTile rebuilds the tile (col, row) of the level-1 from the
+// pixels of the level under it, and the margin of the Filter.
+func (p *Image) buildParentTile(level, col, row int) {
+	margin := p.Filter.Margin()
+	r := image.Rect(
+		col*p.TileSize.X*2-margin, row*p.TileSize.Y*2-margin,
+		(col+1)*p.TileSize.X*2+margin, (row+1)*p.TileSize.Y*2+margin,
+	).Intersect(p.levelRect(level))
+	if r.Empty() {
+		return
+	}
+
+	src := newImageTile(r.Size(), p.Model)
+	if _, err := p.ReadRect(level, r, src); err != nil {
+		p.err.Set(err)
+		return
+	}
+	parent := newImageTile(p.TileSize, p.Model)
+	draw_ext.DrawPyrDown(
+		parent, parent.Bounds(),
+		src, image.Pt(col*p.TileSize.X*2-r.Min.X, row*p.TileSize.Y*2-r.Min.Y),
+		p.Filter,
+	)
+	p.putTile(level-1, col, row, parent)
+}
+
+// levelRect returns the pixels of the level, every level is the half of
+// the next level (rounded up).
+func (p *Image) levelRect(level int) image.Rectangle {
+	r := p.Rect
+	for i := p.adjustLevel(level); i < p.Levels()-1; i++ {
+		r.Min.X, r.Min.Y = r.Min.X/2, r.Min.Y/2
+		r.Max.X, r.Max.Y = (r.Max.X+1)/2, (r.Max.Y+1)/2
+	}
+	return r
+}
+
+// parentRect returns the pixels of the level-1 computed from the pixels
+// r of the level.
+func (p *Image) parentRect(level int, r image.Rectangle) image.Rectangle {
+	margin := p.Filter.Margin()
+	return image.Rect(
+		(r.Min.X-margin)/2, (r.Min.Y-margin)/2,
+		(r.Max.X+margin+1)/2, (r.Max.Y+margin+1)/2,
+	).Intersect(p.levelRect(level - 1))
+}
+
+// forEachTile calls fn with the tiles of the level over the pixels r.
+func (p *Image) forEachTile(level int, r image.Rectangle, fn func(col, row int)) {
+	if r.Empty() {
+		return
+	}
+	maxCol := minInt((r.Max.X-1)/p.TileSize.X, p.TilesAcross(level)-1)
+	maxRow := minInt((r.Max.Y-1)/p.TileSize.Y, p.TilesDown(level)-1)
+	for row := r.Min.Y / p.TileSize.Y; row <= maxRow; row++ {
+		for col := r.Min.X / p.TileSize.X; col <= maxCol; col++ {
+			fn(col, row)
+		}
+	}
+}
+
+// tileSet is a set of tiles, safe for the concurrent use.
+type tileSet struct {
+	mu    sync.Mutex
+	tiles map[tileKey]bool
+}
+
+func newTileSet() *tileSet {
+	return &tileSet{
+		tiles: make(map[tileKey]bool),
+	}
+}
+
+func (p *tileSet) Add(level, col, row int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tiles[tileKey{level, col, row}] = true
+}
+
+func (p *tileSet) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tiles)
+}
+
+// Take removes the tiles of the level from the set, and returns them.
+func (p *tileSet) Take(level int) (keys []tileKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k := range p.tiles {
+		if k.level == level {
+			keys = append(keys, k)
+			delete(p.tiles, k)
+		}
+	}
+	return
+}
@@ -0,0 +1,144 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"testing"
+
+	draw_ext "github.com/chai2010/gopkg/image/draw"
+)
+
+// tCountingTileStore counts the stored tiles of every level.
+type tCountingTileStore struct {
+	*MemoryTileStore
+	mu   sync.Mutex
+	puts map[int]int
+}
+
+func (p *tCountingTileStore) Put(level, col, row int, m draw.Image) error {
+	p.mu.Lock()
+	p.puts[level]++
+	p.mu.Unlock()
+	return p.MemoryTileStore.Put(level, col, row, m)
+}
+
+func tGrayImage(r image.Rectangle) *image.Gray {
+	m := image.NewGray(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			m.SetGray(x, y, color.Gray{uint8(x*13 + y*7)})
+		}
+	}
+	return m
+}
+
+func TestImage_BuildPyramid(t *testing.T) {
+	store := &tCountingTileStore{MemoryTileStore: NewMemoryTileStore(), puts: make(map[int]int)}
+	m := NewImageWithStore(image.Rect(0, 0, 16, 16), image.Pt(4, 4), color.GrayModel, store)
+	m.Filter, m.DeferPyramid = draw_ext.Filter_Mode, true
+
+	src := tGrayImage(m.Bounds())
+	if err := m.WriteRect(-1, m.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+	if tile := m.LookupTile(0, 0, 0); tile != nil {
+		t.Fatalf("the pyramid is not deferred")
+	}
+	if err := m.BuildPyramid(); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.dirty.Len(); n != 0 {
+		t.Fatalf("expect no dirty tiles, got = %d", n)
+	}
+	top := m.GetTile(0, 0, 0).(*image.Gray)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			// all the 2x2 pixels differ: the mode is the top-left pixel
+			if a, b := top.GrayAt(x, y), src.GrayAt(x*4, y*4); a != b {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, b, a)
+			}
+		}
+	}
+
+	// only the parents of the modified tile are rebuilt, the store is
+	// behind a cache which writes the modified tiles on Flush
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	store.puts = make(map[int]int)
+	if err := m.WriteRect(-1, image.Rect(5, 5, 7, 7), image.NewGray(image.Rect(5, 5, 7, 7))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.BuildPyramid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if store.puts[1] != 1 || store.puts[0] != 1 {
+		t.Fatalf("bad rebuilt tiles: %v", store.puts)
+	}
+}
+
+func TestImage_BuildPyramid_gaussian(t *testing.T) {
+	r, tileSize := image.Rect(0, 0, 20, 12), image.Pt(4, 4)
+	src := tGrayImage(r)
+
+	eager := NewImage(r, tileSize, color.GrayModel)
+	eager.Filter = draw_ext.Filter_Gaussian
+	if err := eager.WriteRect(-1, r, src); err != nil {
+		t.Fatal(err)
+	}
+
+	lazy := NewImage(r, tileSize, color.GrayModel)
+	lazy.Filter, lazy.DeferPyramid = draw_ext.Filter_Gaussian, true
+	for y := 0; y < r.Dy(); y += 3 {
+		part := image.NewGray(image.Rect(0, 0, r.Dx(), 3))
+		draw.Draw(part, part.Bounds(), src, image.Pt(0, y), draw.Src)
+		if err := lazy.WriteRect(-1, part.Bounds().Add(image.Pt(0, y)), part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lazy.BuildPyramid(); err != nil {
+		t.Fatal(err)
+	}
+
+	for level := 0; level < eager.Levels(); level++ {
+		a, err := eager.ReadRect(level, eager.levelRect(level), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := lazy.ReadRect(level, lazy.levelRect(level), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a, b := a.(*image.Gray), b.(*image.Gray); string(a.Pix) != string(b.Pix) {
+			t.Fatalf("level %d: expect = %v, got = %v", level, a.Pix, b.Pix)
+		}
+	}
+}
+
+func TestImage_RebuildPyramid_sparse(t *testing.T) {
+	m := NewImage(image.Rect(0, 0, 32, 32), image.Pt(4, 4), color.GrayModel)
+	m.Filter = draw_ext.Filter_Mode
+	if err := m.SetTile(-1, 0, 0, tGrayImage(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RebuildPyramid(); err != nil {
+		t.Fatal(err)
+	}
+	for level := 0; level < m.Levels(); level++ {
+		if m.LookupTile(level, 0, 0) == nil {
+			t.Fatalf("level %d: the tile (0,0) is not built", level)
+		}
+		if level > 0 && m.LookupTile(level, 1, 1) != nil {
+			t.Fatalf("level %d: the tile (1,1) is built", level)
+		}
+	}
+}
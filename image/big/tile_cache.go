@@ -0,0 +1,130 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"container/list"
+	"fmt"
+	"image/draw"
+	"sync"
+)
+
+// DefaultTileCacheSize is the capacity of the cache which NewImageWithStore
+// and NewDemWithStore put in front of a store that keeps its tiles out of
+// memory.
+const DefaultTileCacheSize = 64
+
+// cachedTileStore returns store if it keeps the tiles in memory, or a
+// LRUTileCache of store, so that the pixel accesses of Image and Dem do
+// not read and write a tile of the store every time.
+func cachedTileStore(store TileStore) TileStore {
+	switch store.(type) {
+	case *MemoryTileStore, *LRUTileCache:
+		return store
+	}
+	return NewLRUTileCache(store, DefaultTileCacheSize)
+}
+
+// LRUTileCache keeps the recently used tiles of a store in memory.
+// The stored tiles are written to the store when they are evicted or
+// when the cache is flushed.
+type LRUTileCache struct {
+	store    TileStore
+	capacity int
+
+	mu    sync.Mutex
+	list  *list.List // *lruTile, the most recently used first
+	tiles map[tileKey]*list.Element
+}
+
+type lruTile struct {
+	key   tileKey
+	m     draw.Image
+	dirty bool
+}
+
+// NewLRUTileCache returns a cache of at most capacity tiles of store.
+func NewLRUTileCache(store TileStore, capacity int) *LRUTileCache {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("image/big: NewLRUTileCache, bad capacity: %d", capacity))
+	}
+	return &LRUTileCache{
+		store:    store,
+		capacity: capacity,
+		list:     list.New(),
+		tiles:    make(map[tileKey]*list.Element),
+	}
+}
+
+// Len returns the number of the cached tiles.
+func (p *LRUTileCache) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.list.Len()
+}
+
+func (p *LRUTileCache) Get(level, col, row int) (m draw.Image, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := tileKey{level, col, row}
+	if e, ok := p.tiles[key]; ok {
+		p.list.MoveToFront(e)
+		m = e.Value.(*lruTile).m
+		return
+	}
+	if m, err = p.store.Get(level, col, row); err != nil || m == nil {
+		return
+	}
+	p.tiles[key] = p.list.PushFront(&lruTile{key: key, m: m})
+	err = p.evict()
+	return
+}
+
+func (p *LRUTileCache) Put(level, col, row int, m draw.Image) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := tileKey{level, col, row}
+	if e, ok := p.tiles[key]; ok {
+		t := e.Value.(*lruTile)
+		t.m, t.dirty = m, true
+		p.list.MoveToFront(e)
+		return nil
+	}
+	p.tiles[key] = p.list.PushFront(&lruTile{key: key, m: m, dirty: true})
+	return p.evict()
+}
+
+// Flush writes the modified tiles to the store, and flushes the store.
+func (p *LRUTileCache) Flush() (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for e := p.list.Back(); e != nil; e = e.Prev() {
+		if t := e.Value.(*lruTile); t.dirty {
+			if err = p.store.Put(t.key.level, t.key.col, t.key.row, t.m); err != nil {
+				return
+			}
+			t.dirty = false
+		}
+	}
+	return p.store.Flush()
+}
+
+func (p *LRUTileCache) evict() (err error) {
+	for p.list.Len() > p.capacity {
+		e := p.list.Back()
+		t := e.Value.(*lruTile)
+		if t.dirty {
+			if err = p.store.Put(t.key.level, t.key.col, t.key.row, t.m); err != nil {
+				return
+			}
+		}
+		p.list.Remove(e)
+		delete(p.tiles, t.key)
+	}
+	return
+}
@@ -0,0 +1,331 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/rawp"
+)
+
+// TileHandler serves the tiles of an Image or a Dem over HTTP, as the XYZ
+// tiles (z is the level, x the col and y the row) and a minimal WMTS
+// GetCapabilities document:
+//
+//	<prefix>/{z}/{x}/{y}.{ext}
+//	<prefix>/WMTSCapabilities.xml
+//	<prefix>?SERVICE=WMTS&REQUEST=GetCapabilities
+//
+// The tiles are encoded with the format registered with the extension
+// ext in the gopkg/image registry, like ".png", ".jpg" or ".webp" (the
+// codec packages must be imported by the program).
+//
+// The ETag of a tile is the hash of its pixels. It is computed once, then
+// kept until a tile of the image is written again: the tiles modified in
+// place after GetTile keep their ETag until the next write.
+//
+// A TileHandler can be used with web.Server.Handler:
+//
+//	s.Handler("/tiles/.*", "GET", big.NewImageTileHandler(m))
+type TileHandler struct {
+	// Layer is the WMTS layer identifier, "big" if empty.
+	Layer string
+
+	// Formats are the extensions of the formats in the WMTS capabilities,
+	// ".png" if empty.
+	Formats []string
+
+	// Options are the encoding options of the formats, by format name
+	// (like "jpeg").
+	Options map[string]interface{}
+
+	// BaseURL is the URL of the handler in the WMTS capabilities, like
+	// "https://example.com/tiles". If empty, it is built from the Host
+	// header of the request, which is set by the client.
+	BaseURL string
+
+	tileSize image.Point
+	levels   []image.Point
+	readTile func(level, col, row int) image.Image
+	err      func() error
+	version  func() uint64
+
+	mu          sync.Mutex
+	etags       map[tileETagKey]string // the ETags of the tiles at etagVersion
+	etagVersion uint64
+}
+
+type tileETagKey struct {
+	tileKey
+	format string
+}
+
+var tilePathRegexp = regexp.MustCompile(`(^|/)(\d+)/(\d+)/(\d+)(\.\w+)$`)
+
+// NewImageTileHandler returns a handler of the tiles of m.
+func NewImageTileHandler(m *Image) *TileHandler {
+	return &TileHandler{
+		tileSize: m.TileSize,
+		levels:   m.levels,
+		readTile: func(level, col, row int) image.Image {
+			return m.readTile(level, col, row)
+		},
+		err:     m.Err,
+		version: m.ver.Get,
+	}
+}
+
+// NewDemTileHandler returns a handler of the tiles of m, the tiles have
+// the Gray32fModel.
+func NewDemTileHandler(m *Dem) *TileHandler {
+	return &TileHandler{
+		tileSize: m.TileSize,
+		levels:   m.levels,
+		readTile: func(level, col, row int) image.Image {
+			if tile := m.lookupTile(level, col, row); tile != nil {
+				return tile
+			}
+			return newDemTile(m.TileSize, m.ZeroValue)
+		},
+		err:     m.Err,
+		version: m.ver.Get,
+	}
+}
+
+func (p *TileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.FormValue("REQUEST"), "GetCapabilities") {
+		p.serveCapabilities(w, r, strings.TrimSuffix(r.URL.Path, "/"))
+		return
+	}
+	if path.Base(r.URL.Path) == "WMTSCapabilities.xml" {
+		p.serveCapabilities(w, r, path.Dir(r.URL.Path))
+		return
+	}
+
+	v := tilePathRegexp.FindStringSubmatch(r.URL.Path)
+	if v == nil {
+		http.NotFound(w, r)
+		return
+	}
+	level, err1 := strconv.Atoi(v[2])
+	col, err2 := strconv.Atoi(v[3])
+	row, err3 := strconv.Atoi(v[4])
+	ext := strings.ToLower(v[5])
+	format := image_ext.FormatName(ext)
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if level >= len(p.levels) || col >= p.levels[level].X || row >= p.levels[level].Y || format == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// the tile is not read if the client has the cached ETag, and it is
+	// not encoded if the client has the computed ETag
+	key := tileETagKey{tileKey{level, col, row}, format}
+	version := p.version()
+	match := r.Header.Get("If-None-Match")
+	etag, ok := p.lookupETag(key, version)
+	if ok && (match == "*" || strings.Contains(match, etag)) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	tile := p.readTile(level, col, row)
+	if err := p.err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h := fnv.New64a()
+		fmt.Fprint(h, format)
+		if err := rawp.Encode(h, tile, nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		etag = fmt.Sprintf(`"%x"`, h.Sum64())
+		p.storeETag(key, version, etag)
+	}
+	w.Header().Set("ETag", etag)
+	if match == "*" || strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := image_ext.Encode(format, &buf, tile, p.Options[format]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "image/" + format
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// lookupETag returns the ETag of the tile, if it is computed at the
+// version of the image.
+func (p *TileHandler) lookupETag(key tileETagKey, version uint64) (etag string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.etagVersion != version {
+		return
+	}
+	etag, ok = p.etags[key]
+	return
+}
+
+// storeETag keeps the ETag of the tile, the ETags of the older versions are
+// dropped. The version is read before the tile, so a tile written meanwhile
+// only leaves an ETag of an old version.
+func (p *TileHandler) storeETag(key tileETagKey, version uint64, etag string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.etags == nil || p.etagVersion < version {
+		p.etags = make(map[tileETagKey]string)
+		p.etagVersion = version
+	}
+	if p.etagVersion == version {
+		p.etags[key] = etag
+	}
+}
+
+func (p *TileHandler) serveCapabilities(w http.ResponseWriter, r *http.Request, prefix string) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + r.Host + prefix
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	layer := p.Layer
+	if layer == "" {
+		layer = "big"
+	}
+	capabilities := wmtsCapabilities{
+		Xmlns:              "http://www.opengis.net/wmts/1.0",
+		XmlnsOws:           "http://www.opengis.net/ows/1.1",
+		XmlnsXlink:         "http://www.w3.org/1999/xlink",
+		Version:            "1.0.0",
+		ServiceType:        "OGC WMTS",
+		ServiceTypeVersion: "1.0.0",
+		Layer: wmtsLayer{
+			Identifier:    layer,
+			Style:         wmtsStyle{IsDefault: true, Identifier: "default"},
+			TileMatrixSet: layer,
+		},
+		TileMatrixSet: wmtsTileMatrixSet{
+			Identifier:   layer,
+			SupportedCRS: "urn:ogc:def:crs:OGC:1.3:CRS1",
+		},
+	}
+	formats := p.Formats
+	if len(formats) == 0 {
+		formats = []string{".png"}
+	}
+	for _, ext := range formats {
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "image/" + image_ext.FormatName(ext)
+		}
+		capabilities.Layer.Formats = append(capabilities.Layer.Formats, contentType)
+		capabilities.Layer.ResourceURLs = append(capabilities.Layer.ResourceURLs, wmtsResourceURL{
+			Format:       contentType,
+			ResourceType: "tile",
+			Template:     baseURL + "/{TileMatrix}/{TileCol}/{TileRow}" + ext,
+		})
+	}
+	for i, v := range p.levels {
+		// the unit is the pixel of the last level, and the size of the
+		// pixels is the 0.28mm of the standard rendering
+		capabilities.TileMatrixSet.TileMatrices = append(capabilities.TileMatrixSet.TileMatrices, wmtsTileMatrix{
+			Identifier:       i,
+			ScaleDenominator: float64(int(1)<<uint(len(p.levels)-1-i)) / 0.00028,
+			TopLeftCorner:    "0 0",
+			TileWidth:        p.tileSize.X,
+			TileHeight:       p.tileSize.Y,
+			MatrixWidth:      v.X,
+			MatrixHeight:     v.Y,
+		})
+	}
+
+	data, err := xml.MarshalIndent(&capabilities, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// wmtsCapabilities is the minimal WMTS GetCapabilities document, the names
+// with the ows prefix are written as is.
+type wmtsCapabilities struct {
+	XMLName            xml.Name          `xml:"Capabilities"`
+	Xmlns              string            `xml:"xmlns,attr"`
+	XmlnsOws           string            `xml:"xmlns:ows,attr"`
+	XmlnsXlink         string            `xml:"xmlns:xlink,attr"`
+	Version            string            `xml:"version,attr"`
+	ServiceType        string            `xml:"ows:ServiceIdentification>ows:ServiceType"`
+	ServiceTypeVersion string            `xml:"ows:ServiceIdentification>ows:ServiceTypeVersion"`
+	Layer              wmtsLayer         `xml:"Contents>Layer"`
+	TileMatrixSet      wmtsTileMatrixSet `xml:"Contents>TileMatrixSet"`
+}
+
+type wmtsLayer struct {
+	Identifier    string            `xml:"ows:Identifier"`
+	Style         wmtsStyle         `xml:"Style"`
+	Formats       []string          `xml:"Format"`
+	TileMatrixSet string            `xml:"TileMatrixSetLink>TileMatrixSet"`
+	ResourceURLs  []wmtsResourceURL `xml:"ResourceURL"`
+}
+
+type wmtsStyle struct {
+	IsDefault  bool   `xml:"isDefault,attr"`
+	Identifier string `xml:"ows:Identifier"`
+}
+
+type wmtsResourceURL struct {
+	Format       string `xml:"format,attr"`
+	ResourceType string `xml:"resourceType,attr"`
+	Template     string `xml:"template,attr"`
+}
+
+type wmtsTileMatrixSet struct {
+	Identifier   string           `xml:"ows:Identifier"`
+	SupportedCRS string           `xml:"ows:SupportedCRS"`
+	TileMatrices []wmtsTileMatrix `xml:"TileMatrix"`
+}
+
+type wmtsTileMatrix struct {
+	Identifier       int     `xml:"ows:Identifier"`
+	ScaleDenominator float64 `xml:"ScaleDenominator"`
+	TopLeftCorner    string  `xml:"TopLeftCorner"`
+	TileWidth        int     `xml:"TileWidth"`
+	TileHeight       int     `xml:"TileHeight"`
+	MatrixWidth      int     `xml:"MatrixWidth"`
+	MatrixHeight     int     `xml:"MatrixHeight"`
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	_ "github.com/chai2010/gopkg/image/png"
+)
+
+func tServeTile(h http.Handler, url, etag string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestTileHandler(t *testing.T) {
+	m := NewImage(image.Rect(0, 0, 16, 8), image.Pt(4, 4), color.GrayModel)
+	if err := m.WriteRect(-1, m.Bounds(), tGrayImage(m.Bounds())); err != nil {
+		t.Fatal(err)
+	}
+	h := NewImageTileHandler(m)
+
+	w := tServeTile(h, "/tiles/2/3/1.png", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("bad response: %d, %v", w.Code, w.Header())
+	}
+	tile, format, err := image_ext.Decode(bytes.NewReader(w.Body.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || tile.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("bad tile: %s, %v", format, tile.Bounds())
+	}
+	if a, b := tile.At(1, 2), m.At(3*4+1, 1*4+2); a != b {
+		t.Fatalf("expect = %v, got = %v", b, a)
+	}
+
+	etag := w.Header().Get("ETag")
+	if w := tServeTile(h, "/tiles/2/3/1.png", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("expect = %d, got = %d", http.StatusNotModified, w.Code)
+	}
+
+	// the cached ETag is matched without reading the tile
+	readTile, reads := h.readTile, 0
+	h.readTile = func(level, col, row int) image.Image {
+		reads++
+		return readTile(level, col, row)
+	}
+	if w := tServeTile(h, "/tiles/2/3/1.png", etag); w.Code != http.StatusNotModified || w.Header().Get("ETag") != etag {
+		t.Fatalf("expect = %d, got = %d", http.StatusNotModified, w.Code)
+	}
+	if reads != 0 {
+		t.Fatalf("the tile is read %d times", reads)
+	}
+	m.Set(3*4+1, 1*4+2, color.Gray{1})
+	if w := tServeTile(h, "/tiles/2/3/1.png", etag); w.Code != http.StatusOK {
+		t.Fatalf("expect = %d, got = %d", http.StatusOK, w.Code)
+	}
+
+	for _, url := range []string{"/tiles/2/4/1.png", "/tiles/3/0/0.png", "/tiles/0/0/0.unknown", "/tiles/0/0", "/tiles/18446744073709551616/0/0.png"} {
+		if w := tServeTile(h, url, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: expect = %d, got = %d", url, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+func TestTileHandler_capabilities(t *testing.T) {
+	m := NewDem(image.Rect(0, 0, 16, 8), image.Pt(4, 4), color_ext.Gray32f{})
+	h := NewDemTileHandler(m)
+	h.Layer = "dem"
+
+	for _, url := range []string{
+		"http://example.com/tiles/WMTSCapabilities.xml",
+		"http://example.com/tiles?SERVICE=WMTS&REQUEST=GetCapabilities",
+	} {
+		w := tServeTile(h, url, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: bad response: %d", url, w.Code)
+		}
+		var doc struct {
+			Layer struct {
+				Identifier  string `xml:"Identifier"`
+				ResourceURL struct {
+					Template string `xml:"template,attr"`
+				} `xml:"ResourceURL"`
+			} `xml:"Contents>Layer"`
+			TileMatrix []struct {
+				Identifier   string `xml:"Identifier"`
+				MatrixWidth  int    `xml:"MatrixWidth"`
+				MatrixHeight int    `xml:"MatrixHeight"`
+			} `xml:"Contents>TileMatrixSet>TileMatrix"`
+		}
+		if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Layer.Identifier != "dem" || doc.Layer.ResourceURL.Template != "http://example.com/tiles/{TileMatrix}/{TileCol}/{TileRow}.png" {
+			t.Fatalf("%s: bad layer: %+v", url, doc.Layer)
+		}
+		if n := len(doc.TileMatrix); n != 3 || doc.TileMatrix[2].MatrixWidth != 4 || doc.TileMatrix[2].MatrixHeight != 2 {
+			t.Fatalf("%s: bad tile matrices: %+v", url, doc.TileMatrix)
+		}
+	}
+
+	// the configured base URL, and the escaped names
+	h.Layer = `a<b&"c"`
+	h.BaseURL = "https://tiles.example.com/dem/"
+	w := tServeTile(h, "http://evil.example.com/tiles/WMTSCapabilities.xml", "")
+	var doc struct {
+		Identifier  string `xml:"Contents>Layer>Identifier"`
+		ResourceURL struct {
+			Template string `xml:"template,attr"`
+		} `xml:"Contents>Layer>ResourceURL"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Identifier != h.Layer || doc.ResourceURL.Template != "https://tiles.example.com/dem/{TileMatrix}/{TileCol}/{TileRow}.png" {
+		t.Fatalf("bad layer: %+v", doc)
+	}
+}
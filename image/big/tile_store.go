@@ -0,0 +1,175 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"fmt"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/chai2010/gopkg/image/rawp"
+)
+
+// TileStore is the storage of the tiles of an Image or a Dem.
+//
+// The stores must be safe for the concurrent use, but the same tile is
+// never accessed concurrently by Image and Dem.
+type TileStore interface {
+	// Get returns the tile, or nil if the tile is not stored.
+	Get(level, col, row int) (m draw.Image, err error)
+
+	// Put stores the tile. The tiles are modified in place and stored
+	// again, so the stores must not keep a reference to the tiles they
+	// have written.
+	Put(level, col, row int, m draw.Image) error
+
+	// Flush writes the buffered tiles.
+	Flush() error
+}
+
+type tileKey struct {
+	level, col, row int
+}
+
+// MemoryTileStore keeps the tiles in memory.
+type MemoryTileStore struct {
+	mu    sync.Mutex
+	tiles map[tileKey]draw.Image
+}
+
+func NewMemoryTileStore() *MemoryTileStore {
+	return &MemoryTileStore{
+		tiles: make(map[tileKey]draw.Image),
+	}
+}
+
+func (p *MemoryTileStore) Get(level, col, row int) (m draw.Image, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m = p.tiles[tileKey{level, col, row}]
+	return
+}
+
+func (p *MemoryTileStore) Put(level, col, row int, m draw.Image) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tiles[tileKey{level, col, row}] = m
+	return nil
+}
+
+func (p *MemoryTileStore) Flush() error {
+	return nil
+}
+
+// FileTileStore keeps the tiles in a directory, one rawp file per tile:
+//
+//	Dir/<level>/<col>_<row>.rawp
+//
+// The tiles are written when they are stored, so it is usually used
+// behind a LRUTileCache.
+type FileTileStore struct {
+	Dir       string
+	UseSnappy bool
+}
+
+// NewFileTileStore returns a store in dir, which is created if needed.
+// The tiles already in dir are used.
+func NewFileTileStore(dir string, useSnappy bool) (p *FileTileStore, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	p = &FileTileStore{
+		Dir:       dir,
+		UseSnappy: useSnappy,
+	}
+	return
+}
+
+// TileName returns the file name of a tile.
+func (p *FileTileStore) TileName(level, col, row int) string {
+	return filepath.Join(p.Dir, fmt.Sprint(level), fmt.Sprintf("%d_%d.rawp", col, row))
+}
+
+func (p *FileTileStore) Get(level, col, row int) (m draw.Image, err error) {
+	f, err := os.Open(p.TileName(level, col, row))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	tile, err := rawp.Decode(f, nil)
+	if err != nil {
+		return
+	}
+	m, ok := tile.(draw.Image)
+	if !ok {
+		err = fmt.Errorf("image/big: FileTileStore.Get, bad tile type: %T", tile)
+	}
+	return
+}
+
+func (p *FileTileStore) Put(level, col, row int, m draw.Image) (err error) {
+	name := p.TileName(level, col, row)
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return
+	}
+	if err = rawp.Encode(f, m, &rawp.Options{UseSnappy: p.UseSnappy}); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+func (p *FileTileStore) Flush() error {
+	return nil
+}
+
+// tileVersion counts the tile writes of an image.
+type tileVersion struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+func (p *tileVersion) Add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n++
+}
+
+func (p *tileVersion) Get() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.n
+}
+
+// firstError keeps the first error of the tile accesses which can not
+// return an error (like GetTile and Set).
+type firstError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (p *firstError) Set(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *firstError) Get() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,178 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package big
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func tTempFileTileStore(t *testing.T) (store *FileTileStore, cleanup func()) {
+	dir, err := ioutil.TempDir("", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store, err = NewFileTileStore(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestFileTileStore(t *testing.T) {
+	store, cleanup := tTempFileTileStore(t)
+	defer cleanup()
+
+	if m, err := store.Get(0, 0, 0); m != nil || err != nil {
+		t.Fatalf("expect no tile, got = %v, %v", m, err)
+	}
+
+	tile := image_ext.NewGray32f(image.Rect(0, 0, 4, 4))
+	tile.SetGray32f(1, 2, color_ext.Gray32f{Y: 1.5})
+	if err := store.Put(2, 3, 1, tile); err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Get(2, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := m.(*image_ext.Gray32f)
+	if !ok || got.Bounds() != tile.Bounds() || got.Gray32fAt(1, 2).Y != 1.5 {
+		t.Fatalf("bad tile: %#v", m)
+	}
+}
+
+func TestLRUTileCache_image(t *testing.T) {
+	store, cleanup := tTempFileTileStore(t)
+	defer cleanup()
+
+	r, tileSize := image.Rect(0, 0, 16, 16), image.Pt(4, 4)
+	src := image.NewRGBA(r)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 0, 255})
+		}
+	}
+
+	cache := NewLRUTileCache(store, 2)
+	m := NewImageWithStore(r, tileSize, color.RGBAModel, cache)
+	if err := m.WriteRect(-1, r, src); err != nil {
+		t.Fatal(err)
+	}
+	if n := cache.Len(); n > 2 {
+		t.Fatalf("too many cached tiles: %d", n)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tiles are reloaded from the files
+	m = NewImageWithStore(r, tileSize, color.RGBAModel, NewLRUTileCache(store, 1))
+	dst, err := m.ReadRect(-1, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if a, b := src.At(x, y), dst.At(x, y); a != b {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, a, b)
+			}
+		}
+	}
+
+	// every quarter of the top tile is updated
+	top := m.GetTile(0, 0, 0)
+	for _, pt := range []image.Point{{0, 0}, {3, 0}, {0, 3}, {3, 3}} {
+		if _, _, _, a := top.At(pt.X, pt.Y).RGBA(); a == 0 {
+			t.Fatalf("top tile (%d,%d) not updated", pt.X, pt.Y)
+		}
+	}
+}
+
+func TestLRUTileCache_dem(t *testing.T) {
+	store, cleanup := tTempFileTileStore(t)
+	defer cleanup()
+
+	m := NewDemWithStore(image.Rect(0, 0, 8, 16), image.Pt(4, 4), color_ext.Gray32f{Y: -1}, NewLRUTileCache(store, 1))
+	src := image_ext.NewGray32f(image.Rect(0, 0, 8, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 8; x++ {
+			src.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*8 + x)})
+		}
+	}
+	if err := m.WriteRect(-1, m.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+	m.SetGray32f(5, 9, color_ext.Gray32f{Y: 1000})
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m = NewDemWithStore(m.Bounds(), m.TileSize, m.ZeroValue, store)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 8; x++ {
+			expect := float32(y*8 + x)
+			if x == 5 && y == 9 {
+				expect = 1000
+			}
+			if v := m.Gray32fAt(x, y).Y; v != expect {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, expect, v)
+			}
+		}
+	}
+}
+
+// tFileCountingTileStore counts the tiles written to the files.
+type tFileCountingTileStore struct {
+	TileStore
+	puts int
+}
+
+func (p *tFileCountingTileStore) Put(level, col, row int, m draw.Image) error {
+	p.puts++
+	return p.TileStore.Put(level, col, row, m)
+}
+
+func TestDem_writeBack(t *testing.T) {
+	files, cleanup := tTempFileTileStore(t)
+	defer cleanup()
+	store := &tFileCountingTileStore{TileStore: files}
+
+	m := NewDemWithStore(image.Rect(0, 0, 8, 8), image.Pt(8, 8), color_ext.Gray32f{}, store)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(x + y)})
+		}
+	}
+	if store.puts != 0 {
+		t.Fatalf("the pixels are written to the store: %d puts", store.puts)
+	}
+
+	tile := m.GetTile(-1, 0, 0)
+	tile.SetGray32f(7, 7, color_ext.Gray32f{Y: 100})
+	if err := m.SetTile(-1, 0, 0, tile); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if store.puts != 1 {
+		t.Fatalf("expect 1 put, got %d", store.puts)
+	}
+
+	m = NewDemWithStore(m.Bounds(), m.TileSize, m.ZeroValue, files)
+	if v := m.Gray32fAt(3, 4).Y; v != 7 {
+		t.Fatalf("expect 7, got %v", v)
+	}
+	if v := m.Gray32fAt(7, 7).Y; v != 100 {
+		t.Fatalf("expect 100, got %v", v)
+	}
+}
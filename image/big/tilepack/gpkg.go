@@ -0,0 +1,215 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tilepack
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/chai2010/gopkg/image/big"
+)
+
+// The GeoPackage application id, "GP10".
+const gpkgApplicationId = 0x47503130
+
+var gpkgTableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ExportGeoPackage writes the stored tiles of m to the GeoPackage file
+// filename, which must not exist, as the tile matrix set Options.Name.
+//
+// The coordinates are the pixels of the last level (the undefined
+// cartesian SRS -1), with the y axis up: the upper left corner of the
+// Image is (0, 0). The tile matrix of the level z has 1<<z tiles across
+// and down.
+func ExportGeoPackage(filename string, m *big.Image, opt *Options) (err error) {
+	o := opt.adjust()
+	if !gpkgTableNameRegexp.MatchString(o.Name) {
+		err = fmt.Errorf("image/big/tilepack: ExportGeoPackage, bad table name: %q", o.Name)
+		return
+	}
+	db, err := createDB(filename)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(fmt.Sprintf(`PRAGMA application_id = %d`, gpkgApplicationId)); err != nil {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, stmt := range []string{
+		`CREATE TABLE gpkg_spatial_ref_sys (
+			srs_name TEXT NOT NULL,
+			srs_id INTEGER NOT NULL PRIMARY KEY,
+			organization TEXT NOT NULL,
+			organization_coordsys_id INTEGER NOT NULL,
+			definition TEXT NOT NULL,
+			description TEXT
+		)`,
+		`INSERT INTO gpkg_spatial_ref_sys VALUES
+			('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+			('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+			('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid')`,
+		`CREATE TABLE gpkg_contents (
+			table_name TEXT NOT NULL PRIMARY KEY,
+			data_type TEXT NOT NULL,
+			identifier TEXT UNIQUE,
+			description TEXT DEFAULT '',
+			last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+			min_x DOUBLE,
+			min_y DOUBLE,
+			max_x DOUBLE,
+			max_y DOUBLE,
+			srs_id INTEGER,
+			CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+		)`,
+		`CREATE TABLE gpkg_tile_matrix_set (
+			table_name TEXT NOT NULL PRIMARY KEY,
+			srs_id INTEGER NOT NULL,
+			min_x DOUBLE NOT NULL,
+			min_y DOUBLE NOT NULL,
+			max_x DOUBLE NOT NULL,
+			max_y DOUBLE NOT NULL,
+			CONSTRAINT fk_gtms_table_name FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+			CONSTRAINT fk_gtms_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+		)`,
+		`CREATE TABLE gpkg_tile_matrix (
+			table_name TEXT NOT NULL,
+			zoom_level INTEGER NOT NULL,
+			matrix_width INTEGER NOT NULL,
+			matrix_height INTEGER NOT NULL,
+			tile_width INTEGER NOT NULL,
+			tile_height INTEGER NOT NULL,
+			pixel_x_size DOUBLE NOT NULL,
+			pixel_y_size DOUBLE NOT NULL,
+			CONSTRAINT pk_ttm PRIMARY KEY (table_name, zoom_level),
+			CONSTRAINT fk_tmm_table_name FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name)
+		)`,
+		fmt.Sprintf(`CREATE TABLE "%s" (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			zoom_level INTEGER NOT NULL,
+			tile_column INTEGER NOT NULL,
+			tile_row INTEGER NOT NULL,
+			tile_data BLOB NOT NULL,
+			UNIQUE (zoom_level, tile_column, tile_row)
+		)`, o.Name),
+	} {
+		if _, err = tx.Exec(stmt); err != nil {
+			return
+		}
+	}
+
+	// the full matrix of the last level
+	n := m.Levels() - 1
+	maxX := float64(m.TileSize.X << uint(n))
+	minY := -float64(m.TileSize.Y << uint(n))
+
+	b := m.Bounds()
+	if _, err = tx.Exec(
+		`INSERT INTO gpkg_contents (table_name, data_type, identifier, description, last_change, min_x, min_y, max_x, max_y, srs_id)
+		VALUES (?, 'tiles', ?, ?, ?, ?, ?, ?, ?, -1)`,
+		o.Name, o.Name, o.Description, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		0, -float64(b.Dy()), float64(b.Dx()), 0,
+	); err != nil {
+		return
+	}
+	if _, err = tx.Exec(
+		`INSERT INTO gpkg_tile_matrix_set (table_name, srs_id, min_x, min_y, max_x, max_y) VALUES (?, -1, ?, ?, ?, ?)`,
+		o.Name, 0, minY, maxX, 0,
+	); err != nil {
+		return
+	}
+	for level := 0; level <= n; level++ {
+		pixelSize := float64(int(1) << uint(n-level))
+		if _, err = tx.Exec(
+			`INSERT INTO gpkg_tile_matrix VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			o.Name, level, 1<<uint(level), 1<<uint(level),
+			m.TileSize.X, m.TileSize.Y, pixelSize, pixelSize,
+		); err != nil {
+			return
+		}
+	}
+
+	return exportTiles(tx,
+		fmt.Sprintf(`INSERT INTO "%s" (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)`, o.Name),
+		m, &o, false,
+	)
+}
+
+// ImportGeoPackage reads the tile matrix set name of the GeoPackage file
+// filename (the first tile matrix set if name is empty) to a new Image
+// with the color model.
+//
+// The tile matrices must be a pyramid: the level z has 1<<z tiles across
+// and down, like the files written by ExportGeoPackage. The size of the
+// Image is the bounds of the contents in the pixels of the last level.
+func ImportGeoPackage(filename, name string, model color.Model) (m *big.Image, err error) {
+	db, err := openDB(filename)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	if name == "" {
+		if err = db.QueryRow(
+			`SELECT table_name FROM gpkg_contents WHERE data_type = 'tiles' ORDER BY table_name LIMIT 1`,
+		).Scan(&name); err != nil {
+			return
+		}
+	}
+	if !gpkgTableNameRegexp.MatchString(name) {
+		err = fmt.Errorf("image/big/tilepack: ImportGeoPackage, bad table name: %q", name)
+		return
+	}
+
+	var maxZoom, tileWidth, tileHeight, matrixWidth, matrixHeight int
+	var pixelXSize, pixelYSize float64
+	if err = db.QueryRow(
+		`SELECT zoom_level, tile_width, tile_height, matrix_width, matrix_height, pixel_x_size, pixel_y_size
+		FROM gpkg_tile_matrix WHERE table_name = ? ORDER BY zoom_level DESC LIMIT 1`, name,
+	).Scan(&maxZoom, &tileWidth, &tileHeight, &matrixWidth, &matrixHeight, &pixelXSize, &pixelYSize); err != nil {
+		return
+	}
+	if matrixWidth != 1<<uint(maxZoom) || matrixHeight != 1<<uint(maxZoom) {
+		err = fmt.Errorf("image/big/tilepack: ImportGeoPackage, not a pyramid: %s", name)
+		return
+	}
+
+	var minX, minY, maxX, maxY float64
+	if err = db.QueryRow(
+		`SELECT min_x, min_y, max_x, max_y FROM gpkg_contents WHERE table_name = ?`, name,
+	).Scan(&minX, &minY, &maxX, &maxY); err != nil {
+		return
+	}
+	r := image.Rect(0, 0,
+		int(math.Ceil((maxX-minX)/pixelXSize)),
+		int(math.Ceil((maxY-minY)/pixelYSize)),
+	)
+	if m, err = newImage(r, image.Pt(tileWidth, tileHeight), maxZoom+1, model); err != nil {
+		return
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`SELECT zoom_level, tile_column, tile_row, tile_data FROM "%s"`, name))
+	if err != nil {
+		return
+	}
+	err = importTiles(rows, m, false)
+	return
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tilepack
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+
+	"github.com/chai2010/gopkg/image/big"
+)
+
+// The size of the last level, the MBTiles metadata of the pyramids.
+const (
+	mbtilesWidth  = "width"
+	mbtilesHeight = "height"
+)
+
+// ExportMBTiles writes the stored tiles of m to the MBTiles 1.2 file
+// filename, which must not exist.
+func ExportMBTiles(filename string, m *big.Image, opt *Options) (err error) {
+	o := opt.adjust()
+	db, err := createDB(filename)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, stmt := range []string{
+		`CREATE TABLE metadata (name TEXT, value TEXT)`,
+		`CREATE TABLE tiles (zoom_level INTEGER, tile_column INTEGER, tile_row INTEGER, tile_data BLOB)`,
+		`CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)`,
+	} {
+		if _, err = tx.Exec(stmt); err != nil {
+			return
+		}
+	}
+
+	format := o.Format
+	if format == "jpeg" {
+		format = "jpg"
+	}
+	for _, kv := range [][2]string{
+		{"name", o.Name},
+		{"description", o.Description},
+		{"type", "baselayer"},
+		{"version", "1.0"},
+		{"format", format},
+		{"minzoom", "0"},
+		{"maxzoom", strconv.Itoa(m.Levels() - 1)},
+		{mbtilesWidth, strconv.Itoa(m.Bounds().Dx())},
+		{mbtilesHeight, strconv.Itoa(m.Bounds().Dy())},
+	} {
+		if _, err = tx.Exec(`INSERT INTO metadata (name, value) VALUES (?, ?)`, kv[0], kv[1]); err != nil {
+			return
+		}
+	}
+
+	return exportTiles(tx,
+		`INSERT INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)`,
+		m, &o, true,
+	)
+}
+
+// ImportMBTiles reads the MBTiles file filename to a new Image with the
+// color model. The size of the Image is the size of the exported Image,
+// or the full size of the last level for the other files.
+func ImportMBTiles(filename string, model color.Model) (m *big.Image, err error) {
+	db, err := openDB(filename)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	metadata := make(map[string]string)
+	rows, err := db.Query(`SELECT name, value FROM metadata`)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return
+		}
+		metadata[name] = value
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return
+	}
+	rows.Close()
+
+	var maxZoom int
+	if err = db.QueryRow(`SELECT MAX(zoom_level) FROM tiles`).Scan(&maxZoom); err != nil {
+		return
+	}
+	if rows, err = db.Query(`SELECT tile_data FROM tiles LIMIT 1`); err != nil {
+		return
+	}
+	size, err := tileSize(rows)
+	if err != nil {
+		return
+	}
+
+	var r image.Rectangle
+	if v, ok := metadata[mbtilesWidth]; ok {
+		if r.Max.X, err = strconv.Atoi(v); err != nil {
+			err = fmt.Errorf("image/big/tilepack: ImportMBTiles, bad width: %q", v)
+			return
+		}
+		if r.Max.Y, err = strconv.Atoi(metadata[mbtilesHeight]); err != nil {
+			err = fmt.Errorf("image/big/tilepack: ImportMBTiles, bad height: %q", metadata[mbtilesHeight])
+			return
+		}
+	}
+	if m, err = newImage(r, size, maxZoom+1, model); err != nil {
+		return
+	}
+
+	if rows, err = db.Query(`SELECT zoom_level, tile_column, tile_row, tile_data FROM tiles`); err != nil {
+		return
+	}
+	err = importTiles(rows, m, true)
+	return
+}
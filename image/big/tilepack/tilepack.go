@@ -0,0 +1,212 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package tilepack exports and imports the big.Image pyramids as the MBTiles
+and GeoPackage files, with the database/sqlite3 driver.
+
+The level z of the pyramid is the zoom level z of the files: the level 0
+has one tile, and the level z has at most 1<<z tiles across and down.
+
+	if err := tilepack.ExportMBTiles("map.mbtiles", m, &tilepack.Options{
+		Name:   "map",
+		Format: "jpeg",
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := tilepack.ImportMBTiles("map.mbtiles", color.RGBAModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+The tiles are encoded with a format registered with image_ext.RegisterFormat
+(png by default), the codec packages other than png must be imported by the
+program.
+*/
+package tilepack
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+
+	_ "github.com/chai2010/gopkg/database/sqlite3"
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	"github.com/chai2010/gopkg/image/convert"
+	_ "github.com/chai2010/gopkg/image/png"
+)
+
+// Options are the parameters of the exported files.
+type Options struct {
+	// Name is the name of the tile set ("big" if empty): the name of the
+	// MBTiles metadata, and the table of the GeoPackage tiles.
+	Name string
+
+	// Description is the description of the tile set.
+	Description string
+
+	// Format is the name of the tile format, "png" if empty.
+	Format string
+
+	// EncodeOptions are passed to the encoder of the Format.
+	EncodeOptions interface{}
+}
+
+func (p *Options) adjust() Options {
+	var o Options
+	if p != nil {
+		o = *p
+	}
+	if o.Name == "" {
+		o.Name = "big"
+	}
+	if o.Format == "" {
+		o.Format = "png"
+	}
+	return o
+}
+
+// createDB creates the SQLite database filename, which must not exist.
+func createDB(filename string) (db *sql.DB, err error) {
+	if _, err = os.Stat(filename); err == nil {
+		err = fmt.Errorf("image/big/tilepack: %s already exists", filename)
+		return
+	}
+	return sql.Open("sqlite3", filename)
+}
+
+// openDB opens the SQLite database filename, which must exist.
+func openDB(filename string) (db *sql.DB, err error) {
+	if _, err = os.Stat(filename); err != nil {
+		return
+	}
+	return sql.Open("sqlite3", filename)
+}
+
+// exportTiles encodes the stored tiles of m, and inserts them with the
+// statement stmt(zoom_level, tile_column, tile_row, tile_data). flipY is
+// the TMS rows (0 is the bottom row).
+func exportTiles(tx *sql.Tx, stmt string, m *big.Image, o *Options, flipY bool) (err error) {
+	insert, err := tx.Prepare(stmt)
+	if err != nil {
+		return
+	}
+	defer insert.Close()
+
+	var buf bytes.Buffer
+	for level := 0; level < m.Levels(); level++ {
+		for row := 0; row < m.TilesDown(level); row++ {
+			for col := 0; col < m.TilesAcross(level); col++ {
+				tile := m.LookupTile(level, col, row)
+				if tile == nil {
+					continue
+				}
+				buf.Reset()
+				if err = image_ext.Encode(o.Format, &buf, tile, o.EncodeOptions); err != nil {
+					return
+				}
+				y := row
+				if flipY {
+					y = 1<<uint(level) - 1 - row
+				}
+				if _, err = insert.Exec(level, col, y, buf.Bytes()); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return m.Err()
+}
+
+// importTiles decodes the tiles of the query rows(zoom_level, tile_column,
+// tile_row, tile_data) to m.
+func importTiles(rows *sql.Rows, m *big.Image, flipY bool) (err error) {
+	defer rows.Close()
+	for rows.Next() {
+		var level, col, row int
+		var data []byte
+		if err = rows.Scan(&level, &col, &row, &data); err != nil {
+			return
+		}
+		if level < 0 || level >= m.Levels() || col < 0 || col >= m.TilesAcross(level) {
+			continue
+		}
+		if flipY {
+			row = 1<<uint(level) - 1 - row
+		}
+		if row < 0 || row >= m.TilesDown(level) {
+			continue
+		}
+
+		var tile image.Image
+		if tile, _, err = image_ext.Decode(bytes.NewReader(data), nil); err != nil {
+			return
+		}
+		if tile.ColorModel() != m.Model {
+			tile = convert.ColorModel(tile, m.Model)
+		}
+		t, ok := tile.(draw.Image)
+		if !ok {
+			return fmt.Errorf("image/big/tilepack: bad tile type: %T", tile)
+		}
+		if err = m.SetTile(level, col, row, t); err != nil {
+			return
+		}
+	}
+	return rows.Err()
+}
+
+// tileSize returns the size of the first tile of the query rows(tile_data).
+func tileSize(rows *sql.Rows) (size image.Point, err error) {
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = fmt.Errorf("image/big/tilepack: no tiles")
+		}
+		return
+	}
+	var data []byte
+	if err = rows.Scan(&data); err != nil {
+		return
+	}
+	config, _, err := image_ext.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	size = image.Pt(config.Width, config.Height)
+	return
+}
+
+// maxLevels is the maximum number of levels of the imported pyramids,
+// the zoom levels of the web maps are less than 30.
+const maxLevels = 32
+
+// newImage returns an Image of levels levels, with the rect r if it is
+// not empty.
+func newImage(r image.Rectangle, tileSize image.Point, levels int, model color.Model) (m *big.Image, err error) {
+	if levels < 1 || levels > maxLevels {
+		err = fmt.Errorf("image/big/tilepack: bad levels: %d", levels)
+		return
+	}
+	// the size of the last level does not overflow for maxLevels
+	if tileSize.X <= 0 || tileSize.Y <= 0 || tileSize.X > math.MaxInt32 || tileSize.Y > math.MaxInt32 {
+		err = fmt.Errorf("image/big/tilepack: bad tile size: %v", tileSize)
+		return
+	}
+	if r.Empty() {
+		r = image.Rect(0, 0, tileSize.X<<uint(levels-1), tileSize.Y<<uint(levels-1))
+	}
+	m = big.NewImage(r, tileSize, model)
+	if m.Levels() != levels {
+		err = fmt.Errorf("image/big/tilepack: bad levels: %d, expect = %d", levels, m.Levels())
+	}
+	return
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tilepack
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chai2010/gopkg/image/big"
+)
+
+func tTestImage(t *testing.T) *big.Image {
+	m := big.NewImage(image.Rect(0, 0, 20, 12), image.Pt(8, 8), color.RGBAModel)
+	src := image.NewRGBA(m.Bounds())
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 20; x++ {
+			src.Set(x, y, color.RGBA{uint8(x * 12), uint8(y * 20), 100, 255})
+		}
+	}
+	if err := m.WriteRect(-1, m.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func tCheckImage(t *testing.T, a, b *big.Image) {
+	if a.Bounds() != b.Bounds() || a.TileSize != b.TileSize || a.Levels() != b.Levels() {
+		t.Fatalf("bad image: %v, %v, %d", b.Bounds(), b.TileSize, b.Levels())
+	}
+	for level := 0; level < a.Levels(); level++ {
+		for row := 0; row < a.TilesDown(level); row++ {
+			for col := 0; col < a.TilesAcross(level); col++ {
+				ta, tb := a.LookupTile(level, col, row), b.LookupTile(level, col, row)
+				if tb == nil {
+					t.Fatalf("tile (%d,%d,%d) is not imported", level, col, row)
+				}
+				if string(ta.(*image.RGBA).Pix) != string(tb.(*image.RGBA).Pix) {
+					t.Fatalf("tile (%d,%d,%d) differs", level, col, row)
+				}
+			}
+		}
+	}
+}
+
+func TestMBTiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tilepack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := tTestImage(t)
+	filename := filepath.Join(dir, "test.mbtiles")
+	if err := ExportMBTiles(filename, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportMBTiles(filename, m, nil); err == nil {
+		t.Fatalf("expect an error for an existing file")
+	}
+	m2, err := ImportMBTiles(filename, color.RGBAModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tCheckImage(t, m, m2)
+
+	// the tiles of the foreign files out of the pyramid are skipped
+	db, err := openDB(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][3]int{{-1, 0, 0}, {0, -1, 0}, {m.Levels() + 1, 0, 0}} {
+		if _, err := db.Exec(
+			`INSERT INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)`,
+			v[0], v[1], v[2], []byte("bad"),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+	m2, err = ImportMBTiles(filename, color.RGBAModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tCheckImage(t, m, m2)
+}
+
+func TestGeoPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tilepack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := tTestImage(t)
+	filename := filepath.Join(dir, "test.gpkg")
+	if err := ExportGeoPackage(filename, m, &Options{Name: "tiles"}); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := ImportGeoPackage(filename, "", color.RGBAModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tCheckImage(t, m, m2)
+}
+
+func TestNewImage_badLevels(t *testing.T) {
+	for _, levels := range []int{0, 64, 1 << 20} {
+		if _, err := newImage(image.Rectangle{}, image.Pt(256, 256), levels, color.RGBAModel); err == nil {
+			t.Fatalf("%d: expect an error", levels)
+		}
+	}
+	m, err := newImage(image.Rectangle{}, image.Pt(256, 256), 3, color.RGBAModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := m.Bounds(); b != image.Rect(0, 0, 1024, 1024) {
+		t.Fatalf("bad bounds: %v", b)
+	}
+}
@@ -8,13 +8,14 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 
 	image_ext "github.com/chai2010/gopkg/image"
 	color_ext "github.com/chai2010/gopkg/image/color"
 )
 
-func makeImageTileMap(r image.Rectangle, tileSize image.Point) (tileMap [][][]draw.Image) {
+// makeTileLevels returns the numbers of the tiles across and down of the
+// levels, the level 0 has only one tile.
+func makeTileLevels(r image.Rectangle, tileSize image.Point) (levels []image.Point) {
 	xLevels := 0
 	for i := 0; ; i++ {
 		if x := (tileSize.X << uint8(i)); x >= r.Dx() {
@@ -29,46 +30,13 @@ func makeImageTileMap(r image.Rectangle, tileSize image.Point) (tileMap [][][]dr
 			break
 		}
 	}
-	tileMap = make([][][]draw.Image, maxInt(xLevels, yLevels))
-	for i := 0; i < len(tileMap); i++ {
-		xTileSize := tileSize.X << uint8(len(tileMap)-i-1)
-		yTileSize := tileSize.Y << uint8(len(tileMap)-i-1)
-		xTilesNum := (r.Dx() + xTileSize - 1) / xTileSize
-		yTilesNum := (r.Dy() + yTileSize - 1) / yTileSize
-
-		tileMap[i] = make([][]draw.Image, xTilesNum)
-		for x := 0; x < xTilesNum; x++ {
-			tileMap[i][x] = make([]draw.Image, yTilesNum)
-		}
-	}
-	return
-}
-
-func makeDemTileMap(r image.Rectangle, tileSize image.Point) (tileMap [][][]*image_ext.Gray32f) {
-	xLevels := 0
-	for i := 0; ; i++ {
-		if x := (tileSize.X << uint8(i)); x >= r.Dx() {
-			xLevels = i + 1
-			break
-		}
-	}
-	yLevels := 0
-	for i := 0; ; i++ {
-		if y := (tileSize.Y << uint8(i)); y >= r.Dy() {
-			xLevels = i + 1
-			break
-		}
-	}
-	tileMap = make([][][]*image_ext.Gray32f, maxInt(xLevels, yLevels))
-	for i := 0; i < len(tileMap); i++ {
-		xTileSize := tileSize.X << uint8(len(tileMap)-i-1)
-		yTileSize := tileSize.Y << uint8(len(tileMap)-i-1)
-		xTilesNum := (r.Dx() + xTileSize - 1) / xTileSize
-		yTilesNum := (r.Dy() + yTileSize - 1) / yTileSize
-
-		tileMap[i] = make([][]*image_ext.Gray32f, xTilesNum)
-		for x := 0; x < xTilesNum; x++ {
-			tileMap[i][x] = make([]*image_ext.Gray32f, yTilesNum)
+	levels = make([]image.Point, maxInt(xLevels, yLevels))
+	for i := 0; i < len(levels); i++ {
+		xTileSize := tileSize.X << uint8(len(levels)-i-1)
+		yTileSize := tileSize.Y << uint8(len(levels)-i-1)
+		levels[i] = image.Point{
+			X: (r.Dx() + xTileSize - 1) / xTileSize,
+			Y: (r.Dy() + yTileSize - 1) / yTileSize,
 		}
 	}
 	return
@@ -13,13 +13,14 @@ import (
 	"io"
 
 	"code.google.com/p/go.image/bmp"
-	image_ext "github.com/chai2010/image"
-	"github.com/chai2010/image/convert"
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
 )
 
 // Options are the encoding and decoding parameters.
 type Options struct {
 	ColorModel color.Model
+	Region     *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a BMP image without
@@ -35,6 +36,11 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 	if m, err = bmp.Decode(r); err != nil {
 		return
 	}
+	if opt != nil && opt.Region != nil {
+		if m, err = opt.Region.Apply(m); err != nil {
+			return
+		}
+	}
 	if opt != nil && opt.ColorModel != nil {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
@@ -18,3 +18,14 @@ func f32ToU16(v float32) uint16 {
 		return uint16(v)
 	}
 }
+
+func f64ToU16(v float64) uint16 {
+	switch {
+	case v < 0:
+		return 0
+	case v > math.MaxUint16:
+		return math.MaxUint16
+	default:
+		return uint16(v + 0.5)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package color
+
+import (
+	"image/color"
+	"math"
+)
+
+// Values returns the channel values of c for the weighted sums of the
+// resamplers: the float colors keep their values, the others are the
+// premultiplied 16-bit RGBA values.
+func Values(c color.Color) (v [4]float64) {
+	switch c := c.(type) {
+	case Gray32f:
+		v[0] = float64(c.Y)
+	case RGB96f:
+		v[0], v[1], v[2] = float64(c.R), float64(c.G), float64(c.B)
+	case RGBA128f:
+		v[0], v[1], v[2], v[3] = float64(c.R), float64(c.G), float64(c.B), float64(c.A)
+	default:
+		r, g, b, a := c.RGBA()
+		v[0], v[1], v[2], v[3] = float64(r), float64(g), float64(b), float64(a)
+	}
+	return
+}
+
+// FromValues returns the color of the kind of c with the channel values
+// of Values. The 16-bit values are clamped (the negative weights of a
+// kernel can overshoot them) and kept premultiplied.
+func FromValues(c color.Color, v [4]float64) color.Color {
+	switch c.(type) {
+	case Gray32f:
+		return Gray32f{Y: float32(v[0])}
+	case RGB96f:
+		return RGB96f{R: float32(v[0]), G: float32(v[1]), B: float32(v[2])}
+	case RGBA128f:
+		return RGBA128f{R: float32(v[0]), G: float32(v[1]), B: float32(v[2]), A: float32(v[3])}
+	}
+	a := f64ToU16(v[3])
+	return color.RGBA64{
+		R: uint16(math.Min(float64(f64ToU16(v[0])), float64(a))),
+		G: uint16(math.Min(float64(f64ToU16(v[1])), float64(a))),
+		B: uint16(math.Min(float64(f64ToU16(v[2])), float64(a))),
+		A: a,
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package arcinfogrid implements a decoder and encoder for ESRI ArcInfo
+// ASCII Grid (.asc) images.
+//
+// The file is a text header followed by the cell values, row by row from
+// the north:
+//
+//	ncols         4
+//	nrows         3
+//	xllcorner     500000.0
+//	yllcorner     4000000.0
+//	cellsize      30.0
+//	NODATA_value  -9999
+//	10.5 11 12 -9999
+//	...
+//
+// The xllcenter and yllcenter keys (the center of the lower left cell)
+// are also supported. The header keys are case insensitive.
+package arcinfogrid
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+const defaultNoDataValue = -9999
+
+// Georef is the georeference of the grid.
+type Georef struct {
+	XllCorner   float64 // x of the lower left corner of the lower left cell
+	YllCorner   float64 // y of the lower left corner of the lower left cell
+	CellSize    float64
+	NoDataValue float64
+}
+
+// Options are the encoding and decoding parameters.
+type Options struct {
+	ColorModel color.Model
+
+	// Georef is set by Decode and is written by Encode.
+	// If Georef is nil, Encode writes the origin and a cell size of 1.
+	Georef *Georef
+
+	// UseCenter makes Encode write xllcenter/yllcenter instead of
+	// xllcorner/yllcorner.
+	UseCenter bool
+
+	// Precision is the number of digits after the decimal point written
+	// by Encode, -1 (or 0 by default) means the smallest number of digits
+	// necessary to represent the value exactly.
+	Precision int
+}
+
+// header is the text header of the grid.
+type header struct {
+	NCols, NRows int
+	Georef
+}
+
+func (p *header) IsNoData(v float64) bool {
+	return v == p.NoDataValue || math.IsNaN(v)
+}
+
+func (p *header) check() error {
+	if p.NCols <= 0 || p.NRows <= 0 {
+		return fmt.Errorf("image/dem/ArcInfoGrid: bad size, ncols = %v, nrows = %v", p.NCols, p.NRows)
+	}
+	if !(p.CellSize > 0) {
+		return fmt.Errorf("image/dem/ArcInfoGrid: bad cellsize, %v", p.CellSize)
+	}
+	return nil
+}
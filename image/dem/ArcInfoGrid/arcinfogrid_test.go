@@ -0,0 +1,134 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arcinfogrid
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+const tGrid = `NCOLS 3
+NROWS 2
+XLLCENTER 100.5
+YLLCENTER 200.5
+CELLSIZE 1.0
+NODATA_VALUE -32768
+1.5 2 3
+4 -32768 6e2
+`
+
+func TestDecode(t *testing.T) {
+	cfg, err := DecodeConfig(strings.NewReader(tGrid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 3 || cfg.Height != 2 || cfg.ColorModel != color_ext.Gray32fModel {
+		t.Fatalf("bad config: %v", cfg)
+	}
+
+	m, geo, err := DecodeGeoref(strings.NewReader(tGrid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *geo != (Georef{100, 200, 1, -32768}) {
+		t.Fatalf("bad georef: %v", *geo)
+	}
+	values := []float32{1.5, 2, 3, 4, -32768, 600}
+	for i, v := range values {
+		if got := m.Gray32fAt(i%3, i/3).Y; got != v {
+			t.Fatalf("(%d,%d): expect = %v, got = %v", i%3, i/3, v, got)
+		}
+	}
+}
+
+func TestDecode_badFile(t *testing.T) {
+	tests := []string{
+		"",
+		"ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\n1 2 3 4 5 6\n",             // no cellsize
+		"ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n1 2 3 4 5\n",   // missing value
+		"ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n1 2 3 4 5 x\n", // bad value
+		"ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\nfoo 1\n1 2 3 4 5 6\n",
+		"ncols 2000000000\nnrows 2000000000\nxllcorner 0\nyllcorner 0\ncellsize 1\n1\n", // too large
+	}
+	for i, s := range tests {
+		if _, err := Decode(strings.NewReader(s), nil); err == nil {
+			t.Fatalf("%d: expect error", i)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*4+x) + 0.25})
+		}
+	}
+	m.SetGray32f(1, 1, color_ext.Gray32f{Y: float32(math.NaN())})
+
+	for _, useCenter := range []bool{false, true} {
+		var buf bytes.Buffer
+		geo := &Georef{XllCorner: 500000, YllCorner: 4000000, CellSize: 30, NoDataValue: -9999}
+		if err := Encode(&buf, m, &Options{Georef: geo, UseCenter: useCenter}); err != nil {
+			t.Fatal(err)
+		}
+
+		opt := &Options{}
+		m2, err := Decode(&buf, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *opt.Georef != *geo {
+			t.Fatalf("bad georef: %v", *opt.Georef)
+		}
+		gray := m2.(*image_ext.Gray32f)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				expect := float32(y*4+x) + 0.25
+				if x == 1 && y == 1 {
+					expect = -9999
+				}
+				if v := gray.Gray32fAt(x, y).Y; v != expect {
+					t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, expect, v)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := image_ext.NewGray32f(image.Rect(0, 0, 5, 7))
+	m.SetGray32f(4, 6, color_ext.Gray32f{Y: 123.5})
+
+	filename := filepath.Join(dir, "dem.asc")
+	if err := image_ext.Save(filename, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	m2, format, err := image_ext.Load(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "asc" || m2.Bounds() != m.Bounds() {
+		t.Fatalf("bad image: format = %v, bounds = %v", format, m2.Bounds())
+	}
+	if v := m2.(*image_ext.Gray32f).Gray32fAt(4, 6).Y; v != 123.5 {
+		t.Fatalf("bad value: %v", v)
+	}
+}
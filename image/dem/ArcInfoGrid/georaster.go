@@ -0,0 +1,39 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arcinfogrid
+
+import (
+	"io"
+
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+func demDecode(r io.Reader) (m *dem.GeoRaster, err error) {
+	gray, geo, err := DecodeGeoref(r)
+	if err != nil {
+		return
+	}
+	y0 := geo.YllCorner + float64(gray.Bounds().Dy())*geo.CellSize
+	m = dem.NewGeoRaster(gray, geo.XllCorner, y0, geo.CellSize, geo.CellSize)
+	m.NoData, m.HasNoData = geo.NoDataValue, true
+	return
+}
+
+func demEncode(w io.Writer, m *dem.GeoRaster) (err error) {
+	cellSize, err := m.CellSize()
+	if err != nil {
+		return
+	}
+	geo := &Georef{
+		XllCorner:   m.Transform[0],
+		YllCorner:   m.Transform[3] - float64(m.Image.Bounds().Dy())*cellSize,
+		CellSize:    cellSize,
+		NoDataValue: defaultNoDataValue,
+	}
+	if m.HasNoData {
+		geo.NoDataValue = m.NoData
+	}
+	return Encode(w, m.Image, &Options{Georef: geo})
+}
@@ -0,0 +1,211 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arcinfogrid
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/convert"
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// DecodeConfig returns the color model and dimensions of an ASCII Grid
+// image without decoding the entire image.
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	hdr, _, err := readHeader(newWordScanner(r))
+	if err != nil {
+		return
+	}
+	config = image.Config{ColorModel: color_ext.Gray32fModel, Width: hdr.NCols, Height: hdr.NRows}
+	return
+}
+
+// Decode reads an ASCII Grid image from r and returns it as a
+// *image.Gray32f (or converted to opt.ColorModel). The NODATA cells keep
+// the NODATA_value. If opt is not nil, opt.Georef is set to the
+// georeference of the grid.
+func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
+	gray, geo, err := DecodeGeoref(r)
+	if err != nil {
+		return
+	}
+	m = gray
+	if opt != nil {
+		opt.Georef = geo
+		if opt.ColorModel != nil {
+			m = convert.ColorModel(m, opt.ColorModel)
+		}
+	}
+	return
+}
+
+// DecodeGeoref reads an ASCII Grid image and its georeference from r.
+func DecodeGeoref(r io.Reader) (m *image_ext.Gray32f, geo *Georef, err error) {
+	s := newWordScanner(r)
+	hdr, word, err := readHeader(s)
+	if err != nil {
+		return
+	}
+
+	if err = dem.CheckSize(hdr.NCols, hdr.NRows, 4); err != nil {
+		err = fmt.Errorf("image/dem/ArcInfoGrid: Decode, %v", err)
+		return
+	}
+	m = image_ext.NewGray32f(image.Rect(0, 0, hdr.NCols, hdr.NRows))
+	for y := 0; y < hdr.NRows; y++ {
+		for x := 0; x < hdr.NCols; x++ {
+			if x != 0 || y != 0 {
+				if !s.Scan() {
+					err = fmt.Errorf("image/dem/ArcInfoGrid: Decode, missing value at (%d, %d), err = %v", x, y, s.Err())
+					return
+				}
+				word = s.Text()
+			}
+			var v float64
+			if v, err = strconv.ParseFloat(word, 32); err != nil {
+				err = fmt.Errorf("image/dem/ArcInfoGrid: Decode, bad value at (%d, %d): %q", x, y, word)
+				return
+			}
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(v)})
+		}
+	}
+
+	geo = new(Georef)
+	*geo = hdr.Georef
+	return
+}
+
+func newWordScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+	return s
+}
+
+// readHeader reads the header keys, and returns the first value of the
+// grid data.
+func readHeader(s *bufio.Scanner) (hdr header, first string, err error) {
+	var hasNCols, hasNRows, hasX, hasY, hasCellSize bool
+	var xCenter, yCenter bool
+
+	hdr.NoDataValue = defaultNoDataValue
+	for {
+		if !s.Scan() {
+			err = fmt.Errorf("image/dem/ArcInfoGrid: missing grid data, err = %v", s.Err())
+			return
+		}
+		word := s.Text()
+		if c := word[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+			first = word
+			break
+		}
+
+		key := strings.ToLower(word)
+		if !s.Scan() {
+			err = fmt.Errorf("image/dem/ArcInfoGrid: missing value of %q", word)
+			return
+		}
+		value := s.Text()
+
+		var v float64
+		if v, err = strconv.ParseFloat(value, 64); err != nil {
+			err = fmt.Errorf("image/dem/ArcInfoGrid: bad value of %q: %q", word, value)
+			return
+		}
+		switch key {
+		case "ncols":
+			hdr.NCols, hasNCols = int(v), true
+		case "nrows":
+			hdr.NRows, hasNRows = int(v), true
+		case "xllcorner":
+			hdr.XllCorner, hasX, xCenter = v, true, false
+		case "xllcenter":
+			hdr.XllCorner, hasX, xCenter = v, true, true
+		case "yllcorner":
+			hdr.YllCorner, hasY, yCenter = v, true, false
+		case "yllcenter":
+			hdr.YllCorner, hasY, yCenter = v, true, true
+		case "cellsize":
+			hdr.CellSize, hasCellSize = v, true
+		case "nodata_value":
+			hdr.NoDataValue = v
+		default:
+			err = fmt.Errorf("image/dem/ArcInfoGrid: unknown header key %q", word)
+			return
+		}
+	}
+
+	switch {
+	case !hasNCols:
+		err = fmt.Errorf("image/dem/ArcInfoGrid: missing ncols")
+	case !hasNRows:
+		err = fmt.Errorf("image/dem/ArcInfoGrid: missing nrows")
+	case !hasX:
+		err = fmt.Errorf("image/dem/ArcInfoGrid: missing xllcorner")
+	case !hasY:
+		err = fmt.Errorf("image/dem/ArcInfoGrid: missing yllcorner")
+	case !hasCellSize:
+		err = fmt.Errorf("image/dem/ArcInfoGrid: missing cellsize")
+	}
+	if err != nil {
+		return
+	}
+	if xCenter {
+		hdr.XllCorner -= hdr.CellSize / 2
+	}
+	if yCenter {
+		hdr.YllCorner -= hdr.CellSize / 2
+	}
+	err = hdr.check()
+	return
+}
+
+func imageDecode(r io.Reader) (image.Image, error) {
+	return Decode(r, nil)
+}
+
+func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
+	if opt, ok := opt.(*Options); ok {
+		return Decode(r, opt)
+	} else {
+		return Decode(r, nil)
+	}
+}
+
+func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
+	if opt, ok := opt.(*Options); ok {
+		return Encode(w, m, opt)
+	} else {
+		return Encode(w, m, nil)
+	}
+}
+
+func init() {
+	image.RegisterFormat("asc", "ncols", imageDecode, DecodeConfig)
+	image.RegisterFormat("asc", "NCOLS", imageDecode, DecodeConfig)
+
+	image_ext.RegisterFormat(image_ext.Format{
+		Name:         "asc",
+		Extensions:   []string{".asc"},
+		Magics:       []string{"ncols", "NCOLS", "Ncols"},
+		DecodeConfig: DecodeConfig,
+		Decode:       imageExtDecode,
+		Encode:       imageExtEncode,
+	})
+
+	dem.RegisterFormat(dem.Format{
+		Name:       "asc",
+		Extensions: []string{".asc"},
+		Magics:     []string{"ncols", "NCOLS", "Ncols"},
+		Decode:     demDecode,
+		Encode:     demEncode,
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arcinfogrid
+
+import (
+	"bufio"
+	"image"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+// Encode writes the image m to w as an ASCII Grid. The pixels are
+// converted to Gray32f, the NaN pixels are written as NODATA_value.
+func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
+	if opt != nil && opt.ColorModel != nil {
+		m = convert.ColorModel(m, opt.ColorModel)
+	}
+	gray := convert.Gray32f(m)
+	b := gray.Bounds()
+
+	hdr := header{
+		NCols: b.Dx(),
+		NRows: b.Dy(),
+		Georef: Georef{
+			CellSize:    1,
+			NoDataValue: defaultNoDataValue,
+		},
+	}
+	var useCenter bool
+	prec := -1
+	if opt != nil {
+		if opt.Georef != nil {
+			hdr.Georef = *opt.Georef
+		}
+		useCenter = opt.UseCenter
+		if opt.Precision > 0 {
+			prec = opt.Precision
+		}
+	}
+	if err = hdr.check(); err != nil {
+		return
+	}
+
+	bw := bufio.NewWriter(w)
+	writeKey := func(key string, v float64) {
+		bw.WriteString(key)
+		bw.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		bw.WriteByte('\n')
+	}
+	writeKey("ncols         ", float64(hdr.NCols))
+	writeKey("nrows         ", float64(hdr.NRows))
+	if useCenter {
+		writeKey("xllcenter     ", hdr.XllCorner+hdr.CellSize/2)
+		writeKey("yllcenter     ", hdr.YllCorner+hdr.CellSize/2)
+	} else {
+		writeKey("xllcorner     ", hdr.XllCorner)
+		writeKey("yllcorner     ", hdr.YllCorner)
+	}
+	writeKey("cellsize      ", hdr.CellSize)
+	writeKey("NODATA_value  ", hdr.NoDataValue)
+
+	var buf []byte
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := float64(gray.Gray32fAt(x, y).Y)
+			if math.IsNaN(v) {
+				v = hdr.NoDataValue
+			}
+			if x > b.Min.X {
+				bw.WriteByte(' ')
+			}
+			if prec >= 0 && v != hdr.NoDataValue {
+				buf = strconv.AppendFloat(buf[:0], v, 'f', prec, 32)
+			} else {
+				buf = strconv.AppendFloat(buf[:0], v, 'f', -1, 32)
+			}
+			bw.Write(buf)
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
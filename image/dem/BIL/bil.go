@@ -0,0 +1,343 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bil implements a decoder and encoder for the ESRI BIL/BIP/BSQ
+// band interleaved rasters.
+//
+// The raw pixels are stored in a .bil/.bip/.bsq file, the layout is
+// described by a .hdr text file with the same base name:
+//
+//	BYTEORDER      I
+//	LAYOUT         BIL
+//	NROWS          3601
+//	NCOLS          3601
+//	NBANDS         1
+//	NBITS          16
+//	PIXELTYPE      SIGNEDINT
+//	ULXMAP         100.0
+//	ULYMAP         40.0
+//	XDIM           0.000277777777778
+//	YDIM           0.000277777777778
+//	NODATA         -32768
+//
+// The decoded image type depends on the bands and the pixel type:
+//
+//	1 band,  8 bits unsigned  -> *image.Gray
+//	1 band,  16 bits unsigned -> *image.Gray16
+//	1 band,  others           -> *image_ext.Gray32f
+//	3 bands, 8 bits unsigned  -> *image_ext.RGB
+//	3 bands, 16 bits unsigned -> *image_ext.RGB48
+//	3 bands, others           -> *image_ext.RGB96f
+//
+// The signed integers are decoded as float values, so the SRTM heights
+// (16 bits signed) are returned as *image_ext.Gray32f.
+package bil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// Layout is the band interleaving of the pixels.
+type Layout int
+
+const (
+	Layout_BIL Layout = iota // band interleaved by line
+	Layout_BIP               // band interleaved by pixel
+	Layout_BSQ               // band sequential
+)
+
+func (p Layout) String() string {
+	switch p {
+	case Layout_BIL:
+		return "BIL"
+	case Layout_BIP:
+		return "BIP"
+	case Layout_BSQ:
+		return "BSQ"
+	}
+	return fmt.Sprintf("Layout(%d)", int(p))
+}
+
+// PixelType is the number type of the samples.
+type PixelType int
+
+const (
+	PixelType_UnsignedInt PixelType = iota
+	PixelType_SignedInt
+	PixelType_Float
+)
+
+func (p PixelType) String() string {
+	switch p {
+	case PixelType_UnsignedInt:
+		return "UNSIGNEDINT"
+	case PixelType_SignedInt:
+		return "SIGNEDINT"
+	case PixelType_Float:
+		return "FLOAT"
+	}
+	return fmt.Sprintf("PixelType(%d)", int(p))
+}
+
+// Header is the content of the .hdr file.
+type Header struct {
+	NRows, NCols  int
+	NBands        int
+	NBits         int
+	PixelType     PixelType
+	ByteOrder     binary.ByteOrder
+	Layout        Layout
+	SkipBytes     int
+	BandRowBytes  int // 0 means the default (NCols*NBits/8)
+	TotalRowBytes int // 0 means the default
+	BandGapBytes  int
+
+	ULXMap, ULYMap float64 // center of the upper left pixel
+	XDim, YDim     float64 // pixel size
+	NoData         float64
+	HasNoData      bool
+}
+
+func (p *Header) check() error {
+	if p.NRows <= 0 || p.NCols <= 0 || p.NBands <= 0 {
+		return fmt.Errorf("image/dem/BIL: bad size, nrows = %d, ncols = %d, nbands = %d", p.NRows, p.NCols, p.NBands)
+	}
+	switch {
+	case p.PixelType == PixelType_Float && (p.NBits == 32 || p.NBits == 64):
+	case p.PixelType != PixelType_Float && (p.NBits == 8 || p.NBits == 16 || p.NBits == 32):
+	default:
+		return fmt.Errorf("image/dem/BIL: unsupported pixel type, %v with %d bits", p.PixelType, p.NBits)
+	}
+	if p.NBands > dem.MaxPixelsSize {
+		return fmt.Errorf("image/dem/BIL: bad size, nbands = %d", p.NBands)
+	}
+	if err := dem.CheckSize(p.NCols, p.NRows, p.NBands*p.NBits/8); err != nil {
+		return fmt.Errorf("image/dem/BIL: %v", err)
+	}
+	if p.Layout < Layout_BIL || p.Layout > Layout_BSQ {
+		return fmt.Errorf("image/dem/BIL: bad layout, %v", p.Layout)
+	}
+	// the offsets are bounded, so the data size does not overflow
+	if p.SkipBytes < 0 || p.SkipBytes > dem.MaxPixelsSize {
+		return fmt.Errorf("image/dem/BIL: bad SKIPBYTES, %d", p.SkipBytes)
+	}
+	if p.BandGapBytes < 0 || p.BandGapBytes > dem.MaxPixelsSize {
+		return fmt.Errorf("image/dem/BIL: bad BANDGAPBYTES, %d", p.BandGapBytes)
+	}
+	if p.BandRowBytes < 0 || p.BandRowBytes > dem.MaxPixelsSize || p.BandRowBytes != 0 && p.BandRowBytes < p.NCols*p.NBits/8 {
+		return fmt.Errorf("image/dem/BIL: bad BANDROWBYTES, %d", p.BandRowBytes)
+	}
+	if p.TotalRowBytes < 0 || p.TotalRowBytes > dem.MaxPixelsSize || p.TotalRowBytes != 0 && p.TotalRowBytes < p.minTotalRowBytes() {
+		return fmt.Errorf("image/dem/BIL: bad TOTALROWBYTES, %d", p.TotalRowBytes)
+	}
+	return nil
+}
+
+func (p *Header) bandRowBytes() int {
+	if p.BandRowBytes > 0 {
+		return p.BandRowBytes
+	}
+	return p.NCols * p.NBits / 8
+}
+
+func (p *Header) totalRowBytes() int {
+	if p.TotalRowBytes > 0 {
+		return p.TotalRowBytes
+	}
+	return p.minTotalRowBytes()
+}
+
+// minTotalRowBytes returns the bytes of the samples of a row of all the
+// bands, it is the default TOTALROWBYTES.
+func (p *Header) minTotalRowBytes() int {
+	switch p.Layout {
+	case Layout_BIL:
+		return p.NBands * p.bandRowBytes()
+	case Layout_BIP:
+		return p.NCols * p.NBands * p.NBits / 8
+	}
+	return p.bandRowBytes()
+}
+
+// sampleOffset returns the offset of the sample in the data file.
+func (p *Header) sampleOffset(x, y, band int) int {
+	n := p.NBits / 8
+	switch p.Layout {
+	case Layout_BIL:
+		return p.SkipBytes + y*p.totalRowBytes() + band*p.bandRowBytes() + x*n
+	case Layout_BIP:
+		return p.SkipBytes + y*p.totalRowBytes() + (x*p.NBands+band)*n
+	}
+	bandBytes := p.NRows*p.bandRowBytes() + p.BandGapBytes
+	return p.SkipBytes + band*bandBytes + y*p.bandRowBytes() + x*n
+}
+
+// dataSize returns the minimal size of the data file.
+func (p *Header) dataSize() int {
+	return p.sampleOffset(p.NCols-1, p.NRows-1, p.NBands-1) + p.NBits/8
+}
+
+// ReadHeader reads a .hdr file. The keys are case insensitive, the
+// unknown keys are ignored.
+func ReadHeader(r io.Reader) (hdr *Header, err error) {
+	hdr = &Header{
+		NBands:    1,
+		NBits:     8,
+		ByteOrder: binary.LittleEndian,
+		XDim:      1,
+		YDim:      1,
+	}
+	var hasNRows, hasNCols, hasPixelType bool
+
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			err = fmt.Errorf("image/dem/BIL: ReadHeader, line %d: missing value", lineno)
+			return
+		}
+		key, value := strings.ToUpper(fields[0]), strings.ToUpper(fields[1])
+
+		switch key {
+		case "BYTEORDER":
+			switch value {
+			case "I":
+				hdr.ByteOrder = binary.LittleEndian
+			case "M":
+				hdr.ByteOrder = binary.BigEndian
+			default:
+				err = fmt.Errorf("image/dem/BIL: ReadHeader, line %d: bad BYTEORDER %q", lineno, fields[1])
+			}
+		case "LAYOUT", "INTERLEAVING":
+			switch value {
+			case "BIL":
+				hdr.Layout = Layout_BIL
+			case "BIP":
+				hdr.Layout = Layout_BIP
+			case "BSQ":
+				hdr.Layout = Layout_BSQ
+			default:
+				err = fmt.Errorf("image/dem/BIL: ReadHeader, line %d: bad LAYOUT %q", lineno, fields[1])
+			}
+		case "PIXELTYPE":
+			switch value {
+			case "UNSIGNEDINT":
+				hdr.PixelType = PixelType_UnsignedInt
+			case "SIGNEDINT":
+				hdr.PixelType = PixelType_SignedInt
+			case "FLOAT":
+				hdr.PixelType = PixelType_Float
+			default:
+				err = fmt.Errorf("image/dem/BIL: ReadHeader, line %d: bad PIXELTYPE %q", lineno, fields[1])
+			}
+			hasPixelType = true
+		case "NROWS":
+			hdr.NRows, err = strconv.Atoi(value)
+			hasNRows = true
+		case "NCOLS":
+			hdr.NCols, err = strconv.Atoi(value)
+			hasNCols = true
+		case "NBANDS":
+			hdr.NBands, err = strconv.Atoi(value)
+		case "NBITS":
+			hdr.NBits, err = strconv.Atoi(value)
+		case "SKIPBYTES":
+			hdr.SkipBytes, err = strconv.Atoi(value)
+		case "BANDROWBYTES":
+			hdr.BandRowBytes, err = strconv.Atoi(value)
+		case "TOTALROWBYTES":
+			hdr.TotalRowBytes, err = strconv.Atoi(value)
+		case "BANDGAPBYTES":
+			hdr.BandGapBytes, err = strconv.Atoi(value)
+		case "ULXMAP":
+			hdr.ULXMap, err = strconv.ParseFloat(value, 64)
+		case "ULYMAP":
+			hdr.ULYMap, err = strconv.ParseFloat(value, 64)
+		case "XDIM":
+			hdr.XDim, err = strconv.ParseFloat(value, 64)
+		case "YDIM":
+			hdr.YDim, err = strconv.ParseFloat(value, 64)
+		case "NODATA", "NODATA_VALUE":
+			hdr.NoData, err = strconv.ParseFloat(value, 64)
+			hdr.HasNoData = true
+		}
+		if err != nil {
+			if _, ok := err.(*strconv.NumError); ok {
+				err = fmt.Errorf("image/dem/BIL: ReadHeader, line %d: bad %s %q", lineno, key, fields[1])
+			}
+			return
+		}
+	}
+	if err = s.Err(); err != nil {
+		return
+	}
+	if !hasNRows || !hasNCols {
+		err = fmt.Errorf("image/dem/BIL: ReadHeader, missing NROWS or NCOLS")
+		return
+	}
+	if !hasPixelType && hdr.NBits == 32 {
+		// the old ESRI headers have no PIXELTYPE, 32 bits means float
+		hdr.PixelType = PixelType_Float
+	}
+	err = hdr.check()
+	return
+}
+
+// WriteHeader writes the header as a .hdr file.
+func WriteHeader(w io.Writer, hdr *Header) (err error) {
+	if err = hdr.check(); err != nil {
+		return
+	}
+	byteOrder := "I"
+	if hdr.ByteOrder == binary.BigEndian {
+		byteOrder = "M"
+	}
+	ff := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	lines := [][2]string{
+		{"BYTEORDER", byteOrder},
+		{"LAYOUT", hdr.Layout.String()},
+		{"NROWS", strconv.Itoa(hdr.NRows)},
+		{"NCOLS", strconv.Itoa(hdr.NCols)},
+		{"NBANDS", strconv.Itoa(hdr.NBands)},
+		{"NBITS", strconv.Itoa(hdr.NBits)},
+		{"PIXELTYPE", hdr.PixelType.String()},
+		{"BANDROWBYTES", strconv.Itoa(hdr.bandRowBytes())},
+		{"TOTALROWBYTES", strconv.Itoa(hdr.totalRowBytes())},
+	}
+	if hdr.SkipBytes != 0 {
+		lines = append(lines, [2]string{"SKIPBYTES", strconv.Itoa(hdr.SkipBytes)})
+	}
+	if hdr.BandGapBytes != 0 {
+		lines = append(lines, [2]string{"BANDGAPBYTES", strconv.Itoa(hdr.BandGapBytes)})
+	}
+	lines = append(lines,
+		[2]string{"ULXMAP", ff(hdr.ULXMap)},
+		[2]string{"ULYMAP", ff(hdr.ULYMap)},
+		[2]string{"XDIM", ff(hdr.XDim)},
+		[2]string{"YDIM", ff(hdr.YDim)},
+	)
+	if hdr.HasNoData {
+		lines = append(lines, [2]string{"NODATA", ff(hdr.NoData)})
+	}
+
+	for _, line := range lines {
+		if _, err = fmt.Fprintf(w, "%-14s %s\n", line[0], line[1]); err != nil {
+			return
+		}
+	}
+	return
+}
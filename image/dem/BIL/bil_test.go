@@ -0,0 +1,153 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func TestDecode_srtm(t *testing.T) {
+	hdr, err := ReadHeader(strings.NewReader(`
+byteorder M
+layout bil
+nrows 2
+ncols 3
+nbands 1
+nbits 16
+pixeltype signedint
+ulxmap 100.0
+ulymap 40.0
+xdim 0.5
+ydim 0.25
+nodata -32768
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.ByteOrder != binary.BigEndian || hdr.NoData != -32768 || !hdr.HasNoData || hdr.YDim != 0.25 {
+		t.Fatalf("bad header: %+v", hdr)
+	}
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.BigEndian, []int16{-10, 0, 10, 1000, -32768, 8848})
+	m, err := Decode(&data, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray, ok := m.(*image_ext.Gray32f)
+	if !ok {
+		t.Fatalf("bad image type: %T", m)
+	}
+	for i, v := range []float32{-10, 0, 10, 1000, -32768, 8848} {
+		if got := gray.Gray32fAt(i%3, i/3).Y; got != v {
+			t.Fatalf("(%d,%d): expect = %v, got = %v", i%3, i/3, v, got)
+		}
+	}
+}
+
+func TestDecode_bsqGap(t *testing.T) {
+	hdr := &Header{
+		NRows: 1, NCols: 2, NBands: 3, NBits: 8,
+		ByteOrder:    binary.LittleEndian,
+		Layout:       Layout_BSQ,
+		SkipBytes:    1,
+		BandGapBytes: 2,
+	}
+	data := []byte{0xFF, 1, 2, 0, 0, 3, 4, 0, 0, 5, 6}
+	m, err := Decode(bytes.NewReader(data), hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgb := m.(*image_ext.RGB)
+	if c := rgb.RGBAt(1, 0); c != (color_ext.RGB{R: 2, G: 4, B: 6}) {
+		t.Fatalf("bad color: %v", c)
+	}
+}
+
+func TestDecode_badHeader(t *testing.T) {
+	for _, text := range []string{
+		"nrows 2\nncols 3\nskipbytes -8\n",
+		"nrows 2\nncols 3\nnbands 3\nlayout bsq\nbandgapbytes -8\n",
+		"nrows 2\nncols 3\ntotalrowbytes -8\n",
+		"nrows 2\nncols 3\nnbands 3\ntotalrowbytes 4\n",
+		"nrows 2000000000\nncols 2000000000\n",
+		"nrows 2\nncols 3\nskipbytes 100000000000\n",
+	} {
+		hdr, err := ReadHeader(strings.NewReader(text))
+		if err != nil {
+			continue
+		}
+		if _, err = Decode(bytes.NewReader(make([]byte, 64)), hdr); err == nil {
+			t.Fatalf("%q: expect an error", text)
+		}
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := image.Rect(0, 0, 5, 4)
+	images := []image_ext.ImageBuffer{
+		image.NewGray(r),
+		image.NewGray16(r),
+		image_ext.NewGray32f(r),
+		image_ext.NewRGB(r),
+		image_ext.NewRGB48(r),
+		image_ext.NewRGB96f(r),
+	}
+	for _, m := range images {
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				v := uint16(y*1000 + x*100 + 7)
+				m.Set(x, y, color.RGBA64{v, v / 2, v / 3, 0xFFFF})
+			}
+		}
+	}
+
+	opt := &Options{ULXMap: 100, ULYMap: 40, XDim: 0.5, YDim: 0.5}
+	for _, layout := range []Layout{Layout_BIL, Layout_BIP, Layout_BSQ} {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			opt.ByteOrder = order // the layout is chosen by the extension
+			for i, m := range images {
+				filename := filepath.Join(dir, "test."+strings.ToLower(layout.String()))
+				if err := Save(filename, m, opt); err != nil {
+					t.Fatal(err)
+				}
+				m2, hdr, err := Load(filename)
+				if err != nil {
+					t.Fatalf("%v/%v/%d: %v", layout, order, i, err)
+				}
+				if hdr.Layout != layout || hdr.ByteOrder != order || hdr.ULXMap != 100 || hdr.XDim != 0.5 {
+					t.Fatalf("%v/%v/%d: bad header: %+v", layout, order, i, hdr)
+				}
+				if m2.ColorModel() != m.ColorModel() || m2.Bounds() != r {
+					t.Fatalf("%v/%v/%d: bad image: %T", layout, order, i, m2)
+				}
+				for y := 0; y < r.Dy(); y++ {
+					for x := 0; x < r.Dx(); x++ {
+						if c0, c1 := m.At(x, y), m2.At(x, y); c0 != c1 {
+							t.Fatalf("%v/%v/%d: (%d,%d): expect = %v, got = %v", layout, order, i, x, y, c0, c1)
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bil
+
+import (
+	"fmt"
+
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+func demLoad(filename string) (m *dem.GeoRaster, err error) {
+	img, hdr, err := Load(filename)
+	if err != nil {
+		return
+	}
+	// ULXMAP/ULYMAP are the center of the upper left pixel
+	m = dem.NewGeoRaster(img, hdr.ULXMap-hdr.XDim/2, hdr.ULYMap+hdr.YDim/2, hdr.XDim, hdr.YDim)
+	m.NoData, m.HasNoData = hdr.NoData, hdr.HasNoData
+	return
+}
+
+func demSave(filename string, m *dem.GeoRaster) (err error) {
+	t := m.Transform
+	if !t.IsNorthUp() {
+		err = fmt.Errorf("image/dem/BIL: Save, rotated raster: %v", t)
+		return
+	}
+	cx, cy := t.PixelToMap(0.5, 0.5)
+	return Save(filename, m.Image, &Options{
+		ULXMap:    cx,
+		ULYMap:    cy,
+		XDim:      t[1],
+		YDim:      -t[5],
+		NoData:    m.NoData,
+		HasNoData: m.HasNoData,
+	})
+}
+
+func init() {
+	dem.RegisterFormat(dem.Format{
+		Name:       "bil",
+		Extensions: []string{".bil", ".bip", ".bsq"},
+		Load:       demLoad,
+		Save:       demSave,
+	})
+}
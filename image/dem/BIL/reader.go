@@ -0,0 +1,163 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bil
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// HeaderName returns the name of the .hdr file of the data file.
+func HeaderName(filename string) string {
+	return filename[:len(filename)-len(filepath.Ext(filename))] + ".hdr"
+}
+
+// Load reads the data file and its .hdr file.
+func Load(filename string) (m image.Image, hdr *Header, err error) {
+	fh, err := os.Open(HeaderName(filename))
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	if hdr, err = ReadHeader(fh); err != nil {
+		return
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	m, err = Decode(f, hdr)
+	return
+}
+
+// DecodeConfig returns the color model and dimensions of the image
+// described by hdr.
+func DecodeConfig(hdr *Header) (config image.Config, err error) {
+	if err = hdr.check(); err != nil {
+		return
+	}
+	m := newImage(hdr, image.Rect(0, 0, 1, 1))
+	if m == nil {
+		err = fmt.Errorf("image/dem/BIL: DecodeConfig, unsupported bands: %d", hdr.NBands)
+		return
+	}
+	config = image.Config{ColorModel: m.ColorModel(), Width: hdr.NCols, Height: hdr.NRows}
+	return
+}
+
+// Decode reads the pixels described by hdr from r.
+func Decode(r io.Reader, hdr *Header) (m image.Image, err error) {
+	if err = hdr.check(); err != nil {
+		return
+	}
+	buf := newImage(hdr, image.Rect(0, 0, hdr.NCols, hdr.NRows))
+	if buf == nil {
+		err = fmt.Errorf("image/dem/BIL: Decode, unsupported bands: %d", hdr.NBands)
+		return
+	}
+
+	if hdr.dataSize() > dem.MaxPixelsSize {
+		err = fmt.Errorf("image/dem/BIL: Decode, data too large: %d bytes", hdr.dataSize())
+		return
+	}
+	data := make([]byte, hdr.dataSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		err = fmt.Errorf("image/dem/BIL: Decode, read data failed, err = %v", err)
+		return
+	}
+
+	var v [3]float64
+	for y := 0; y < hdr.NRows; y++ {
+		for x := 0; x < hdr.NCols; x++ {
+			for i := 0; i < hdr.NBands; i++ {
+				v[i] = readSample(hdr, data[hdr.sampleOffset(x, y, i):])
+			}
+			switch buf := buf.(type) {
+			case *image.Gray:
+				buf.Pix[y*buf.Stride+x] = uint8(v[0])
+			case *image.Gray16:
+				buf.Pix[y*buf.Stride+x*2+0] = uint8(uint16(v[0]) >> 8)
+				buf.Pix[y*buf.Stride+x*2+1] = uint8(uint16(v[0]))
+			case *image_ext.Gray32f:
+				buf.SetGray32f(x, y, color_ext.Gray32f{Y: float32(v[0])})
+			case *image_ext.RGB:
+				buf.SetRGB(x, y, color_ext.RGB{R: uint8(v[0]), G: uint8(v[1]), B: uint8(v[2])})
+			case *image_ext.RGB48:
+				buf.SetRGB48(x, y, color_ext.RGB48{R: uint16(v[0]), G: uint16(v[1]), B: uint16(v[2])})
+			case *image_ext.RGB96f:
+				buf.SetRGB96f(x, y, color_ext.RGB96f{R: float32(v[0]), G: float32(v[1]), B: float32(v[2])})
+			}
+		}
+	}
+	m = buf
+	return
+}
+
+// newImage returns the image type for hdr, or nil if the number of
+// bands is not supported.
+func newImage(hdr *Header, r image.Rectangle) image_ext.ImageBuffer {
+	unsigned := hdr.PixelType == PixelType_UnsignedInt
+	switch hdr.NBands {
+	case 1:
+		switch {
+		case unsigned && hdr.NBits == 8:
+			return image.NewGray(r)
+		case unsigned && hdr.NBits == 16:
+			return image.NewGray16(r)
+		}
+		return image_ext.NewGray32f(r)
+	case 3:
+		switch {
+		case unsigned && hdr.NBits == 8:
+			return image_ext.NewRGB(r)
+		case unsigned && hdr.NBits == 16:
+			return image_ext.NewRGB48(r)
+		}
+		return image_ext.NewRGB96f(r)
+	}
+	return nil
+}
+
+func readSample(hdr *Header, b []byte) float64 {
+	order := hdr.ByteOrder
+	switch hdr.PixelType {
+	case PixelType_UnsignedInt:
+		switch hdr.NBits {
+		case 8:
+			return float64(b[0])
+		case 16:
+			return float64(order.Uint16(b))
+		case 32:
+			return float64(order.Uint32(b))
+		}
+	case PixelType_SignedInt:
+		switch hdr.NBits {
+		case 8:
+			return float64(int8(b[0]))
+		case 16:
+			return float64(int16(order.Uint16(b)))
+		case 32:
+			return float64(int32(order.Uint32(b)))
+		}
+	case PixelType_Float:
+		switch hdr.NBits {
+		case 32:
+			return float64(math.Float32frombits(order.Uint32(b)))
+		case 64:
+			return math.Float64frombits(order.Uint64(b))
+		}
+	}
+	return 0
+}
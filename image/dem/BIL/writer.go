@@ -0,0 +1,174 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bil
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+// Options are the encoding parameters.
+type Options struct {
+	Layout    Layout           // default BIL
+	ByteOrder binary.ByteOrder // default little endian
+
+	ULXMap, ULYMap float64 // center of the upper left pixel
+	XDim, YDim     float64 // pixel size, default 1
+	NoData         float64
+	HasNoData      bool
+}
+
+// Save writes the image to the data file and its .hdr file. The layout
+// is chosen by the extension (.bil, .bip or .bsq), opt.Layout is used for
+// the other extensions.
+func Save(filename string, m image.Image, opt *Options) (err error) {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".bil":
+		o.Layout = Layout_BIL
+	case ".bip":
+		o.Layout = Layout_BIP
+	case ".bsq":
+		o.Layout = Layout_BSQ
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	hdr, err := Encode(f, m, &o)
+	if err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+
+	fh, err := os.Create(HeaderName(filename))
+	if err != nil {
+		return
+	}
+	if err = WriteHeader(fh, hdr); err != nil {
+		fh.Close()
+		return
+	}
+	return fh.Close()
+}
+
+// Encode writes the pixels of m to w, and returns the header of the data.
+//
+// The Gray, Gray16, RGB and RGB48 images are written as unsigned integers,
+// the Gray32f and RGB96f images as 32 bits float. The other images are
+// converted to RGB.
+func Encode(w io.Writer, m image.Image, opt *Options) (hdr *Header, err error) {
+	switch m.(type) {
+	case *image.Gray, *image.Gray16, *image_ext.Gray32f:
+	case *image_ext.RGB, *image_ext.RGB48, *image_ext.RGB96f:
+	default:
+		m = convert.RGB(m)
+	}
+	b := m.Bounds()
+
+	hdr = &Header{
+		NRows:     b.Dy(),
+		NCols:     b.Dx(),
+		NBands:    1,
+		ByteOrder: binary.LittleEndian,
+		XDim:      1,
+		YDim:      1,
+	}
+	if opt != nil {
+		hdr.Layout = opt.Layout
+		if opt.ByteOrder != nil {
+			hdr.ByteOrder = opt.ByteOrder
+		}
+		hdr.ULXMap, hdr.ULYMap = opt.ULXMap, opt.ULYMap
+		if opt.XDim != 0 && opt.YDim != 0 {
+			hdr.XDim, hdr.YDim = opt.XDim, opt.YDim
+		}
+		hdr.NoData, hdr.HasNoData = opt.NoData, opt.HasNoData
+	}
+	switch m.(type) {
+	case *image.Gray:
+		hdr.NBits, hdr.PixelType = 8, PixelType_UnsignedInt
+	case *image.Gray16:
+		hdr.NBits, hdr.PixelType = 16, PixelType_UnsignedInt
+	case *image_ext.Gray32f:
+		hdr.NBits, hdr.PixelType = 32, PixelType_Float
+	case *image_ext.RGB:
+		hdr.NBands, hdr.NBits, hdr.PixelType = 3, 8, PixelType_UnsignedInt
+	case *image_ext.RGB48:
+		hdr.NBands, hdr.NBits, hdr.PixelType = 3, 16, PixelType_UnsignedInt
+	case *image_ext.RGB96f:
+		hdr.NBands, hdr.NBits, hdr.PixelType = 3, 32, PixelType_Float
+	}
+	if err = hdr.check(); err != nil {
+		return
+	}
+
+	data := make([]byte, hdr.dataSize())
+	var v [3]float64
+	for y := 0; y < hdr.NRows; y++ {
+		for x := 0; x < hdr.NCols; x++ {
+			switch m := m.(type) {
+			case *image.Gray:
+				v[0] = float64(m.GrayAt(b.Min.X+x, b.Min.Y+y).Y)
+			case *image.Gray16:
+				v[0] = float64(m.Gray16At(b.Min.X+x, b.Min.Y+y).Y)
+			case *image_ext.Gray32f:
+				v[0] = float64(m.Gray32fAt(b.Min.X+x, b.Min.Y+y).Y)
+			case *image_ext.RGB:
+				c := m.RGBAt(b.Min.X+x, b.Min.Y+y)
+				v[0], v[1], v[2] = float64(c.R), float64(c.G), float64(c.B)
+			case *image_ext.RGB48:
+				c := m.RGB48At(b.Min.X+x, b.Min.Y+y)
+				v[0], v[1], v[2] = float64(c.R), float64(c.G), float64(c.B)
+			case *image_ext.RGB96f:
+				c := m.RGB96fAt(b.Min.X+x, b.Min.Y+y)
+				v[0], v[1], v[2] = float64(c.R), float64(c.G), float64(c.B)
+			}
+			for i := 0; i < hdr.NBands; i++ {
+				writeSample(hdr, data[hdr.sampleOffset(x, y, i):], v[i])
+			}
+		}
+	}
+
+	_, err = w.Write(data)
+	return
+}
+
+func writeSample(hdr *Header, b []byte, v float64) {
+	order := hdr.ByteOrder
+	switch hdr.PixelType {
+	case PixelType_UnsignedInt, PixelType_SignedInt:
+		switch hdr.NBits {
+		case 8:
+			b[0] = uint8(int64(v))
+		case 16:
+			order.PutUint16(b, uint16(int64(v)))
+		case 32:
+			order.PutUint32(b, uint32(int64(v)))
+		}
+	case PixelType_Float:
+		switch hdr.NBits {
+		case 32:
+			order.PutUint32(b, math.Float32bits(float32(v)))
+		case 64:
+			order.PutUint64(b, math.Float64bits(v))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cnsdtf implements a decoder and encoder for the CNSDTF-DEM
+// format, the binary variant of NSDTF-DEM.
+//
+// The file starts with the 12 text header lines of NSDTF-DEM (see
+// image/dem/NSDTF) with the DataMark CNSDTF-DEM. The heights follow the
+// header as Row*Col little endian int32 raw values (the real height is
+// value/HZoom), row by row from the north. If Compress is 1, the heights
+// are compressed with zlib.
+//
+// The NODATA heights are -99999.
+package cnsdtf
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/convert"
+	"github.com/chai2010/gopkg/image/dem"
+	nsdtf "github.com/chai2010/gopkg/image/dem/NSDTF"
+)
+
+const (
+	DataMark    = "CNSDTF-DEM"
+	NoDataValue = nsdtf.NoDataValue
+)
+
+// Header is the header of a CNSDTF-DEM file.
+type Header nsdtf.Header
+
+// Options are the encoding and decoding parameters.
+type Options struct {
+	ColorModel color.Model
+
+	// Header is set by Decode, and is used by Encode (Row, Col and
+	// DataMark are set from the image).
+	Header *Header
+
+	// NoCompress makes Encode write the heights without zlib.
+	NoCompress bool
+}
+
+// DecodeConfig returns the color model and dimensions of a CNSDTF-DEM
+// image without decoding the entire image.
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	hdr, err := nsdtf.ReadHeader(bufio.NewReader(r))
+	if err != nil {
+		return
+	}
+	config = image.Config{ColorModel: color_ext.Gray32fModel, Width: hdr.Col, Height: hdr.Row}
+	return
+}
+
+// Decode reads a CNSDTF-DEM image from r and returns the heights as a
+// *image.Gray32f (or converted to opt.ColorModel). If opt is not nil,
+// opt.Header is set to the header of the file.
+func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
+	br := bufio.NewReader(r)
+	hdr, err := nsdtf.ReadHeader(br)
+	if err != nil {
+		return
+	}
+
+	var data io.Reader = br
+	switch hdr.Compress {
+	case 0:
+	case 1:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(br); err != nil {
+			err = fmt.Errorf("image/dem/CNSDTF: Decode, zlib err: %v", err)
+			return
+		}
+		defer zr.Close()
+		data = zr
+	default:
+		err = fmt.Errorf("image/dem/CNSDTF: Decode, unsupported compress %d", hdr.Compress)
+		return
+	}
+
+	if err = dem.CheckSize(hdr.Col, hdr.Row, 4); err != nil {
+		err = fmt.Errorf("image/dem/CNSDTF: Decode, %v", err)
+		return
+	}
+	gray := image_ext.NewGray32f(image.Rect(0, 0, hdr.Col, hdr.Row))
+	row := make([]byte, hdr.Col*4)
+	for y := 0; y < hdr.Row; y++ {
+		if _, err = io.ReadFull(data, row); err != nil {
+			err = fmt.Errorf("image/dem/CNSDTF: Decode, read row %d failed, err = %v", y, err)
+			return
+		}
+		for x := 0; x < hdr.Col; x++ {
+			v := int32(binary.LittleEndian.Uint32(row[x*4:]))
+			gray.SetGray32f(x, y, color_ext.Gray32f{Y: hdr.Height(float64(v))})
+		}
+	}
+
+	m = gray
+	if opt != nil {
+		opt.Header = (*Header)(hdr)
+		if opt.ColorModel != nil {
+			m = convert.ColorModel(m, opt.ColorModel)
+		}
+	}
+	return
+}
+
+// Encode writes the image m to w as a CNSDTF-DEM file. The pixels are
+// converted to Gray32f heights. If opt.Header is nil, the default header
+// of nsdtf.NewHeader is used. It returns an error if a raw value
+// (height*HZoom) does not fit in an int32.
+func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
+	if opt != nil && opt.ColorModel != nil {
+		m = convert.ColorModel(m, opt.ColorModel)
+	}
+	gray := convert.Gray32f(m)
+	b := gray.Bounds()
+
+	hdr := nsdtf.NewHeader(b.Dx(), b.Dy())
+	if opt != nil && opt.Header != nil {
+		*hdr = nsdtf.Header(*opt.Header)
+		hdr.Col, hdr.Row = b.Dx(), b.Dy()
+	}
+	hdr.DataMark, hdr.Compress = DataMark, 1
+	if opt != nil && opt.NoCompress {
+		hdr.Compress = 0
+	}
+
+	bw := bufio.NewWriter(w)
+	if err = nsdtf.WriteHeader(bw, hdr); err != nil {
+		return
+	}
+
+	var data io.Writer = bw
+	var zw *zlib.Writer
+	if hdr.Compress == 1 {
+		zw = zlib.NewWriter(bw)
+		data = zw
+	}
+	row := make([]byte, hdr.Col*4)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := hdr.RawValue(gray.Gray32fAt(x, y).Y)
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return fmt.Errorf("image/dem/CNSDTF: Encode, height at (%d, %d) out of the int32 raw values", x, y)
+			}
+			binary.LittleEndian.PutUint32(row[(x-b.Min.X)*4:], uint32(int32(v)))
+		}
+		if _, err = data.Write(row); err != nil {
+			return
+		}
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return
+		}
+	}
+	return bw.Flush()
+}
+
+func imageDecode(r io.Reader) (image.Image, error) {
+	return Decode(r, nil)
+}
+
+func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
+	if opt, ok := opt.(*Options); ok {
+		return Decode(r, opt)
+	} else {
+		return Decode(r, nil)
+	}
+}
+
+func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
+	if opt, ok := opt.(*Options); ok {
+		return Encode(w, m, opt)
+	} else {
+		return Encode(w, m, nil)
+	}
+}
+
+func init() {
+	image.RegisterFormat("cnsdtf", DataMark, imageDecode, DecodeConfig)
+
+	image_ext.RegisterFormat(image_ext.Format{
+		Name:         "cnsdtf",
+		Extensions:   []string{".cdem"},
+		Magics:       []string{DataMark},
+		DecodeConfig: DecodeConfig,
+		Decode:       imageExtDecode,
+		Encode:       imageExtEncode,
+	})
+
+	dem.RegisterFormat(dem.Format{
+		Name:       "cnsdtf",
+		Extensions: []string{".cdem"},
+		Magics:     []string{DataMark},
+		Decode:     demDecode,
+		Encode:     demEncode,
+	})
+}
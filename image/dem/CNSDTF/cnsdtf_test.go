@@ -0,0 +1,86 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cnsdtf
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	nsdtf "github.com/chai2010/gopkg/image/dem/NSDTF"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 31, 17))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 31; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*31+x)*0.25 - 50})
+		}
+	}
+	m.SetGray32f(5, 7, color_ext.Gray32f{Y: float32(math.NaN())})
+
+	for _, noCompress := range []bool{false, true} {
+		hdr := &Header{Version: "1.0", Unit: "M", X0: 10, Y0: 20, DX: 5, DY: 5, HZoom: 100}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, &Options{Header: hdr, NoCompress: noCompress}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte(DataMark+"\n")) {
+			t.Fatalf("bad data mark: %q", buf.Bytes()[:16])
+		}
+
+		opt := &Options{}
+		m2, format, err := image_ext.Decode(&buf, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "cnsdtf" {
+			t.Fatalf("bad format: %v", format)
+		}
+		if h := opt.Header; h.Row != 17 || h.Col != 31 || h.X0 != 10 || h.DX != 5 || h.HZoom != 100 {
+			t.Fatalf("bad header: %+v", h)
+		}
+		if noCompress != (opt.Header.Compress == 0) {
+			t.Fatalf("bad compress: %v", opt.Header.Compress)
+		}
+		gray := m2.(*image_ext.Gray32f)
+		for y := 0; y < 17; y++ {
+			for x := 0; x < 31; x++ {
+				expect := float32(y*31+x)*0.25 - 50
+				if x == 5 && y == 7 {
+					expect = NoDataValue
+				}
+				if v := gray.Gray32fAt(x, y).Y; v != expect {
+					t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, expect, v)
+				}
+			}
+		}
+	}
+}
+
+func TestDecode_tooLarge(t *testing.T) {
+	hdr := &Header{DataMark: DataMark, Version: "1.0", Unit: "M", DX: 1, DY: 1, HZoom: 1, Row: 2000000000, Col: 3000000000}
+	var buf bytes.Buffer
+	if err := nsdtf.WriteHeader(&buf, (*nsdtf.Header)(hdr)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(&buf, nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestEncode_badRawValue(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 2, 2))
+	m.SetGray32f(1, 1, color_ext.Gray32f{Y: 1e8})
+	hdr := &Header{Version: "1.0", Unit: "M", DX: 1, DY: 1, HZoom: 100}
+	if err := Encode(ioutil.Discard, m, &Options{Header: hdr}); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
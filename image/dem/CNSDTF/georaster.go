@@ -0,0 +1,33 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cnsdtf
+
+import (
+	"io"
+
+	"github.com/chai2010/gopkg/image/dem"
+	nsdtf "github.com/chai2010/gopkg/image/dem/NSDTF"
+)
+
+// HZoom of the rasters encoded by the image/dem registry.
+const demHZoom = 100
+
+func demDecode(r io.Reader) (m *dem.GeoRaster, err error) {
+	opt := &Options{}
+	gray, err := Decode(r, opt)
+	if err != nil {
+		return
+	}
+	m = (*nsdtf.Header)(opt.Header).GeoRaster(gray)
+	return
+}
+
+func demEncode(w io.Writer, m *dem.GeoRaster) (err error) {
+	hdr, err := nsdtf.HeaderOf(m, demHZoom)
+	if err != nil {
+		return
+	}
+	return Encode(w, m.WithNoData(NoDataValue).Image, &Options{Header: (*Header)(hdr)})
+}
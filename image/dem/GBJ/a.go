@@ -4,4 +4,5 @@
 
 // +build ingore
 
+// The GBJ format is not implemented yet.
 package foo
@@ -4,4 +4,5 @@
 
 // +build ingore
 
+// The ImageInfo format is not implemented yet.
 package foo
@@ -0,0 +1,58 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nsdtf
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// HZoom of the rasters encoded by the image/dem registry (centimeters for
+// the heights in meters).
+const demHZoom = 100
+
+// GeoRaster returns the GeoRaster of the heights m with the georeference
+// of the header. The rotation (Alpha) is ignored.
+func (p *Header) GeoRaster(m image.Image) *dem.GeoRaster {
+	g := dem.NewGeoRaster(m, p.X0, p.Y0, p.DX, p.DY)
+	g.NoData, g.HasNoData = NoDataValue, true
+	return g
+}
+
+// HeaderOf returns the header of a north-up GeoRaster.
+func HeaderOf(m *dem.GeoRaster, hzoom float64) (hdr *Header, err error) {
+	t := m.Transform
+	if !t.IsNorthUp() {
+		err = fmt.Errorf("image/dem/NSDTF: HeaderOf, rotated raster: %v", t)
+		return
+	}
+	b := m.Image.Bounds()
+	hdr = NewHeader(b.Dx(), b.Dy())
+	hdr.X0, hdr.Y0 = t[0], t[3]
+	hdr.DX, hdr.DY = t[1], -t[5]
+	hdr.HZoom = hzoom
+	return
+}
+
+func demDecode(r io.Reader) (m *dem.GeoRaster, err error) {
+	opt := &Options{}
+	gray, err := Decode(r, opt)
+	if err != nil {
+		return
+	}
+	m = opt.Header.GeoRaster(gray)
+	return
+}
+
+func demEncode(w io.Writer, m *dem.GeoRaster) (err error) {
+	hdr, err := HeaderOf(m, demHZoom)
+	if err != nil {
+		return
+	}
+	return Encode(w, m.WithNoData(NoDataValue).Image, &Options{Header: hdr})
+}
@@ -0,0 +1,198 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nsdtf implements a decoder and encoder for the NSDTF-DEM text
+// format (the Chinese national spatial data transfer format, GB/T 17798).
+//
+// The file is a header of one value per line, followed by the heights
+// (integers, the real height is value/HZoom) row by row from the north:
+//
+//	NSDTF-DEM     DataMark
+//	1.0           Version
+//	M             Unit (M: meter, D: degree)
+//	0.0           Alpha (rotation angle)
+//	0             Compress
+//	500000.0      X0 (x of the upper left corner)
+//	4000000.0     Y0 (y of the upper left corner)
+//	25.0          DX
+//	25.0          DY
+//	400           Row
+//	500           Col
+//	100           HZoom
+//	12345 12350 ...
+//
+// The header values may be prefixed with their names ("DX: 25.0").
+// The NODATA heights are -99999.
+package nsdtf
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	DataMark    = "NSDTF-DEM"
+	NoDataValue = -99999
+)
+
+// Header is the header of a NSDTF-DEM file.
+type Header struct {
+	DataMark string
+	Version  string
+	Unit     string
+	Alpha    float64
+	Compress int
+	X0, Y0   float64 // upper left corner
+	DX, DY   float64 // cell size
+	Row, Col int
+	HZoom    float64 // scale of the heights
+}
+
+// Options are the encoding and decoding parameters.
+type Options struct {
+	ColorModel color.Model
+
+	// Header is set by Decode, and is used by Encode (Row, Col and
+	// DataMark are set from the image).
+	Header *Header
+}
+
+// NewHeader returns the header of a w x h grid with the default values
+// (version 1.0, meter unit, cell size 1, HZoom 1).
+func NewHeader(w, h int) *Header {
+	return &Header{
+		DataMark: DataMark,
+		Version:  "1.0",
+		Unit:     "M",
+		DX:       1,
+		DY:       1,
+		Row:      h,
+		Col:      w,
+		HZoom:    1,
+	}
+}
+
+func (p *Header) check() error {
+	if p.Row <= 0 || p.Col <= 0 {
+		return fmt.Errorf("image/dem/NSDTF: bad size, row = %v, col = %v", p.Row, p.Col)
+	}
+	if !(p.DX > 0) || !(p.DY > 0) {
+		return fmt.Errorf("image/dem/NSDTF: bad cell size, dx = %v, dy = %v", p.DX, p.DY)
+	}
+	if !(p.HZoom > 0) {
+		return fmt.Errorf("image/dem/NSDTF: bad HZoom, %v", p.HZoom)
+	}
+	return nil
+}
+
+// Height returns the height of the raw value v.
+func (p *Header) Height(v float64) float32 {
+	if v == NoDataValue {
+		return NoDataValue
+	}
+	return float32(v / p.HZoom)
+}
+
+// RawValue returns the raw value of the height h.
+// NaN is written as NODATA.
+func (p *Header) RawValue(h float32) int64 {
+	if h == NoDataValue || math.IsNaN(float64(h)) {
+		return NoDataValue
+	}
+	return int64(math.Floor(float64(h)*p.HZoom + 0.5))
+}
+
+// ReadHeader reads the 12 header lines from r.
+func ReadHeader(r *bufio.Reader) (hdr *Header, err error) {
+	var values [12]string
+	for i := 0; i < len(values); {
+		var line string
+		if line, err = r.ReadString('\n'); err != nil && (err != io.EOF || line == "") {
+			err = fmt.Errorf("image/dem/NSDTF: ReadHeader, missing header line %d", i+1)
+			return
+		}
+		err = nil
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			line = line[idx+1:]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			values[i] = fields[0]
+			i++
+		}
+	}
+
+	hdr = &Header{
+		DataMark: values[0],
+		Version:  values[1],
+		Unit:     values[2],
+	}
+	if !strings.HasSuffix(strings.ToUpper(hdr.DataMark), "-DEM") {
+		err = fmt.Errorf("image/dem/NSDTF: ReadHeader, bad DataMark %q", hdr.DataMark)
+		return
+	}
+
+	parseFloat := func(i int, v *float64) {
+		if err == nil {
+			if *v, err = strconv.ParseFloat(values[i], 64); err != nil {
+				err = fmt.Errorf("image/dem/NSDTF: ReadHeader, bad value at line %d: %q", i+1, values[i])
+			}
+		}
+	}
+	parseInt := func(i int, v *int) {
+		if err == nil {
+			if *v, err = strconv.Atoi(values[i]); err != nil {
+				err = fmt.Errorf("image/dem/NSDTF: ReadHeader, bad value at line %d: %q", i+1, values[i])
+			}
+		}
+	}
+	parseFloat(3, &hdr.Alpha)
+	parseInt(4, &hdr.Compress)
+	parseFloat(5, &hdr.X0)
+	parseFloat(6, &hdr.Y0)
+	parseFloat(7, &hdr.DX)
+	parseFloat(8, &hdr.DY)
+	parseInt(9, &hdr.Row)
+	parseInt(10, &hdr.Col)
+	parseFloat(11, &hdr.HZoom)
+	if err != nil {
+		return
+	}
+	err = hdr.check()
+	return
+}
+
+// WriteHeader writes the 12 header lines to w.
+func WriteHeader(w io.Writer, hdr *Header) (err error) {
+	if err = hdr.check(); err != nil {
+		return
+	}
+	ff := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	lines := []string{
+		hdr.DataMark,
+		hdr.Version,
+		hdr.Unit,
+		ff(hdr.Alpha),
+		strconv.Itoa(hdr.Compress),
+		ff(hdr.X0),
+		ff(hdr.Y0),
+		ff(hdr.DX),
+		ff(hdr.DY),
+		strconv.Itoa(hdr.Row),
+		strconv.Itoa(hdr.Col),
+		ff(hdr.HZoom),
+	}
+	for _, s := range lines {
+		if _, err = io.WriteString(w, s+"\n"); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nsdtf
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"strings"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+const tDem = `NSDTF-DEM
+1.0
+M
+0.000000
+0
+X0: 500000.0
+Y0: 4000000.0
+DX: 25.0
+DY: 25.0
+Row: 2
+Col: 3
+HZoom: 100
+123456 123500 -99999
+100 0 -250
+`
+
+func TestDecode(t *testing.T) {
+	cfg, _, err := image.DecodeConfig(strings.NewReader(tDem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 3 || cfg.Height != 2 {
+		t.Fatalf("bad config: %v", cfg)
+	}
+
+	opt := &Options{}
+	m, err := Decode(strings.NewReader(tDem), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := opt.Header
+	if hdr.X0 != 500000 || hdr.Y0 != 4000000 || hdr.DX != 25 || hdr.HZoom != 100 || hdr.Unit != "M" {
+		t.Fatalf("bad header: %+v", hdr)
+	}
+	gray := m.(*image_ext.Gray32f)
+	for i, v := range []float32{1234.56, 1235, NoDataValue, 1, 0, -2.5} {
+		if got := gray.Gray32fAt(i%3, i/3).Y; got != v {
+			t.Fatalf("(%d,%d): expect = %v, got = %v", i%3, i/3, v, got)
+		}
+	}
+}
+
+func TestDecode_tooLarge(t *testing.T) {
+	s := strings.Replace(tDem, "Row: 2\nCol: 3", "Row: 2000000000\nCol: 3000000000", 1)
+	if _, err := Decode(strings.NewReader(s), nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 23, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 23; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*23+x) * 0.5})
+		}
+	}
+	m.SetGray32f(3, 3, color_ext.Gray32f{Y: float32(math.NaN())})
+
+	hdr := NewHeader(0, 0)
+	hdr.X0, hdr.Y0, hdr.DX, hdr.DY, hdr.HZoom = 100, 200, 2, 2, 10
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, &Options{Header: hdr}); err != nil {
+		t.Fatal(err)
+	}
+	opt := &Options{}
+	m2, _, err := image_ext.Decode(&buf, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Header.Col != 23 || opt.Header.Row != 5 || opt.Header.X0 != 100 || opt.Header.HZoom != 10 {
+		t.Fatalf("bad header: %+v", opt.Header)
+	}
+	gray := m2.(*image_ext.Gray32f)
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 23; x++ {
+			expect := float32(y*23+x) * 0.5
+			if x == 3 && y == 3 {
+				expect = NoDataValue
+			}
+			if v := gray.Gray32fAt(x, y).Y; v != expect {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, expect, v)
+			}
+		}
+	}
+}
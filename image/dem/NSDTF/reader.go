@@ -0,0 +1,124 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nsdtf
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/convert"
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// DecodeConfig returns the color model and dimensions of a NSDTF-DEM
+// image without decoding the entire image.
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	hdr, err := ReadHeader(bufio.NewReader(r))
+	if err != nil {
+		return
+	}
+	config = image.Config{ColorModel: color_ext.Gray32fModel, Width: hdr.Col, Height: hdr.Row}
+	return
+}
+
+// Decode reads a NSDTF-DEM image from r and returns the heights as a
+// *image.Gray32f (or converted to opt.ColorModel). If opt is not nil,
+// opt.Header is set to the header of the file.
+func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
+	br := bufio.NewReader(r)
+	hdr, err := ReadHeader(br)
+	if err != nil {
+		return
+	}
+	if hdr.Compress != 0 {
+		err = fmt.Errorf("image/dem/NSDTF: Decode, unsupported compress %d (see image/dem/CNSDTF)", hdr.Compress)
+		return
+	}
+	gray, err := DecodeData(br, hdr)
+	if err != nil {
+		return
+	}
+	m = gray
+	if opt != nil {
+		opt.Header = hdr
+		if opt.ColorModel != nil {
+			m = convert.ColorModel(m, opt.ColorModel)
+		}
+	}
+	return
+}
+
+// DecodeData reads the text heights following the header from r.
+func DecodeData(r io.Reader, hdr *Header) (m *image_ext.Gray32f, err error) {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+
+	if err = dem.CheckSize(hdr.Col, hdr.Row, 4); err != nil {
+		err = fmt.Errorf("image/dem/NSDTF: Decode, %v", err)
+		return
+	}
+	m = image_ext.NewGray32f(image.Rect(0, 0, hdr.Col, hdr.Row))
+	for y := 0; y < hdr.Row; y++ {
+		for x := 0; x < hdr.Col; x++ {
+			if !s.Scan() {
+				err = fmt.Errorf("image/dem/NSDTF: Decode, missing value at (%d, %d), err = %v", x, y, s.Err())
+				return
+			}
+			var v float64
+			if v, err = strconv.ParseFloat(s.Text(), 64); err != nil {
+				err = fmt.Errorf("image/dem/NSDTF: Decode, bad value at (%d, %d): %q", x, y, s.Text())
+				return
+			}
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: hdr.Height(v)})
+		}
+	}
+	return
+}
+
+func imageDecode(r io.Reader) (image.Image, error) {
+	return Decode(r, nil)
+}
+
+func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
+	if opt, ok := opt.(*Options); ok {
+		return Decode(r, opt)
+	} else {
+		return Decode(r, nil)
+	}
+}
+
+func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
+	if opt, ok := opt.(*Options); ok {
+		return Encode(w, m, opt)
+	} else {
+		return Encode(w, m, nil)
+	}
+}
+
+func init() {
+	image.RegisterFormat("nsdtf", DataMark, imageDecode, DecodeConfig)
+
+	image_ext.RegisterFormat(image_ext.Format{
+		Name:         "nsdtf",
+		Extensions:   []string{".dem"},
+		Magics:       []string{DataMark},
+		DecodeConfig: DecodeConfig,
+		Decode:       imageExtDecode,
+		Encode:       imageExtEncode,
+	})
+
+	dem.RegisterFormat(dem.Format{
+		Name:       "nsdtf",
+		Extensions: []string{".dem"},
+		Magics:     []string{DataMark},
+		Decode:     demDecode,
+		Encode:     demEncode,
+	})
+}
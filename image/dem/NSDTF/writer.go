@@ -0,0 +1,61 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nsdtf
+
+import (
+	"bufio"
+	"image"
+	"io"
+	"strconv"
+
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+// valuesPerLine is the number of heights written per line.
+const valuesPerLine = 10
+
+// Encode writes the image m to w as a NSDTF-DEM file. The pixels are
+// converted to Gray32f heights. If opt.Header is nil, the default header
+// of NewHeader is used.
+func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
+	if opt != nil && opt.ColorModel != nil {
+		m = convert.ColorModel(m, opt.ColorModel)
+	}
+	gray := convert.Gray32f(m)
+	b := gray.Bounds()
+
+	hdr := NewHeader(b.Dx(), b.Dy())
+	if opt != nil && opt.Header != nil {
+		*hdr = *opt.Header
+		hdr.DataMark, hdr.Compress = DataMark, 0
+		hdr.Col, hdr.Row = b.Dx(), b.Dy()
+	}
+
+	bw := bufio.NewWriter(w)
+	if err = WriteHeader(bw, hdr); err != nil {
+		return
+	}
+	EncodeData(bw, gray, hdr)
+	return bw.Flush()
+}
+
+// EncodeData writes the heights of m as text to w.
+func EncodeData(w *bufio.Writer, m image.Image, hdr *Header) {
+	gray := convert.Gray32f(m)
+	b := gray.Bounds()
+
+	var buf []byte
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			buf = strconv.AppendInt(buf[:0], hdr.RawValue(gray.Gray32fAt(x, y).Y), 10)
+			if n := x - b.Min.X + 1; n%valuesPerLine == 0 || x == b.Max.X-1 {
+				buf = append(buf, '\n')
+			} else {
+				buf = append(buf, ' ')
+			}
+			w.Write(buf)
+		}
+	}
+}
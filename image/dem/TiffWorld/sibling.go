@@ -0,0 +1,103 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiffworld
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// WorldFileName returns the short world file name of the image
+// (a.tif -> a.tfw). The case of the extension is kept.
+func WorldFileName(imageName string) string {
+	ext := filepath.Ext(imageName)
+	base := imageName[:len(imageName)-len(ext)]
+	if len(ext) < 3 {
+		return imageName + "w"
+	}
+	w := "w"
+	if c := ext[len(ext)-1]; c >= 'A' && c <= 'Z' {
+		w = "W"
+	}
+	return base + ext[:2] + ext[len(ext)-1:] + w
+}
+
+// worldFileNames returns the names to look for the world file of the
+// image, the short name first.
+func worldFileNames(imageName string) []string {
+	return []string{
+		WorldFileName(imageName),
+		imageName + "w",
+	}
+}
+
+// LoadSibling reads the world file next to the image. If no world file
+// is found, it returns nil and the error of os.Open.
+func LoadSibling(imageName string) (wf *WorldFile, err error) {
+	for _, name := range worldFileNames(imageName) {
+		if wf, err = ReadFile(name); err == nil || !os.IsNotExist(err) {
+			return
+		}
+	}
+	return
+}
+
+// SaveSibling writes the short world file next to the image.
+func SaveSibling(imageName string, wf *WorldFile) error {
+	return WriteFile(WorldFileName(imageName), wf)
+}
+
+// LoadImage reads an image of a registered format with image.Load, and
+// its world file if there is one (wf is nil otherwise). A world file which
+// can not be read is skipped like a missing one.
+func LoadImage(filename string, opt interface{}) (m image.Image, format string, wf *WorldFile, err error) {
+	var data map[string]interface{}
+	if m, format, data, err = image_ext.LoadWithSidecars(filename, opt); err != nil {
+		return
+	}
+	wf, _ = data[SidecarName].(*WorldFile)
+	return
+}
+
+// SaveImage writes an image with image.Save, and its world file if wf is
+// not nil.
+func SaveImage(filename string, m image.Image, opt interface{}, wf *WorldFile) (err error) {
+	var data map[string]interface{}
+	if wf != nil {
+		data = map[string]interface{}{SidecarName: wf}
+	}
+	return image_ext.SaveWithSidecars(filename, m, opt, data)
+}
+
+// SidecarName is the name of the world file in the sidecar data of
+// image.LoadWithSidecars and image.SaveWithSidecars.
+const SidecarName = "worldfile"
+
+func init() {
+	image_ext.RegisterSidecar(image_ext.Sidecar{
+		Name: SidecarName,
+		Load: func(filename string) (data interface{}, err error) {
+			wf, err := LoadSibling(filename)
+			if err != nil {
+				if os.IsNotExist(err) {
+					err = nil
+				}
+				return
+			}
+			return wf, nil
+		},
+		Save: func(filename string, data interface{}) error {
+			wf, ok := data.(*WorldFile)
+			if !ok {
+				return fmt.Errorf("image/dem/TiffWorld: bad sidecar data: %T", data)
+			}
+			return SaveSibling(filename, wf)
+		},
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package tiffworld reads and writes the world files (.tfw, .jgw, .pgw,
+// ...) which georeference an image with an affine transform.
+//
+// The world file has six lines:
+//
+//	A  pixel size in the x direction
+//	D  rotation about the y axis
+//	B  rotation about the x axis
+//	E  pixel size in the y direction (negative)
+//	C  x of the center of the upper left pixel
+//	F  y of the center of the upper left pixel
+//
+// The map coordinates of the pixel (x, y) are:
+//
+//	X = A*x + B*y + C
+//	Y = D*x + E*y + F
+//
+// The world file of an image is a sibling file, named by the first and
+// the last letters of the image extension followed by 'w' (a.tif -> a.tfw,
+// a.jpg -> a.jgw, a.png -> a.pgw), or by the image extension followed by
+// 'w' (a.tif -> a.tifw).
+//
+// The package registers the world file as an image sidecar: it is read by
+// image.LoadWithSidecars and written by image.SaveWithSidecars, so the
+// georeferencing is kept when an image is encoded again.
+package tiffworld
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WorldFile is the affine transform of a world file.
+type WorldFile struct {
+	A, D, B, E, C, F float64
+}
+
+// NewWorldFile returns the world file of a north-up image, (x0, y0) is
+// the center of the upper left pixel.
+func NewWorldFile(x0, y0, xSize, ySize float64) *WorldFile {
+	return &WorldFile{A: xSize, E: -ySize, C: x0, F: y0}
+}
+
+// PixelToMap returns the map coordinates of the pixel (x, y).
+// The integer pixel coordinates are the pixel centers.
+func (p *WorldFile) PixelToMap(x, y float64) (mx, my float64) {
+	mx = p.A*x + p.B*y + p.C
+	my = p.D*x + p.E*y + p.F
+	return
+}
+
+// MapToPixel returns the pixel coordinates of the map coordinates
+// (mx, my). ok is false if the transform is not invertible.
+func (p *WorldFile) MapToPixel(mx, my float64) (x, y float64, ok bool) {
+	det := p.A*p.E - p.B*p.D
+	if det == 0 {
+		return
+	}
+	dx, dy := mx-p.C, my-p.F
+	x = (p.E*dx - p.B*dy) / det
+	y = (p.A*dy - p.D*dx) / det
+	ok = true
+	return
+}
+
+// Read reads the six lines of a world file.
+func Read(r io.Reader) (wf *WorldFile, err error) {
+	var v [6]float64
+	s := bufio.NewScanner(r)
+	for i := 0; i < len(v); {
+		if !s.Scan() {
+			err = fmt.Errorf("image/dem/TiffWorld: Read, missing line %d, err = %v", i+1, s.Err())
+			return
+		}
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if v[i], err = strconv.ParseFloat(line, 64); err != nil {
+			err = fmt.Errorf("image/dem/TiffWorld: Read, bad line %d: %q", i+1, line)
+			return
+		}
+		i++
+	}
+	wf = &WorldFile{A: v[0], D: v[1], B: v[2], E: v[3], C: v[4], F: v[5]}
+	return
+}
+
+// Write writes the six lines of a world file.
+func Write(w io.Writer, wf *WorldFile) (err error) {
+	for _, v := range []float64{wf.A, wf.D, wf.B, wf.E, wf.C, wf.F} {
+		if _, err = io.WriteString(w, strconv.FormatFloat(v, 'f', -1, 64)+"\n"); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ReadFile reads the named world file.
+func ReadFile(filename string) (wf *WorldFile, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// WriteFile writes the named world file.
+func WriteFile(filename string, wf *WorldFile) (err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return Write(f, wf)
+}
@@ -0,0 +1,155 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiffworld
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	_ "github.com/chai2010/gopkg/image/png"
+)
+
+func TestWorldFile(t *testing.T) {
+	wf := &WorldFile{A: 2, D: 0.5, B: -0.25, E: -2, C: 1000, F: 5000}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, wf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "2\n0.5\n-0.25\n-2\n1000\n5000\n" {
+		t.Fatalf("bad world file: %q", s)
+	}
+	wf2, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *wf2 != *wf {
+		t.Fatalf("expect = %v, got = %v", *wf, *wf2)
+	}
+
+	for _, pt := range [][2]float64{{0, 0}, {10, 20}, {-3.5, 7.25}} {
+		mx, my := wf.PixelToMap(pt[0], pt[1])
+		x, y, ok := wf.MapToPixel(mx, my)
+		if !ok || math.Abs(x-pt[0]) > 1e-9 || math.Abs(y-pt[1]) > 1e-9 {
+			t.Fatalf("%v: bad pixel: %v, %v", pt, x, y)
+		}
+	}
+	if mx, my := NewWorldFile(100, 200, 10, 10).PixelToMap(1, 1); mx != 110 || my != 190 {
+		t.Fatalf("bad map: %v, %v", mx, my)
+	}
+	if _, _, ok := (&WorldFile{A: 1, B: 1, D: 1, E: 1}).MapToPixel(0, 0); ok {
+		t.Fatal("expect not invertible")
+	}
+}
+
+func TestWorldFileName(t *testing.T) {
+	tests := [][2]string{
+		{"a.tif", "a.tfw"},
+		{"dir/a.tiff", "dir/a.tfw"},
+		{"a.jpg", "a.jgw"},
+		{"a.PNG", "a.PGW"},
+		{"a", "aw"},
+	}
+	for _, tt := range tests {
+		if s := WorldFileName(tt[0]); s != tt[1] {
+			t.Fatalf("%s: expect = %s, got = %s", tt[0], tt[1], s)
+		}
+	}
+}
+
+func TestLoadSaveImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiffworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := image.NewGray(image.Rect(0, 0, 4, 3))
+	filename := filepath.Join(dir, "a.png")
+
+	// no world file
+	if err := SaveImage(filename, m, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, wf, err := LoadImage(filename, nil); err != nil || wf != nil {
+		t.Fatalf("expect no world file: %v, %v", wf, err)
+	}
+
+	wf := NewWorldFile(500000, 4000000, 30, 30)
+	if err := SaveImage(filename, m, nil, wf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.pgw")); err != nil {
+		t.Fatal(err)
+	}
+	m2, format, wf2, err := LoadImage(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || m2.Bounds() != m.Bounds() || wf2 == nil || *wf2 != *wf {
+		t.Fatalf("bad image: %v, %v, %v", format, m2.Bounds(), wf2)
+	}
+
+	// the long name is found too
+	os.Rename(filepath.Join(dir, "a.pgw"), filepath.Join(dir, "a.pngw"))
+	if wf3, err := LoadSibling(filename); err != nil || *wf3 != *wf {
+		t.Fatalf("bad long world file: %v, %v", wf3, err)
+	}
+}
+
+func TestImageLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiffworld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wf := NewWorldFile(500000, 4000000, 30, 30)
+	if err := SaveImage(filepath.Join(dir, "a.png"), image.NewGray(image.Rect(0, 0, 4, 3)), nil, wf); err != nil {
+		t.Fatal(err)
+	}
+
+	// image.Load returns the image of the codec
+	m, _, err := image_ext.Load(filepath.Join(dir, "a.png"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*image.Gray); !ok {
+		t.Fatalf("bad image type: %T", m)
+	}
+
+	// the image is encoded again with its sidecars, the world file is kept
+	m, _, data, err := image_ext.LoadWithSidecars(filepath.Join(dir, "a.png"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*image.Gray); !ok {
+		t.Fatalf("bad image type: %T", m)
+	}
+	if err := image_ext.SaveWithSidecars(filepath.Join(dir, "b.png"), m, nil, data); err != nil {
+		t.Fatal(err)
+	}
+	wf2, err := ReadFile(filepath.Join(dir, "b.pgw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *wf2 != *wf {
+		t.Fatalf("expect = %v, got = %v", *wf, *wf2)
+	}
+
+	// a bad world file is skipped
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.pgw"), []byte("bad"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if m, _, wf3, err := LoadImage(filepath.Join(dir, "b.png"), nil); err != nil || m == nil || wf3 != nil {
+		t.Fatalf("bad world file is not skipped: %v, %v", wf3, err)
+	}
+}
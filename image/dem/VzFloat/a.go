@@ -4,4 +4,5 @@
 
 // +build ingore
 
+// The VzFloat format is not implemented yet.
 package foo
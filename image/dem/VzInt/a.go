@@ -4,4 +4,5 @@
 
 // +build ingore
 
+// The VzInt format is not implemented yet.
 package foo
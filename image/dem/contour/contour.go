@@ -0,0 +1,401 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package contour implements the contour lines generation from the elevation
+rasters, with the marching squares algorithm.
+
+The raster values are sampled at the pixel centers, and the lines are
+oriented with the higher side on their right (in the pixel coordinates,
+with y to the south).
+
+Generate the contours of a DEM every 10 meters and save them as shapefile:
+
+	m, _, err := dem.Load("n40e100.asc")
+	if err != nil {
+		log.Fatal(err)
+	}
+	lines, err := contour.Contours(m.Image.(*image_ext.Gray32f),
+		contour.Levels(0, 9000, 10),
+		&contour.Options{Transform: &m.Transform},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := contour.SaveShp("n40e100.shp", lines); err != nil {
+		log.Fatal(err)
+	}
+*/
+package contour
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	"github.com/chai2010/gopkg/image/dem"
+)
+
+// Point is a vertex of a contour line.
+type Point struct {
+	X, Y float64
+}
+
+// Line is a contour line. The first and the last points of a closed line
+// are equal.
+type Line struct {
+	Level  float64
+	Points []Point
+	Closed bool
+}
+
+// Options are the contour generation parameters.
+type Options struct {
+	// Transform maps the pixel coordinates to the map coordinates,
+	// nil keeps the pixel coordinates.
+	Transform *dem.GeoTransform
+
+	// The cells with a NoData corner are skipped.
+	NoData    float32
+	HasNoData bool
+}
+
+// Levels returns the multiples of interval in [min, max].
+func Levels(min, max, interval float64) (levels []float64) {
+	if !(interval > 0) {
+		panic(fmt.Sprintf("image/dem/contour: Levels, bad interval: %v", interval))
+	}
+	for i := math.Ceil(min / interval); i*interval <= max; i++ {
+		levels = append(levels, i*interval)
+	}
+	return
+}
+
+// Contours returns the contour lines of m at the levels.
+func Contours(m *image_ext.Gray32f, levels []float64, opt *Options) (lines []Line, err error) {
+	b := m.Bounds()
+	if b.Dx() < 2 || b.Dy() < 2 {
+		err = fmt.Errorf("image/dem/contour: Contours, image too small: %v", b)
+		return
+	}
+	t := newTracer(b, levels, opt)
+	t.AddRows(m, b.Min.Y)
+	lines = t.Lines()
+	return
+}
+
+// DemContours returns the contour lines of the last level of m at the
+// levels. The Dem is read by bands of the tile height, but all the lines
+// are returned: use WalkDemContours for the Dems with more lines than the
+// memory.
+func DemContours(m *big.Dem, levels []float64, opt *Options) (lines []Line, err error) {
+	b := m.Bounds()
+	if b.Dx() < 2 || b.Dy() < 2 {
+		err = fmt.Errorf("image/dem/contour: DemContours, image too small: %v", b)
+		return
+	}
+	t := newTracer(b, levels, opt)
+	if err = t.AddDem(m, nil); err != nil {
+		return
+	}
+	lines = t.Lines()
+	return
+}
+
+// WalkDemContours calls fn with the contour lines of the last level of m
+// at the levels: the closed lines as soon as they are complete, and the
+// open lines at the end. The Dem is read by bands of the tile height, and
+// only the open lines are kept in memory besides the band. It stops at the
+// first error of fn.
+//
+// The lines are written to a shapefile as they are found by:
+//
+//	err := contour.WalkDemContours(m, levels, nil, func(line *contour.Line) error {
+//		_, err := w.Write(line.ShpPolyLine(), line.Level)
+//		return err
+//	})
+func WalkDemContours(m *big.Dem, levels []float64, opt *Options, fn func(line *Line) error) (err error) {
+	b := m.Bounds()
+	if b.Dx() < 2 || b.Dy() < 2 {
+		err = fmt.Errorf("image/dem/contour: WalkDemContours, image too small: %v", b)
+		return
+	}
+	t := newTracer(b, levels, opt)
+	if err = t.AddDem(m, fn); err != nil {
+		return
+	}
+	lines := t.Lines()
+	for i := range lines {
+		if err = fn(&lines[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// edgeKey is a cell edge: the horizontal edge from the pixel (x, y) to
+// (x+1, y), or the vertical edge from (x, y) to (x, y+1).
+type edgeKey struct {
+	x, y     int
+	vertical bool
+}
+
+type tracer struct {
+	rect    image.Rectangle
+	opt     Options
+	joiners []*joiner
+}
+
+func newTracer(r image.Rectangle, levels []float64, opt *Options) *tracer {
+	t := &tracer{rect: r}
+	if opt != nil {
+		t.opt = *opt
+	}
+	for _, v := range levels {
+		t.joiners = append(t.joiners, newJoiner(v))
+	}
+	return t
+}
+
+// AddDem adds the cells of the last level of m, by bands of the tile
+// height. If fn is not nil, it is called with the closed lines after every
+// band, and they are dropped.
+func (p *tracer) AddDem(m *big.Dem, fn func(line *Line) error) (err error) {
+	b := m.Bounds()
+	for _, j := range p.joiners {
+		j.stream = fn != nil
+	}
+	for y0 := b.Min.Y; y0 < b.Max.Y-1; y0 += m.TileSize.Y {
+		// the band with the next row
+		r := image.Rect(b.Min.X, y0, b.Max.X, minInt(y0+m.TileSize.Y+1, b.Max.Y))
+		var band *image_ext.Gray32f
+		if band, err = m.ReadRect(-1, r, nil); err != nil {
+			return
+		}
+		// the buffer is at the origin
+		band.Rect = r
+		p.AddRows(band, y0)
+		if fn == nil {
+			continue
+		}
+		for _, j := range p.joiners {
+			for _, c := range j.done {
+				line := Line{Level: j.level, Points: c.Points(), Closed: true}
+				if err = fn(&line); err != nil {
+					return
+				}
+			}
+			j.Compact()
+		}
+	}
+	return
+}
+
+// AddRows adds the cells whose upper rows are in m, starting from the row
+// y0. The cells of the last row of m are added with the next band.
+func (p *tracer) AddRows(m *image_ext.Gray32f, y0 int) {
+	b := m.Bounds()
+	var v [4]float64
+	for y := y0; y < b.Max.Y-1; y++ {
+		for x := b.Min.X; x < b.Max.X-1; x++ {
+			// the corners in the clockwise order
+			corners := [4]image.Point{{x, y}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}}
+			skip := false
+			for i, pt := range corners {
+				z := m.Gray32fAt(pt.X, pt.Y).Y
+				if p.opt.HasNoData && z == p.opt.NoData || math.IsNaN(float64(z)) {
+					skip = true
+					break
+				}
+				v[i] = float64(z)
+			}
+			if skip {
+				continue
+			}
+			for _, j := range p.joiners {
+				p.addCell(j, x, y, &corners, &v)
+			}
+		}
+	}
+}
+
+var cellEdges = [4]struct {
+	dx, dy   int
+	vertical bool
+}{
+	{0, 0, false}, // top
+	{1, 0, true},  // right
+	{0, 1, false}, // bottom
+	{0, 0, true},  // left
+}
+
+// addCell adds the segments of the cell (x, y). The edge i of the cell is
+// from the corner i to the corner i+1, the segments are from an edge
+// going down to an edge going up, so the higher corners are on their right.
+func (p *tracer) addCell(j *joiner, x, y int, corners *[4]image.Point, v *[4]float64) {
+	var high [4]bool
+	n := 0
+	for i := 0; i < 4; i++ {
+		if high[i] = v[i] >= j.level; high[i] {
+			n++
+		}
+	}
+	if n == 0 || n == 4 {
+		return
+	}
+
+	saddle := high[0] == high[2] && high[1] == high[3]
+	centerHigh := (v[0]+v[1]+v[2]+v[3])/4 >= j.level
+	for i := 0; i < 4; i++ {
+		if !high[i] || high[(i+1)%4] {
+			continue
+		}
+		// the edge i goes down, find the edge going up
+		var k int
+		switch {
+		case saddle && centerHigh:
+			// the higher corners are connected
+			k = (i + 1) % 4
+		case saddle:
+			k = (i + 3) % 4
+		default:
+			for k = (i + 1) % 4; high[k] || !high[(k+1)%4]; k = (k + 1) % 4 {
+			}
+		}
+		j.Add(
+			p.edgeKey(x, y, i), p.edgePoint(j.level, corners, v, i),
+			p.edgeKey(x, y, k), p.edgePoint(j.level, corners, v, k),
+		)
+	}
+}
+
+func (p *tracer) edgeKey(x, y, i int) edgeKey {
+	e := cellEdges[i]
+	return edgeKey{x + e.dx, y + e.dy, e.vertical}
+}
+
+func (p *tracer) edgePoint(level float64, corners *[4]image.Point, v *[4]float64, i int) Point {
+	a, b := corners[i], corners[(i+1)%4]
+	t := (level - v[i]) / (v[(i+1)%4] - v[i])
+	x := float64(a.X) + t*float64(b.X-a.X) - float64(p.rect.Min.X) + 0.5
+	y := float64(a.Y) + t*float64(b.Y-a.Y) - float64(p.rect.Min.Y) + 0.5
+	if p.opt.Transform != nil {
+		x, y = p.opt.Transform.PixelToMap(x, y)
+	}
+	return Point{x, y}
+}
+
+// Lines returns the lines of all the levels.
+func (p *tracer) Lines() (lines []Line) {
+	for _, j := range p.joiners {
+		lines = append(lines, j.Lines()...)
+	}
+	return
+}
+
+// chain is a line being built, front is the reversed head of the line
+// and back is the tail.
+type chain struct {
+	front, back []Point
+	head, tail  edgeKey
+	closed      bool
+	merged      bool
+	done        bool // the closed line is in joiner.done
+}
+
+func (p *chain) Points() []Point {
+	pts := make([]Point, 0, len(p.front)+len(p.back))
+	for i := len(p.front) - 1; i >= 0; i-- {
+		pts = append(pts, p.front[i])
+	}
+	return append(pts, p.back...)
+}
+
+// joiner joins the oriented segments of a level into lines.
+// If stream is set, the closed lines are moved to done.
+type joiner struct {
+	level  float64
+	heads  map[edgeKey]*chain
+	tails  map[edgeKey]*chain
+	chains []*chain
+	stream bool
+	done   []*chain
+}
+
+func newJoiner(level float64) *joiner {
+	return &joiner{
+		level: level,
+		heads: make(map[edgeKey]*chain),
+		tails: make(map[edgeKey]*chain),
+	}
+}
+
+func (p *joiner) Add(s edgeKey, ps Point, e edgeKey, pe Point) {
+	a, b := p.tails[s], p.heads[e]
+	switch {
+	case a != nil && a == b:
+		delete(p.tails, s)
+		delete(p.heads, e)
+		a.back = append(a.back, pe)
+		a.closed = true
+		if p.stream {
+			a.done = true
+			p.done = append(p.done, a)
+		}
+	case a != nil && b != nil:
+		delete(p.tails, s)
+		delete(p.heads, e)
+		a.back = append(a.back, b.Points()...)
+		a.tail = b.tail
+		p.tails[a.tail] = a
+		b.merged = true
+	case a != nil:
+		delete(p.tails, s)
+		a.back = append(a.back, pe)
+		a.tail = e
+		p.tails[e] = a
+	case b != nil:
+		delete(p.heads, e)
+		b.front = append(b.front, ps)
+		b.head = s
+		p.heads[s] = b
+	default:
+		c := &chain{back: []Point{ps, pe}, head: s, tail: e}
+		p.heads[s] = c
+		p.tails[e] = c
+		p.chains = append(p.chains, c)
+	}
+}
+
+// Compact drops the merged chains and the done lines.
+func (p *joiner) Compact() {
+	chains := p.chains[:0]
+	for _, c := range p.chains {
+		if !c.merged && !c.done {
+			chains = append(chains, c)
+		}
+	}
+	for i := len(chains); i < len(p.chains); i++ {
+		p.chains[i] = nil
+	}
+	p.chains, p.done = chains, nil
+}
+
+func (p *joiner) Lines() (lines []Line) {
+	for _, c := range p.chains {
+		if !c.merged && !c.done {
+			lines = append(lines, Line{Level: p.level, Points: c.Points(), Closed: c.closed})
+		}
+	}
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
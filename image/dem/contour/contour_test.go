@@ -0,0 +1,191 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package contour
+
+import (
+	"errors"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/shape/dxf"
+	"github.com/chai2010/gopkg/shape/shp"
+)
+
+// tCone returns a cone of the height 10 at the center of a 11x11 image,
+// its slope is 1 per pixel.
+func tCone() *image_ext.Gray32f {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 11, 11))
+	for y := 0; y < 11; y++ {
+		for x := 0; x < 11; x++ {
+			d := math.Hypot(float64(x-5), float64(y-5))
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(10 - d)})
+		}
+	}
+	return m
+}
+
+func TestContours(t *testing.T) {
+	lines, err := Contours(tCone(), []float64{6.5, 8.5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expect 2 lines, got = %d", len(lines))
+	}
+	for _, l := range lines {
+		if !l.Closed || l.Points[0] != l.Points[len(l.Points)-1] {
+			t.Fatalf("level %v: line not closed", l.Level)
+		}
+		// the circle of the radius 10-level around the center (5.5, 5.5)
+		var area float64
+		for i, pt := range l.Points {
+			d := math.Hypot(pt.X-5.5, pt.Y-5.5)
+			if math.Abs(d-(10-l.Level)) > 0.2 {
+				t.Fatalf("level %v: bad point %v", l.Level, pt)
+			}
+			if i > 0 {
+				prev := l.Points[i-1]
+				area += prev.X*pt.Y - pt.X*prev.Y
+			}
+		}
+		// the higher side on the right: clockwise with y to the south
+		if area <= 0 {
+			t.Fatalf("level %v: bad orientation", l.Level)
+		}
+	}
+}
+
+func TestDemContours(t *testing.T) {
+	src := tCone()
+	levels := Levels(0, 10, 2)
+	if !reflect.DeepEqual(levels, []float64{0, 2, 4, 6, 8, 10}) {
+		t.Fatalf("bad levels: %v", levels)
+	}
+	expect, err := Contours(src, levels, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lines cross the tiles
+	m := big.NewDem(src.Bounds(), image.Pt(4, 4), color_ext.Gray32f{})
+	if err := m.WriteRect(-1, src.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := DemContours(m, levels, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(expect) {
+		t.Fatalf("expect %d lines, got = %d", len(expect), len(lines))
+	}
+	for i := range lines {
+		if lines[i].Level != expect[i].Level || lines[i].Closed != expect[i].Closed ||
+			len(lines[i].Points) != len(expect[i].Points) {
+			t.Fatalf("line %d: expect = %v, got = %v", i, expect[i], lines[i])
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "contour")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lines, err := Contours(tCone(), []float64{6, 8}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "contour.shp")
+	if err := SaveShp(filename, lines); err != nil {
+		t.Fatal(err)
+	}
+	r, err := shp.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if n := r.NumRecords(); n != len(lines) {
+		t.Fatalf("expect %d records, got = %d", len(lines), n)
+	}
+	if v, err := r.Attribute(1, r.FieldIndex("ELEV")); err != nil || v != "8.000" {
+		t.Fatalf("bad ELEV: %q, %v", v, err)
+	}
+
+	filename = filepath.Join(dir, "contour.dxf")
+	if err := SaveDxf(filename, lines); err != nil {
+		t.Fatal(err)
+	}
+	d, err := dxf.Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Entities) != len(lines) {
+		t.Fatalf("expect %d entities, got = %d", len(lines), len(d.Entities))
+	}
+	e, ok := d.Entities[0].(*dxf.LwPolyline)
+	if !ok || !e.Closed() || e.Elevation != 6 || len(e.Vertices) != len(lines[0].Points)-1 {
+		t.Fatalf("bad polyline: %#v", d.Entities[0])
+	}
+}
+
+func TestWalkDemContours(t *testing.T) {
+	src := tCone()
+	levels := Levels(0, 10, 2)
+	expect, err := Contours(src, levels, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := big.NewDem(src.Bounds(), image.Pt(4, 4), color_ext.Gray32f{})
+	if err := m.WriteRect(-1, src.Bounds(), src); err != nil {
+		t.Fatal(err)
+	}
+	var lines []Line
+	err = WalkDemContours(m, levels, nil, func(line *Line) error {
+		lines = append(lines, *line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(expect) {
+		t.Fatalf("expect %d lines, got = %d", len(expect), len(lines))
+	}
+	// the same lines, maybe in another order
+	for _, l := range expect {
+		found := false
+		for i := range lines {
+			if lines[i].Level == l.Level && lines[i].Closed == l.Closed && reflect.DeepEqual(lines[i].Points, l.Points) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("line not found: %v", l)
+		}
+	}
+
+	// the error of fn stops the walk
+	errStop := errors.New("stop")
+	n := 0
+	err = WalkDemContours(m, levels, nil, func(line *Line) error {
+		n++
+		return errStop
+	})
+	if err != errStop || n != 1 {
+		t.Fatalf("expect the error of fn: %v, %d", err, n)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package contour
+
+import (
+	"github.com/chai2010/gopkg/shape/dxf"
+	"github.com/chai2010/gopkg/shape/shp"
+)
+
+// ElevField is the elevation attribute of the shapefiles.
+var ElevField = shp.FloatField("ELEV", 16, 3)
+
+// ShpPolyLine returns the line as a shapefile PolyLine.
+func (p *Line) ShpPolyLine() *shp.PolyLine {
+	pts := make([]shp.Point, len(p.Points))
+	for i, pt := range p.Points {
+		pts[i] = shp.Point{X: pt.X, Y: pt.Y}
+	}
+	return &shp.PolyLine{Parts: []int32{0}, Points: pts}
+}
+
+// DxfPolyline returns the line as a DXF LWPOLYLINE on the layer, at the
+// elevation of the level.
+func (p *Line) DxfPolyline(layer string) *dxf.LwPolyline {
+	pts := p.Points
+	flags := 0
+	if p.Closed {
+		// the closed polylines do not repeat the first vertex
+		pts, flags = pts[:len(pts)-1], 1
+	}
+	e := &dxf.LwPolyline{
+		EntityBase: dxf.EntityBase{Layer: layer},
+		Flags:      flags,
+		Elevation:  p.Level,
+		Vertices:   make([]dxf.LwVertex, len(pts)),
+	}
+	for i, pt := range pts {
+		e.Vertices[i] = dxf.LwVertex{X: pt.X, Y: pt.Y}
+	}
+	return e
+}
+
+// WriteShp writes the lines to w, which must be a PolyLine writer with
+// the ElevField as only field.
+func WriteShp(w *shp.Writer, lines []Line) (err error) {
+	for i := range lines {
+		if _, err = w.Write(lines[i].ShpPolyLine(), lines[i].Level); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// SaveShp writes the lines to the named shapefile, with the ElevField.
+func SaveShp(filename string, lines []Line) (err error) {
+	w, err := shp.Create(filename, shp.ShapeType_PolyLine, ElevField)
+	if err != nil {
+		return
+	}
+	if err = WriteShp(w, lines); err != nil {
+		w.Close()
+		return
+	}
+	return w.Close()
+}
+
+// SaveDxf writes the lines to the named DXF file, on the layer CONTOUR.
+func SaveDxf(filename string, lines []Line) (err error) {
+	d := &dxf.Drawing{
+		Layers: []dxf.Layer{{Name: "CONTOUR", Color: 7}},
+	}
+	for i := range lines {
+		d.Entities = append(d.Entities, lines[i].DxfPolyline("CONTOUR"))
+	}
+	return dxf.Save(filename, d)
+}
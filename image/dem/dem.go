@@ -0,0 +1,204 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package dem implements the georeferenced rasters shared by the DEM formats.
+
+A GeoRaster is a pixel buffer with an affine geotransform, a NODATA value
+and a CRS. The DEM formats (image/dem/ArcInfoGrid, image/dem/BIL, ...)
+register themselves with RegisterFormat, and a raster can be converted
+between any pair of them:
+
+	import (
+		"github.com/chai2010/gopkg/image/dem"
+		_ "github.com/chai2010/gopkg/image/dem/ArcInfoGrid"
+		_ "github.com/chai2010/gopkg/image/dem/BIL"
+	)
+
+	m, _, err := dem.Load("n40e100.bil")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := dem.Save("n40e100.asc", m); err != nil {
+		log.Fatal(err)
+	}
+
+The images of the other formats registered with image.RegisterFormat are
+loaded and saved with their world file (see image/dem/TiffWorld).
+For all the formats, the CRS is read from and written to the .prj sidecar
+file if there is one.
+
+The rasters are reprojected between WGS84, Web Mercator and the UTM zones
+by image/dem/warp (see image/dem/proj for the supported CRS).
+
+The GBJ, VzInt, VzFloat and ImageInfo formats are not implemented, their
+directories are placeholders which are not built.
+*/
+package dem
+
+import (
+	"fmt"
+	"image"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	tiffworld "github.com/chai2010/gopkg/image/dem/TiffWorld"
+)
+
+// GeoTransform is the affine transform from the pixel coordinates to the
+// map coordinates, with the GDAL coefficients order:
+//
+//	X = T[0] + x*T[1] + y*T[2]
+//	Y = T[3] + x*T[4] + y*T[5]
+//
+// The pixel (0, 0) is the upper left corner of the upper left pixel.
+type GeoTransform [6]float64
+
+// NewGeoTransform returns the transform of a north-up raster, (x0, y0)
+// is the upper left corner of the raster.
+func NewGeoTransform(x0, y0, xSize, ySize float64) GeoTransform {
+	return GeoTransform{x0, xSize, 0, y0, 0, -ySize}
+}
+
+// IsNorthUp reports whether the transform has no rotation.
+func (p GeoTransform) IsNorthUp() bool {
+	return p[2] == 0 && p[4] == 0
+}
+
+// PixelToMap returns the map coordinates of the pixel coordinates.
+func (p GeoTransform) PixelToMap(x, y float64) (mx, my float64) {
+	mx = p[0] + x*p[1] + y*p[2]
+	my = p[3] + x*p[4] + y*p[5]
+	return
+}
+
+// MapToPixel returns the pixel coordinates of the map coordinates.
+// ok is false if the transform is not invertible.
+func (p GeoTransform) MapToPixel(mx, my float64) (x, y float64, ok bool) {
+	det := p[1]*p[5] - p[2]*p[4]
+	if det == 0 {
+		return
+	}
+	dx, dy := mx-p[0], my-p[3]
+	x = (p[5]*dx - p[2]*dy) / det
+	y = (p[1]*dy - p[4]*dx) / det
+	ok = true
+	return
+}
+
+// WorldFile returns the world file of the transform (which refers to the
+// center of the upper left pixel).
+func (p GeoTransform) WorldFile() *tiffworld.WorldFile {
+	cx, cy := p.PixelToMap(0.5, 0.5)
+	return &tiffworld.WorldFile{A: p[1], D: p[4], B: p[2], E: p[5], C: cx, F: cy}
+}
+
+// GeoTransformFromWorldFile returns the transform of a world file.
+func GeoTransformFromWorldFile(wf *tiffworld.WorldFile) GeoTransform {
+	return GeoTransform{
+		wf.C - wf.A/2 - wf.B/2, wf.A, wf.B,
+		wf.F - wf.D/2 - wf.E/2, wf.D, wf.E,
+	}
+}
+
+// MaxPixelsSize is the maximum size of the pixels of a raster decoded by
+// the DEM formats, it is the limit of the image/tiff, image/rawp and
+// image/zdct decoders too.
+const MaxPixelsSize = 1 << 30
+
+// CheckSize returns an error if the raster of width x height pixels of
+// pixelSize bytes is empty or larger than MaxPixelsSize. The DEM formats
+// check the size of their header before the pixels are allocated.
+func CheckSize(width, height, pixelSize int) error {
+	if width <= 0 || height <= 0 || pixelSize <= 0 {
+		return fmt.Errorf("bad size, %dx%d", width, height)
+	}
+	// width*height*pixelSize > MaxPixelsSize, without overflow
+	if width > MaxPixelsSize/pixelSize/height {
+		return fmt.Errorf("raster too large, %dx%d", width, height)
+	}
+	return nil
+}
+
+// GeoRaster is a georeferenced raster.
+type GeoRaster struct {
+	// Image is the pixel buffer, a *image_ext.Gray32f for the elevation
+	// models, or any other (multi-band) image type.
+	Image     image.Image
+	Transform GeoTransform
+	NoData    float64
+	HasNoData bool
+	CRS       string // WKT, "EPSG:xxxx" or PROJ.4 string, empty if unknown
+}
+
+// NewGeoRaster returns a GeoRaster with the north-up transform.
+func NewGeoRaster(m image.Image, x0, y0, xSize, ySize float64) *GeoRaster {
+	return &GeoRaster{
+		Image:     m,
+		Transform: NewGeoTransform(x0, y0, xSize, ySize),
+	}
+}
+
+// Bounds returns the map bounds (minX, minY, maxX, maxY) of the raster.
+func (p *GeoRaster) Bounds() (minX, minY, maxX, maxY float64) {
+	b := p.Image.Bounds()
+	corners := [4][2]float64{
+		{0, 0}, {float64(b.Dx()), 0},
+		{0, float64(b.Dy())}, {float64(b.Dx()), float64(b.Dy())},
+	}
+	for i, c := range corners {
+		x, y := p.Transform.PixelToMap(c[0], c[1])
+		if i == 0 || x < minX {
+			minX = x
+		}
+		if i == 0 || x > maxX {
+			maxX = x
+		}
+		if i == 0 || y < minY {
+			minY = y
+		}
+		if i == 0 || y > maxY {
+			maxY = y
+		}
+	}
+	return
+}
+
+// CellSize returns the square pixel size of a north-up raster.
+// It returns an error if the raster is rotated or the pixels are not
+// square, which is needed by the grid formats.
+func (p *GeoRaster) CellSize() (size float64, err error) {
+	t := p.Transform
+	if !t.IsNorthUp() || t[1] != -t[5] || !(t[1] > 0) {
+		err = fmt.Errorf("image/dem: GeoRaster.CellSize, not a north-up square grid: %v", t)
+		return
+	}
+	size = t[1]
+	return
+}
+
+// WithNoData returns a raster whose NODATA pixels are set to v.
+// The pixels of a *image_ext.Gray32f image are copied if they need to be
+// changed, the other images are shared.
+func (p *GeoRaster) WithNoData(v float64) *GeoRaster {
+	q := *p
+	q.NoData, q.HasNoData = v, true
+
+	gray, ok := p.Image.(*image_ext.Gray32f)
+	if !ok || !p.HasNoData || p.NoData == v {
+		return &q
+	}
+	old, b := float32(p.NoData), gray.Bounds()
+	m := image_ext.NewGray32f(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := gray.Gray32fAt(x, y)
+			if c.Y == old {
+				c.Y = float32(v)
+			}
+			m.SetGray32f(x, y, c)
+		}
+	}
+	q.Image = m
+	return &q
+}
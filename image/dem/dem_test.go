@@ -0,0 +1,122 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dem_test
+
+import (
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/dem"
+	_ "github.com/chai2010/gopkg/image/dem/ArcInfoGrid"
+	_ "github.com/chai2010/gopkg/image/dem/BIL"
+	_ "github.com/chai2010/gopkg/image/dem/CNSDTF"
+	_ "github.com/chai2010/gopkg/image/dem/NSDTF"
+	_ "github.com/chai2010/gopkg/image/rawp"
+)
+
+func TestGeoTransform(t *testing.T) {
+	gt := dem.GeoTransform{1000, 2, 0.5, 5000, -0.25, -2}
+	for _, pt := range [][2]float64{{0, 0}, {10, 20}, {-3.5, 7.25}} {
+		mx, my := gt.PixelToMap(pt[0], pt[1])
+		x, y, ok := gt.MapToPixel(mx, my)
+		if !ok || math.Abs(x-pt[0]) > 1e-9 || math.Abs(y-pt[1]) > 1e-9 {
+			t.Fatalf("%v: bad pixel: %v, %v", pt, x, y)
+		}
+	}
+	if gt2 := dem.GeoTransformFromWorldFile(gt.WorldFile()); gt2 != gt {
+		t.Fatalf("expect = %v, got = %v", gt, gt2)
+	}
+
+	m := dem.NewGeoRaster(image_ext.NewGray32f(image.Rect(0, 0, 4, 3)), 100, 200, 10, 10)
+	if minX, minY, maxX, maxY := m.Bounds(); minX != 100 || minY != 170 || maxX != 140 || maxY != 200 {
+		t.Fatalf("bad bounds: %v, %v, %v, %v", minX, minY, maxX, maxY)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gray := image_ext.NewGray32f(image.Rect(0, 0, 5, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			gray.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*5+x) * 1.5})
+		}
+	}
+	gray.SetGray32f(2, 2, color_ext.Gray32f{Y: -9999})
+
+	src := dem.NewGeoRaster(gray, 500000, 4000000, 30, 30)
+	src.NoData, src.HasNoData = -9999, true
+	src.CRS = "EPSG:32650"
+
+	name := filepath.Join(dir, "a.asc")
+	if err := dem.Save(name, src); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range []string{".bil", ".dem", ".cdem", ".bsq", ".asc"} {
+		next := filepath.Join(dir, "b"+ext)
+		if err := dem.Convert(next, name); err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		name = next
+	}
+
+	m, format, err := dem.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "asc" || m.Transform != src.Transform || m.CRS != src.CRS {
+		t.Fatalf("bad raster: %v, %v, %v", format, m.Transform, m.CRS)
+	}
+	// the NODATA value is changed by NSDTF/CNSDTF
+	if !m.HasNoData || m.NoData != -99999 {
+		t.Fatalf("bad NoData: %v", m.NoData)
+	}
+	got := m.Image.(*image_ext.Gray32f)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			expect := float32(y*5+x) * 1.5
+			if x == 2 && y == 2 {
+				expect = -99999
+			}
+			if v := got.Gray32fAt(x, y).Y; v != expect {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, expect, v)
+			}
+		}
+	}
+}
+
+func TestLoadSave_worldFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := dem.NewGeoRaster(image_ext.NewGray32f(image.Rect(0, 0, 5, 4)), 100, 40, 0.25, 0.5)
+	name := filepath.Join(dir, "a.rawp")
+	if err := dem.Save(name, src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.rpw")); err != nil {
+		t.Fatal(err)
+	}
+	m, format, err := dem.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "rawp" || m.Transform != src.Transform || m.Image.Bounds() != src.Image.Bounds() {
+		t.Fatalf("bad raster: %v, %v", format, m.Transform)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dem
+
+import (
+	"bufio"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tiffworld "github.com/chai2010/gopkg/image/dem/TiffWorld"
+)
+
+// A Format holds a DEM format's name, magic header and how to decode it.
+// Name is the name of the format, like "asc" or "bil".
+// Extensions is the name extensions, like ".asc".
+// Magics is the magic prefix that identifies the format's encoding. The magic
+// string can contain "?" wildcards that each match any one byte.
+// Decode and Encode are the stream codec (nil for the formats with sidecar
+// files). Load and Save are the file codec (nil if the stream codec is
+// enough).
+type Format struct {
+	Name       string
+	Extensions []string
+	Magics     []string
+	Decode     func(r io.Reader) (*GeoRaster, error)
+	Encode     func(w io.Writer, m *GeoRaster) error
+	Load       func(filename string) (*GeoRaster, error)
+	Save       func(filename string, m *GeoRaster) error
+}
+
+// formats is the list of registered formats.
+var formats []Format
+
+// RegisterFormat registers a DEM format for use by Decode, Encode, Load
+// and Save.
+func RegisterFormat(f Format) {
+	formats = append(formats, Format{
+		Name:       f.Name,
+		Extensions: append([]string(nil), f.Extensions...),
+		Magics:     append([]string(nil), f.Magics...),
+		Decode:     f.Decode,
+		Encode:     f.Encode,
+		Load:       f.Load,
+		Save:       f.Save,
+	})
+}
+
+// match reports whether magic matches b. Magic may contain "?" wildcards.
+func match(magic string, b []byte) bool {
+	if len(magic) != len(b) {
+		return false
+	}
+	for i, c := range b {
+		if magic[i] != c && magic[i] != '?' {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffByName determines the format by filename extension.
+func sniffByName(filename string) Format {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, f := range formats {
+		for _, extensions := range f.Extensions {
+			if ext == extensions {
+				return f
+			}
+		}
+	}
+	return Format{}
+}
+
+// sniffByMagic determines the format of r's data.
+func sniffByMagic(r *bufio.Reader) Format {
+	for _, f := range formats {
+		for _, magic := range f.Magics {
+			b, err := r.Peek(len(magic))
+			if err == nil && match(magic, b) && f.Decode != nil {
+				return f
+			}
+		}
+	}
+	return Format{}
+}
+
+// Decode decodes a GeoRaster that has been encoded in a registered format.
+// The string returned is the format name used during format registration.
+func Decode(r io.Reader) (*GeoRaster, string, error) {
+	br := bufio.NewReader(r)
+	f := sniffByMagic(br)
+	if f.Decode == nil {
+		return nil, "", image.ErrFormat
+	}
+	m, err := f.Decode(br)
+	return m, f.Name, err
+}
+
+// Encode encodes a GeoRaster as a registered format.
+// The format is the format name used during format registration.
+func Encode(format string, w io.Writer, m *GeoRaster) error {
+	for _, f := range formats {
+		if f.Name == format && f.Encode != nil {
+			return f.Encode(w, m)
+		}
+	}
+	return image.ErrFormat
+}
+
+// Load reads the named file. The format is found by the file extension,
+// then by the magic header. The files of the other formats registered with
+// image.RegisterFormat are loaded with their world file.
+func Load(filename string) (m *GeoRaster, format string, err error) {
+	if f := sniffByName(filename); f.Load != nil {
+		if m, err = f.Load(filename); err != nil {
+			return
+		}
+		format = f.Name
+	} else if m, format, err = loadStream(filename); err == image.ErrFormat {
+		m, format, err = loadImage(filename)
+	}
+	if err != nil {
+		return
+	}
+	if m.CRS == "" {
+		m.CRS, _ = readPrj(filename)
+	}
+	return
+}
+
+// Save writes the named file, the format is found by the file extension.
+// The files of the other formats registered with image.RegisterFormat are
+// saved with their world file.
+func Save(filename string, m *GeoRaster) (err error) {
+	switch f := sniffByName(filename); {
+	case f.Save != nil:
+		err = f.Save(filename, m)
+	case f.Encode != nil:
+		err = saveStream(filename, m, f)
+	default:
+		err = saveImage(filename, m)
+	}
+	if err != nil {
+		return
+	}
+	if m.CRS != "" {
+		err = ioutil.WriteFile(PrjName(filename), []byte(m.CRS), 0666)
+	}
+	return
+}
+
+// Convert reads the src file and writes it to dst, in the formats given
+// by the file extensions.
+func Convert(dst, src string) (err error) {
+	m, _, err := Load(src)
+	if err != nil {
+		return
+	}
+	return Save(dst, m)
+}
+
+// PrjName returns the name of the .prj file of the raster file.
+func PrjName(filename string) string {
+	return filename[:len(filename)-len(filepath.Ext(filename))] + ".prj"
+}
+
+func readPrj(filename string) (crs string, err error) {
+	data, err := ioutil.ReadFile(PrjName(filename))
+	if err != nil {
+		return
+	}
+	crs = strings.TrimSpace(string(data))
+	return
+}
+
+func loadStream(filename string) (m *GeoRaster, format string, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+func saveStream(filename string, m *GeoRaster, format Format) (err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	if err = format.Encode(f, m); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+func loadImage(filename string) (m *GeoRaster, format string, err error) {
+	img, format, wf, err := tiffworld.LoadImage(filename, nil)
+	if err != nil {
+		return
+	}
+	m = &GeoRaster{Image: img, Transform: NewGeoTransform(0, 0, 1, 1)}
+	if wf != nil {
+		m.Transform = GeoTransformFromWorldFile(wf)
+	}
+	return
+}
+
+func saveImage(filename string, m *GeoRaster) error {
+	return tiffworld.SaveImage(filename, m.Image, nil, m.Transform.WorldFile())
+}
@@ -0,0 +1,306 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package proj implements the map projections of the common CRS on the
+WGS84 ellipsoid: the geographic coordinates, the Web Mercator and the UTM
+zones.
+
+	utm, err := proj.Parse("EPSG:32650")
+	if err != nil {
+		log.Fatal(err)
+	}
+	x, y, err := proj.Transform(proj.WGS84, utm, 117.0, 40.0)
+*/
+package proj
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Projection converts between the geographic coordinates (the longitude
+// and latitude in degrees on WGS84) and the coordinates of a CRS.
+type Projection interface {
+	// Forward returns the CRS coordinates of (lon, lat).
+	Forward(lon, lat float64) (x, y float64, err error)
+
+	// Inverse returns the geographic coordinates of (x, y).
+	Inverse(x, y float64) (lon, lat float64, err error)
+
+	// String returns the "EPSG:xxxx" code of the CRS.
+	String() string
+}
+
+// The WGS84 ellipsoid.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+)
+
+var (
+	WGS84       Projection = geographic{}
+	WebMercator Projection = webMercator{}
+)
+
+// Transform returns the coordinates in dst of the coordinates (x, y)
+// in src.
+func Transform(src, dst Projection, x, y float64) (dx, dy float64, err error) {
+	if src == dst {
+		return x, y, nil
+	}
+	lon, lat, err := src.Inverse(x, y)
+	if err != nil {
+		return
+	}
+	return dst.Forward(lon, lat)
+}
+
+type geographic struct{}
+
+func (geographic) Forward(lon, lat float64) (x, y float64, err error) {
+	return lon, lat, nil
+}
+
+func (geographic) Inverse(x, y float64) (lon, lat float64, err error) {
+	return x, y, nil
+}
+
+func (geographic) String() string {
+	return "EPSG:4326"
+}
+
+// webMercator is the spherical Mercator of the web maps (EPSG:3857), the
+// geographic coordinates are used as the spherical coordinates.
+type webMercator struct{}
+
+// webMercatorMaxLat is the latitude of the square world of the web maps.
+const webMercatorMaxLat = 85.0511287798066
+
+func (webMercator) Forward(lon, lat float64) (x, y float64, err error) {
+	if math.Abs(lat) > webMercatorMaxLat {
+		err = fmt.Errorf("image/dem/proj: WebMercator.Forward, bad latitude: %v", lat)
+		return
+	}
+	x = wgs84A * lon * math.Pi / 180
+	y = wgs84A * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360))
+	return
+}
+
+func (webMercator) Inverse(x, y float64) (lon, lat float64, err error) {
+	lon = x / wgs84A * 180 / math.Pi
+	lat = (2*math.Atan(math.Exp(y/wgs84A)) - math.Pi/2) * 180 / math.Pi
+	return
+}
+
+func (webMercator) String() string {
+	return "EPSG:3857"
+}
+
+// UTM is the Universal Transverse Mercator projection of a zone.
+type UTM struct {
+	Zone  int  // 1 to 60
+	South bool // the southern hemisphere (the false northing is 10000000m)
+}
+
+// NewUTM returns the UTM projection of a zone.
+func NewUTM(zone int, south bool) (p *UTM, err error) {
+	if zone < 1 || zone > 60 {
+		err = fmt.Errorf("image/dem/proj: NewUTM, bad zone: %d", zone)
+		return
+	}
+	p = &UTM{Zone: zone, South: south}
+	return
+}
+
+// UTMZone returns the UTM zone of the longitude.
+func UTMZone(lon float64) int {
+	zone := int(math.Floor((lon+180)/6)) + 1
+	if zone > 60 {
+		zone -= 60
+	}
+	if zone < 1 {
+		zone += 60
+	}
+	return zone
+}
+
+// The parameters of the transverse Mercator of the UTM zones.
+const (
+	utmK0            = 0.9996
+	utmFalseEasting  = 500000.0
+	utmFalseNorthing = 10000000.0
+)
+
+func (p *UTM) centralMeridian() float64 {
+	return float64(p.Zone)*6 - 183
+}
+
+// Forward uses the series of the transverse Mercator (Snyder, Map
+// Projections: A Working Manual, 1987), the error is less than 1mm in
+// the zone.
+func (p *UTM) Forward(lon, lat float64) (x, y float64, err error) {
+	if math.Abs(lat) > 84.5 {
+		err = fmt.Errorf("image/dem/proj: UTM.Forward, bad latitude: %v", lat)
+		return
+	}
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+
+	phi := lat * math.Pi / 180
+	dlam := (lon - p.centralMeridian()) * math.Pi / 180
+	for dlam > math.Pi {
+		dlam -= 2 * math.Pi
+	}
+	for dlam < -math.Pi {
+		dlam += 2 * math.Pi
+	}
+
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+	n := wgs84A / math.Sqrt(1-e2*sin*sin)
+	t := tan * tan
+	c := ep2 * cos * cos
+	a := cos * dlam
+	m := meridianArc(phi, e2)
+
+	x = utmK0 * n * (a + (1-t+c)*a*a*a/6 +
+		(5-18*t+t*t+72*c-58*ep2)*a*a*a*a*a/120)
+	y = utmK0 * (m + n*tan*(a*a/2+
+		(5-t+9*c+4*c*c)*a*a*a*a/24+
+		(61-58*t+t*t+600*c-330*ep2)*a*a*a*a*a*a/720))
+
+	x += utmFalseEasting
+	if p.South {
+		y += utmFalseNorthing
+	}
+	return
+}
+
+func (p *UTM) Inverse(x, y float64) (lon, lat float64, err error) {
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+
+	x -= utmFalseEasting
+	if p.South {
+		y -= utmFalseNorthing
+	}
+
+	// the footpoint latitude
+	m := y / utmK0
+	mu := m / (wgs84A * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	phi1 := mu + (3*e1/2-27*e1*e1*e1/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*e1*e1*e1*e1/32)*math.Sin(4*mu) +
+		(151*e1*e1*e1/96)*math.Sin(6*mu) +
+		(1097*e1*e1*e1*e1/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	t1 := tan * tan
+	c1 := ep2 * cos * cos
+	d := x / (n1 * utmK0)
+
+	phi := phi1 - (n1*tan/r1)*(d*d/2-
+		(5+3*t1+10*c1-4*c1*c1-9*ep2)*d*d*d*d/24+
+		(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*d*d*d*d*d*d/720)
+	dlam := (d - (1+2*t1+c1)*d*d*d/6 +
+		(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*d*d*d*d*d/120) / cos
+
+	lat = phi * 180 / math.Pi
+	lon = p.centralMeridian() + dlam*180/math.Pi
+	return
+}
+
+func (p *UTM) String() string {
+	if p.South {
+		return fmt.Sprintf("EPSG:%d", 32700+p.Zone)
+	}
+	return fmt.Sprintf("EPSG:%d", 32600+p.Zone)
+}
+
+// meridianArc returns the distance from the equator to the latitude phi
+// along the meridian.
+func meridianArc(phi, e2 float64) float64 {
+	return wgs84A * ((1-e2/4-3*e2*e2/64-5*e2*e2*e2/256)*phi -
+		(3*e2/8+3*e2*e2/32+45*e2*e2*e2/1024)*math.Sin(2*phi) +
+		(15*e2*e2/256+45*e2*e2*e2/1024)*math.Sin(4*phi) -
+		(35*e2*e2*e2/3072)*math.Sin(6*phi))
+}
+
+var (
+	wktAuthorityRegexp = regexp.MustCompile(`AUTHORITY\["EPSG",\s*"(\d+)"\]\]\s*$`)
+	wktUTMRegexp       = regexp.MustCompile(`UTM zone (\d+)([NS])`)
+	projZoneRegexp     = regexp.MustCompile(`\+zone=(\d+)`)
+)
+
+// Parse returns the projection of a CRS: an "EPSG:xxxx" code, a PROJ.4
+// string or a WKT (like the GeoRaster.CRS).
+//
+// The supported CRS are WGS84 (EPSG:4326), the Web Mercator (EPSG:3857
+// and its aliases) and the UTM zones on WGS84 (EPSG:326xx and 327xx).
+func Parse(crs string) (p Projection, err error) {
+	s := strings.TrimSpace(crs)
+	switch {
+	case strings.HasPrefix(strings.ToUpper(s), "EPSG:"):
+		var code int
+		if code, err = strconv.Atoi(s[len("EPSG:"):]); err != nil {
+			err = fmt.Errorf("image/dem/proj: Parse, bad EPSG code: %q", crs)
+			return
+		}
+		return epsg(code)
+
+	case strings.HasPrefix(s, "+"):
+		switch {
+		case strings.Contains(s, "+proj=longlat"), strings.Contains(s, "+proj=latlong"):
+			return WGS84, nil
+		case strings.Contains(s, "+proj=webmerc"),
+			strings.Contains(s, "+proj=merc") && strings.Contains(s, "+a=6378137") && strings.Contains(s, "+b=6378137"):
+			return WebMercator, nil
+		case strings.Contains(s, "+proj=utm"):
+			if v := projZoneRegexp.FindStringSubmatch(s); v != nil {
+				zone, _ := strconv.Atoi(v[1])
+				return NewUTM(zone, strings.Contains(s, "+south"))
+			}
+		}
+
+	case strings.HasPrefix(s, "PROJCS["), strings.HasPrefix(s, "GEOGCS["):
+		if v := wktAuthorityRegexp.FindStringSubmatch(s); v != nil {
+			code, _ := strconv.Atoi(v[1])
+			if p, err = epsg(code); err == nil {
+				return
+			}
+		}
+		switch {
+		case strings.HasPrefix(s, "GEOGCS[") && strings.Contains(s, "WGS"):
+			return WGS84, nil
+		case strings.Contains(s, "Pseudo-Mercator"), strings.Contains(s, "Popular Visualisation"):
+			return WebMercator, nil
+		case strings.Contains(s, "WGS") && wktUTMRegexp.MatchString(s):
+			v := wktUTMRegexp.FindStringSubmatch(s)
+			zone, _ := strconv.Atoi(v[1])
+			return NewUTM(zone, v[2] == "S")
+		}
+	}
+	err = fmt.Errorf("image/dem/proj: Parse, unsupported CRS: %q", crs)
+	return
+}
+
+func epsg(code int) (p Projection, err error) {
+	switch {
+	case code == 4326:
+		return WGS84, nil
+	case code == 3857, code == 900913, code == 3785, code == 102100, code == 102113:
+		return WebMercator, nil
+	case code > 32600 && code <= 32660:
+		return NewUTM(code-32600, false)
+	case code > 32700 && code <= 32760:
+		return NewUTM(code-32700, true)
+	}
+	err = fmt.Errorf("image/dem/proj: unsupported EPSG code: %d", code)
+	return
+}
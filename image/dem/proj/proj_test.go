@@ -0,0 +1,105 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proj
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUTM(t *testing.T) {
+	for i, v := range []struct {
+		zone     int
+		south    bool
+		lon, lat float64
+		x, y     float64
+	}{
+		// the reference values of the Krüger series of the 5th order
+		{31, false, 3, 0, 500000, 0},
+		{50, false, 116.391667, 39.906667, 448002.0743, 4417575.3475},
+		{56, true, 151.209444, -33.865, 334374.6134, 6251369.9834},
+		{33, false, 12, 60, 332705.1789, 6655205.4836},
+		{50, false, 119.5, 45, 697038.3282, 4985991.0174},
+	} {
+		p, err := NewUTM(v.zone, v.south)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y, err := p.Forward(v.lon, v.lat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(x-v.x) > 0.01 || math.Abs(y-v.y) > 0.01 {
+			t.Fatalf("%d: expect = (%v, %v), got = (%v, %v)", i, v.x, v.y, x, y)
+		}
+		lon, lat, err := p.Inverse(x, y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(lon-v.lon) > 1e-8 || math.Abs(lat-v.lat) > 1e-8 {
+			t.Fatalf("%d: expect = (%v, %v), got = (%v, %v)", i, v.lon, v.lat, lon, lat)
+		}
+	}
+}
+
+func TestWebMercator(t *testing.T) {
+	x, y, err := WebMercator.Forward(180, webMercatorMaxLat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(x-20037508.342789) > 1e-3 || math.Abs(y-20037508.342789) > 1e-3 {
+		t.Fatalf("bad corner: %v, %v", x, y)
+	}
+	lon, lat, _ := WebMercator.Inverse(x, y)
+	if math.Abs(lon-180) > 1e-9 || math.Abs(lat-webMercatorMaxLat) > 1e-9 {
+		t.Fatalf("bad inverse: %v, %v", lon, lat)
+	}
+	if _, _, err := WebMercator.Forward(0, 89); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, v := range []struct {
+		crs  string
+		code string
+	}{
+		{"EPSG:4326", "EPSG:4326"},
+		{"epsg:900913", "EPSG:3857"},
+		{"EPSG:32650", "EPSG:32650"},
+		{"EPSG:32756", "EPSG:32756"},
+		{"+proj=longlat +datum=WGS84 +no_defs", "EPSG:4326"},
+		{"+proj=merc +a=6378137 +b=6378137 +lat_ts=0.0 +lon_0=0.0 +x_0=0.0 +y_0=0 +k=1.0 +units=m +nadgrids=@null +no_defs", "EPSG:3857"},
+		{"+proj=utm +zone=33 +south +datum=WGS84 +units=m +no_defs", "EPSG:32733"},
+		{`GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563]],PRIMEM["Greenwich",0],UNIT["degree",0.0174532925199433]]`, "EPSG:4326"},
+		{`PROJCS["WGS 84 / UTM zone 50N",GEOGCS["WGS 84",AUTHORITY["EPSG","4326"]],PROJECTION["Transverse_Mercator"],AUTHORITY["EPSG","32650"]]`, "EPSG:32650"},
+		{`PROJCS["WGS_1984_UTM_Zone_50N_ish",GEOGCS["GCS_WGS_1984"],PROJECTION["Transverse_Mercator"],PARAMETER["central_meridian",117],UNIT["Meter",1]] UTM zone 50N`, "EPSG:32650"},
+	} {
+		p, err := Parse(v.crs)
+		if err != nil {
+			t.Fatalf("%s: %v", v.crs, err)
+		}
+		if s := p.String(); s != v.code {
+			t.Fatalf("%s: expect = %s, got = %s", v.crs, v.code, s)
+		}
+	}
+	for _, crs := range []string{"EPSG:2000", "+proj=lcc", "EPSG:x", ""} {
+		if _, err := Parse(crs); err == nil {
+			t.Fatalf("%s: expect an error", crs)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	utm, _ := NewUTM(50, false)
+	x, y, err := Transform(WebMercator, utm, 12956620.6, 4852834.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lon, lat, _ := utm.Inverse(x, y)
+	if math.Abs(lon-116.3913) > 1e-4 || math.Abs(lat-39.9097) > 1e-4 {
+		t.Fatalf("bad transform: %v, %v", lon, lat)
+	}
+}
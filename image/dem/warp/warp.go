@@ -0,0 +1,330 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package warp resamples the georeferenced rasters from a CRS into another.
+
+The CRS of the rasters are parsed by image/dem/proj (WGS84, Web Mercator
+and the UTM zones):
+
+	src, _, err := dem.Load("n40e116_utm50.asc")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dst, err := warp.Warp(src, "EPSG:3857", &warp.Options{
+		Interp: warp.Interp_Bilinear,
+	})
+
+The Image of the rasters can be any image.Image, and the destination
+image of WarpTo can be any draw.Image: the *big.Image and *big.Dem
+destinations are written tile by tile.
+*/
+package warp
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/dem"
+	"github.com/chai2010/gopkg/image/dem/proj"
+)
+
+// Interp is the interpolation of the source pixels.
+type Interp int
+
+const (
+	Interp_Nearest Interp = iota
+	Interp_Bilinear
+	Interp_Cubic
+)
+
+// Options are the parameters of Warp and WarpTo.
+type Options struct {
+	Interp Interp
+
+	// CellSize is the square pixel size of the Warp raster, in the units
+	// of the destination CRS. If 0, the raster has about the same number
+	// of pixels as the source.
+	CellSize float64
+}
+
+func (p *Options) adjust() Options {
+	if p == nil {
+		return Options{}
+	}
+	return *p
+}
+
+// Warp returns the raster src resampled into the CRS dstCRS, with a
+// north-up transform over the bounds of src. The image of the raster has
+// the color model of the source image (a *image_ext.Gray32f for the
+// elevation models), and the NoData of src.
+func Warp(src *dem.GeoRaster, dstCRS string, opt *Options) (dst *dem.GeoRaster, err error) {
+	o := opt.adjust()
+	srcProj, err := proj.Parse(src.CRS)
+	if err != nil {
+		return
+	}
+	dstProj, err := proj.Parse(dstCRS)
+	if err != nil {
+		return
+	}
+
+	// the bounds of the points along the edges of src
+	const steps = 20
+	b := src.Image.Bounds()
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= steps; i++ {
+		u := float64(b.Dx()) * float64(i) / steps
+		v := float64(b.Dy()) * float64(i) / steps
+		for _, pt := range [4][2]float64{{u, 0}, {u, float64(b.Dy())}, {0, v}, {float64(b.Dx()), v}} {
+			mx, my := src.Transform.PixelToMap(pt[0], pt[1])
+			x, y, e := proj.Transform(srcProj, dstProj, mx, my)
+			if e != nil {
+				continue
+			}
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	if !(minX < maxX && minY < maxY) {
+		err = fmt.Errorf("image/dem/warp: Warp, the raster is out of %s", dstCRS)
+		return
+	}
+
+	cellSize := o.CellSize
+	if cellSize <= 0 {
+		cellSize = math.Sqrt((maxX - minX) * (maxY - minY) / float64(b.Dx()*b.Dy()))
+	}
+	r := image.Rect(0, 0,
+		int(math.Ceil((maxX-minX)/cellSize)),
+		int(math.Ceil((maxY-minY)/cellSize)),
+	)
+	dst = &dem.GeoRaster{
+		Image:     newImage(r, src.Image.ColorModel()),
+		Transform: dem.NewGeoTransform(minX, maxY, cellSize, cellSize),
+		NoData:    src.NoData,
+		HasNoData: src.HasNoData,
+		CRS:       dstProj.String(),
+	}
+	if err = WarpTo(dst, src, &o); err != nil {
+		dst = nil
+	}
+	return
+}
+
+// WarpTo resamples the raster src into the raster dst, whose Image must
+// be a draw.Image. The pixels of dst out of src (and the NoData pixels
+// of src) are set to the NoData of dst if it has one, or are not changed.
+func WarpTo(dst, src *dem.GeoRaster, opt *Options) (err error) {
+	o := opt.adjust()
+	srcProj, err := proj.Parse(src.CRS)
+	if err != nil {
+		return
+	}
+	dstProj, err := proj.Parse(dst.CRS)
+	if err != nil {
+		return
+	}
+
+	w := &warper{
+		src:       src,
+		srcProj:   srcProj,
+		dst:       dst,
+		dstProj:   dstProj,
+		interp:    o.Interp,
+		srcNoData: float32(src.NoData),
+	}
+	switch m := dst.Image.(type) {
+	case *big.Image:
+		return w.warpBig(m.Bounds(), m.TileSize, m.ColorModel(), func(r image.Rectangle, buf draw.Image) error {
+			return m.WriteRect(-1, r, buf)
+		})
+	case *big.Dem:
+		return w.warpBig(m.Bounds(), m.TileSize, m.ColorModel(), func(r image.Rectangle, buf draw.Image) error {
+			return m.WriteRect(-1, r, buf.(*image_ext.Gray32f))
+		})
+	case draw.Image:
+		w.warpRect(m, m.Bounds())
+		return
+	}
+	return fmt.Errorf("image/dem/warp: WarpTo, not a draw.Image: %T", dst.Image)
+}
+
+type warper struct {
+	src       *dem.GeoRaster
+	srcProj   proj.Projection
+	dst       *dem.GeoRaster
+	dstProj   proj.Projection
+	interp    Interp
+	srcNoData float32
+}
+
+// warpBig warps a big image tile by tile, with a buffer of the tile size.
+func (p *warper) warpBig(b image.Rectangle, tileSize image.Point, model color.Model, write func(r image.Rectangle, buf draw.Image) error) (err error) {
+	for y := b.Min.Y; y < b.Max.Y; y += tileSize.Y {
+		for x := b.Min.X; x < b.Max.X; x += tileSize.X {
+			r := image.Rect(x, y, x+tileSize.X, y+tileSize.Y).Intersect(b)
+			buf := newImage(image.Rect(0, 0, r.Dx(), r.Dy()), model)
+			if !p.dst.HasNoData {
+				// the pixels out of src are not changed
+				draw.Draw(buf, buf.Bounds(), p.dst.Image, r.Min, draw.Src)
+			}
+			p.warpRect(buf, r)
+			if err = write(r, buf); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// warpRect warps the pixels r of the destination image to m, the pixel
+// r.Min is at m.Bounds().Min.
+func (p *warper) warpRect(m draw.Image, r image.Rectangle) {
+	off := m.Bounds().Min.Sub(r.Min)
+	min := p.dst.Image.Bounds().Min
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c, ok := p.sample(x-min.X, y-min.Y)
+			if !ok {
+				if !p.dst.HasNoData {
+					continue
+				}
+				c = p.noDataColor(m.ColorModel())
+			}
+			m.Set(x+off.X, y+off.Y, c)
+		}
+	}
+}
+
+// sample returns the source color at the center of the destination pixel
+// (x, y) (relative to the upper left pixel), ok is false if it is out of
+// src.
+func (p *warper) sample(x, y int) (c color.Color, ok bool) {
+	mx, my := p.dst.Transform.PixelToMap(float64(x)+0.5, float64(y)+0.5)
+	sx, sy, err := proj.Transform(p.dstProj, p.srcProj, mx, my)
+	if err != nil {
+		return
+	}
+	u, v, ok := p.src.Transform.MapToPixel(sx, sy)
+	if !ok {
+		return
+	}
+	b := p.src.Image.Bounds()
+	u, v = u+float64(b.Min.X), v+float64(b.Min.Y)
+	if u < float64(b.Min.X) || v < float64(b.Min.Y) || u >= float64(b.Max.X) || v >= float64(b.Max.Y) {
+		ok = false
+		return
+	}
+
+	switch p.interp {
+	case Interp_Bilinear:
+		return p.interpolate(u-0.5, v-0.5, 1, bilinearWeight)
+	case Interp_Cubic:
+		return p.interpolate(u-0.5, v-0.5, 2, cubicWeight)
+	}
+	c = p.src.Image.At(int(math.Floor(u)), int(math.Floor(v)))
+	ok = !p.isNoData(c)
+	return
+}
+
+// interpolate returns the weighted sum of the pixels around (u, v), the
+// pixels out of the image and the NoData pixels are skipped.
+func (p *warper) interpolate(u, v float64, radius int, weight func(d float64) float64) (c color.Color, ok bool) {
+	b := p.src.Image.Bounds()
+	x0, y0 := int(math.Floor(u)), int(math.Floor(v))
+
+	var values [4]float64
+	var sum float64
+	var kind color.Color
+	for y := y0 - radius + 1; y <= y0+radius; y++ {
+		if y < b.Min.Y || y >= b.Max.Y {
+			continue
+		}
+		wy := weight(v - float64(y))
+		for x := x0 - radius + 1; x <= x0+radius; x++ {
+			if x < b.Min.X || x >= b.Max.X {
+				continue
+			}
+			cc := p.src.Image.At(x, y)
+			if p.isNoData(cc) {
+				continue
+			}
+			w := wy * weight(u-float64(x))
+			cv := color_ext.Values(cc)
+			for i := range values {
+				values[i] += cv[i] * w
+			}
+			sum += w
+			kind = cc
+		}
+	}
+	if kind == nil || sum == 0 {
+		return
+	}
+	for i := range values {
+		values[i] /= sum
+	}
+	return color_ext.FromValues(kind, values), true
+}
+
+func (p *warper) isNoData(c color.Color) bool {
+	if !p.src.HasNoData {
+		return false
+	}
+	v, ok := c.(color_ext.Gray32f)
+	return ok && v.Y == p.srcNoData
+}
+
+func (p *warper) noDataColor(model color.Model) color.Color {
+	if model == color_ext.Gray32fModel {
+		return color_ext.Gray32f{Y: float32(p.dst.NoData)}
+	}
+	return color.Transparent
+}
+
+func bilinearWeight(d float64) float64 {
+	if d = math.Abs(d); d < 1 {
+		return 1 - d
+	}
+	return 0
+}
+
+// cubicWeight is the cubic convolution of Keys (a = -0.5).
+func cubicWeight(d float64) float64 {
+	const a = -0.5
+	switch d = math.Abs(d); {
+	case d < 1:
+		return ((a+2)*d-(a+3))*d*d + 1
+	case d < 2:
+		return ((a*d-5*a)*d+8*a)*d - 4*a
+	}
+	return 0
+}
+
+// newImage returns an image of the color model, the models which are not
+// supported by big.Image are RGBA64.
+func newImage(r image.Rectangle, model color.Model) draw.Image {
+	switch model {
+	case color.GrayModel:
+		return image.NewGray(r)
+	case color.Gray16Model:
+		return image.NewGray16(r)
+	case color_ext.Gray32fModel:
+		return image_ext.NewGray32f(r)
+	case color.RGBAModel:
+		return image.NewRGBA(r)
+	case color_ext.RGBA128fModel:
+		return image_ext.NewRGBA128f(r)
+	}
+	return image.NewRGBA64(r)
+}
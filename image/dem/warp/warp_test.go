@@ -0,0 +1,166 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package warp
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/dem"
+	"github.com/chai2010/gopkg/image/dem/proj"
+)
+
+// tLonRaster returns a WGS84 raster whose values are the longitude of
+// the pixel centers.
+func tLonRaster() *dem.GeoRaster {
+	gray := image_ext.NewGray32f(image.Rect(0, 0, 40, 30))
+	src := dem.NewGeoRaster(gray, 116, 40, 0.01, 0.01)
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			lon, _ := src.Transform.PixelToMap(float64(x)+0.5, float64(y)+0.5)
+			gray.SetGray32f(x, y, color_ext.Gray32f{Y: float32(lon)})
+		}
+	}
+	src.CRS = "EPSG:4326"
+	src.NoData, src.HasNoData = -9999, true
+	return src
+}
+
+func TestWarpIdentity(t *testing.T) {
+	src := tLonRaster()
+	for _, interp := range []Interp{Interp_Nearest, Interp_Bilinear, Interp_Cubic} {
+		dst := *src
+		dst.Image = image_ext.NewGray32f(src.Image.Bounds())
+		if err := WarpTo(&dst, src, &Options{Interp: interp}); err != nil {
+			t.Fatal(err)
+		}
+		a, b := src.Image.(*image_ext.Gray32f), dst.Image.(*image_ext.Gray32f)
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 40; x++ {
+				if v0, v1 := a.Gray32fAt(x, y).Y, b.Gray32fAt(x, y).Y; math.Abs(float64(v0-v1)) > 1e-4 {
+					t.Fatalf("%d: (%d,%d): expect = %v, got = %v", interp, x, y, v0, v1)
+				}
+			}
+		}
+	}
+}
+
+// TestWarpIdentity_rgb96f tests the RGB96f pixels of an image whose
+// origin is negative.
+func TestWarpIdentity_rgb96f(t *testing.T) {
+	b := image.Rect(-20, -10, 20, 20)
+	rgb := image_ext.NewRGB96f(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			rgb.SetRGB96f(x, y, color_ext.RGB96f{R: float32(x), G: float32(y), B: 1000})
+		}
+	}
+	src := dem.NewGeoRaster(rgb, 116, 40, 0.01, 0.01)
+	src.CRS = "EPSG:4326"
+	for _, interp := range []Interp{Interp_Nearest, Interp_Bilinear} {
+		dst := *src
+		dst.Image = image_ext.NewRGB96f(b)
+		if err := WarpTo(&dst, src, &Options{Interp: interp}); err != nil {
+			t.Fatal(err)
+		}
+		m := dst.Image.(*image_ext.RGB96f)
+		for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+			for x := b.Min.X + 1; x < b.Max.X-1; x++ {
+				if c := m.RGB96fAt(x, y); math.Abs(float64(c.R)-float64(x)) > 1e-3 || math.Abs(float64(c.G)-float64(y)) > 1e-3 || c.B != 1000 {
+					t.Fatalf("%d: (%d,%d): bad color %v", interp, x, y, c)
+				}
+			}
+		}
+	}
+}
+
+func TestWarp(t *testing.T) {
+	src := tLonRaster()
+	for _, interp := range []Interp{Interp_Nearest, Interp_Bilinear, Interp_Cubic} {
+		dst, err := Warp(src, "EPSG:3857", &Options{Interp: interp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dst.CRS != "EPSG:3857" || !dst.HasNoData || dst.NoData != -9999 {
+			t.Fatalf("bad raster: %q, %v, %v", dst.CRS, dst.HasNoData, dst.NoData)
+		}
+
+		// the cubic kernel is truncated along the edges of src
+		tolerance := 1e-4
+		switch interp {
+		case Interp_Nearest:
+			tolerance = 0.01
+		case Interp_Cubic:
+			tolerance = 1e-3
+		}
+		m, b := dst.Image.(*image_ext.Gray32f), dst.Image.Bounds()
+		valid := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				v := m.Gray32fAt(x, y).Y
+				if v == -9999 {
+					continue
+				}
+				mx, my := dst.Transform.PixelToMap(float64(x)+0.5, float64(y)+0.5)
+				lon, _, _ := proj.WebMercator.Inverse(mx, my)
+				if math.Abs(float64(v)-lon) > tolerance {
+					t.Fatalf("%d: (%d,%d): expect = %v, got = %v", interp, x, y, lon, v)
+				}
+				valid++
+			}
+		}
+		if valid < b.Dx()*b.Dy()*9/10 {
+			t.Fatalf("%d: too few pixels: %d/%d", interp, valid, b.Dx()*b.Dy())
+		}
+	}
+}
+
+func TestWarpBigDem(t *testing.T) {
+	src := tLonRaster()
+	utm, err := tUTMRaster(src, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WarpTo(utm, src, &Options{Interp: Interp_Bilinear}); err != nil {
+		t.Fatal(err)
+	}
+
+	bigDem := big.NewDem(utm.Image.Bounds(), image.Pt(3, 3), color_ext.Gray32f{})
+	tiled := *utm
+	tiled.Image = bigDem
+	if err := WarpTo(&tiled, src, &Options{Interp: Interp_Bilinear}); err != nil {
+		t.Fatal(err)
+	}
+	m, b := utm.Image.(*image_ext.Gray32f), utm.Image.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if v0, v1 := m.Gray32fAt(x, y), bigDem.Gray32fAt(x, y); v0 != v1 {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, v0, v1)
+			}
+		}
+	}
+}
+
+// tUTMRaster returns an empty UTM raster over src, with the cell size.
+func tUTMRaster(src *dem.GeoRaster, cellSize float64) (*dem.GeoRaster, error) {
+	minX, _, maxX, maxY := src.Bounds()
+	utm, err := proj.NewUTM(proj.UTMZone((minX+maxX)/2), false)
+	if err != nil {
+		return nil, err
+	}
+	x0, y0, _ := utm.Forward(minX, maxY)
+	r := image.Rect(0, 0, 7, 5)
+	return &dem.GeoRaster{
+		Image:     image_ext.NewGray32f(r),
+		Transform: dem.NewGeoTransform(x0, y0, cellSize, cellSize),
+		NoData:    -9999,
+		HasNoData: true,
+		CRS:       utm.String(),
+	}, nil
+}
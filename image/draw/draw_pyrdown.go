@@ -8,18 +8,40 @@ import (
 	"image"
 	"image/draw"
 
-	image_ext "github.com/chai2010/image"
+	image_ext "github.com/chai2010/gopkg/image"
 )
 
 type Filter int
 
 const (
-	Filter_Average Filter = iota
-	Filter_Interlace
+	Filter_Average   Filter = iota // the mean of the 2x2 pixels
+	Filter_Interlace               // the top-left pixel of the 2x2 pixels
+	Filter_Bilinear                // the 4x4 pixels with the triangle weights
+	Filter_Gaussian                // the 6x6 pixels with the binomial weights
+	Filter_Mode                    // the most frequent color of the 2x2 pixels
 )
 
+// Filter_Nearest is the nearest neighbour filter.
+const Filter_Nearest = Filter_Interlace
+
+// Margin returns the number of the pixels read by the filter around the
+// 2x2 pixels of every downsampled pixel.
+func (f Filter) Margin() int {
+	switch f {
+	case Filter_Bilinear:
+		return len(kernelBilinear)/2 - 1
+	case Filter_Gaussian:
+		return len(kernelGaussian)/2 - 1
+	}
+	return 0
+}
+
 // DrawPyrDown aligns r.Min in dst with sp in src and then replaces
 // the rectangle r in dst with downsamples src.
+//
+// The Filter_Bilinear and Filter_Gaussian filters also read the pixels
+// of src around the 2x2 pixels (see Filter.Margin), the pixels out of
+// src.Bounds() are skipped.
 func DrawPyrDown(
 	dst draw.Image, r image.Rectangle, src image.Image, sp image.Point,
 	filter Filter,
@@ -95,6 +117,15 @@ func DrawPyrDown(
 			drawPyrDown_Interlace(dst, r, src, sp)
 			return
 		}
+	case Filter_Bilinear:
+		drawPyrDown_Kernel(dst, r, src, sp, kernelBilinear)
+		return
+	case Filter_Gaussian:
+		drawPyrDown_Kernel(dst, r, src, sp, kernelGaussian)
+		return
+	case Filter_Mode:
+		drawPyrDown_Mode(dst, r, src, sp)
+		return
 	}
 	panic("image/draw: DrawPyrDown, unreachable")
 }
@@ -0,0 +1,57 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package draw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+var (
+	kernelBilinear = []float64{1, 3, 3, 1}
+	kernelGaussian = []float64{1, 5, 10, 10, 5, 1}
+)
+
+func drawPyrDown_Kernel(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point, kernel []float64) {
+	b := src.Bounds()
+	margin := len(kernel)/2 - 1
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			x0 := (x-r.Min.X)*2 + sp.X - margin
+			y0 := (y-r.Min.Y)*2 + sp.Y - margin
+
+			var weight float64
+			var v [4]float64
+			var c color.Color
+			for j, ky := range kernel {
+				if y0+j < b.Min.Y || y0+j >= b.Max.Y {
+					continue
+				}
+				for i, kx := range kernel {
+					if x0+i < b.Min.X || x0+i >= b.Max.X {
+						continue
+					}
+					c = src.At(x0+i, y0+j)
+					cv := color_ext.Values(c)
+					w := kx * ky
+					for k := 0; k < len(v); k++ {
+						v[k] += cv[k] * w
+					}
+					weight += w
+				}
+			}
+			if weight == 0 {
+				continue
+			}
+			for k := 0; k < len(v); k++ {
+				v[k] /= weight
+			}
+			dst.Set(x, y, color_ext.FromValues(c, v))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package draw
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+func drawPyrDown_Mode(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	b := src.Bounds()
+	var colors [4]color.Color
+	var counts [4]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			x0 := (x-r.Min.X)*2 + sp.X
+			y0 := (y-r.Min.Y)*2 + sp.Y
+
+			n := 0
+			for _, pt := range [4]image.Point{{x0, y0}, {x0 + 1, y0}, {x0, y0 + 1}, {x0 + 1, y0 + 1}} {
+				if !pt.In(b) {
+					continue
+				}
+				c, i := src.At(pt.X, pt.Y), 0
+				for i < n && colors[i] != c {
+					i++
+				}
+				if i == n {
+					colors[n], counts[n] = c, 0
+					n++
+				}
+				counts[i]++
+			}
+			if n == 0 {
+				continue
+			}
+
+			// the first of the most frequent colors
+			best := 0
+			for i := 1; i < n; i++ {
+				if counts[i] > counts[best] {
+					best = i
+				}
+			}
+			dst.Set(x, y, colors[best])
+		}
+	}
+}
@@ -48,6 +48,56 @@ func TestDrawPyrDown_Interlace(t *testing.T) {
 	}
 }
 
+func TestDrawPyrDown_Kernel(t *testing.T) {
+	for _, filter := range []Filter{Filter_Bilinear, Filter_Gaussian, Filter_Mode} {
+		for i, v := range tDrawPyrDownTesterList {
+			tClearImage(v.BgdImage, v.BgdColor)
+			tClearImage(v.FgdImage, v.FgdColor)
+			DrawPyrDown(v.BgdImage, v.DrawRect, v.FgdImage, v.DrawSp, filter)
+			err := tCheckImageColor(v.BgdImage, v.FgdRect, v.FgdColor, v.BgdColor)
+			if err != nil {
+				t.Fatalf("%d: %d: %v", filter, i, err)
+			}
+		}
+	}
+}
+
+func TestDrawPyrDown_Gaussian_margin(t *testing.T) {
+	// a vertical edge at x = 4: the downsampled pixel 1 reads it
+	src := image_ext.NewGray32f(image.Rect(0, 0, 8, 2))
+	for x := 4; x < 8; x++ {
+		src.SetGray32f(x, 0, color_ext.Gray32f{Y: 32})
+		src.SetGray32f(x, 1, color_ext.Gray32f{Y: 32})
+	}
+	dst := image_ext.NewGray32f(image.Rect(0, 0, 4, 1))
+	DrawPyrDown(dst, dst.Bounds(), src, image.Pt(0, 0), Filter_Gaussian)
+	if v := dst.Gray32fAt(0, 0).Y; v != 0 {
+		t.Fatalf("expect = 0, got = %v", v)
+	}
+	if v := dst.Gray32fAt(1, 0).Y; v != 5+1 {
+		t.Fatalf("expect = 6, got = %v", v)
+	}
+	if v := dst.Gray32fAt(2, 0).Y; v != 10+10+5+1 {
+		t.Fatalf("expect = 26, got = %v", v)
+	}
+	if Filter_Gaussian.Margin() != 2 || Filter_Bilinear.Margin() != 1 || Filter_Average.Margin() != 0 {
+		t.Fatalf("bad margins")
+	}
+}
+
+func TestDrawPyrDown_Mode(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	copy(src.Pix, []uint8{
+		1, 2, 3, 4,
+		2, 2, 4, 3,
+	})
+	dst := image.NewGray(image.Rect(0, 0, 2, 1))
+	DrawPyrDown(dst, dst.Bounds(), src, image.Pt(0, 0), Filter_Mode)
+	if dst.Pix[0] != 2 || dst.Pix[1] != 3 {
+		t.Fatalf("expect = [2 3], got = %v", dst.Pix)
+	}
+}
+
 var tDrawPyrDownTesterList = []tDrawPyrDownTester{
 	// Gray
 	tDrawPyrDownTester{
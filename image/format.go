@@ -20,6 +20,9 @@ import (
 // Decode is the function that decodes the encoded image.
 // DecodeConfig is the function that decodes just its configuration.
 // Encode is the function that encodes just its configuration.
+//
+// A format without magic (like image/gdal) is a fallback: Decode and
+// DecodeConfig use the first one if no magic matches.
 type Format struct {
 	Name         string
 	Extensions   []string
@@ -100,13 +103,27 @@ func sniffByMagic(r reader) Format {
 	return Format{}
 }
 
+// sniff determines the format of r's data, or returns the first
+// fallback format.
+func sniff(r reader) Format {
+	if f := sniffByMagic(r); f.Name != "" {
+		return f
+	}
+	for _, f := range formats {
+		if len(f.Magics) == 0 {
+			return f
+		}
+	}
+	return Format{}
+}
+
 // Decode decodes an image that has been encoded in a registered format.
 // The string returned is the format name used during format registration.
 // Format registration is typically done by an init function in the codec-
 // specific package.
 func Decode(r io.Reader, opt interface{}) (image.Image, string, error) {
 	rr := asReader(r)
-	f := sniffByMagic(rr)
+	f := sniff(rr)
 	if f.Decode == nil {
 		return nil, "", image.ErrFormat
 	}
@@ -120,7 +137,7 @@ func Decode(r io.Reader, opt interface{}) (image.Image, string, error) {
 // an init function in the codec-specific package.
 func DecodeConfig(r io.Reader) (image.Config, string, error) {
 	rr := asReader(r)
-	f := sniffByMagic(rr)
+	f := sniff(rr)
 	if f.DecodeConfig == nil {
 		return image.Config{}, "", image.ErrFormat
 	}
@@ -128,6 +145,13 @@ func DecodeConfig(r io.Reader) (image.Config, string, error) {
 	return c, f.Name, err
 }
 
+// FormatName returns the name of the format registered with the
+// extension of filename (like "tile.jpg" or ".jpg"), or "" if there
+// is none.
+func FormatName(filename string) string {
+	return sniffByName(filename).Name
+}
+
 // Encode encodes an image as a registered format.
 // The format is the format name used during format registration.
 // Format registration is typically done by an init function in the codec-
@@ -141,6 +165,26 @@ func Encode(format string, w io.Writer, m image.Image, opt interface{}) error {
 	return image.ErrFormat
 }
 
+// A Sidecar reads and writes a sidecar file of the images, like the world
+// file registered by image/dem/TiffWorld. Name is the key of its data in
+// the sidecars of LoadWithSidecars and SaveWithSidecars. Load returns the
+// data of the sidecar file of the image filename, or nil if there is none.
+// Save writes the data as the sidecar file of the image filename.
+type Sidecar struct {
+	Name string
+	Load func(filename string) (data interface{}, err error)
+	Save func(filename string, data interface{}) error
+}
+
+// sidecars is the list of registered sidecars.
+var sidecars []Sidecar
+
+// RegisterSidecar registers a sidecar file for use by LoadWithSidecars and
+// SaveWithSidecars.
+func RegisterSidecar(s Sidecar) {
+	sidecars = append(sidecars, s)
+}
+
 func Load(filename string, opt interface{}) (m image.Image, format string, err error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -154,15 +198,56 @@ func Load(filename string, opt interface{}) (m image.Image, format string, err e
 	return
 }
 
+// LoadWithSidecars decodes the image file like Load, and reads the
+// registered sidecar files of the image. The sidecar data is keyed by
+// Sidecar.Name, the sidecar files which are missing or can not be read
+// are skipped, so they never fail the image load.
+func LoadWithSidecars(filename string, opt interface{}) (m image.Image, format string, data map[string]interface{}, err error) {
+	if m, format, err = Load(filename, opt); err != nil {
+		return
+	}
+	for _, s := range sidecars {
+		if v, err := s.Load(filename); err == nil && v != nil {
+			if data == nil {
+				data = make(map[string]interface{})
+			}
+			data[s.Name] = v
+		}
+	}
+	return
+}
+
+// Save encodes the image in the format registered with the extension of
+// filename.
 func Save(filename string, m image.Image, opt interface{}) (err error) {
+	format := sniffByName(filename)
+	if format.Encode == nil {
+		return image.ErrFormat
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
 		return
 	}
-	defer f.Close()
+	if err = format.Encode(f, m, opt); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
 
-	if err = sniffByName(filename).Encode(f, m, opt); err != nil {
+// SaveWithSidecars encodes the image like Save, and writes the sidecar
+// files of the data returned by LoadWithSidecars next to it.
+func SaveWithSidecars(filename string, m image.Image, opt interface{}, data map[string]interface{}) (err error) {
+	if err = Save(filename, m, opt); err != nil {
 		return
 	}
+	for _, s := range sidecars {
+		if v, ok := data[s.Name]; ok && v != nil {
+			if err = s.Save(filename, v); err != nil {
+				return
+			}
+		}
+	}
 	return
 }
@@ -0,0 +1,177 @@
+// Copyright 2011 go-gdal. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdal
+
+/*
+#include "go_gdal.h"
+*/
+import "C"
+import (
+	"fmt"
+	"image"
+	"unsafe"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// RasterBand is a band of a Dataset, it is valid until the Dataset is
+// closed.
+type RasterBand struct {
+	h  GDALRasterBandH
+	ds *Dataset
+}
+
+// Handle returns the GDALRasterBandH of the band.
+func (p *RasterBand) Handle() GDALRasterBandH {
+	return p.h
+}
+
+// Dataset returns the dataset of the band.
+func (p *RasterBand) Dataset() *Dataset {
+	return p.ds
+}
+
+func (p *RasterBand) DataType() GDALDataType {
+	return GDALDataType(C.GDALGetRasterDataType(C.GDALRasterBandH(p.h)))
+}
+
+func (p *RasterBand) Width() int {
+	return int(C.GDALGetRasterBandXSize(C.GDALRasterBandH(p.h)))
+}
+
+func (p *RasterBand) Height() int {
+	return int(C.GDALGetRasterBandYSize(C.GDALRasterBandH(p.h)))
+}
+
+// Bounds returns the pixel rectangle of the band, at (0, 0).
+func (p *RasterBand) Bounds() image.Rectangle {
+	return image.Rect(0, 0, p.Width(), p.Height())
+}
+
+// BlockSize returns the natural block size of the band, the read and
+// write of the whole blocks are the most efficient.
+func (p *RasterBand) BlockSize() image.Point {
+	var x, y C.int
+	C.GDALGetBlockSize(C.GDALRasterBandH(p.h), &x, &y)
+	return image.Pt(int(x), int(y))
+}
+
+// Description returns the description of the band, "" if it is not set.
+func (p *RasterBand) Description() string {
+	return C.GoString(C.GDALGetDescription(C.GDALMajorObjectH(p.h)))
+}
+
+// NoData returns the nodata value of the band, ok is false if the band
+// has no nodata value.
+func (p *RasterBand) NoData() (v float64, ok bool) {
+	var success C.int
+	v = float64(C.GDALGetRasterNoDataValue(C.GDALRasterBandH(p.h), &success))
+	ok = success != 0
+	return
+}
+
+func (p *RasterBand) SetNoData(v float64) (err error) {
+	C.CPLErrorReset()
+	if C.GDALSetRasterNoDataValue(C.GDALRasterBandH(p.h), C.double(v)) != C.CE_None {
+		err = fmt.Errorf("image/gdal: RasterBand.SetNoData, %s", lastError())
+	}
+	return
+}
+
+func (p *RasterBand) ColorInterp() GDALColorInterp {
+	return GDALColorInterp(C.GDALGetRasterColorInterpretation(C.GDALRasterBandH(p.h)))
+}
+
+func (p *RasterBand) SetColorInterp(v GDALColorInterp) (err error) {
+	C.CPLErrorReset()
+	if C.GDALSetRasterColorInterpretation(C.GDALRasterBandH(p.h), C.GDALColorInterp(v)) != C.CE_None {
+		err = fmt.Errorf("image/gdal: RasterBand.SetColorInterp, %s", lastError())
+	}
+	return
+}
+
+// OverviewCount returns the number of the reduced resolution overviews.
+func (p *RasterBand) OverviewCount() int {
+	return int(C.GDALGetOverviewCount(C.GDALRasterBandH(p.h)))
+}
+
+// MetadataItem returns the metadata item of the domain ("" is the
+// default domain), or "" if it is not set.
+func (p *RasterBand) MetadataItem(name, domain string) string {
+	return metadataItem(C.GDALMajorObjectH(p.h), name, domain)
+}
+
+// ReadRect reads the pixels r of the band to buf, and returns buf.
+//
+// If buf is nil, it is a new image of the bounds r: a Gray, Gray16 or
+// Gray32f image for the Byte, UInt16 and other bands. buf can be any of
+// these images, the samples are converted by GDAL. If the size of buf is
+// not the size of r, the pixels are resampled.
+func (p *RasterBand) ReadRect(r image.Rectangle, buf image_ext.ImageBuffer) (m image.Image, err error) {
+	if err = p.checkRect("ReadRect", r); err != nil {
+		return
+	}
+	if buf == nil {
+		buf = newImage(r, p.DataType(), 1)
+	}
+	px, ok := imagePixels(buf)
+	if !ok || px.Channels != 1 {
+		err = fmt.Errorf("image/gdal: RasterBand.ReadRect, unsupported buffer: %T", buf)
+		return
+	}
+	if err = p.rasterIO(GF_Read, r, &px); err != nil {
+		return
+	}
+	if px.needSwap() {
+		px.swap()
+	}
+	m = buf
+	return
+}
+
+// WriteRect writes the pixels of m (a Gray, Gray16 or Gray32f image) to
+// the pixels r of the band, the pixel m.Bounds().Min is written to r.Min.
+// If the size of m is not the size of r, the pixels are resampled.
+func (p *RasterBand) WriteRect(r image.Rectangle, m image.Image) (err error) {
+	if err = p.checkRect("WriteRect", r); err != nil {
+		return
+	}
+	if m.Bounds().Empty() {
+		return
+	}
+	px, ok := imagePixels(m)
+	if !ok || px.Channels != 1 {
+		err = fmt.Errorf("image/gdal: RasterBand.WriteRect, unsupported image: %T", m)
+		return
+	}
+	if px.needSwap() {
+		px = px.clone()
+		px.swap()
+	}
+	return p.rasterIO(GF_Write, r, &px)
+}
+
+func (p *RasterBand) checkRect(method string, r image.Rectangle) error {
+	if p.ds.h == nil {
+		return fmt.Errorf("image/gdal: RasterBand.%s, the dataset is closed", method)
+	}
+	if r.Empty() || !r.In(p.Bounds()) {
+		return fmt.Errorf("image/gdal: RasterBand.%s, bad rect: %v", method, r)
+	}
+	return nil
+}
+
+func (p *RasterBand) rasterIO(rw GDALRWFlag, r image.Rectangle, px *pixels) error {
+	C.CPLErrorReset()
+	if C.GDALRasterIO(
+		C.GDALRasterBandH(p.h), C.GDALRWFlag(rw),
+		C.int(r.Min.X), C.int(r.Min.Y), C.int(r.Dx()), C.int(r.Dy()),
+		unsafe.Pointer(&px.Pix[0]), C.int(px.Rect.Dx()), C.int(px.Rect.Dy()),
+		C.GDALDataType(px.DataType), C.int(px.Size), C.int(px.Stride),
+	) != C.CE_None {
+		return fmt.Errorf("image/gdal: RasterBand.RasterIO, %s", lastError())
+	}
+	return nil
+}
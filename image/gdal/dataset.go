@@ -0,0 +1,232 @@
+// Copyright 2011 go-gdal. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdal
+
+/*
+#include "go_gdal.h"
+*/
+import "C"
+import (
+	"fmt"
+	"image"
+	"unsafe"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// Dataset is a raster dataset of GDAL, normally a file.
+//
+// A Dataset must be closed by Close, which flushes the written pixels,
+// and its bands can not be used after it is closed.
+type Dataset struct {
+	h GDALDatasetH
+}
+
+// Open opens the dataset of the filename (or any name which GDAL
+// understands, like the "/vsizip/" paths).
+func Open(filename string, access GDALAccess) (p *Dataset, err error) {
+	name := C.CString(filename)
+	defer C.free(unsafe.Pointer(name))
+
+	C.CPLErrorReset()
+	h := C.GDALOpen(name, C.GDALAccess(access))
+	if h == nil {
+		err = fmt.Errorf("image/gdal: Open, %s: %s", filename, lastError())
+		return
+	}
+	p = &Dataset{h: GDALDatasetH(h)}
+	return
+}
+
+// Close closes the dataset, it is a no-op if the dataset is closed.
+func (p *Dataset) Close() (err error) {
+	if p.h == nil {
+		return
+	}
+	C.CPLErrorReset()
+	C.GDALClose(C.GDALDatasetH(p.h))
+	p.h = nil
+	if C.CPLGetLastErrorType() >= C.CE_Failure {
+		err = fmt.Errorf("image/gdal: Dataset.Close, %s", lastError())
+	}
+	return
+}
+
+// Handle returns the GDALDatasetH of the dataset, or nil if it is closed.
+func (p *Dataset) Handle() GDALDatasetH {
+	return p.h
+}
+
+// Driver returns the driver of the dataset.
+func (p *Dataset) Driver() *Driver {
+	return &Driver{h: GDALDriverH(C.GDALGetDatasetDriver(C.GDALDatasetH(p.h)))}
+}
+
+func (p *Dataset) Width() int {
+	return int(C.GDALGetRasterXSize(C.GDALDatasetH(p.h)))
+}
+
+func (p *Dataset) Height() int {
+	return int(C.GDALGetRasterYSize(C.GDALDatasetH(p.h)))
+}
+
+// Bounds returns the pixel rectangle of the dataset, at (0, 0).
+func (p *Dataset) Bounds() image.Rectangle {
+	return image.Rect(0, 0, p.Width(), p.Height())
+}
+
+func (p *Dataset) BandCount() int {
+	return int(C.GDALGetRasterCount(C.GDALDatasetH(p.h)))
+}
+
+// Band returns the band i (from 0, the GDAL band i+1), or nil if there
+// is no such band.
+func (p *Dataset) Band(i int) *RasterBand {
+	if i < 0 || i >= p.BandCount() {
+		return nil
+	}
+	h := C.GDALGetRasterBand(C.GDALDatasetH(p.h), C.int(i+1))
+	return &RasterBand{h: GDALRasterBandH(h), ds: p}
+}
+
+// DataType returns the data type of the first band.
+func (p *Dataset) DataType() GDALDataType {
+	if b := p.Band(0); b != nil {
+		return b.DataType()
+	}
+	return GDT_Unknown
+}
+
+// GeoTransform returns the affine transform from the pixel coordinates
+// to the map coordinates, in the GDAL order:
+//
+//	X = T[0] + x*T[1] + y*T[2]
+//	Y = T[3] + x*T[4] + y*T[5]
+func (p *Dataset) GeoTransform() (t [6]float64, err error) {
+	var v [6]C.double
+	C.CPLErrorReset()
+	if C.GDALGetGeoTransform(C.GDALDatasetH(p.h), &v[0]) != C.CE_None {
+		err = fmt.Errorf("image/gdal: Dataset.GeoTransform, %s", lastError())
+		return
+	}
+	for i := range t {
+		t[i] = float64(v[i])
+	}
+	return
+}
+
+func (p *Dataset) SetGeoTransform(t [6]float64) (err error) {
+	var v [6]C.double
+	for i := range t {
+		v[i] = C.double(t[i])
+	}
+	C.CPLErrorReset()
+	if C.GDALSetGeoTransform(C.GDALDatasetH(p.h), &v[0]) != C.CE_None {
+		err = fmt.Errorf("image/gdal: Dataset.SetGeoTransform, %s", lastError())
+	}
+	return
+}
+
+// Projection returns the WKT of the CRS, or "" if it is unknown.
+func (p *Dataset) Projection() string {
+	return C.GoString(C.GDALGetProjectionRef(C.GDALDatasetH(p.h)))
+}
+
+// SetProjection sets the CRS, a WKT string.
+func (p *Dataset) SetProjection(wkt string) (err error) {
+	s := C.CString(wkt)
+	defer C.free(unsafe.Pointer(s))
+
+	C.CPLErrorReset()
+	if C.GDALSetProjection(C.GDALDatasetH(p.h), s) != C.CE_None {
+		err = fmt.Errorf("image/gdal: Dataset.SetProjection, %s", lastError())
+	}
+	return
+}
+
+// MetadataItem returns the metadata item of the domain ("" is the
+// default domain), or "" if it is not set.
+func (p *Dataset) MetadataItem(name, domain string) string {
+	return metadataItem(C.GDALMajorObjectH(p.h), name, domain)
+}
+
+// ReadRect reads the pixels r of the bands to buf, and returns buf.
+//
+// If buf is nil, it is a new image of the bounds r: a Gray, RGB or
+// NRGBA image (with 8-bit or 16-bit samples) for the Byte and UInt16
+// bands, or a float image for the others. buf can be any of these images
+// (but not an RGBA or RGBA64 image, the alpha bands are not
+// premultiplied), the first bands of the dataset are read to its channels. If the size of
+// buf is not the size of r, the pixels are resampled (from the overviews
+// if there are some).
+func (p *Dataset) ReadRect(r image.Rectangle, buf image_ext.ImageBuffer) (m image.Image, err error) {
+	if err = p.checkRect("ReadRect", r); err != nil {
+		return
+	}
+	if buf == nil {
+		buf = newImage(r, p.DataType(), p.BandCount())
+	}
+	px, ok := imagePixels(buf)
+	if !ok || px.Channels > p.BandCount() {
+		err = fmt.Errorf("image/gdal: Dataset.ReadRect, unsupported buffer: %T", buf)
+		return
+	}
+	if err = p.rasterIO(GF_Read, r, &px); err != nil {
+		return
+	}
+	if px.needSwap() {
+		px.swap()
+	}
+	m = buf
+	return
+}
+
+// WriteRect writes the pixels of m to the pixels r of the bands, the
+// pixel m.Bounds().Min is written to r.Min. If the size of m is not the
+// size of r, the pixels are resampled. The images which are not read by
+// ReadRect are converted to NRGBA images (the RGBA images) or to NRGBA64
+// images (the others).
+func (p *Dataset) WriteRect(r image.Rectangle, m image.Image) (err error) {
+	if err = p.checkRect("WriteRect", r); err != nil {
+		return
+	}
+	if m.Bounds().Empty() {
+		return
+	}
+	px := toPixels(m)
+	if px.Channels > p.BandCount() {
+		err = fmt.Errorf("image/gdal: Dataset.WriteRect, too many channels: %T", m)
+		return
+	}
+	if px.needSwap() {
+		px = px.clone()
+		px.swap()
+	}
+	return p.rasterIO(GF_Write, r, &px)
+}
+
+func (p *Dataset) checkRect(method string, r image.Rectangle) error {
+	if p.h == nil {
+		return fmt.Errorf("image/gdal: Dataset.%s, the dataset is closed", method)
+	}
+	if r.Empty() || !r.In(p.Bounds()) {
+		return fmt.Errorf("image/gdal: Dataset.%s, bad rect: %v", method, r)
+	}
+	return nil
+}
+
+func (p *Dataset) rasterIO(rw GDALRWFlag, r image.Rectangle, px *pixels) error {
+	C.CPLErrorReset()
+	if C.GDALDatasetRasterIO(
+		C.GDALDatasetH(p.h), C.GDALRWFlag(rw),
+		C.int(r.Min.X), C.int(r.Min.Y), C.int(r.Dx()), C.int(r.Dy()),
+		unsafe.Pointer(&px.Pix[0]), C.int(px.Rect.Dx()), C.int(px.Rect.Dy()),
+		C.GDALDataType(px.DataType), C.int(px.Channels), nil,
+		C.int(px.Channels*px.Size), C.int(px.Stride), C.int(px.Size),
+	) != C.CE_None {
+		return fmt.Errorf("image/gdal: Dataset.RasterIO, %s", lastError())
+	}
+	return nil
+}
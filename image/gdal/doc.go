@@ -2,5 +2,30 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Bindings for GDAL - Geospatial Data Abstraction Library
+/*
+Bindings for GDAL - Geospatial Data Abstraction Library
+
+The Dataset, RasterBand and Driver types wrap the GDAL handles:
+
+	ds, err := gdal.Open("n40e116.tif", gdal.GA_ReadOnly)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ds.Close()
+
+	gt, err := ds.GeoTransform()
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := ds.Band(0).ReadRect(image.Rect(0, 0, 256, 256), nil)
+
+RegisterFormat registers the "gdal" format without magic to image.RegisterFormat,
+which is the fallback of image.Decode and image.Load for the formats which are
+not registered. The fallback reads only a few raster formats of a single file
+(like GTiff, HFA and AAIGrid):
+
+	gdal.RegisterFormat()
+
+	m, format, err := image_ext.Load("n40e116.img", nil)
+*/
 package gdal
@@ -0,0 +1,146 @@
+// Copyright 2011 go-gdal. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdal
+
+/*
+#include "go_gdal.h"
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Driver is a format driver of GDAL.
+//
+// The drivers are owned by the GDAL driver manager, they are not closed.
+type Driver struct {
+	h GDALDriverH
+}
+
+// GetDriver returns the driver of the short name, like "GTiff" or "MEM".
+func GetDriver(name string) (p *Driver, err error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	h := C.GDALGetDriverByName(cname)
+	if h == nil {
+		err = fmt.Errorf("image/gdal: GetDriver, unknown driver: %q", name)
+		return
+	}
+	p = &Driver{h: GDALDriverH(h)}
+	return
+}
+
+// Drivers returns all the registered drivers.
+func Drivers() []*Driver {
+	n := int(C.GDALGetDriverCount())
+	drivers := make([]*Driver, n)
+	for i := 0; i < n; i++ {
+		drivers[i] = &Driver{h: GDALDriverH(C.GDALGetDriver(C.int(i)))}
+	}
+	return drivers
+}
+
+// Handle returns the GDALDriverH of the driver.
+func (p *Driver) Handle() GDALDriverH {
+	return p.h
+}
+
+// ShortName returns the short name of the driver, like "GTiff".
+func (p *Driver) ShortName() string {
+	return C.GoString(C.GDALGetDriverShortName(C.GDALDriverH(p.h)))
+}
+
+// LongName returns the long name of the driver, like "GeoTIFF".
+func (p *Driver) LongName() string {
+	return C.GoString(C.GDALGetDriverLongName(C.GDALDriverH(p.h)))
+}
+
+// MetadataItem returns the metadata item of the driver, like
+// GDAL_DMD_EXTENSION or GDAL_DCAP_CREATE, or "" if it is not set.
+func (p *Driver) MetadataItem(name string) string {
+	return metadataItem(C.GDALMajorObjectH(p.h), name, "")
+}
+
+// Create creates a new dataset of the driver, which must have the
+// GDAL_DCAP_CREATE capability.
+func (p *Driver) Create(filename string, width, height, bands int, dataType GDALDataType, options []string) (ds *Dataset, err error) {
+	C.CPLErrorReset()
+	h := GDALCreate(p.h, filename, width, height, bands, dataType, options)
+	if h == nil {
+		err = fmt.Errorf("image/gdal: Driver.Create, %s: %s", filename, lastError())
+		return
+	}
+	ds = &Dataset{h: h}
+	return
+}
+
+// CreateCopy creates a copy of the dataset src with the driver.
+// If strict is false, the driver may adapt the data types and the
+// metadata which it does not support.
+func (p *Driver) CreateCopy(filename string, src *Dataset, strict bool, options []string) (ds *Dataset, err error) {
+	name := C.CString(filename)
+	defer C.free(unsafe.Pointer(name))
+
+	opts := cStringList(options)
+	defer freeCStringList(opts)
+
+	bStrict := C.int(0)
+	if strict {
+		bStrict = 1
+	}
+
+	C.CPLErrorReset()
+	h := C.GDALCreateCopy(
+		C.GDALDriverH(p.h), name,
+		C.GDALDatasetH(src.h),
+		bStrict, (**C.char)(unsafe.Pointer(&opts[0])),
+		nil, nil,
+	)
+	if h == nil {
+		err = fmt.Errorf("image/gdal: Driver.CreateCopy, %s: %s", filename, lastError())
+		return
+	}
+	ds = &Dataset{h: GDALDatasetH(h)}
+	return
+}
+
+// cStringList returns the NULL terminated list of C strings.
+func cStringList(list []string) []*C.char {
+	opts := make([]*C.char, len(list)+1)
+	for i := 0; i < len(list); i++ {
+		opts[i] = C.CString(list[i])
+	}
+	return opts
+}
+
+func freeCStringList(opts []*C.char) {
+	for _, s := range opts {
+		if s != nil {
+			C.free(unsafe.Pointer(s))
+		}
+	}
+}
+
+func metadataItem(h C.GDALMajorObjectH, name, domain string) string {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	var cdomain *C.char
+	if domain != "" {
+		cdomain = C.CString(domain)
+		defer C.free(unsafe.Pointer(cdomain))
+	}
+	return C.GoString(C.GDALGetMetadataItem(h, cname, cdomain))
+}
+
+// lastError returns the message of the last CPL error.
+func lastError() string {
+	if msg := C.GoString(C.CPLGetLastErrorMsg()); msg != "" {
+		return msg
+	}
+	return "unknown error"
+}
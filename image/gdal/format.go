@@ -0,0 +1,245 @@
+// Copyright 2011 go-gdal. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdal
+
+/*
+#include "go_gdal.h"
+*/
+import "C"
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// Options are the encoding parameters.
+type Options struct {
+	DriverName    string   // the driver short name, the default is "GTiff"
+	CreateOptions []string // the creation options, like "COMPRESS=LZW"
+}
+
+func (p *Options) adjust() Options {
+	var o Options
+	if p != nil {
+		o = *p
+	}
+	if o.DriverName == "" {
+		o.DriverName = "GTiff"
+	}
+	return o
+}
+
+// DecodeConfig returns the color model and dimensions of an image of any
+// format which GDAL can read from a single file. Like Decode, it reads all
+// the data of r in memory.
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	ds, name, err := openReader(r)
+	if err != nil {
+		return
+	}
+	defer removeMemFile(name)
+	defer ds.Close()
+
+	config = image.Config{
+		ColorModel: newImage(image.Rect(0, 0, 1, 1), ds.DataType(), ds.BandCount()).ColorModel(),
+		Width:      ds.Width(),
+		Height:     ds.Height(),
+	}
+	return
+}
+
+// Decode reads an image of any format which GDAL can read from a single
+// file, the image type is the buffer of Dataset.ReadRect. The data of r is
+// read in memory and copied to a GDAL memory file, so it is limited to
+// MaxDataSize bytes.
+func Decode(r io.Reader) (m image.Image, err error) {
+	ds, name, err := openReader(r)
+	if err != nil {
+		return
+	}
+	defer removeMemFile(name)
+	defer ds.Close()
+
+	// width*height*bands*size > maxPixelsSize, without overflow
+	size := GDALGetDataTypeSize(ds.DataType()) / 8
+	if size <= 0 || ds.BandCount() <= 0 || ds.Height() <= 0 || ds.Width()*ds.BandCount() > maxPixelsSize/size/ds.Height() {
+		err = fmt.Errorf("image/gdal: Decode, image too large: %v", ds.Bounds())
+		return
+	}
+	m, err = ds.ReadRect(ds.Bounds(), nil)
+	return
+}
+
+// Encode writes the image m to w in the format of the GDAL driver
+// opt.DriverName, which must have the GDAL_DCAP_CREATECOPY capability.
+func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
+	o := opt.adjust()
+	driver, err := GetDriver(o.DriverName)
+	if err != nil {
+		return
+	}
+	mem, err := GetDriver("MEM")
+	if err != nil {
+		return
+	}
+
+	b := m.Bounds()
+	if b.Empty() {
+		err = fmt.Errorf("image/gdal: Encode, empty image")
+		return
+	}
+	px := toPixels(m)
+	src, err := mem.Create("", b.Dx(), b.Dy(), px.Channels, px.DataType, nil)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	if err = src.WriteRect(src.Bounds(), m); err != nil {
+		return
+	}
+
+	name := newMemFileName()
+	defer removeMemFile(name)
+	dst, err := driver.CreateCopy(name, src, false, o.CreateOptions)
+	if err != nil {
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	_, err = w.Write(readMemFile(name))
+	return
+}
+
+// MaxDataSize is the maximum size of the data read by Decode and
+// DecodeConfig.
+const MaxDataSize = 1 << 30
+
+// maxPixelsSize is the maximum size of the pixels decoded by Decode.
+const maxPixelsSize = 1 << 30
+
+// decodeDrivers are the drivers of Decode and DecodeConfig, the raster
+// formats of a single file. The drivers which open other files or URLs
+// (like VRT) are not used for the data of a reader.
+var decodeDrivers = []string{
+	"GTiff", "HFA", "AAIGrid", "EHdr", "ENVI", "ERS", "NITF",
+	"SRTMHGT", "USGSDEM", "BMP", "GIF", "PNG", "JPEG",
+}
+
+var memFileCount int64
+
+// newMemFileName returns a new file name of the GDAL memory files.
+func newMemFileName() string {
+	return fmt.Sprintf("/vsimem/image_gdal_%d", atomic.AddInt64(&memFileCount, 1))
+}
+
+// openReader opens the data of r as a memory file, which must be removed
+// after the dataset is closed. The data is limited to MaxDataSize bytes.
+func openReader(r io.Reader) (ds *Dataset, name string, err error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxDataSize+1))
+	if err != nil {
+		return
+	}
+	if len(data) == 0 {
+		err = fmt.Errorf("image/gdal: empty data")
+		return
+	}
+	if len(data) > MaxDataSize {
+		err = fmt.Errorf("image/gdal: data larger than %d bytes", MaxDataSize)
+		return
+	}
+
+	// the buffer is owned (and freed) by the memory file
+	buf := C.CPLMalloc(C.size_t(len(data)))
+	copy((*[MaxDataSize]byte)(buf)[:len(data):len(data)], data)
+
+	name = newMemFileName()
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	C.VSIFCloseL(C.VSIFileFromMemBuffer(cname, (*C.GByte)(buf), C.vsi_l_offset(len(data)), 1))
+
+	if ds, err = openRaster(name, decodeDrivers); err != nil {
+		removeMemFile(name)
+		name = ""
+	}
+	return
+}
+
+// openRaster opens the raster dataset of the filename read only, with
+// one of the drivers.
+func openRaster(filename string, drivers []string) (p *Dataset, err error) {
+	name := C.CString(filename)
+	defer C.free(unsafe.Pointer(name))
+
+	list := cStringList(drivers)
+	defer freeCStringList(list)
+
+	C.CPLErrorReset()
+	h := C.GDALOpenEx(
+		name, C.GDAL_OF_RASTER|C.GDAL_OF_READONLY,
+		(**C.char)(unsafe.Pointer(&list[0])), nil, nil,
+	)
+	if h == nil {
+		err = fmt.Errorf("image/gdal: Open, %s: %s", filename, lastError())
+		return
+	}
+	p = &Dataset{h: GDALDatasetH(h)}
+	return
+}
+
+func readMemFile(name string) []byte {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	var n C.vsi_l_offset
+	p := C.VSIGetMemFileBuffer(cname, &n, 0)
+	if p == nil {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(p), C.int(n))
+}
+
+func removeMemFile(name string) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	C.VSIUnlink(cname)
+}
+
+func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
+	return Decode(r)
+}
+
+func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
+	if opt, ok := opt.(*Options); ok {
+		return Encode(w, m, opt)
+	} else {
+		return Encode(w, m, nil)
+	}
+}
+
+// RegisterFormat registers the "gdal" format to image.RegisterFormat.
+// The format has no magic, so it is the fallback of image.Decode and
+// image.Load for the data which no other format recognizes; it is not
+// registered by the import of the package. It registers the format once.
+func RegisterFormat() {
+	registerOnce.Do(registerFormat)
+}
+
+var registerOnce sync.Once
+
+func registerFormat() {
+	image_ext.RegisterFormat(image_ext.Format{
+		Name:         "gdal",
+		DecodeConfig: DecodeConfig,
+		Decode:       imageExtDecode,
+		Encode:       imageExtEncode,
+	})
+}
@@ -3,3 +3,174 @@
 // license that can be found in the LICENSE file.
 
 package gdal
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func tNewMemDataset(t *testing.T, width, height, bands int, dataType GDALDataType) *Dataset {
+	mem, err := GetDriver("MEM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := mem.Create("", width, height, bands, dataType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func TestDataset(t *testing.T) {
+	ds := tNewMemDataset(t, 8, 6, 3, GDT_UInt16)
+	defer ds.Close()
+
+	if ds.Bounds() != image.Rect(0, 0, 8, 6) || ds.BandCount() != 3 || ds.DataType() != GDT_UInt16 {
+		t.Fatalf("bad dataset: %v, %d, %v", ds.Bounds(), ds.BandCount(), ds.DataType())
+	}
+	if ds.Band(3) != nil {
+		t.Fatalf("expect no band 3")
+	}
+
+	gt := [6]float64{500000, 30, 0, 4000000, 0, -30}
+	if err := ds.SetGeoTransform(gt); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ds.GeoTransform(); err != nil || v != gt {
+		t.Fatalf("expect = %v, got = %v, %v", gt, v, err)
+	}
+
+	m := image_ext.NewRGB48(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			m.SetRGB48(x, y, color_ext.RGB48{R: uint16(x * 1000), G: uint16(y * 1000), B: 0x1234})
+		}
+	}
+	r := image.Rect(2, 1, 6, 4)
+	if err := ds.WriteRect(r, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.ReadRect(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, ok := got.(*image_ext.RGB48)
+	if !ok || m2.Bounds() != r {
+		t.Fatalf("bad image: %T, %v", got, got.Bounds())
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			if c0, c1 := m.RGB48At(x, y), m2.RGB48At(x+r.Min.X, y+r.Min.Y); c0 != c1 {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, c0, c1)
+			}
+		}
+	}
+
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.ReadRect(r, nil); err == nil {
+		t.Fatalf("expect an error for a closed dataset")
+	}
+}
+
+func TestDataset_rgba(t *testing.T) {
+	ds := tNewMemDataset(t, 2, 2, 4, GDT_Byte)
+	defer ds.Close()
+
+	// the premultiplied pixels are written as straight alpha
+	m := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	c := color.RGBA{64, 32, 16, 128}
+	m.SetRGBA(1, 1, c)
+	if err := ds.WriteRect(ds.Bounds(), m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ds.ReadRect(ds.Bounds(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, ok := got.(*image.NRGBA)
+	if !ok {
+		t.Fatalf("bad image type: %T", got)
+	}
+	if c0, c1 := color.NRGBAModel.Convert(c), m2.NRGBAAt(1, 1); c0 != c1 {
+		t.Fatalf("expect = %v, got = %v", c0, c1)
+	}
+
+	if _, err := ds.ReadRect(ds.Bounds(), image.NewRGBA(ds.Bounds())); err == nil {
+		t.Fatalf("expect an error for a RGBA buffer")
+	}
+}
+
+func TestRasterBand(t *testing.T) {
+	ds := tNewMemDataset(t, 5, 4, 1, GDT_Float32)
+	defer ds.Close()
+
+	band := ds.Band(0)
+	if err := band.SetNoData(-9999); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := band.NoData(); !ok || v != -9999 {
+		t.Fatalf("bad nodata: %v, %v", v, ok)
+	}
+
+	m := image_ext.NewGray32f(band.Bounds())
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(y*5+x) + 0.25})
+		}
+	}
+	if err := band.WriteRect(band.Bounds(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	// the samples are converted to the buffer type
+	got, err := band.ReadRect(image.Rect(1, 1, 3, 3), image.NewGray16(image.Rect(0, 0, 2, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := got.(*image.Gray16).Gray16At(1, 1); c != (color.Gray16{Y: 12}) {
+		t.Fatalf("bad pixel: %v", c)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 7, 5))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i * 7)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, &Options{DriverName: "GTiff"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// GDAL is the fallback of the formats which are not registered
+	RegisterFormat()
+	got, name, err := image_ext.Decode(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "gdal" {
+		t.Fatalf("bad format: %s", name)
+	}
+	if m2, ok := got.(*image.Gray); !ok || !bytes.Equal(m2.Pix, m.Pix) {
+		t.Fatalf("bad image: %T", got)
+	}
+}
+
+func TestDecode_vrt(t *testing.T) {
+	// the VRT driver is not used for the data of a reader
+	vrt := `<VRTDataset rasterXSize="1" rasterYSize="1">
+  <VRTRasterBand dataType="Byte" band="1">
+    <SimpleSource><SourceFilename>/vsicurl/http://localhost/a.tif</SourceFilename></SimpleSource>
+  </VRTRasterBand>
+</VRTDataset>`
+	if _, err := Decode(bytes.NewReader([]byte(vrt))); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
@@ -5,8 +5,11 @@
 #ifndef GO_GDAL_H_
 #define GO_GDAL_H_
 
+#include <stdlib.h>
 #include <gdal.h>
 #include <cpl_conv.h>
+#include <cpl_error.h>
+#include <cpl_vsi.h>
 
 // transform GDALProgressFunc to go func
 GDALProgressFunc goGDALProgressFuncProxyB();
@@ -0,0 +1,140 @@
+// Copyright 2011 go-gdal. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gdal
+
+import (
+	"image"
+	"image/draw"
+	"unsafe"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// pixels is the pixel layout of an image buffer for RasterIO.
+type pixels struct {
+	Pix      []byte // the first pixel is Rect.Min
+	Stride   int
+	Rect     image.Rectangle
+	DataType GDALDataType
+	Channels int
+	Size     int  // the bytes of a sample
+	BigEnd   bool // the 16-bit samples are big endian (the Go images)
+}
+
+var nativeBigEndian = func() bool {
+	v := uint16(1)
+	return (*[2]byte)(unsafe.Pointer(&v))[0] == 0
+}()
+
+// imagePixels returns the pixels of the supported image buffers.
+// The float images are native endian, like the GDAL buffers. The RGBA and
+// RGBA64 images are not supported, the GDAL alpha bands are not
+// premultiplied.
+func imagePixels(m image.Image) (p pixels, ok bool) {
+	b := m.Bounds()
+	if b.Empty() {
+		return
+	}
+	switch m := m.(type) {
+	case *image.Gray:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Byte, 1, 1, false}
+	case *image.Gray16:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_UInt16, 1, 2, true}
+	case *image_ext.Gray32f:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Float32, 1, 4, false}
+	case *image_ext.RGB:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Byte, 3, 1, false}
+	case *image_ext.RGB48:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_UInt16, 3, 2, true}
+	case *image_ext.RGB96f:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Float32, 3, 4, false}
+	case *image.NRGBA:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Byte, 4, 1, false}
+	case *image.NRGBA64:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_UInt16, 4, 2, true}
+	case *image_ext.RGBA128f:
+		p = pixels{m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b, GDT_Float32, 4, 4, false}
+	default:
+		return
+	}
+	ok = true
+	return
+}
+
+// needSwap reports whether the 16-bit samples must be swapped for GDAL.
+func (p *pixels) needSwap() bool {
+	return p.Size == 2 && p.BigEnd != nativeBigEndian
+}
+
+// swap swaps the bytes of the 16-bit samples.
+func (p *pixels) swap() {
+	n := p.Rect.Dx() * p.Channels * 2
+	for y := 0; y < p.Rect.Dy(); y++ {
+		row := p.Pix[y*p.Stride:][:n]
+		for i := 0; i < n; i += 2 {
+			row[i], row[i+1] = row[i+1], row[i]
+		}
+	}
+}
+
+// clone returns a packed copy of the pixels.
+func (p *pixels) clone() pixels {
+	q := *p
+	n := p.Rect.Dx() * p.Channels * p.Size
+	q.Pix, q.Stride = make([]byte, n*p.Rect.Dy()), n
+	for y := 0; y < p.Rect.Dy(); y++ {
+		copy(q.Pix[y*n:][:n], p.Pix[y*p.Stride:])
+	}
+	return q
+}
+
+// newImage returns the image buffer of the bands: the 8-bit and 16-bit
+// bands are Gray, RGB and NRGBA images, the others are float images.
+// The second band of 2 bands (gray and alpha) is not read.
+func newImage(r image.Rectangle, dataType GDALDataType, bands int) image_ext.ImageBuffer {
+	switch dataType {
+	case GDT_Byte:
+		switch {
+		case bands >= 4:
+			return image.NewNRGBA(r)
+		case bands == 3:
+			return image_ext.NewRGB(r)
+		}
+		return image.NewGray(r)
+	case GDT_UInt16:
+		switch {
+		case bands >= 4:
+			return image.NewNRGBA64(r)
+		case bands == 3:
+			return image_ext.NewRGB48(r)
+		}
+		return image.NewGray16(r)
+	}
+	switch {
+	case bands >= 4:
+		return image_ext.NewRGBA128f(r)
+	case bands == 3:
+		return image_ext.NewRGB96f(r)
+	}
+	return image_ext.NewGray32f(r)
+}
+
+// toPixels returns the pixels of m, the RGBA images are copied to a NRGBA
+// image, and the other unsupported images to a NRGBA64 image.
+func toPixels(m image.Image) pixels {
+	if p, ok := imagePixels(m); ok {
+		return p
+	}
+	b := m.Bounds()
+	var rgba draw.Image
+	if _, ok := m.(*image.RGBA); ok {
+		rgba = image.NewNRGBA(b)
+	} else {
+		rgba = image.NewNRGBA64(b)
+	}
+	draw.Draw(rgba, b, m, b.Min, draw.Src)
+	p, _ := imagePixels(rgba)
+	return p
+}
@@ -13,14 +13,15 @@ import (
 	"image/jpeg"
 	"io"
 
-	image_ext "github.com/chai2010/image"
-	"github.com/chai2010/image/convert"
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
 )
 
 // Options are the encoding and decoding parameters.
 type Options struct {
 	*jpeg.Options
 	ColorModel color.Model
+	Region     *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a JPEG image without
@@ -30,10 +31,19 @@ func DecodeConfig(r io.Reader) (config image.Config, err error) {
 }
 
 // Decode reads a JPEG image from r and returns it as an image.Image.
+// The Region of opt is cut and scaled from the whole image, which is
+// decoded at full size.
 func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 	if m, err = jpeg.Decode(r); err != nil {
 		return
 	}
+	if opt != nil && opt.Region != nil {
+		// image/jpeg has no DCT scaling, the region is taken from the
+		// whole image, which is decoded at full size.
+		if m, err = opt.Region.Apply(m); err != nil {
+			return
+		}
+	}
 	if opt != nil && opt.ColorModel != nil {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
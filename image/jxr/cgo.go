@@ -18,6 +18,7 @@ package jxr
 import "C"
 import (
 	"fmt"
+	"image"
 	"unsafe"
 )
 
@@ -79,6 +80,46 @@ func jxr_decode(data, pix []byte, stride int) (
 	return
 }
 
+// jxr_decode_region decodes the rectangle r of the image, pix has the rows
+// of r, and the stride of a row of the full width.
+func jxr_decode_region(data, pix []byte, stride int, r image.Rectangle) (
+	channels C.int,
+	err error,
+) {
+	if len(data) == 0 || len(pix) == 0 || r.Empty() {
+		err = fmt.Errorf("jxr_decode_region: bad arguments")
+		return
+	}
+	p := C.jxr_decoder_new()
+	if p == nil {
+		err = fmt.Errorf("jxr_decode_region: failed")
+		return
+	}
+	defer C.jxr_decoder_delete(p)
+
+	if jxr_bool_t(C.jxr_decoder_init(p, (*C.char)(unsafe.Pointer(&data[0])), C.int(len(data)))) != jxr_true {
+		err = fmt.Errorf("jxr_decode_region: failed")
+		return
+	}
+	if len(pix) < stride*r.Dy() {
+		err = fmt.Errorf("jxr_decode_region: bad arguments")
+		return
+	}
+	rect := C.jxr_rect_t{
+		x:      C.int(r.Min.X),
+		y:      C.int(r.Min.Y),
+		width:  C.int(r.Dx()),
+		height: C.int(r.Dy()),
+	}
+	rv := jxr_bool_t(C.jxr_decoder_decode(p, &rect, (*C.char)(unsafe.Pointer(&pix[0])), C.int(stride)))
+	if rv != jxr_true {
+		err = fmt.Errorf("jxr_decode_region: failed")
+		return
+	}
+	channels = C.jxr_decoder_channels(p)
+	return
+}
+
 func jxr_encode_len(
 	pix []byte, stride int,
 	width, height, channels, depth, quality int,
@@ -12,10 +12,10 @@ import (
 	"io"
 	"io/ioutil"
 
-	"github.com/chai2010/builtin"
-	image_ext "github.com/chai2010/image"
-	color_ext "github.com/chai2010/image/image_color"
-	"github.com/chai2010/image/convert"
+	"github.com/chai2010/gopkg/builtin"
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+	"github.com/chai2010/gopkg/image/convert"
 )
 
 const (
@@ -75,6 +75,7 @@ func decodeConfig(data []byte) (config image.Config, err error) {
 // Options are the encoding and decoding parameters.
 type Options struct {
 	ColorModel color.Model
+	Region     *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a JPEG/XR image without
@@ -99,80 +100,96 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 		return
 	}
 
+	// jxrlib decodes the rows of the region with the stride of the full
+	// width, the region is the sub image of the rows.
+	var region *image_ext.Region
+	if opt != nil {
+		region = opt.Region
+	}
+	rect, scale, err := region.Adjust(image.Rect(0, 0, config.Width, config.Height))
+	if err != nil {
+		return
+	}
+	rows := image.Rect(0, 0, config.Width, rect.Dy())
+	size := image.Rect(0, 0, rect.Dx(), rect.Dy())
+
 	var channels C.int
 	switch config.ColorModel {
 	case color.GrayModel:
-		gray := image.NewGray(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, _, _, _, err = jxr_decode(data, gray.Pix, gray.Stride); err != nil {
+		gray := image.NewGray(rows)
+		if _, err = jxr_decode_region(data, gray.Pix, gray.Stride, rect); err != nil {
 			return
 		}
-		m = gray
+		m = gray.SubImage(size)
 	case color.Gray16Model:
-		gray16 := image.NewGray16(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, _, _, _, err = jxr_decode(data, gray16.Pix, gray16.Stride); err != nil {
+		gray16 := image.NewGray16(rows)
+		if _, err = jxr_decode_region(data, gray16.Pix, gray16.Stride, rect); err != nil {
 			return
 		}
-		m = gray16
+		m = gray16.SubImage(size)
 	case color_ext.Gray32fModel:
-		gray32f := image_ext.NewGray32f(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, _, _, _, err = jxr_decode(data, gray32f.Pix, gray32f.Stride); err != nil {
+		gray32f := image_ext.NewGray32f(rows)
+		if _, err = jxr_decode_region(data, gray32f.Pix, gray32f.Stride, rect); err != nil {
 			return
 		}
-		m = gray32f
+		m = gray32f.SubImage(size)
 	case color.RGBAModel:
-		rgba := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, channels, _, _, err = jxr_decode(data, rgba.Pix, rgba.Stride); err != nil {
+		rgba := image.NewRGBA(rows)
+		if channels, err = jxr_decode_region(data, rgba.Pix, rgba.Stride, rect); err != nil {
 			return
 		}
 		if channels == 3 {
-			b := rgba.Bounds()
-			for y := 0; y < b.Max.Y; y++ {
+			for y := 0; y < size.Max.Y; y++ {
 				d := rgba.Pix[y*rgba.Stride:]
-				for x := b.Max.X - 1; x >= 0; x-- {
+				for x := size.Max.X - 1; x >= 0; x-- {
 					copy(d[x*4:][:3], d[x*3:])
 					d[x*4+3] = 0xff
 				}
 			}
 		}
-		m = rgba
+		m = rgba.SubImage(size)
 	case color.RGBA64Model:
-		rgba64 := image.NewRGBA64(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, channels, _, _, err = jxr_decode(data, rgba64.Pix, rgba64.Stride); err != nil {
+		rgba64 := image.NewRGBA64(rows)
+		if channels, err = jxr_decode_region(data, rgba64.Pix, rgba64.Stride, rect); err != nil {
 			return
 		}
 		if channels == 3 {
-			b := rgba64.Bounds()
-			for y := 0; y < b.Max.Y; y++ {
+			for y := 0; y < size.Max.Y; y++ {
 				d := rgba64.Pix[y*rgba64.Stride:]
-				for x := b.Max.X - 1; x >= 0; x-- {
+				for x := size.Max.X - 1; x >= 0; x-- {
 					copy(d[x*8:][:6], d[x*6:])
 					d[x*8+7] = 0xff
 					d[x*8+6] = 0xff
 				}
 			}
 		}
-		m = rgba64
+		m = rgba64.SubImage(size)
 	case color_ext.RGBA128fModel:
-		rgba128f := image_ext.NewRGBA128f(image.Rect(0, 0, config.Width, config.Height))
-		if _, _, channels, _, _, err = jxr_decode(data, rgba128f.Pix, rgba128f.Stride); err != nil {
+		rgba128f := image_ext.NewRGBA128f(rows)
+		if channels, err = jxr_decode_region(data, rgba128f.Pix, rgba128f.Stride, rect); err != nil {
 			return
 		}
 		if channels == 3 {
-			b := rgba128f.Bounds()
-			for y := 0; y < b.Max.Y; y++ {
+			for y := 0; y < size.Max.Y; y++ {
 				d := rgba128f.Pix[y*rgba128f.Stride:]
-				for x := b.Max.X - 1; x >= 0; x-- {
+				for x := size.Max.X - 1; x >= 0; x-- {
 					copy(d[x*8:][:6], d[x*6:])
 					builtin.PutFloat32(d[x*8+6:], 0xffff)
 				}
 			}
 		}
-		m = rgba128f
+		m = rgba128f.SubImage(size)
 	}
 	if m == nil {
 		err = fmt.Errorf("jxr: Decode, unsupported colot model: %T", config.ColorModel)
 		return
 	}
+	if scale > 1 {
+		// jxrlib has no scaled decoding
+		if m, err = image_ext.ScaleDown(m, scale); err != nil {
+			return
+		}
+	}
 	if opt != nil && opt.ColorModel != nil {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
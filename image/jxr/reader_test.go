@@ -5,10 +5,14 @@
 package jxr
 
 import (
+	"bytes"
 	"image"
 	_ "image/png"
+	"io/ioutil"
 	"os"
 	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
 )
 
 const testdataDir = "../testdata/"
@@ -61,6 +65,36 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+// TestDecodeRegion tests that the regions of jxrlib are the regions taken
+// from the whole image.
+func TestDecodeRegion(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001.wdp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, region := range []*image_ext.Region{
+		{Rect: image.Rect(11, 20, 90, 77)},
+		{Rect: image.Rect(30, 15, 1000, 1000), Scale: 2},
+		{Scale: 4},
+	} {
+		m0, err := region.Apply(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m1, err := Decode(bytes.NewReader(data), &Options{Region: region})
+		if err != nil {
+			t.Fatalf("%v: %v", region, err)
+		}
+		if got, want := averageDelta(m0, m1), int64(2<<8); m0.Bounds() != m1.Bounds() || got > want {
+			t.Fatalf("%v: average delta too high; got %d, want <= %d", region, got, want)
+		}
+	}
+}
+
 // averageDelta returns the average delta in RGB space. The two images must
 // have the same bounds.
 func averageDelta(m0, m1 image.Image) int64 {
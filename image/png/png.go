@@ -22,6 +22,7 @@ const pngHeader = "\x89PNG\r\n\x1a\n"
 // Options are the encoding and decoding parameters.
 type Options struct {
 	ColorModel color.Model
+	Region     *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a PNG image
@@ -36,6 +37,11 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 	if m, err = png.Decode(r); err != nil {
 		return
 	}
+	if opt != nil && opt.Region != nil {
+		if m, err = opt.Region.Apply(m); err != nil {
+			return
+		}
+	}
 	if opt != nil && opt.ColorModel != nil {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
@@ -5,10 +5,12 @@
 package raw
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"io"
 	"reflect"
 	"testing"
 
@@ -270,6 +272,76 @@ func TestEncodeAndDecode_YCbCr2RGBA(t *testing.T) {
 	}
 }
 
+func TestReaderWriter(t *testing.T) {
+	for i, v := range tTesterList {
+		b := v.Image.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				v.Image.Set(x, y, color.RGBA{uint8(x * 20), uint8(y * 20), uint8(x + y), 0xFF})
+			}
+		}
+
+		// write the rows in bands of 3 rows
+		var buf bytes.Buffer
+		wr, err := NewWriter(&buf, &Encoder{v.Channels, v.DataType}, b.Dx())
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y += 3 {
+			band := v.Image.(image_ext.ImageBuffer).SubImage(image.Rect(b.Min.X, y, b.Max.X, y+3))
+			if err = wr.WriteRows(band); err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+		}
+
+		// read the rows in bands of 4 rows into the buffer
+		rd, err := NewReader(&buf, &Decoder{v.Channels, v.DataType, b.Dx(), b.Dy()})
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		m := tNewImageBuffer(b, v.Model)
+		for {
+			rows, err := rd.ReadRows(4, m)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%d: %v", i, err)
+			}
+			if r := rows.Bounds(); r.Min.Y%4 != 0 || r.Dy() > 4 {
+				t.Fatalf("%d: bad rows bounds: %v", i, r)
+			}
+		}
+		if err = tCompareImage(v.Image, v.Channels, v.Model, m); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+}
+
+func tNewImageBuffer(r image.Rectangle, model color.Model) image_ext.ImageBuffer {
+	switch model {
+	case color.GrayModel:
+		return image.NewGray(r)
+	case color.Gray16Model:
+		return image.NewGray16(r)
+	case color_ext.Gray32fModel:
+		return image_ext.NewGray32f(r)
+	case color_ext.RGBModel:
+		return image_ext.NewRGB(r)
+	case color_ext.RGB48Model:
+		return image_ext.NewRGB48(r)
+	case color_ext.RGB96fModel:
+		return image_ext.NewRGB96f(r)
+	case color.RGBAModel:
+		return image.NewRGBA(r)
+	case color.RGBA64Model:
+		return image.NewRGBA64(r)
+	case color_ext.RGBA128fModel:
+		return image_ext.NewRGBA128f(r)
+	}
+	return nil
+}
+
 func tCompareImage(img0 image.Image, channels int, model color.Model, img1 image.Image) error {
 	if img1.ColorModel() != model {
 		return fmt.Errorf("img1 wrong image model: want %v, got %v", model, img1.ColorModel())
@@ -65,7 +65,7 @@ func (p *Encoder) encodeGray(m image.Image, buf []byte) (data []byte, err error)
 	case *image.Gray:
 		var off = 0
 		for y := b.Min.Y; y < b.Max.Y; y++ {
-			copy(d[off:][:b.Dx()], m.Pix[y*m.Stride:])
+			copy(d[off:][:b.Dx()], m.Pix[m.PixOffset(b.Min.X, y):])
 			off += b.Dx()
 		}
 	case *image.Gray16:
@@ -79,7 +79,7 @@ func (p *Encoder) encodeGray(m image.Image, buf []byte) (data []byte, err error)
 	case *image.YCbCr:
 		var off = 0
 		for y := b.Min.Y; y < b.Max.Y; y++ {
-			copy(d[off:][:b.Dx()], m.Y[y*m.YStride:])
+			copy(d[off:][:b.Dx()], m.Y[m.YOffset(b.Min.X, y):])
 			off += b.Dx()
 		}
 	default:
@@ -239,7 +239,7 @@ func (p *Encoder) encodeRGB(m image.Image, buf []byte) (data []byte, err error)
 	case *image_ext.RGB:
 		var off = 0
 		for y := b.Min.Y; y < b.Max.Y; y++ {
-			copy(d[off:][:b.Dx()*3], m.Pix[y*m.Stride:])
+			copy(d[off:][:b.Dx()*3], m.Pix[m.PixOffset(b.Min.X, y):])
 			off += b.Dx() * 3
 		}
 	case *image.RGBA:
@@ -532,9 +532,9 @@ func (p *Encoder) encodeRGBA(m image.Image, buf []byte) (data []byte, err error)
 		for y := b.Min.Y; y < b.Max.Y; y++ {
 			for x := b.Min.X; x < b.Max.X; x++ {
 				v := m.RGBAt(x, y)
-				d[off+0] = uint8(v.R >> 8)
-				d[off+1] = uint8(v.G >> 8)
-				d[off+2] = uint8(v.B >> 8)
+				d[off+0] = v.R
+				d[off+1] = v.G
+				d[off+2] = v.B
 				d[off+3] = 0xFF
 				off += 4
 			}
@@ -0,0 +1,106 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raw
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+// A Reader decodes the raw rows of an image from an io.Reader, a band of
+// rows at a time.
+type Reader struct {
+	r       io.Reader
+	decoder Decoder
+	height  int
+	y       int
+	data    []byte
+}
+
+// NewReader returns a Reader of the image described by d. d.Width and
+// d.Height are required.
+func NewReader(r io.Reader, d *Decoder) (p *Reader, err error) {
+	if d.Width <= 0 || d.Height <= 0 {
+		err = fmt.Errorf("image/raw: NewReader, bad size: width = %v, height = %v", d.Width, d.Height)
+		return
+	}
+	p = &Reader{r: r, decoder: *d, height: d.Height}
+	return
+}
+
+// ReadRows decodes the next n rows (less at the end of the image). The
+// result has the bounds (0, y, Width, y+n), where y is the rows read
+// before, and shares the pixels of buf if buf contains the bounds.
+// ReadRows returns io.EOF if all the rows have been read.
+func (p *Reader) ReadRows(n int, buf image_ext.ImageBuffer) (m draw.Image, err error) {
+	if p.y >= p.height {
+		return nil, io.EOF
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("image/raw: ReadRows, bad rows: %v", n)
+	}
+	if p.y+n > p.height {
+		n = p.height - p.y
+	}
+
+	p.decoder.Height = n
+	p.data = newBytes(p.decoder.getImageDataSize(), p.data)
+	if _, err = io.ReadFull(p.r, p.data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("image/raw: ReadRows, %v", err)
+	}
+
+	// the Decoder decodes the rows at (0, 0)
+	r := image.Rect(0, p.y, p.decoder.Width, p.y+n)
+	var dst image_ext.ImageBuffer
+	if buf != nil && r.In(buf.Bounds()) {
+		dst = translate(buf.SubImage(r), r.Min.Mul(-1))
+	}
+	if m, err = p.decoder.Decode(p.data, dst); err != nil {
+		return
+	}
+	m = translate(m, r.Min)
+	p.y += n
+	return
+}
+
+// A Writer encodes the rows of an image to an io.Writer, a band of rows
+// at a time.
+type Writer struct {
+	w       io.Writer
+	encoder Encoder
+	width   int
+	data    []byte
+}
+
+// NewWriter returns a Writer of the rows of width pixels, encoded by e.
+func NewWriter(w io.Writer, e *Encoder, width int) (p *Writer, err error) {
+	if width <= 0 {
+		err = fmt.Errorf("image/raw: NewWriter, bad width: %v", width)
+		return
+	}
+	p = &Writer{w: w, encoder: *e, width: width}
+	return
+}
+
+// WriteRows encodes the rows of m, which must have the width of the Writer.
+func (p *Writer) WriteRows(m image.Image) (err error) {
+	if dx := m.Bounds().Dx(); dx != p.width {
+		return fmt.Errorf("image/raw: WriteRows, bad width: expect = %d, got = %d", p.width, dx)
+	}
+	if p.data, err = p.encoder.Encode(m, p.data); err != nil {
+		return
+	}
+	if _, err = p.w.Write(p.data); err != nil {
+		return fmt.Errorf("image/raw: WriteRows, %v", err)
+	}
+	return
+}
@@ -110,3 +110,45 @@ func newRGBA128f(r image.Rectangle, buf image_ext.ImageBuffer) *image_ext.RGBA12
 	}
 	return image_ext.NewRGBA128f(r)
 }
+
+func translate(m image.Image, d image.Point) image_ext.ImageBuffer {
+	switch m := m.(type) {
+	case *image.Gray:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.Gray16:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.Gray32f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB48:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB96f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.RGBA:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.RGBA64:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGBA128f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	}
+	return nil
+}
@@ -19,6 +19,27 @@
 //		Data         []byte  // ?Bytes, image data (RawPImage.DataSize)
 //	}
 //
+// RawP Strip Image Structs (Little Endian), written if Options.StripHeight > 0:
+//	type RawPStripImage struct {
+//		Sig            [4]byte // 4Bytes, RAWP
+//		Magic          uint32  // 4Bytes, 0x1BF2380B
+//		Width          uint32  // 4Bytes, image Width
+//		Height         uint32  // 4Bytes, image Height
+//		Channels       byte    // 1Bytes, 1=Gray, 3=RGB, 4=RGBA
+//		Depth          byte    // 1Bytes, 8/16/32/64 bits
+//		DataType       byte    // 1Bytes, 1=Uint, 2=Int, 3=Float
+//		UseSnappy      byte    // 1Bytes, 0=disabled, 1=enabled (every strip)
+//		StripHeight    uint32  // 4Bytes, rows of a strip, the last strip may have less
+//		HeaderCheckSum uint32  // 4Bytes, CRC32 of the 24 Bytes before
+//		Strips         []struct {
+//			DataSize     uint32 // 4Bytes, strip data size
+//			DataCheckSum uint32 // 4Bytes, CRC32(Data[DataSize])
+//			Data         []byte // ?Bytes, the rows of the strip
+//		}
+//	}
+//
+// The Reader and Writer decode and encode a strip image a strip at a time.
+//
 // Please report bugs to chaishushan{AT}gmail.com.
 //
 // Thanks!
@@ -40,6 +61,9 @@ const (
 	rawpHeaderSize = 24
 	rawpSig        = "RAWP"
 	rawpMagic      = 0x1BF2380A
+
+	// the header is little endian, so the magic bytes are reversed
+	rawpMagicString = "RAWP\x0A\x38\xF2\x1B" // rawSig + rawpMagic
 )
 
 // data type
@@ -373,54 +397,37 @@ func rawpMakeHeader(width, height int, model color.Model, useSnappy bool) (hdr *
 		hdr.UseSnappy = 1
 	}
 
+	if hdr.Channels, hdr.Depth, hdr.DataType, err = rawpPixFormat(model); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// rawpPixFormat returns the channels, the depth and the data type of the
+// pixels of the color model.
+func rawpPixFormat(model color.Model) (channels, depth, dataType byte, err error) {
 	switch model {
 	case color.GrayModel:
-		hdr.Channels = 1
-		hdr.Depth = 8
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 1, 8, rawpDataType_UInt, nil
 	case color.Gray16Model:
-		hdr.Channels = 1
-		hdr.Depth = 16
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 1, 16, rawpDataType_UInt, nil
 	case color_ext.Gray32fModel:
-		hdr.Channels = 1
-		hdr.Depth = 32
-		hdr.DataType = rawpDataType_Float
-		return
+		return 1, 32, rawpDataType_Float, nil
 	case color_ext.RGBModel:
-		hdr.Channels = 3
-		hdr.Depth = 8
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 3, 8, rawpDataType_UInt, nil
 	case color_ext.RGB48Model:
-		hdr.Channels = 3
-		hdr.Depth = 16
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 3, 16, rawpDataType_UInt, nil
 	case color_ext.RGB96fModel:
-		hdr.Channels = 3
-		hdr.Depth = 32
-		hdr.DataType = rawpDataType_Float
-		return
+		return 3, 32, rawpDataType_Float, nil
 	case color.RGBAModel:
-		hdr.Channels = 4
-		hdr.Depth = 8
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 4, 8, rawpDataType_UInt, nil
 	case color.RGBA64Model:
-		hdr.Channels = 4
-		hdr.Depth = 16
-		hdr.DataType = rawpDataType_UInt
-		return
+		return 4, 16, rawpDataType_UInt, nil
 	case color_ext.RGBA128fModel:
-		hdr.Channels = 4
-		hdr.Depth = 32
-		hdr.DataType = rawpDataType_Float
-		return
+		return 4, 32, rawpDataType_Float, nil
 	}
-	return nil, fmt.Errorf("image/rawp: unsupport color model, %T", model)
+	err = fmt.Errorf("image/rawp: unsupport color model, %T", model)
+	return
 }
 
 func rawpDecodeHeader(data []byte) (hdr *rawpHeader, err error) {
@@ -5,6 +5,7 @@
 package rawp
 
 import (
+	"bufio"
 	"fmt"
 	"image"
 	"image/color"
@@ -17,12 +18,23 @@ import (
 )
 
 type Options struct {
-	ColorModel color.Model
-	UseSnappy  bool
+	ColorModel  color.Model
+	UseSnappy   bool
+	StripHeight int               // rows of a strip, 0 for the single block image
+	Region      *image_ext.Region // the decoded region, nil for the whole image
 }
 
 func DecodeConfig(r io.Reader) (config image.Config, err error) {
-	data, err := ioutil.ReadAll(r)
+	br := bufio.NewReader(r)
+	if isStripImage(br) {
+		var rd *Reader
+		if rd, err = NewReader(br); err != nil {
+			return
+		}
+		config = rd.Config()
+		return
+	}
+	data, err := ioutil.ReadAll(br)
 	if err != nil {
 		return
 	}
@@ -36,11 +48,46 @@ func DecodeConfig(r io.Reader) (config image.Config, err error) {
 		return
 	}
 
-	config = image.Config{model, int(hdr.Width), int(hdr.Height)}
+	config = image.Config{
+		ColorModel: model,
+		Width:      int(hdr.Width),
+		Height:     int(hdr.Height),
+	}
 	return
 }
 
 func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
+	br := bufio.NewReader(r)
+	if isStripImage(br) {
+		m, err = decodeStrips(br)
+	} else {
+		m, err = decodeImage(br)
+	}
+	if err != nil {
+		return
+	}
+
+	if opt != nil && opt.Region != nil {
+		if m, err = opt.Region.Apply(m); err != nil {
+			return
+		}
+	}
+
+	// convert color model
+	if opt != nil && opt.ColorModel != nil {
+		m = convert.ColorModel(m, opt.ColorModel)
+	}
+
+	return
+}
+
+// isStripImage reports whether r has the header of a RawP strip image.
+func isStripImage(r *bufio.Reader) bool {
+	b, err := r.Peek(len(rawpStripMagicString))
+	return err == nil && string(b) == rawpStripMagicString
+}
+
+func decodeImage(r io.Reader) (m image.Image, err error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return
@@ -66,17 +113,7 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 	}
 
 	// decode raw pix
-	m, err = decoder.Decode(pix, nil)
-	if err != nil {
-		return
-	}
-
-	// convert color model
-	if opt != nil && opt.ColorModel != nil {
-		m = convert.ColorModel(m, opt.ColorModel)
-	}
-
-	return
+	return decoder.Decode(pix, nil)
 }
 
 func imageDecode(r io.Reader) (image.Image, error) {
@@ -100,12 +137,13 @@ func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
 }
 
 func init() {
-	image.RegisterFormat("rawp", "RAWP\x1B\xF2\x38\x0A", imageDecode, DecodeConfig)
+	image.RegisterFormat("rawp", rawpMagicString, imageDecode, DecodeConfig)
+	image.RegisterFormat("rawp", rawpStripMagicString, imageDecode, DecodeConfig)
 
 	image_ext.RegisterFormat(image_ext.Format{
 		Name:         "rawp",
 		Extensions:   []string{".rawp"},
-		Magics:       []string{"RAWP\x1B\xF2\x38\x0A"}, // rawSig + rawpMagic
+		Magics:       []string{rawpMagicString, rawpStripMagicString},
 		DecodeConfig: DecodeConfig,
 		Decode:       imageExtDecode,
 		Encode:       imageExtEncode,
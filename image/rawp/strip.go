@@ -0,0 +1,454 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+
+	"code.google.com/p/snappy-go/snappy"
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+const (
+	rawpStripHeaderSize = 28
+	rawpStripMagic      = 0x1BF2380B
+
+	rawpStripMagicString = "RAWP\x0B\x38\xF2\x1B" // rawSig + rawpStripMagic
+
+	// DefaultStripHeight is the rows of a strip of the Writer if
+	// Options.StripHeight is 0.
+	DefaultStripHeight = 64
+
+	// maxStripSize is the maximum size of the pixels of a strip, and
+	// maxImageSize of a whole image decoded by Decode.
+	maxStripSize = 1 << 30
+	maxImageSize = 1 << 30
+)
+
+// RawP Strip Image Spec (Little Endian), 28Bytes, followed by the strips.
+type rawpStripHeader struct {
+	Sig            [4]byte // 4Bytes, RAWP
+	Magic          uint32  // 4Bytes, 0x1BF2380B
+	Width          uint32  // 4Bytes, image Width
+	Height         uint32  // 4Bytes, image Height
+	Channels       byte    // 1Bytes, 1=Gray, 3=RGB, 4=RGBA
+	Depth          byte    // 1Bytes, 8/16/32/64 bits
+	DataType       byte    // 1Bytes, 1=Uint, 2=Int, 3=Float
+	UseSnappy      byte    // 1Bytes, 0=disabled, 1=enabled (every strip)
+	StripHeight    uint32  // 4Bytes, rows of a strip, the last strip may have less
+	HeaderCheckSum uint32  // 4Bytes, CRC32 of the 24 Bytes before
+}
+
+// RawP Strip Spec (Little Endian), 8Bytes, followed by the strip data.
+type rawpStrip struct {
+	DataSize     uint32 // 4Bytes, strip data size
+	DataCheckSum uint32 // 4Bytes, CRC32(Data[DataSize])
+}
+
+func (p *rawpStripHeader) checkSum() uint32 {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, p)
+	return crc32.ChecksumIEEE(buf.Bytes()[:rawpStripHeaderSize-4])
+}
+
+func rawpIsValidStripHeader(hdr *rawpStripHeader) error {
+	if string(hdr.Sig[:]) != rawpSig {
+		return fmt.Errorf("image/rawp: bad Sig, %v", hdr.Sig)
+	}
+	if hdr.Magic != rawpStripMagic {
+		return fmt.Errorf("image/rawp: bad Magic, %x", hdr.Magic)
+	}
+	if v := hdr.checkSum(); v != hdr.HeaderCheckSum {
+		return fmt.Errorf("image/rawp: bad HeaderCheckSum, expect = %x, got = %x", hdr.HeaderCheckSum, v)
+	}
+
+	if hdr.Width <= 0 || hdr.Height <= 0 || hdr.Width > math.MaxInt32 || hdr.Height > math.MaxInt32 {
+		return fmt.Errorf("image/rawp: bad size, width = %v, height = %v", hdr.Width, hdr.Height)
+	}
+	if hdr.StripHeight <= 0 || hdr.StripHeight > hdr.Height {
+		return fmt.Errorf("image/rawp: bad StripHeight, %v", hdr.StripHeight)
+	}
+	if hdr.pixSize(hdr.StripHeight) > maxStripSize {
+		return fmt.Errorf("image/rawp: strip too large, width = %v, StripHeight = %v", hdr.Width, hdr.StripHeight)
+	}
+	if hdr.UseSnappy != 0 && hdr.UseSnappy != 1 {
+		return fmt.Errorf("image/rawp: bad UseSnappy, %v", hdr.UseSnappy)
+	}
+	return nil
+}
+
+// pixSize returns the size of the pixels of rows rows. The sizes larger
+// than maxImageSize are not exact, they are only compared with the limits.
+func (p *rawpStripHeader) pixSize(rows uint32) uint64 {
+	n := uint64(p.Width) * uint64(rows)
+	if n > maxImageSize {
+		return n
+	}
+	return n * uint64(p.Channels) * uint64(p.Depth) / 8
+}
+
+// pixHeader returns the header of the pixel format, for rawpColorModel and
+// rawpPixDecoder.
+func (p *rawpStripHeader) pixHeader() *rawpHeader {
+	return &rawpHeader{
+		Channels: p.Channels,
+		Depth:    p.Depth,
+		DataType: p.DataType,
+	}
+}
+
+// A Reader reads a RawP strip image, a strip of rows at a time.
+type Reader struct {
+	r       io.Reader
+	hdr     rawpStripHeader
+	model   color.Model
+	decoder *pixDecoder
+	y       int
+	data    []byte
+	pix     []byte
+}
+
+// NewReader reads the header of a RawP strip image from r.
+func NewReader(r io.Reader) (p *Reader, err error) {
+	p = &Reader{r: r}
+	if err = binary.Read(r, binary.LittleEndian, &p.hdr); err != nil {
+		return nil, fmt.Errorf("image/rawp: NewReader, %v", err)
+	}
+	if err = rawpIsValidStripHeader(&p.hdr); err != nil {
+		return nil, err
+	}
+	if p.model, err = rawpColorModel(p.hdr.pixHeader()); err != nil {
+		return nil, err
+	}
+	if p.decoder, err = rawpPixDecoder(p.hdr.pixHeader()); err != nil {
+		return nil, err
+	}
+	p.decoder.Width = int(p.hdr.Width)
+	return
+}
+
+// Config returns the color model and dimensions of the image.
+func (p *Reader) Config() image.Config {
+	return image.Config{
+		ColorModel: p.model,
+		Width:      int(p.hdr.Width),
+		Height:     int(p.hdr.Height),
+	}
+}
+
+// StripHeight returns the rows of the strips, the last strip may have less.
+func (p *Reader) StripHeight() int {
+	return int(p.hdr.StripHeight)
+}
+
+// ReadStrip reads the next strip. It has the bounds (0, y, Width, y+rows) of
+// the strip in the image, and it is decoded into buf if buf contains these
+// bounds. It returns io.EOF after the last strip.
+func (p *Reader) ReadStrip(buf image_ext.ImageBuffer) (m draw.Image, err error) {
+	if p.y >= int(p.hdr.Height) {
+		return nil, io.EOF
+	}
+	rows := int(p.hdr.StripHeight)
+	if p.y+rows > int(p.hdr.Height) {
+		rows = int(p.hdr.Height) - p.y
+	}
+
+	var strip rawpStrip
+	if err = binary.Read(p.r, binary.LittleEndian, &strip); err != nil {
+		return nil, fmt.Errorf("image/rawp: ReadStrip, %v", noEOF(err))
+	}
+	size := p.decoder.getPixelSize() * p.decoder.Width * rows
+	if p.hdr.UseSnappy == 0 && int(strip.DataSize) != size {
+		return nil, fmt.Errorf("image/rawp: ReadStrip, bad DataSize, %v", strip.DataSize)
+	}
+	if p.hdr.UseSnappy != 0 && int(strip.DataSize) > 32+size+size/6 {
+		// larger than the snappy bound
+		return nil, fmt.Errorf("image/rawp: ReadStrip, bad DataSize, %v", strip.DataSize)
+	}
+	p.data = newBytes(int(strip.DataSize), p.data)
+	if _, err = io.ReadFull(p.r, p.data); err != nil {
+		return nil, fmt.Errorf("image/rawp: ReadStrip, %v", noEOF(err))
+	}
+	if v := crc32.ChecksumIEEE(p.data); v != strip.DataCheckSum {
+		return nil, fmt.Errorf("image/rawp: ReadStrip, bad DataCheckSum, expect = %x, got = %x", strip.DataCheckSum, v)
+	}
+
+	pix := p.data
+	if p.hdr.UseSnappy != 0 {
+		n, err := snappy.DecodedLen(p.data)
+		if err != nil || n != size {
+			return nil, fmt.Errorf("image/rawp: ReadStrip, bad snappy data")
+		}
+		if p.pix, err = snappy.Decode(newBytes(n, p.pix), p.data); err != nil {
+			return nil, fmt.Errorf("image/rawp: ReadStrip, snappy err: %v", err)
+		}
+		pix = p.pix
+	}
+
+	// the pixDecoder decodes the strip at (0, 0)
+	r := image.Rect(0, p.y, p.decoder.Width, p.y+rows)
+	var dst image_ext.ImageBuffer
+	if buf != nil && r.In(buf.Bounds()) {
+		dst = translate(buf.SubImage(r), r.Min.Mul(-1))
+	}
+	p.decoder.Height = rows
+	if m, err = p.decoder.Decode(pix, dst); err != nil {
+		return
+	}
+	m = translate(m, r.Min)
+	p.y += rows
+	return
+}
+
+// A Writer writes a RawP strip image, the rows can be written in the bands
+// of any height.
+type Writer struct {
+	w       io.Writer
+	hdr     rawpStripHeader
+	encoder *pixEncoder
+	y       int
+	strip   []byte // the encoded rows of the current strip
+	data    []byte
+	pix     []byte
+}
+
+// NewWriter writes the header of a RawP strip image to w. opt determines
+// the strip height and the compression.
+func NewWriter(w io.Writer, width, height int, model color.Model, opt *Options) (p *Writer, err error) {
+	if width <= 0 || height <= 0 || width > math.MaxInt32 || height > math.MaxInt32 {
+		err = fmt.Errorf("image/rawp: NewWriter, bad size: width = %v, height = %v", width, height)
+		return
+	}
+	p = &Writer{w: w}
+	p.hdr = rawpStripHeader{
+		Sig:         [4]byte{'R', 'A', 'W', 'P'},
+		Magic:       rawpStripMagic,
+		Width:       uint32(width),
+		Height:      uint32(height),
+		StripHeight: DefaultStripHeight,
+	}
+	if p.hdr.Channels, p.hdr.Depth, p.hdr.DataType, err = rawpPixFormat(model); err != nil {
+		return nil, err
+	}
+	if opt != nil {
+		if opt.StripHeight > 0 {
+			p.hdr.StripHeight = uint32(opt.StripHeight)
+		}
+		if opt.UseSnappy {
+			p.hdr.UseSnappy = 1
+		}
+	}
+	if p.hdr.StripHeight > p.hdr.Height {
+		p.hdr.StripHeight = p.hdr.Height
+	}
+	p.hdr.HeaderCheckSum = p.hdr.checkSum()
+
+	if p.encoder, err = rawpPixEncoder(p.hdr.pixHeader()); err != nil {
+		return nil, err
+	}
+	if err = binary.Write(w, binary.LittleEndian, &p.hdr); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// WriteRows writes the rows of m, after the rows written before. The width
+// of m must be the image width, and the pixels are converted to the color
+// model of the image.
+func (p *Writer) WriteRows(m image.Image) (err error) {
+	b := m.Bounds()
+	if b.Dx() != int(p.hdr.Width) {
+		return fmt.Errorf("image/rawp: WriteRows, bad width, expect = %d, got = %d", p.hdr.Width, b.Dx())
+	}
+	if p.y+b.Dy() > int(p.hdr.Height) {
+		return fmt.Errorf("image/rawp: WriteRows, too many rows")
+	}
+
+	rowSize := int(p.hdr.Channels) * int(p.hdr.Depth) / 8 * int(p.hdr.Width)
+	for y := b.Min.Y; y < b.Max.Y; {
+		// the rows which fill the current strip
+		n := int(p.hdr.StripHeight) - len(p.strip)/rowSize
+		if n > b.Max.Y-y {
+			n = b.Max.Y - y
+		}
+		band := image.Rect(b.Min.X, y, b.Max.X, y+n)
+		if p.pix, err = p.encoder.Encode(subImage(m, band), p.pix); err != nil {
+			return
+		}
+		p.strip = append(p.strip, p.pix...)
+		p.y += n
+		y += n
+		if len(p.strip) == int(p.hdr.StripHeight)*rowSize || p.y == int(p.hdr.Height) {
+			if err = p.writeStrip(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Close checks that all the rows are written. It does not close the
+// underlying writer.
+func (p *Writer) Close() error {
+	if p.y != int(p.hdr.Height) {
+		return fmt.Errorf("image/rawp: Close, %d of %d rows are written", p.y, p.hdr.Height)
+	}
+	return nil
+}
+
+func (p *Writer) writeStrip() (err error) {
+	data := p.strip
+	if p.hdr.UseSnappy != 0 {
+		if p.data, err = snappy.Encode(p.data[:cap(p.data)], p.strip); err != nil {
+			return
+		}
+		data = p.data
+	}
+	strip := rawpStrip{
+		DataSize:     uint32(len(data)),
+		DataCheckSum: crc32.ChecksumIEEE(data),
+	}
+	if err = binary.Write(p.w, binary.LittleEndian, &strip); err != nil {
+		return
+	}
+	if _, err = p.w.Write(data); err != nil {
+		return
+	}
+	p.strip = p.strip[:0]
+	return
+}
+
+// encodeStrips writes m as a RawP strip image.
+func encodeStrips(w io.Writer, m image.Image, opt *Options) (err error) {
+	b := m.Bounds()
+	wr, err := NewWriter(w, b.Dx(), b.Dy(), m.ColorModel(), opt)
+	if err != nil {
+		return
+	}
+	for y := b.Min.Y; y < b.Max.Y; y += int(wr.hdr.StripHeight) {
+		band := image.Rect(b.Min.X, y, b.Max.X, y+int(wr.hdr.StripHeight)).Intersect(b)
+		if err = wr.WriteRows(subImage(m, band)); err != nil {
+			return
+		}
+	}
+	return wr.Close()
+}
+
+// decodeStrips reads a RawP strip image into a whole image.
+func decodeStrips(r io.Reader) (m image.Image, err error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return
+	}
+	if rd.hdr.pixSize(rd.hdr.Height) > maxImageSize {
+		err = fmt.Errorf("image/rawp: image too large, width = %v, height = %v", rd.hdr.Width, rd.hdr.Height)
+		return
+	}
+	config := rd.Config()
+	buf := newImage(image.Rect(0, 0, config.Width, config.Height), config.ColorModel)
+	for {
+		if _, err = rd.ReadStrip(buf); err != nil {
+			if err == io.EOF {
+				return buf, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func subImage(m image.Image, r image.Rectangle) image.Image {
+	if m, ok := m.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return m.SubImage(r)
+	}
+	d := image.NewRGBA64(r)
+	draw.Draw(d, r, m, r.Min, draw.Src)
+	return d
+}
+
+func newImage(r image.Rectangle, model color.Model) image_ext.ImageBuffer {
+	switch model {
+	case color.GrayModel:
+		return image.NewGray(r)
+	case color.Gray16Model:
+		return image.NewGray16(r)
+	case color_ext.Gray32fModel:
+		return image_ext.NewGray32f(r)
+	case color_ext.RGBModel:
+		return image_ext.NewRGB(r)
+	case color_ext.RGB48Model:
+		return image_ext.NewRGB48(r)
+	case color_ext.RGB96fModel:
+		return image_ext.NewRGB96f(r)
+	case color.RGBAModel:
+		return image.NewRGBA(r)
+	case color.RGBA64Model:
+		return image.NewRGBA64(r)
+	case color_ext.RGBA128fModel:
+		return image_ext.NewRGBA128f(r)
+	}
+	return nil
+}
+
+// translate returns the image m with the bounds moved by d, the pixels are
+// shared.
+func translate(m image.Image, d image.Point) image_ext.ImageBuffer {
+	switch m := m.(type) {
+	case *image.Gray:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.Gray16:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.Gray32f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB48:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGB96f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.RGBA:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image.RGBA64:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	case *image_ext.RGBA128f:
+		t := *m
+		t.Rect = t.Rect.Add(d)
+		return &t
+	}
+	return nil
+}
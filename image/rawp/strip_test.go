@@ -0,0 +1,163 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rawp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+)
+
+func tNewImage(m image_ext.ImageBuffer) image_ext.ImageBuffer {
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := uint16(x*1237 + y*4133)
+			m.Set(x, y, color.RGBA64{v, v / 2, v / 3, 0xffff})
+		}
+	}
+	return m
+}
+
+func TestEncodeDecodeStrips(t *testing.T) {
+	r := image.Rect(0, 0, 37, 45)
+	imgs := []image_ext.ImageBuffer{
+		tNewImage(image.NewGray(r)),
+		tNewImage(image.NewGray16(r)),
+		tNewImage(image_ext.NewGray32f(r)),
+		tNewImage(image_ext.NewRGB(r)),
+		tNewImage(image_ext.NewRGB48(r)),
+		tNewImage(image_ext.NewRGB96f(r)),
+		tNewImage(image.NewRGBA(r)),
+		tNewImage(image.NewRGBA64(r)),
+		tNewImage(image_ext.NewRGBA128f(r)),
+	}
+	for _, opt := range []*Options{
+		{StripHeight: 8},
+		{StripHeight: 8, UseSnappy: true},
+		{StripHeight: 100},
+	} {
+		for _, m0 := range imgs {
+			var buf bytes.Buffer
+			if err := Encode(&buf, m0, opt); err != nil {
+				t.Fatalf("%T, %v: %v", m0, opt, err)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("%T, %v: %v", m0, opt, err)
+			}
+			if config.ColorModel != m0.ColorModel() || config.Width != 37 || config.Height != 45 {
+				t.Fatalf("%T, %v: bad config: %v", m0, opt, config)
+			}
+			m1, err := Decode(&buf, nil)
+			if err != nil {
+				t.Fatalf("%T, %v: %v", m0, opt, err)
+			}
+			if err = diff(m0, m1); err != nil {
+				t.Fatalf("%T, %v: %v", m0, opt, err)
+			}
+		}
+	}
+}
+
+// TestReaderWriter writes the rows in bands of 5 rows, and reads the strips
+// of 16 rows into a buffer of one strip.
+func TestReaderWriter(t *testing.T) {
+	m0 := tNewImage(image_ext.NewRGB48(image.Rect(0, 0, 50, 70)))
+
+	var buf bytes.Buffer
+	wr, err := NewWriter(&buf, 50, 70, m0.ColorModel(), &Options{StripHeight: 16, UseSnappy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 70; y += 5 {
+		if err = wr.WriteRows(m0.SubImage(image.Rect(0, y, 50, y+5))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = wr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	rd, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd.StripHeight() != 16 {
+		t.Fatalf("bad strip height: %d", rd.StripHeight())
+	}
+	band := image_ext.NewRGB48(image.Rect(0, 0, 50, 16))
+	for y := 0; ; y += 16 {
+		// move the buffer to the next strip
+		band.Rect = image.Rect(0, y, 50, y+16)
+		m, err := rd.ReadStrip(band)
+		if err == io.EOF {
+			if y != 80 {
+				t.Fatalf("unexpected EOF at %d", y)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := image.Rect(0, y, 50, y+16).Intersect(m0.Bounds()); m.Bounds() != r {
+			t.Fatalf("bad strip bounds: %v", m.Bounds())
+		}
+		if &m.(*image_ext.RGB48).Pix[0] != &band.Pix[0] {
+			t.Fatalf("the strip is not decoded into the buffer")
+		}
+		if err = diff(m0.SubImage(m.Bounds()), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a bad strip
+	data[len(data)-1] ^= 0xff
+	if _, err = Decode(bytes.NewReader(data), nil); err == nil {
+		t.Fatalf("expect a CRC32 error")
+	}
+
+	// the missing rows
+	wr, err = NewWriter(&buf, 50, 70, m0.ColorModel(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = wr.WriteRows(m0.SubImage(image.Rect(0, 0, 50, 69))); err != nil {
+		t.Fatal(err)
+	}
+	if err = wr.Close(); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	for _, size := range []struct{ width, height, stripHeight uint32 }{
+		{1<<31 - 1, 1<<31 - 1, 1}, // the strip is too large
+		{1 << 16, 1 << 16, 1},     // the image is too large
+	} {
+		hdr := rawpStripHeader{
+			Magic:       rawpStripMagic,
+			Width:       size.width,
+			Height:      size.height,
+			Channels:    4,
+			Depth:       8,
+			DataType:    rawpDataType_UInt,
+			StripHeight: size.stripHeight,
+		}
+		copy(hdr.Sig[:], rawpSig)
+		hdr.HeaderCheckSum = hdr.checkSum()
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, &hdr)
+		if _, err := Decode(bytes.NewReader(buf.Bytes()), nil); err == nil {
+			t.Fatalf("%v: expect an error", size)
+		}
+	}
+}
@@ -21,6 +21,9 @@ func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
 	m = adjustImage(m)
+	if opt != nil && opt.StripHeight > 0 {
+		return encodeStrips(w, m, opt)
+	}
 
 	var useSnappy bool
 	if opt != nil {
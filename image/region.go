@@ -0,0 +1,243 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/chai2010/gopkg/builtin"
+)
+
+// A Region selects the part of an image to decode, and its resolution.
+//
+// The decoded image has the bounds (0, 0, ceil(Rect.Dx()/Scale),
+// ceil(Rect.Dy()/Scale)). Every output pixel is the average of a
+// Scale x Scale block of the source pixels (the last blocks are clipped by
+// Rect). A nil *Region decodes the whole image at the full resolution.
+//
+// The codecs map the Region to their native region or scaled decoding if
+// they have one, and decode the whole image then call Apply otherwise.
+type Region struct {
+	Rect  image.Rectangle // the source rectangle, empty for the whole image
+	Scale int             // the power-of-two reduction factor, 0 or 1 for none
+}
+
+// Adjust returns the source rectangle clipped by bounds, and the scale
+// factor.
+func (p *Region) Adjust(bounds image.Rectangle) (r image.Rectangle, scale int, err error) {
+	r, scale = bounds, 1
+	if p == nil {
+		return
+	}
+	if p.Scale < 0 || p.Scale&(p.Scale-1) != 0 {
+		err = fmt.Errorf("image: Region.Adjust, scale %d is not a power of two", p.Scale)
+		return
+	}
+	if p.Scale > 1 {
+		scale = p.Scale
+	}
+	if !p.Rect.Empty() {
+		r = p.Rect.Intersect(bounds)
+	}
+	if r.Empty() {
+		err = fmt.Errorf("image: Region.Adjust, %v is outside of %v", p.Rect, bounds)
+		return
+	}
+	return
+}
+
+// Apply returns the region of the decoded image m.
+func (p *Region) Apply(m image.Image) (image.Image, error) {
+	if p == nil {
+		return m, nil
+	}
+	r, scale, err := p.Adjust(m.Bounds())
+	if err != nil {
+		return nil, err
+	}
+	return scaleDown(m, r, scale), nil
+}
+
+// ScaleDown returns the image m reduced by the power-of-two factor scale.
+func ScaleDown(m image.Image, scale int) (image.Image, error) {
+	return (&Region{Scale: scale}).Apply(m)
+}
+
+// scaleDown returns the rectangle r of m reduced by the factor scale, as an
+// image of the same type, except the types without the Pix field which are
+// returned as *image.RGBA64.
+func scaleDown(m image.Image, r image.Rectangle, scale int) image.Image {
+	dr := image.Rect(0, 0, (r.Dx()+scale-1)/scale, (r.Dy()+scale-1)/scale)
+	switch m := m.(type) {
+	case *image.Gray:
+		d := image.NewGray(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 1, 1)
+		return d
+	case *image.Gray16:
+		d := image.NewGray16(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 1, 2)
+		return d
+	case *Gray32f:
+		d := NewGray32f(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 1, 4)
+		return d
+	case *RGB:
+		d := NewRGB(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 3, 1)
+		return d
+	case *RGB48:
+		d := NewRGB48(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 3, 2)
+		return d
+	case *RGB96f:
+		d := NewRGB96f(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 4)
+		return d
+	case *image.RGBA:
+		d := image.NewRGBA(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 1)
+		return d
+	case *image.NRGBA:
+		d := image.NewNRGBA(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 1)
+		return d
+	case *image.RGBA64:
+		d := image.NewRGBA64(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 2)
+		return d
+	case *image.NRGBA64:
+		d := image.NewNRGBA64(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 2)
+		return d
+	case *RGBA128f:
+		d := NewRGBA128f(dr)
+		scalePix(d.Pix, d.Stride, m.Pix[m.PixOffset(r.Min.X, r.Min.Y):], m.Stride, r.Size(), scale, 4, 4)
+		return d
+	case *image.YCbCr:
+		return scaleYCbCr(m, r, scale)
+	case *image.Paletted:
+		// the palette indexes can not be averaged
+		d := image.NewPaletted(dr, m.Palette)
+		for y := 0; y < dr.Max.Y; y++ {
+			for x := 0; x < dr.Max.X; x++ {
+				d.Pix[y*d.Stride+x] = m.Pix[m.PixOffset(r.Min.X+x*scale, r.Min.Y+y*scale)]
+			}
+		}
+		return d
+	}
+
+	d := image.NewRGBA64(dr)
+	for y := 0; y < dr.Max.Y; y++ {
+		for x := 0; x < dr.Max.X; x++ {
+			var sum [4]uint64
+			b := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale).Add(r.Min).Intersect(r)
+			for sy := b.Min.Y; sy < b.Max.Y; sy++ {
+				for sx := b.Min.X; sx < b.Max.X; sx++ {
+					r0, g0, b0, a0 := m.At(sx, sy).RGBA()
+					sum[0], sum[1], sum[2], sum[3] = sum[0]+uint64(r0), sum[1]+uint64(g0), sum[2]+uint64(b0), sum[3]+uint64(a0)
+				}
+			}
+			n := uint64(b.Dx() * b.Dy())
+			d.SetRGBA64(x, y, color.RGBA64{
+				R: uint16((sum[0] + n/2) / n),
+				G: uint16((sum[1] + n/2) / n),
+				B: uint16((sum[2] + n/2) / n),
+				A: uint16((sum[3] + n/2) / n),
+			})
+		}
+	}
+	return d
+}
+
+// scalePix averages the scale x scale blocks of the size pixels of src into
+// dst. The pixels have the channels samples of the depth bytes, the samples
+// of 2 bytes are big-endian and the samples of 4 bytes are float32.
+func scalePix(dst []byte, dstStride int, src []byte, srcStride int, size image.Point, scale, channels, depth int) {
+	pixSize := channels * depth
+	dw, dh := (size.X+scale-1)/scale, (size.Y+scale-1)/scale
+	sum := make([]float64, channels)
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*scale, (y+1)*scale
+		if y1 > size.Y {
+			y1 = size.Y
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*scale, (x+1)*scale
+			if x1 > size.X {
+				x1 = size.X
+			}
+			for i := range sum {
+				sum[i] = 0
+			}
+			for sy := y0; sy < y1; sy++ {
+				s := src[sy*srcStride:]
+				for sx := x0; sx < x1; sx++ {
+					p := s[sx*pixSize:]
+					for i := range sum {
+						switch depth {
+						case 1:
+							sum[i] += float64(p[i])
+						case 2:
+							sum[i] += float64(uint16(p[i*2])<<8 | uint16(p[i*2+1]))
+						case 4:
+							sum[i] += float64(builtin.Float32(p[i*4:]))
+						}
+					}
+				}
+			}
+			n := float64((x1 - x0) * (y1 - y0))
+			d := dst[y*dstStride+x*pixSize:]
+			for i, v := range sum {
+				switch depth {
+				case 1:
+					d[i] = uint8(v/n + 0.5)
+				case 2:
+					v := uint16(v/n + 0.5)
+					d[i*2], d[i*2+1] = uint8(v>>8), uint8(v)
+				case 4:
+					builtin.PutFloat32(d[i*4:], float32(v/n))
+				}
+			}
+		}
+	}
+}
+
+// scaleYCbCr averages the luma of the blocks, and the chroma of the source
+// pixels covered by every chroma sample of the result.
+func scaleYCbCr(m *image.YCbCr, r image.Rectangle, scale int) *image.YCbCr {
+	d := image.NewYCbCr(image.Rect(0, 0, (r.Dx()+scale-1)/scale, (r.Dy()+scale-1)/scale), m.SubsampleRatio)
+	cb := make([]int, len(d.Cb))
+	cr := make([]int, len(d.Cr))
+	cn := make([]int, len(d.Cb))
+	for y := 0; y < d.Rect.Max.Y; y++ {
+		for x := 0; x < d.Rect.Max.X; x++ {
+			b := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale).Add(r.Min).Intersect(r)
+			var sy, sb, sr int
+			for y1 := b.Min.Y; y1 < b.Max.Y; y1++ {
+				for x1 := b.Min.X; x1 < b.Max.X; x1++ {
+					ci := m.COffset(x1, y1)
+					sy += int(m.Y[m.YOffset(x1, y1)])
+					sb += int(m.Cb[ci])
+					sr += int(m.Cr[ci])
+				}
+			}
+			n := b.Dx() * b.Dy()
+			d.Y[d.YOffset(x, y)] = uint8((sy + n/2) / n)
+			ci := d.COffset(x, y)
+			cb[ci] += sb
+			cr[ci] += sr
+			cn[ci] += n
+		}
+	}
+	for i, n := range cn {
+		if n > 0 {
+			d.Cb[i] = uint8((cb[i] + n/2) / n)
+			d.Cr[i] = uint8((cr[i] + n/2) / n)
+		}
+	}
+	return d
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package image_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func TestRegionAdjust(t *testing.T) {
+	b := image.Rect(0, 0, 100, 80)
+	for _, v := range []struct {
+		region *image_ext.Region
+		rect   image.Rectangle
+		scale  int
+		ok     bool
+	}{
+		{nil, b, 1, true},
+		{&image_ext.Region{}, b, 1, true},
+		{&image_ext.Region{Scale: 4}, b, 4, true},
+		{&image_ext.Region{Rect: image.Rect(90, 70, 120, 90), Scale: 2}, image.Rect(90, 70, 100, 80), 2, true},
+		{&image_ext.Region{Rect: image.Rect(200, 0, 210, 10)}, image.Rectangle{}, 1, false},
+		{&image_ext.Region{Scale: 3}, image.Rectangle{}, 1, false},
+		{&image_ext.Region{Scale: -2}, image.Rectangle{}, 1, false},
+	} {
+		r, scale, err := v.region.Adjust(b)
+		if (err == nil) != v.ok {
+			t.Fatalf("%v: unexpected error: %v", v.region, err)
+		}
+		if v.ok && (r != v.rect || scale != v.scale) {
+			t.Fatalf("%v: got %v/%d, want %v/%d", v.region, r, scale, v.rect, v.scale)
+		}
+	}
+}
+
+func TestRegionApply(t *testing.T) {
+	r := image.Rect(3, 2, 14, 13)
+	ms := []tImage{
+		image.NewGray(r),
+		image.NewGray16(r),
+		image_ext.NewGray32f(r),
+		image_ext.NewRGB(r),
+		image_ext.NewRGB48(r),
+		image_ext.NewRGB96f(r),
+		image.NewRGBA(r),
+		image.NewRGBA64(r),
+		image_ext.NewRGBA128f(r),
+	}
+	region := &image_ext.Region{Rect: image.Rect(4, 4, 20, 9), Scale: 2}
+	for _, m := range ms {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				// the blocks of the region have the same color
+				v := uint16((x/2)*0x1111 + (y/2)*0x0707)
+				m.Set(x, y, color.RGBA64{v, v, v, 0xffff})
+			}
+		}
+		m1, err := region.Apply(m)
+		if err != nil {
+			t.Fatalf("%T: %v", m, err)
+		}
+		if _, ok := m1.(tImage); !ok || m1.ColorModel() != m.ColorModel() {
+			t.Fatalf("%T: bad type %T", m, m1)
+		}
+		if b := m1.Bounds(); b != image.Rect(0, 0, 5, 3) {
+			t.Fatalf("%T: bad bounds %v", m, b)
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				if !cmp(t, m.ColorModel(), m.At(4+x*2, 4+y*2), m1.At(x, y)) {
+					t.Fatalf("%T: at (%d, %d), want %v, got %v", m, x, y, m.At(4+x*2, 4+y*2), m1.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func TestScaleDown(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 5, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 5; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(x + y*5)})
+		}
+	}
+	m1, err := image_ext.ScaleDown(m, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := m1.(*image_ext.Gray32f)
+	if g.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Fatalf("bad bounds %v", g.Bounds())
+	}
+	// (0+1+2+3 + 5+6+7+8 + 10+11+12+13)/12, (4+9+14)/3
+	if a, b := g.Gray32fAt(0, 0).Y, g.Gray32fAt(1, 0).Y; a != 6.5 || b != 9 {
+		t.Fatalf("bad pixels: %v, %v", a, b)
+	}
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 9, 7), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = 100
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 50, 200
+	}
+	m1, err = image_ext.ScaleDown(ycbcr, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := m1.(*image.YCbCr).YCbCrAt(4, 3); c != (color.YCbCr{100, 50, 200}) {
+		t.Fatalf("bad YCbCr: %v", c)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// decompress returns the decompressed data of a chunk, the data after
+// the max bytes of the pixels is dropped (PackBits may keep a few more).
+func decompress(data []byte, compression int, max int) (out []byte, err error) {
+	switch compression {
+	case cNone:
+		out = data
+	case cLZW:
+		out, err = lzwDecode(data, max)
+	case cDeflate, cDeflateOld:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(data)); err != nil {
+			return
+		}
+		out, err = ioutil.ReadAll(io.LimitReader(zr, int64(max)))
+		zr.Close()
+	case cPackBits:
+		out, err = unpackBits(data, max)
+	default:
+		err = UnsupportedError("compression value " + strconv.Itoa(compression))
+	}
+	return
+}
+
+// compress returns the compressed data of a chunk, which has the rows of
+// rowBytes.
+func compress(data []byte, compression CompressionType, rowBytes int) (out []byte, err error) {
+	switch compression {
+	case Uncompressed:
+		out = data
+	case LZW:
+		out = lzwEncode(data)
+	case Deflate:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err = zw.Write(data); err != nil {
+			return
+		}
+		if err = zw.Close(); err != nil {
+			return
+		}
+		out = buf.Bytes()
+	case PackBits:
+		// The rows are packed separately (p. 42 of the spec).
+		for len(data) > 0 {
+			out = packBits(out, data[:rowBytes])
+			data = data[rowBytes:]
+		}
+	}
+	return
+}
+
+// unpackBits decodes the PackBits data (p. 42 of the spec), it stops
+// after max bytes.
+func unpackBits(src []byte, max int) (dst []byte, err error) {
+	for len(src) > 0 && len(dst) < max {
+		n := int(int8(src[0]))
+		src = src[1:]
+		switch {
+		case n >= 0:
+			if n+1 > len(src) {
+				return nil, formatError("bad PackBits data")
+			}
+			dst = append(dst, src[:n+1]...)
+			src = src[n+1:]
+		case n != -128:
+			if len(src) == 0 {
+				return nil, formatError("bad PackBits data")
+			}
+			for i := 0; i < 1-n; i++ {
+				dst = append(dst, src[0])
+			}
+			src = src[1:]
+		}
+	}
+	return
+}
+
+// packBits appends the PackBits data of src to dst.
+func packBits(dst, src []byte) []byte {
+	for len(src) > 0 {
+		// the run of the same bytes
+		run := 1
+		for run < len(src) && run < 128 && src[run] == src[0] {
+			run++
+		}
+		if run > 1 {
+			dst = append(dst, byte(1-run), src[0])
+			src = src[run:]
+			continue
+		}
+		// the literal bytes, until a run of 3 bytes
+		n := 1
+		for n < len(src) && n < 128 {
+			if n+2 < len(src) && src[n] == src[n+1] && src[n] == src[n+2] {
+				break
+			}
+			n++
+		}
+		dst = append(dst, byte(n-1))
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
+
+// undoHorizontal reverts the horizontal differencing of a row, the
+// samples are of the depth (8, 16, 32 or 64) and the byte order, stride
+// is the number of samples of a pixel.
+func undoHorizontal(row []byte, depth, stride int, order binary.ByteOrder) {
+	switch depth {
+	case 8:
+		for i := stride; i < len(row); i++ {
+			row[i] += row[i-stride]
+		}
+	case 16:
+		for i := 2 * stride; i+2 <= len(row); i += 2 {
+			order.PutUint16(row[i:], order.Uint16(row[i:])+order.Uint16(row[i-2*stride:]))
+		}
+	case 32:
+		for i := 4 * stride; i+4 <= len(row); i += 4 {
+			order.PutUint32(row[i:], order.Uint32(row[i:])+order.Uint32(row[i-4*stride:]))
+		}
+	case 64:
+		for i := 8 * stride; i+8 <= len(row); i += 8 {
+			order.PutUint64(row[i:], order.Uint64(row[i:])+order.Uint64(row[i-8*stride:]))
+		}
+	}
+}
+
+// doHorizontal applies the horizontal differencing to a row, it is the
+// inverse of undoHorizontal.
+func doHorizontal(row []byte, depth, stride int, order binary.ByteOrder) {
+	switch depth {
+	case 8:
+		for i := len(row) - 1; i >= stride; i-- {
+			row[i] -= row[i-stride]
+		}
+	case 16:
+		for i := len(row)/2*2 - 2; i >= 2*stride; i -= 2 {
+			order.PutUint16(row[i:], order.Uint16(row[i:])-order.Uint16(row[i-2*stride:]))
+		}
+	case 32:
+		for i := len(row)/4*4 - 4; i >= 4*stride; i -= 4 {
+			order.PutUint32(row[i:], order.Uint32(row[i:])-order.Uint32(row[i-4*stride:]))
+		}
+	case 64:
+		for i := len(row)/8*8 - 8; i >= 8*stride; i -= 8 {
+			order.PutUint64(row[i:], order.Uint64(row[i:])-order.Uint64(row[i-8*stride:]))
+		}
+	}
+}
+
+// undoFloatingPoint reverts the floating point predictor of a row (Adobe
+// Photoshop TIFF Technical Note 3): the bytes are differenced, and the
+// bytes of the samples are split in planes, from the most significant
+// byte. The samples are returned in the byte order of the file.
+func undoFloatingPoint(row, tmp []byte, depth, stride int, order binary.ByteOrder) {
+	size := depth / 8
+	n := len(row) / size
+	for i := stride; i < n*size; i++ {
+		row[i] += row[i-stride]
+	}
+	copy(tmp, row[:n*size])
+	for i := 0; i < n; i++ {
+		for j := 0; j < size; j++ {
+			if order == binary.BigEndian {
+				row[size*i+j] = tmp[j*n+i]
+			} else {
+				row[size*i+j] = tmp[(size-1-j)*n+i]
+			}
+		}
+	}
+}
+
+// doFloatingPoint applies the floating point predictor to a row of the
+// little-endian samples, it is the inverse of undoFloatingPoint.
+func doFloatingPoint(row, tmp []byte, depth, stride int) {
+	size := depth / 8
+	n := len(row) / size
+	for i := 0; i < n; i++ {
+		for j := 0; j < size; j++ {
+			tmp[j*n+i] = row[size*i+size-1-j]
+		}
+	}
+	copy(row, tmp[:n*size])
+	for i := n*size - 1; i >= stride; i-- {
+		row[i] -= row[i-stride]
+	}
+}
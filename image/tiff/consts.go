@@ -0,0 +1,171 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+const (
+	leHeader    = "II\x2A\x00" // Header for little-endian files.
+	beHeader    = "MM\x00\x2A" // Header for big-endian files.
+	leBigHeader = "II\x2B\x00" // Header for little-endian BigTIFF files.
+	beBigHeader = "MM\x00\x2B" // Header for big-endian BigTIFF files.
+
+	ifdLen    = 12 // Length of an IFD entry in bytes.
+	bigIfdLen = 20 // Length of a BigTIFF IFD entry in bytes.
+)
+
+// Data types (p. 14-16 of the spec, and the BigTIFF types).
+type dataType uint16
+
+const (
+	dtByte      dataType = 1
+	dtASCII     dataType = 2
+	dtShort     dataType = 3
+	dtLong      dataType = 4
+	dtRational  dataType = 5
+	dtSByte     dataType = 6
+	dtUndefined dataType = 7
+	dtSShort    dataType = 8
+	dtSLong     dataType = 9
+	dtSRational dataType = 10
+	dtFloat     dataType = 11
+	dtDouble    dataType = 12
+	dtLong8     dataType = 16
+	dtSLong8    dataType = 17
+	dtIFD8      dataType = 18
+)
+
+// The length of one instance of each data type in bytes.
+var lengths = [...]uint64{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 0, 0, 0, 8, 8, 8}
+
+func (t dataType) size() uint64 {
+	if int(t) < len(lengths) {
+		return lengths[t]
+	}
+	return 0
+}
+
+// Tags (see p. 28-41 of the spec).
+const (
+	tNewSubfileType            = 254
+	tImageWidth                = 256
+	tImageLength               = 257
+	tBitsPerSample             = 258
+	tCompression               = 259
+	tPhotometricInterpretation = 262
+
+	tStripOffsets    = 273
+	tSamplesPerPixel = 277
+	tRowsPerStrip    = 278
+	tStripByteCounts = 279
+
+	tXResolution         = 282
+	tYResolution         = 283
+	tPlanarConfiguration = 284
+	tResolutionUnit      = 296
+
+	tSoftware       = 305
+	tPredictor      = 317
+	tColorMap       = 320
+	tTileWidth      = 322
+	tTileLength     = 323
+	tTileOffsets    = 324
+	tTileByteCounts = 325
+
+	tExtraSamples = 338
+	tSampleFormat = 339
+
+	// GeoTIFF and GDAL tags.
+	tModelPixelScale     = 33550
+	tModelTiepoint       = 33922
+	tModelTransformation = 34264
+	tGeoKeyDirectory     = 34735
+	tGeoDoubleParams     = 34736
+	tGeoAsciiParams      = 34737
+	tGDALMetadata        = 42112
+	tGDALNoData          = 42113
+)
+
+// Compression types (defined in various places in the spec and supplements).
+const (
+	cNone       = 1
+	cCCITT      = 2
+	cG3         = 3 // Group 3 Fax.
+	cG4         = 4 // Group 4 Fax.
+	cLZW        = 5
+	cJPEGOld    = 6 // Superseded by cJPEG.
+	cJPEG       = 7
+	cDeflate    = 8 // zlib compression.
+	cPackBits   = 32773
+	cDeflateOld = 32946 // Superseded by cDeflate.
+)
+
+// Photometric interpretation values (see p. 37 of the spec).
+const (
+	pWhiteIsZero = 0
+	pBlackIsZero = 1
+	pRGB         = 2
+	pPaletted    = 3
+	pTransMask   = 4 // transparency mask
+	pCMYK        = 5
+	pYCbCr       = 6
+	pCIELab      = 8
+)
+
+// Values for the tPredictor tag (page 64-65 of the spec).
+const (
+	prNone          = 1
+	prHorizontal    = 2
+	prFloatingPoint = 3 // Adobe Photoshop TIFF Technical Note 3.
+)
+
+// Values for the tExtraSamples tag.
+const (
+	esUnspecified  = 0
+	esAssociated   = 1 // premultiplied alpha
+	esUnassociated = 2
+)
+
+// Values for the tSampleFormat tag.
+const (
+	sfUint  = 1
+	sfInt   = 2
+	sfFloat = 3
+)
+
+// Values for the tPlanarConfiguration tag.
+const (
+	pcChunky = 1
+	pcPlanar = 2
+)
+
+// Values for the tResolutionUnit tag (page 18).
+const (
+	resNone    = 1
+	resPerInch = 2 // Dots per inch.
+	resPerCM   = 3 // Dots per centimeter.
+)
+
+// CompressionType describes the type of compression used in Options.
+type CompressionType int
+
+const (
+	Uncompressed CompressionType = iota
+	Deflate
+	LZW
+	PackBits
+)
+
+// specValue returns the compression type constant from the TIFF spec that
+// is equivalent to c.
+func (c CompressionType) specValue() uint32 {
+	switch c {
+	case Deflate:
+		return cDeflate
+	case LZW:
+		return cLZW
+	case PackBits:
+		return cPackBits
+	}
+	return cNone
+}
@@ -0,0 +1,364 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The GeoKey ids (see 6.2 of the GeoTIFF spec).
+const (
+	GeoKey_GTModelType      = 1024
+	GeoKey_GTRasterType     = 1025
+	GeoKey_GTCitation       = 1026
+	GeoKey_GeographicType   = 2048
+	GeoKey_GeogCitation     = 2049
+	GeoKey_GeogAngularUnits = 2054
+	GeoKey_ProjectedCSType  = 3072
+	GeoKey_PCSCitation      = 3073
+	GeoKey_ProjLinearUnits  = 3076
+	GeoKey_VerticalCSType   = 4096
+	GeoKey_VerticalCitation = 4097
+	GeoKey_VerticalUnits    = 4099
+)
+
+// The GeoKey values.
+const (
+	GeoKey_ModelTypeProjected  = 1 // GTModelType
+	GeoKey_ModelTypeGeographic = 2
+	GeoKey_RasterPixelIsArea   = 1 // GTRasterType
+	GeoKey_RasterPixelIsPoint  = 2
+	GeoKey_UserDefined         = 32767
+)
+
+// GeoKey is a key of the GeoKeyDirectory, with one of the value types.
+// It is also used for the GeoKeys of the LAS files (see shape/las).
+type GeoKey struct {
+	Id      uint16
+	Shorts  []uint16  // in the GeoKeyDirectory
+	Doubles []float64 // in the GeoDoubleParams
+	Ascii   string    // in the GeoAsciiParams
+}
+
+// ParseGeoKeys decodes the GeoKeyDirectory, with the values of the
+// GeoDoubleParams and the GeoAsciiParams.
+func ParseGeoKeys(dir []uint16, doubles []float64, ascii string) (keys []GeoKey, err error) {
+	if len(dir) < 4 {
+		err = fmt.Errorf("image/tiff: ParseGeoKeys, bad directory size: %d", len(dir))
+		return
+	}
+	n := int(dir[3])
+	if len(dir) < 4+4*n {
+		err = fmt.Errorf("image/tiff: ParseGeoKeys, bad directory size: %d", len(dir))
+		return
+	}
+	for i := 0; i < n; i++ {
+		e := dir[4+4*i : 8+4*i]
+		key := GeoKey{Id: e[0]}
+		location, count, offset := e[1], int(e[2]), int(e[3])
+		switch location {
+		case 0:
+			key.Shorts = []uint16{e[3]}
+		case tGeoKeyDirectory:
+			if offset+count > len(dir) {
+				err = fmt.Errorf("image/tiff: ParseGeoKeys, bad key: %d", key.Id)
+				return
+			}
+			key.Shorts = append([]uint16(nil), dir[offset:offset+count]...)
+		case tGeoDoubleParams:
+			if offset+count > len(doubles) {
+				err = fmt.Errorf("image/tiff: ParseGeoKeys, bad key: %d", key.Id)
+				return
+			}
+			key.Doubles = append([]float64(nil), doubles[offset:offset+count]...)
+		case tGeoAsciiParams:
+			if offset+count > len(ascii) {
+				err = fmt.Errorf("image/tiff: ParseGeoKeys, bad key: %d", key.Id)
+				return
+			}
+			key.Ascii = strings.TrimRight(ascii[offset:offset+count], "|\x00")
+		default:
+			err = fmt.Errorf("image/tiff: ParseGeoKeys, bad key location: %d", location)
+			return
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// MakeGeoKeys encodes the keys to the GeoKeyDirectory, the GeoDoubleParams
+// and the GeoAsciiParams, it is the inverse of ParseGeoKeys.
+func MakeGeoKeys(keys []GeoKey) (dir []uint16, doubles []float64, ascii string) {
+	keys = append([]GeoKey(nil), keys...)
+	sort.Sort(byGeoKeyId(keys))
+
+	dir = []uint16{1, 1, 0, uint16(len(keys))}
+	var shorts []uint16
+	for _, key := range keys {
+		switch {
+		case key.Doubles != nil:
+			dir = append(dir, key.Id, tGeoDoubleParams, uint16(len(key.Doubles)), uint16(len(doubles)))
+			doubles = append(doubles, key.Doubles...)
+		case key.Shorts == nil:
+			dir = append(dir, key.Id, tGeoAsciiParams, uint16(len(key.Ascii)+1), uint16(len(ascii)))
+			ascii += key.Ascii + "|"
+		case len(key.Shorts) == 1:
+			dir = append(dir, key.Id, 0, 1, key.Shorts[0])
+		default:
+			// the offset is fixed below, after the keys
+			dir = append(dir, key.Id, tGeoKeyDirectory, uint16(len(key.Shorts)), uint16(len(shorts)))
+			shorts = append(shorts, key.Shorts...)
+		}
+	}
+	for i := 4; i < len(dir); i += 4 {
+		if dir[i+1] == tGeoKeyDirectory {
+			dir[i+3] += uint16(len(dir))
+		}
+	}
+	dir = append(dir, shorts...)
+	return
+}
+
+type byGeoKeyId []GeoKey
+
+func (p byGeoKeyId) Len() int           { return len(p) }
+func (p byGeoKeyId) Less(i, j int) bool { return p[i].Id < p[j].Id }
+func (p byGeoKeyId) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// GeoInfo is the georeference of a GeoTIFF image.
+type GeoInfo struct {
+	ModelPixelScale     []float64 // ScaleX, ScaleY, ScaleZ
+	ModelTiepoint       []float64 // (I, J, K, X, Y, Z) of the tie points
+	ModelTransformation []float64 // 4x4 matrix, in row major order
+	GeoKeys             []GeoKey  // sorted by id
+	NoData              string    // GDAL_NODATA
+	Metadata            string    // GDAL_METADATA, XML
+}
+
+// NewGeoInfo returns the georeference of the transform (with the GDAL
+// coefficients order, see image/dem) and of the EPSG code, which is
+// ignored if it is 0.
+func NewGeoInfo(t [6]float64, epsg int) *GeoInfo {
+	p := new(GeoInfo)
+	p.SetGeoTransform(t)
+	p.SetKey(GeoKey{Id: GeoKey_GTRasterType, Shorts: []uint16{GeoKey_RasterPixelIsArea}})
+	if epsg != 0 {
+		p.SetEPSG(epsg)
+	}
+	return p
+}
+
+// Key returns the key of the id, or nil.
+func (p *GeoInfo) Key(id uint16) *GeoKey {
+	i := sort.Search(len(p.GeoKeys), func(i int) bool { return p.GeoKeys[i].Id >= id })
+	if i < len(p.GeoKeys) && p.GeoKeys[i].Id == id {
+		return &p.GeoKeys[i]
+	}
+	return nil
+}
+
+// SetKey adds or replaces the key.
+func (p *GeoInfo) SetKey(key GeoKey) {
+	if k := p.Key(key.Id); k != nil {
+		*k = key
+		return
+	}
+	p.GeoKeys = append(p.GeoKeys, key)
+	sort.Sort(byGeoKeyId(p.GeoKeys))
+}
+
+func (p *GeoInfo) shortKey(id uint16) (v uint16, ok bool) {
+	if k := p.Key(id); k != nil && len(k.Shorts) == 1 {
+		return k.Shorts[0], true
+	}
+	return
+}
+
+// GeoTransform returns the transform from the pixel coordinates to the
+// map coordinates, with the GDAL coefficients order (see image/dem).
+// The pixel (0, 0) is the upper left corner of the upper left pixel, for
+// the PixelIsPoint rasters too.
+func (p *GeoInfo) GeoTransform() (t [6]float64, ok bool) {
+	switch {
+	case len(p.ModelTransformation) == 16:
+		m := p.ModelTransformation
+		t = [6]float64{m[3], m[0], m[1], m[7], m[4], m[5]}
+	case len(p.ModelTiepoint) >= 6 && len(p.ModelPixelScale) >= 2:
+		i, j, x, y := p.ModelTiepoint[0], p.ModelTiepoint[1], p.ModelTiepoint[3], p.ModelTiepoint[4]
+		sx, sy := p.ModelPixelScale[0], p.ModelPixelScale[1]
+		t = [6]float64{x - i*sx, sx, 0, y + j*sy, 0, -sy}
+	default:
+		return
+	}
+	if v, _ := p.shortKey(GeoKey_GTRasterType); v == GeoKey_RasterPixelIsPoint {
+		t[0] -= 0.5*t[1] + 0.5*t[2]
+		t[3] -= 0.5*t[4] + 0.5*t[5]
+	}
+	ok = true
+	return
+}
+
+// SetGeoTransform sets the ModelPixelScale and the ModelTiepoint of a
+// north-up transform, or the ModelTransformation, the raster type is set
+// to PixelIsArea.
+func (p *GeoInfo) SetGeoTransform(t [6]float64) {
+	if t[2] == 0 && t[4] == 0 {
+		p.ModelPixelScale = []float64{t[1], -t[5], 0}
+		p.ModelTiepoint = []float64{0, 0, 0, t[0], t[3], 0}
+		p.ModelTransformation = nil
+	} else {
+		p.ModelPixelScale, p.ModelTiepoint = nil, nil
+		p.ModelTransformation = []float64{
+			t[1], t[2], 0, t[0],
+			t[4], t[5], 0, t[3],
+			0, 0, 0, 0,
+			0, 0, 0, 1,
+		}
+	}
+	if p.Key(GeoKey_GTRasterType) != nil {
+		p.SetKey(GeoKey{Id: GeoKey_GTRasterType, Shorts: []uint16{GeoKey_RasterPixelIsArea}})
+	}
+}
+
+// EPSG returns the EPSG code of the projected or the geographic CRS, ok
+// is false if it is unknown or user-defined.
+func (p *GeoInfo) EPSG() (code int, ok bool) {
+	for _, id := range []uint16{GeoKey_ProjectedCSType, GeoKey_GeographicType} {
+		if v, found := p.shortKey(id); found {
+			return int(v), v != 0 && v != GeoKey_UserDefined
+		}
+	}
+	return
+}
+
+// SetEPSG sets the model type and the CRS keys of the EPSG code, the
+// codes 4000-4999 are the geographic CRS.
+func (p *GeoInfo) SetEPSG(code int) {
+	if code >= 4000 && code < 5000 {
+		p.SetKey(GeoKey{Id: GeoKey_GTModelType, Shorts: []uint16{GeoKey_ModelTypeGeographic}})
+		p.SetKey(GeoKey{Id: GeoKey_GeographicType, Shorts: []uint16{uint16(code)}})
+		return
+	}
+	p.SetKey(GeoKey{Id: GeoKey_GTModelType, Shorts: []uint16{GeoKey_ModelTypeProjected}})
+	p.SetKey(GeoKey{Id: GeoKey_ProjectedCSType, Shorts: []uint16{uint16(code)}})
+}
+
+// NoDataValue returns the GDAL_NODATA value.
+func (p *GeoInfo) NoDataValue() (v float64, ok bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(p.NoData), 64)
+	return v, err == nil
+}
+
+// newGeoInfo returns the georeference of the IFD, or nil.
+func newGeoInfo(ifd *ifd) (p *GeoInfo, err error) {
+	p = &GeoInfo{
+		ModelPixelScale:     ifd.floats(tModelPixelScale),
+		ModelTiepoint:       ifd.floats(tModelTiepoint),
+		ModelTransformation: ifd.floats(tModelTransformation),
+		NoData:              ifd.string(tGDALNoData),
+		Metadata:            ifd.string(tGDALMetadata),
+	}
+	if dir := ifd.ints(tGeoKeyDirectory); dir != nil {
+		shorts := make([]uint16, len(dir))
+		for i, v := range dir {
+			shorts[i] = uint16(v)
+		}
+		p.GeoKeys, err = ParseGeoKeys(shorts, ifd.floats(tGeoDoubleParams), ifd.string(tGeoAsciiParams))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.ModelPixelScale == nil && p.ModelTiepoint == nil && p.ModelTransformation == nil &&
+		p.GeoKeys == nil && p.NoData == "" && p.Metadata == "" {
+		return nil, nil
+	}
+	return
+}
+
+// fields returns the GeoTIFF fields.
+func (p *GeoInfo) fields() (fields []field) {
+	if p.ModelPixelScale != nil {
+		fields = append(fields, newField(tModelPixelScale, p.ModelPixelScale))
+	}
+	if p.ModelTiepoint != nil {
+		fields = append(fields, newField(tModelTiepoint, p.ModelTiepoint))
+	}
+	if p.ModelTransformation != nil {
+		fields = append(fields, newField(tModelTransformation, p.ModelTransformation))
+	}
+	if len(p.GeoKeys) > 0 {
+		dir, doubles, ascii := MakeGeoKeys(p.GeoKeys)
+		fields = append(fields, newField(tGeoKeyDirectory, dir))
+		if len(doubles) > 0 {
+			fields = append(fields, newField(tGeoDoubleParams, doubles))
+		}
+		if len(ascii) > 0 {
+			fields = append(fields, newField(tGeoAsciiParams, ascii))
+		}
+	}
+	if p.NoData != "" {
+		fields = append(fields, newField(tGDALNoData, p.NoData))
+	}
+	if p.Metadata != "" {
+		fields = append(fields, newField(tGDALMetadata, p.Metadata))
+	}
+	return
+}
+
+// DecodeGeo reads the first image of a GeoTIFF file from r, with its
+// georeference. geo is nil if the image has no GeoTIFF tag. The geo
+// transform of geo is moved to the region of opt.
+func DecodeGeo(r io.Reader, opt *Options) (m image.Image, geo *GeoInfo, err error) {
+	rd, err := newReader(r)
+	if err != nil {
+		return
+	}
+	ds, err := rd.decoders(1)
+	if err != nil {
+		return
+	}
+	if geo, err = newGeoInfo(ds[0].ifd); err != nil {
+		return
+	}
+	if m, err = decodeImage(ds[0], opt); err != nil {
+		return nil, nil, err
+	}
+	if geo != nil && opt != nil && opt.Region != nil {
+		r, scale, err := opt.Region.Adjust(image.Rect(0, 0, ds[0].config.Width, ds[0].config.Height))
+		if err != nil {
+			return nil, nil, err
+		}
+		if t, ok := geo.GeoTransform(); ok {
+			x, y, s := float64(r.Min.X), float64(r.Min.Y), float64(scale)
+			geo.SetGeoTransform([6]float64{
+				t[0] + x*t[1] + y*t[2], t[1] * s, t[2] * s,
+				t[3] + x*t[4] + y*t[5], t[4] * s, t[5] * s,
+			})
+		}
+	}
+	return
+}
+
+// EncodeGeo writes the image m to w, with the georeference geo (which can
+// be nil).
+func EncodeGeo(w io.Writer, m image.Image, geo *GeoInfo, opt *Options) (err error) {
+	wr, err := newWriter(w, opt != nil && opt.BigTIFF)
+	if err != nil {
+		return
+	}
+	e, err := newEncoder(m, opt)
+	if err != nil {
+		return
+	}
+	if geo != nil {
+		e.extra = geo.fields()
+	}
+	return wr.writePage(e, true)
+}
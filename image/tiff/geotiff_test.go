@@ -0,0 +1,122 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+func TestGeoKeys(t *testing.T) {
+	keys := []GeoKey{
+		{Id: GeoKey_GTModelType, Shorts: []uint16{GeoKey_ModelTypeProjected}},
+		{Id: GeoKey_GTCitation, Ascii: "WGS 84 / UTM zone 50N"},
+		{Id: GeoKey_GeogCitation, Ascii: "WGS 84"},
+		{Id: 2057, Doubles: []float64{6378137}},
+		{Id: 2059, Doubles: []float64{298.257223563}},
+		{Id: GeoKey_ProjectedCSType, Shorts: []uint16{32650}},
+		{Id: 4000, Shorts: []uint16{1, 2, 3}},
+	}
+	dir, doubles, ascii := MakeGeoKeys(keys)
+	if ascii != "WGS 84 / UTM zone 50N|WGS 84|" || len(doubles) != 2 || dir[3] != uint16(len(keys)) {
+		t.Fatalf("bad directory: %v, %v, %q", dir, doubles, ascii)
+	}
+	got, err := ParseGeoKeys(dir, doubles, ascii)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, keys) {
+		t.Fatalf("bad keys: %v", got)
+	}
+
+	if _, err := ParseGeoKeys(dir[:len(dir)-1], doubles, ascii); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestGeoTransform(t *testing.T) {
+	for _, v := range [][6]float64{
+		{500000, 30, 0, 4400000, 0, -30},
+		{500000, 30, 2, 4400000, 3, -30},
+	} {
+		geo := NewGeoInfo(v, 32650)
+		if got, ok := geo.GeoTransform(); !ok || got != v {
+			t.Fatalf("%v: got %v", v, got)
+		}
+		if code, ok := geo.EPSG(); !ok || code != 32650 {
+			t.Fatalf("bad EPSG: %d", code)
+		}
+	}
+
+	// the tie point is the center of the pixel
+	geo := &GeoInfo{
+		ModelPixelScale: []float64{1, 1, 0},
+		ModelTiepoint:   []float64{0, 0, 0, 100, 50, 0},
+	}
+	geo.SetKey(GeoKey{Id: GeoKey_GTRasterType, Shorts: []uint16{GeoKey_RasterPixelIsPoint}})
+	if got, _ := geo.GeoTransform(); got != [6]float64{99.5, 1, 0, 50.5, 0, -1} {
+		t.Fatalf("bad transform: %v", got)
+	}
+}
+
+func TestEncodeDecodeGeo(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(x*y) - 100})
+		}
+	}
+	m.SetGray32f(3, 4, color_ext.Gray32f{Y: -9999})
+
+	geo := NewGeoInfo([6]float64{100, 0.001, 0, 40, 0, -0.001}, 4326)
+	geo.SetKey(GeoKey{Id: GeoKey_GeogCitation, Ascii: "WGS 84"})
+	geo.NoData = "-9999"
+	geo.Metadata = `<GDALMetadata><Item name="UNITS">m</Item></GDALMetadata>`
+
+	var buf bytes.Buffer
+	opt := &Options{Compression: Deflate, Predictor: true, TileSize: image.Pt(16, 16)}
+	if err := EncodeGeo(&buf, m, geo, opt); err != nil {
+		t.Fatal(err)
+	}
+	m1, geo1, err := DecodeGeo(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, m1) {
+		t.Fatalf("different image")
+	}
+	if !reflect.DeepEqual(geo, geo1) {
+		t.Fatalf("bad GeoInfo: %v", geo1)
+	}
+	if v, ok := geo1.NoDataValue(); !ok || v != -9999 {
+		t.Fatalf("bad NODATA: %v", v)
+	}
+	if code, ok := geo1.EPSG(); !ok || code != 4326 {
+		t.Fatalf("bad EPSG: %d", code)
+	}
+
+	// the geo transform of a region
+	opt = &Options{Region: &image_ext.Region{Rect: image.Rect(10, 20, 40, 30), Scale: 2}}
+	if _, geo1, err = DecodeGeo(bytes.NewReader(buf.Bytes()), opt); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := geo1.GeoTransform(); got != [6]float64{100.01, 0.002, 0, 39.98, 0, -0.002} {
+		t.Fatalf("bad region transform: %v", got)
+	}
+
+	// a TIFF image without GeoTIFF tags
+	buf.Reset()
+	if err := Encode(&buf, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, geo1, err = DecodeGeo(&buf, nil); err != nil || geo1 != nil {
+		t.Fatalf("expect no GeoInfo: %v, %v", geo1, err)
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// field is an IFD entry, Value is in the byte order of the file.
+type field struct {
+	Tag   uint16
+	Type  dataType
+	Count uint64
+	Value []byte
+}
+
+// ifd is an image file directory, the fields are sorted by tag.
+type ifd struct {
+	Fields    []field
+	ByteOrder binary.ByteOrder
+}
+
+// readIFD reads the IFD at the offset, and returns the offset of the next
+// IFD (0 if it is the last one).
+func readIFD(r io.ReaderAt, offset int64, order binary.ByteOrder, big bool) (p *ifd, next int64, err error) {
+	countLen, entryLen, offsetLen := int64(2), int64(ifdLen), int64(4)
+	if big {
+		countLen, entryLen, offsetLen = 8, bigIfdLen, 8
+	}
+
+	buf := make([]byte, countLen)
+	if _, err = r.ReadAt(buf, offset); err != nil {
+		err = formatError("bad IFD offset")
+		return
+	}
+	var n int64
+	if big {
+		n = int64(order.Uint64(buf))
+	} else {
+		n = int64(order.Uint16(buf))
+	}
+	if n <= 0 || n > 1<<16 {
+		err = formatError("bad IFD entry count")
+		return
+	}
+
+	buf = make([]byte, n*entryLen+offsetLen)
+	if _, err = r.ReadAt(buf, offset+countLen); err != nil {
+		err = formatError("bad IFD entries")
+		return
+	}
+
+	p = &ifd{ByteOrder: order}
+	for i := int64(0); i < n; i++ {
+		e := buf[i*entryLen : (i+1)*entryLen]
+		f := field{
+			Tag:  order.Uint16(e[0:2]),
+			Type: dataType(order.Uint16(e[2:4])),
+		}
+		var inline []byte
+		if big {
+			f.Count, inline = order.Uint64(e[4:12]), e[12:20]
+		} else {
+			f.Count, inline = uint64(order.Uint32(e[4:8])), e[8:12]
+		}
+		size := f.Type.size()
+		if size == 0 {
+			continue // unknown types are skipped
+		}
+		if f.Count > 1<<30/size {
+			err = formatError("bad IFD entry count")
+			return
+		}
+		if datalen := size * f.Count; datalen <= uint64(len(inline)) {
+			f.Value = append([]byte(nil), inline[:datalen]...)
+		} else {
+			var off int64
+			if big {
+				off = int64(order.Uint64(inline))
+			} else {
+				off = int64(order.Uint32(inline))
+			}
+			f.Value = make([]byte, datalen)
+			if _, err = r.ReadAt(f.Value, off); err != nil {
+				err = formatError("bad IFD entry offset")
+				return
+			}
+		}
+		p.Fields = append(p.Fields, f)
+	}
+	sort.Sort(byTag(p.Fields))
+
+	if big {
+		next = int64(order.Uint64(buf[n*entryLen:]))
+	} else {
+		next = int64(order.Uint32(buf[n*entryLen:]))
+	}
+	return
+}
+
+type byTag []field
+
+func (p byTag) Len() int           { return len(p) }
+func (p byTag) Less(i, j int) bool { return p[i].Tag < p[j].Tag }
+func (p byTag) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+func (p *ifd) lookup(tag uint16) *field {
+	i := sort.Search(len(p.Fields), func(i int) bool { return p.Fields[i].Tag >= tag })
+	if i < len(p.Fields) && p.Fields[i].Tag == tag {
+		return &p.Fields[i]
+	}
+	return nil
+}
+
+// ints returns the integer values of the tag, or nil.
+func (p *ifd) ints(tag uint16) []uint64 {
+	f := p.lookup(tag)
+	if f == nil {
+		return nil
+	}
+	v := make([]uint64, f.Count)
+	for i := range v {
+		b := f.Value[uint64(i)*f.Type.size():]
+		switch f.Type {
+		case dtByte, dtSByte, dtUndefined, dtASCII:
+			v[i] = uint64(b[0])
+		case dtShort, dtSShort:
+			v[i] = uint64(p.ByteOrder.Uint16(b))
+		case dtLong, dtSLong:
+			v[i] = uint64(p.ByteOrder.Uint32(b))
+		case dtLong8, dtSLong8, dtIFD8:
+			v[i] = p.ByteOrder.Uint64(b)
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// int returns the first integer value of the tag, or the default value.
+func (p *ifd) int(tag uint16, defaultValue uint64) uint64 {
+	if v := p.ints(tag); len(v) > 0 {
+		return v[0]
+	}
+	return defaultValue
+}
+
+// floats returns the float values of the tag (which can be integers or
+// rationals), or nil.
+func (p *ifd) floats(tag uint16) []float64 {
+	f := p.lookup(tag)
+	if f == nil {
+		return nil
+	}
+	v := make([]float64, f.Count)
+	for i := range v {
+		b := f.Value[uint64(i)*f.Type.size():]
+		switch f.Type {
+		case dtFloat:
+			v[i] = float64(math.Float32frombits(p.ByteOrder.Uint32(b)))
+		case dtDouble:
+			v[i] = math.Float64frombits(p.ByteOrder.Uint64(b))
+		case dtRational:
+			v[i] = float64(p.ByteOrder.Uint32(b)) / float64(p.ByteOrder.Uint32(b[4:]))
+		case dtSRational:
+			v[i] = float64(int32(p.ByteOrder.Uint32(b))) / float64(int32(p.ByteOrder.Uint32(b[4:])))
+		case dtSByte:
+			v[i] = float64(int8(b[0]))
+		case dtSShort:
+			v[i] = float64(int16(p.ByteOrder.Uint16(b)))
+		case dtSLong:
+			v[i] = float64(int32(p.ByteOrder.Uint32(b)))
+		case dtSLong8:
+			v[i] = float64(int64(p.ByteOrder.Uint64(b)))
+		default:
+			u := p.ints(tag)
+			if u == nil {
+				return nil
+			}
+			v[i] = float64(u[i])
+		}
+	}
+	return v
+}
+
+// string returns the ASCII value of the tag, without the trailing NULs.
+func (p *ifd) string(tag uint16) string {
+	f := p.lookup(tag)
+	if f == nil || f.Type != dtASCII {
+		return ""
+	}
+	return strings.TrimRight(string(f.Value), "\x00")
+}
+
+// newField returns a little-endian field of the values, which can be
+// []uint16 (SHORT), []uint32 (LONG), []uint64 (LONG8), []float64
+// (DOUBLE), [][2]uint32 (RATIONAL), []byte (UNDEFINED) or string (ASCII).
+func newField(tag uint16, values interface{}) field {
+	le := binary.LittleEndian
+	f := field{Tag: tag}
+	switch v := values.(type) {
+	case []uint16:
+		f.Type, f.Count, f.Value = dtShort, uint64(len(v)), make([]byte, 2*len(v))
+		for i, x := range v {
+			le.PutUint16(f.Value[2*i:], x)
+		}
+	case []uint32:
+		f.Type, f.Count, f.Value = dtLong, uint64(len(v)), make([]byte, 4*len(v))
+		for i, x := range v {
+			le.PutUint32(f.Value[4*i:], x)
+		}
+	case []uint64:
+		f.Type, f.Count, f.Value = dtLong8, uint64(len(v)), make([]byte, 8*len(v))
+		for i, x := range v {
+			le.PutUint64(f.Value[8*i:], x)
+		}
+	case []float64:
+		f.Type, f.Count, f.Value = dtDouble, uint64(len(v)), make([]byte, 8*len(v))
+		for i, x := range v {
+			le.PutUint64(f.Value[8*i:], math.Float64bits(x))
+		}
+	case [][2]uint32:
+		f.Type, f.Count, f.Value = dtRational, uint64(len(v)), make([]byte, 8*len(v))
+		for i, x := range v {
+			le.PutUint32(f.Value[8*i:], x[0])
+			le.PutUint32(f.Value[8*i+4:], x[1])
+		}
+	case []byte:
+		f.Type, f.Count, f.Value = dtUndefined, uint64(len(v)), append([]byte(nil), v...)
+	case string:
+		f.Type, f.Count, f.Value = dtASCII, uint64(len(v)+1), append([]byte(v), 0)
+	default:
+		panic(fmt.Sprintf("image/tiff: newField, bad type: %T", values))
+	}
+	return f
+}
+
+// offsetsField returns the field of the chunk offsets, LONG or LONG8.
+func offsetsField(tag uint16, offsets []uint64, big bool) field {
+	if big {
+		return newField(tag, offsets)
+	}
+	v := make([]uint32, len(offsets))
+	for i, x := range offsets {
+		v[i] = uint32(x)
+	}
+	return newField(tag, v)
+}
+
+// ifdSize returns the size of the IFD with the fields, and the size of
+// the values which are not inline.
+func ifdSize(fields []field, big bool) (size, valueSize int64) {
+	entryLen, inlineLen := int64(ifdLen), uint64(4)
+	size = 2 + int64(len(fields))*entryLen + 4
+	if big {
+		entryLen, inlineLen = bigIfdLen, 8
+		size = 8 + int64(len(fields))*entryLen + 8
+	}
+	for _, f := range fields {
+		if n := uint64(len(f.Value)); n > inlineLen {
+			valueSize += int64(n+1) &^ 1 // word aligned
+		}
+	}
+	return
+}
+
+// writeIFD writes the little-endian IFD at the offset, followed by its
+// values which are not inline.
+func writeIFD(w io.Writer, fields []field, offset, next int64, big bool) (err error) {
+	le := binary.LittleEndian
+	sort.Sort(byTag(fields))
+	size, valueSize := ifdSize(fields, big)
+
+	buf := make([]byte, size, size+valueSize)
+	inlineLen := 4
+	p := buf
+	if big {
+		inlineLen = 8
+		le.PutUint64(p, uint64(len(fields)))
+		p = p[8:]
+	} else {
+		le.PutUint16(p, uint16(len(fields)))
+		p = p[2:]
+	}
+	for _, f := range fields {
+		le.PutUint16(p[0:], f.Tag)
+		le.PutUint16(p[2:], uint16(f.Type))
+		var inline []byte
+		if big {
+			le.PutUint64(p[4:], f.Count)
+			inline, p = p[12:20], p[20:]
+		} else {
+			le.PutUint32(p[4:], uint32(f.Count))
+			inline, p = p[8:12], p[12:]
+		}
+		if len(f.Value) <= inlineLen {
+			copy(inline, f.Value)
+			continue
+		}
+		if big {
+			le.PutUint64(inline, uint64(offset+int64(len(buf))))
+		} else {
+			le.PutUint32(inline, uint32(offset+int64(len(buf))))
+		}
+		buf = append(buf, f.Value...)
+		if len(f.Value)%2 != 0 {
+			buf = append(buf, 0)
+		}
+	}
+	if big {
+		le.PutUint64(p, uint64(next))
+	} else {
+		le.PutUint32(p, uint32(next))
+	}
+	_, err = w.Write(buf)
+	return
+}
+
+// A FormatError reports that the input is not a valid TIFF image.
+type FormatError string
+
+func (e FormatError) Error() string {
+	return "image/tiff: invalid format: " + string(e)
+}
+
+// An UnsupportedError reports that the input uses a valid but
+// unimplemented feature.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string {
+	return "image/tiff: unsupported feature: " + string(e)
+}
+
+func formatError(s string) error {
+	return FormatError(s)
+}
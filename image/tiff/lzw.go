@@ -0,0 +1,139 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+// The TIFF variant of LZW differs from compress/lzw: the codes are packed
+// MSB first, and the code width is increased one code earlier ("early
+// change"), see p. 57-61 of the spec.
+
+const (
+	lzwClear    = 256
+	lzwEOI      = 257
+	lzwFirst    = 258
+	lzwMinWidth = 9
+	lzwMaxWidth = 12
+	lzwMaxCode  = 1<<lzwMaxWidth - 1
+)
+
+// lzwDecode decodes the LZW data, it stops after max bytes.
+func lzwDecode(src []byte, max int) (dst []byte, err error) {
+	// The string of a code is always in dst, it is the previous string
+	// and the first byte of the string following it.
+	var table [lzwMaxCode + 1]struct{ off, n int }
+
+	var bits uint32
+	var nbits uint
+	width, next := uint(lzwMinWidth), lzwFirst
+	prevOff, prevN := 0, 0
+
+	for i := 0; len(dst) < max; {
+		for nbits < width {
+			if i >= len(src) {
+				return // missing EOI
+			}
+			bits = bits<<8 | uint32(src[i])
+			nbits += 8
+			i++
+		}
+		code := int(bits>>(nbits-width)) & (1<<width - 1)
+		nbits -= width
+
+		switch code {
+		case lzwClear:
+			width, next = lzwMinWidth, lzwFirst
+			prevN = 0
+			continue
+		case lzwEOI:
+			return
+		}
+
+		off, n := len(dst), 0
+		switch {
+		case code < lzwClear:
+			dst, n = append(dst, byte(code)), 1
+		case code < next && code >= lzwFirst:
+			e := table[code]
+			dst, n = append(dst, dst[e.off:e.off+e.n]...), e.n
+		case code == next && prevN > 0:
+			dst = append(dst, dst[prevOff:prevOff+prevN]...)
+			dst, n = append(dst, dst[prevOff]), prevN+1
+		default:
+			err = formatError("bad LZW code")
+			return
+		}
+
+		if prevN > 0 && next <= lzwMaxCode {
+			table[next].off, table[next].n = prevOff, prevN+1
+			if next++; next >= 1<<width-1 && width < lzwMaxWidth {
+				width++
+			}
+		}
+		prevOff, prevN = off, n
+	}
+	return
+}
+
+// lzwEncode encodes the data with LZW, as libtiff does.
+func lzwEncode(src []byte) []byte {
+	w := &bitWriter{}
+	w.write(lzwClear, lzwMinWidth)
+	if len(src) == 0 {
+		w.write(lzwEOI, lzwMinWidth)
+		return w.flush()
+	}
+
+	table := make(map[uint32]int)
+	width, next := uint(lzwMinWidth), lzwFirst
+	addCode := func() {
+		if next++; next == lzwMaxCode-1 {
+			w.write(lzwClear, width)
+			table = make(map[uint32]int)
+			width, next = lzwMinWidth, lzwFirst
+		} else if next > 1<<width-1 {
+			width++
+		}
+	}
+
+	code := int(src[0])
+	for _, c := range src[1:] {
+		key := uint32(code)<<8 | uint32(c)
+		if v, ok := table[key]; ok {
+			code = v
+			continue
+		}
+		w.write(code, width)
+		table[key] = next
+		addCode()
+		code = int(c)
+	}
+	w.write(code, width)
+	addCode()
+	w.write(lzwEOI, width)
+	return w.flush()
+}
+
+// bitWriter packs the codes MSB first.
+type bitWriter struct {
+	buf   []byte
+	bits  uint32
+	nbits uint
+}
+
+func (p *bitWriter) write(code int, width uint) {
+	p.bits = p.bits<<width | uint32(code)
+	p.nbits += width
+	for p.nbits >= 8 {
+		p.buf = append(p.buf, byte(p.bits>>(p.nbits-8)))
+		p.nbits -= 8
+	}
+}
+
+func (p *bitWriter) flush() []byte {
+	if p.nbits > 0 {
+		p.buf = append(p.buf, byte(p.bits<<(8-p.nbits)))
+		p.nbits = 0
+	}
+	return p.buf
+}
@@ -0,0 +1,601 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+
+	"github.com/chai2010/gopkg/builtin"
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// imageMode is the type of the decoded image.
+type imageMode int
+
+const (
+	mGray imageMode = iota
+	mPaletted
+	mGray16
+	mGray32f
+	mRGB
+	mRGB48
+	mRGB96f
+	mRGBA
+	mNRGBA
+	mRGBA64
+	mNRGBA64
+	mRGBA128f
+)
+
+// maxPixelsSize is the maximum size of the pixels of a decoded image, the
+// larger images can be decoded by regions.
+const maxPixelsSize = 1 << 30
+
+// pixelSize returns the bytes of a pixel of the decoded image.
+func (m imageMode) pixelSize() int {
+	switch m {
+	case mGray, mPaletted:
+		return 1
+	case mGray16:
+		return 2
+	case mRGB:
+		return 3
+	case mGray32f, mRGBA, mNRGBA:
+		return 4
+	case mRGB48:
+		return 6
+	case mRGBA64, mNRGBA64:
+		return 8
+	case mRGB96f:
+		return 12
+	}
+	return 16
+}
+
+// reader reads the header and the IFDs of a TIFF file.
+type reader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+	big   bool
+	first int64 // offset of the first IFD
+}
+
+// newReader reads the header of the TIFF file, the whole file is read
+// into memory if r is not an io.ReaderAt.
+func newReader(r io.Reader) (p *reader, err error) {
+	p = new(reader)
+	if ra, ok := r.(io.ReaderAt); ok {
+		p.r = ra
+	} else {
+		var data []byte
+		if data, err = ioutil.ReadAll(r); err != nil {
+			return
+		}
+		p.r = bytes.NewReader(data)
+	}
+
+	var hdr [16]byte
+	if _, err = p.r.ReadAt(hdr[:8], 0); err != nil {
+		err = formatError("bad header")
+		return
+	}
+	switch string(hdr[:4]) {
+	case leHeader:
+		p.order = binary.LittleEndian
+	case beHeader:
+		p.order = binary.BigEndian
+	case leBigHeader:
+		p.order, p.big = binary.LittleEndian, true
+	case beBigHeader:
+		p.order, p.big = binary.BigEndian, true
+	default:
+		err = formatError("bad header")
+		return
+	}
+	if !p.big {
+		p.first = int64(p.order.Uint32(hdr[4:8]))
+		return
+	}
+	if _, err = p.r.ReadAt(hdr[8:16], 8); err != nil {
+		err = formatError("bad header")
+		return
+	}
+	if p.order.Uint16(hdr[4:6]) != 8 || p.order.Uint16(hdr[6:8]) != 0 {
+		err = formatError("bad BigTIFF header")
+		return
+	}
+	p.first = int64(p.order.Uint64(hdr[8:16]))
+	return
+}
+
+// decoders returns the decoders of the IFDs, at most n if n >= 0.
+func (p *reader) decoders(n int) (ds []*decoder, err error) {
+	seen := make(map[int64]bool)
+	for offset := p.first; offset != 0 && n != 0; n-- {
+		if seen[offset] {
+			err = formatError("IFD loop")
+			return
+		}
+		seen[offset] = true
+
+		var ifd *ifd
+		if ifd, offset, err = readIFD(p.r, offset, p.order, p.big); err != nil {
+			return
+		}
+		var d *decoder
+		if d, err = newDecoder(p.r, ifd); err != nil {
+			return
+		}
+		ds = append(ds, d)
+	}
+	if len(ds) == 0 {
+		err = formatError("no IFD")
+	}
+	return
+}
+
+// decoder decodes the image of an IFD.
+type decoder struct {
+	r      io.ReaderAt
+	ifd    *ifd
+	order  binary.ByteOrder
+	config image.Config
+	mode   imageMode
+
+	bpp          int // bits per sample
+	spp          int // samples per pixel
+	nc           int // channels of the image
+	sampleFormat int
+	compression  int
+	predictor    int
+	planar       bool
+	invert       bool // WhiteIsZero
+	premultiply  bool // unassociated alpha of the float samples
+
+	chunkW, chunkH  int // size of a tile or a strip
+	offsets, counts []uint64
+}
+
+func newDecoder(r io.ReaderAt, ifd *ifd) (p *decoder, err error) {
+	p = &decoder{
+		r:     r,
+		ifd:   ifd,
+		order: ifd.ByteOrder,
+	}
+	p.config.Width = int(ifd.int(tImageWidth, 0))
+	p.config.Height = int(ifd.int(tImageLength, 0))
+	if p.config.Width <= 0 || p.config.Height <= 0 || p.config.Width > 1<<30 || p.config.Height > 1<<30 {
+		err = formatError("bad image size")
+		return
+	}
+
+	p.spp = int(ifd.int(tSamplesPerPixel, 1))
+	if p.spp <= 0 || p.spp > 0xffff {
+		err = formatError("bad SamplesPerPixel")
+		return
+	}
+	bps := ifd.ints(tBitsPerSample)
+	if len(bps) == 0 {
+		bps = []uint64{1}
+	}
+	for _, v := range bps {
+		if v != bps[0] {
+			err = UnsupportedError("different BitsPerSample values")
+			return
+		}
+	}
+	p.bpp = int(bps[0])
+	p.sampleFormat = int(ifd.int(tSampleFormat, sfUint))
+	p.compression = int(ifd.int(tCompression, cNone))
+	p.predictor = int(ifd.int(tPredictor, prNone))
+	p.planar = ifd.int(tPlanarConfiguration, pcChunky) == pcPlanar
+
+	switch p.sampleFormat {
+	case sfUint:
+		switch p.bpp {
+		case 1, 2, 4, 8, 16, 32:
+		default:
+			err = UnsupportedError("BitsPerSample of " + strconv.Itoa(p.bpp))
+			return
+		}
+	case sfInt:
+		switch p.bpp {
+		case 8, 16, 32:
+		default:
+			err = UnsupportedError("signed BitsPerSample of " + strconv.Itoa(p.bpp))
+			return
+		}
+	case sfFloat:
+		switch p.bpp {
+		case 32, 64:
+		default:
+			err = UnsupportedError("float BitsPerSample of " + strconv.Itoa(p.bpp))
+			return
+		}
+	default:
+		err = UnsupportedError("SampleFormat of " + strconv.Itoa(p.sampleFormat))
+		return
+	}
+	isUint := p.sampleFormat == sfUint
+
+	if ifd.lookup(tPhotometricInterpretation) == nil {
+		err = formatError("missing PhotometricInterpretation")
+		return
+	}
+	photometric := int(ifd.int(tPhotometricInterpretation, 0))
+	switch photometric {
+	case pWhiteIsZero, pBlackIsZero:
+		p.invert = photometric == pWhiteIsZero
+		p.nc = 1
+		switch {
+		case isUint && p.bpp <= 8:
+			p.mode, p.config.ColorModel = mGray, color.GrayModel
+		case isUint && p.bpp == 16:
+			p.mode, p.config.ColorModel = mGray16, color.Gray16Model
+		default:
+			p.mode, p.config.ColorModel = mGray32f, color_ext.Gray32fModel
+		}
+	case pPaletted:
+		if !isUint || p.bpp > 8 {
+			err = UnsupportedError("palette of BitsPerSample of " + strconv.Itoa(p.bpp))
+			return
+		}
+		cmap := ifd.ints(tColorMap)
+		n := 1 << uint(p.bpp)
+		if len(cmap) != 3*n {
+			err = formatError("bad ColorMap length")
+			return
+		}
+		palette := make(color.Palette, n)
+		for i := range palette {
+			palette[i] = color.RGBA64{
+				R: uint16(cmap[i]),
+				G: uint16(cmap[i+n]),
+				B: uint16(cmap[i+2*n]),
+				A: 0xffff,
+			}
+		}
+		p.nc = 1
+		p.mode, p.config.ColorModel = mPaletted, palette
+	case pRGB:
+		if p.spp < 3 {
+			err = formatError("bad SamplesPerPixel for RGB")
+			return
+		}
+		p.nc = 3
+		extra := ifd.int(tExtraSamples, esUnspecified)
+		if p.spp >= 4 && (extra == esAssociated || extra == esUnassociated) {
+			p.nc = 4
+		}
+		switch {
+		case isUint && p.bpp == 8 && p.nc == 3:
+			p.mode, p.config.ColorModel = mRGB, color_ext.RGBModel
+		case isUint && p.bpp == 8 && extra == esAssociated:
+			p.mode, p.config.ColorModel = mRGBA, color.RGBAModel
+		case isUint && p.bpp == 8:
+			p.mode, p.config.ColorModel = mNRGBA, color.NRGBAModel
+		case isUint && p.bpp == 16 && p.nc == 3:
+			p.mode, p.config.ColorModel = mRGB48, color_ext.RGB48Model
+		case isUint && p.bpp == 16 && extra == esAssociated:
+			p.mode, p.config.ColorModel = mRGBA64, color.RGBA64Model
+		case isUint && p.bpp == 16:
+			p.mode, p.config.ColorModel = mNRGBA64, color.NRGBA64Model
+		case isUint && p.bpp < 8:
+			err = UnsupportedError("RGB of BitsPerSample of " + strconv.Itoa(p.bpp))
+			return
+		case p.nc == 3:
+			p.mode, p.config.ColorModel = mRGB96f, color_ext.RGB96fModel
+		default:
+			p.mode, p.config.ColorModel = mRGBA128f, color_ext.RGBA128fModel
+			p.premultiply = extra == esUnassociated
+		}
+	default:
+		err = UnsupportedError("PhotometricInterpretation of " + strconv.Itoa(photometric))
+		return
+	}
+	if p.predictor != prNone {
+		switch {
+		case p.predictor == prHorizontal && p.bpp >= 8:
+		case p.predictor == prFloatingPoint && p.sampleFormat == sfFloat:
+		default:
+			err = UnsupportedError("Predictor of " + strconv.Itoa(p.predictor))
+			return
+		}
+	}
+
+	if ifd.lookup(tTileWidth) != nil {
+		p.chunkW = int(ifd.int(tTileWidth, 0))
+		p.chunkH = int(ifd.int(tTileLength, 0))
+		p.offsets = ifd.ints(tTileOffsets)
+		p.counts = ifd.ints(tTileByteCounts)
+	} else {
+		p.chunkW = p.config.Width
+		p.chunkH = int(ifd.int(tRowsPerStrip, uint64(p.config.Height)))
+		p.offsets = ifd.ints(tStripOffsets)
+		p.counts = ifd.ints(tStripByteCounts)
+	}
+	if p.chunkW <= 0 || p.chunkH <= 0 || p.chunkW > 1<<30 || p.chunkH > 1<<30 {
+		err = formatError("bad tile or strip size")
+		return
+	}
+	if p.chunkH > p.config.Height {
+		p.chunkH = p.config.Height
+	}
+	if uint64(p.rowBytes()) > uint64(maxPixelsSize/p.chunkH) {
+		err = formatError("tile or strip too large")
+		return
+	}
+	if p.counts == nil && p.compression == cNone {
+		p.counts = make([]uint64, len(p.offsets))
+		for i := range p.counts {
+			p.counts[i] = uint64(p.chunkBytes(p.chunkRows(i)))
+		}
+	}
+	if n := p.chunksAcross() * p.chunksDown() * p.planes(); len(p.offsets) < n || len(p.counts) < n {
+		err = formatError("not enough tiles or strips")
+		return
+	}
+	return
+}
+
+func (p *decoder) chunksAcross() int {
+	return (p.config.Width + p.chunkW - 1) / p.chunkW
+}
+
+func (p *decoder) chunksDown() int {
+	return (p.config.Height + p.chunkH - 1) / p.chunkH
+}
+
+func (p *decoder) planes() int {
+	if p.planar {
+		return p.spp
+	}
+	return 1
+}
+
+// rowBytes returns the size of a row of a chunk.
+func (p *decoder) rowBytes() int {
+	if p.planar {
+		return (p.chunkW*p.bpp + 7) / 8
+	}
+	return (p.chunkW*p.spp*p.bpp + 7) / 8
+}
+
+// chunkRows returns the rows of the chunk i, the last strip can be
+// shorter than the others, but the tiles are padded.
+func (p *decoder) chunkRows(i int) int {
+	if p.ifd.lookup(tTileWidth) != nil {
+		return p.chunkH
+	}
+	y := (i % p.chunksDown()) * p.chunkH
+	if y+p.chunkH > p.config.Height {
+		return p.config.Height - y
+	}
+	return p.chunkH
+}
+
+func (p *decoder) chunkBytes(rows int) int {
+	return p.rowBytes() * rows
+}
+
+// decode decodes the image in the rectangle, which is in the image.
+func (p *decoder) decode(r image.Rectangle) (m image.Image, err error) {
+	if uint64(r.Dx())*uint64(r.Dy()) > uint64(maxPixelsSize/p.mode.pixelSize()) {
+		err = formatError("image too large")
+		return
+	}
+	m = newImage(r, p.mode, p.config.ColorModel)
+	dst := newPixels(m)
+
+	nx, ny := p.chunksAcross(), p.chunksDown()
+	for plane := 0; plane < p.planes() && plane < p.nc; plane++ {
+		for cy := r.Min.Y / p.chunkH; cy < ny && cy*p.chunkH < r.Max.Y; cy++ {
+			for cx := r.Min.X / p.chunkW; cx < nx && cx*p.chunkW < r.Max.X; cx++ {
+				i := (plane*ny+cy)*nx + cx
+				var data []byte
+				if data, err = p.readChunk(i); err != nil {
+					return nil, err
+				}
+				if data != nil {
+					p.storeChunk(&dst, data, image.Pt(cx*p.chunkW, cy*p.chunkH), plane)
+				}
+			}
+		}
+	}
+	if p.premultiply {
+		premultiply(m.(*image_ext.RGBA128f))
+	}
+	return
+}
+
+// readChunk returns the decompressed data of the chunk i, it returns nil
+// if the chunk is missing.
+func (p *decoder) readChunk(i int) (data []byte, err error) {
+	offset, count := p.offsets[i], p.counts[i]
+	if offset == 0 || count == 0 {
+		return
+	}
+	// the compressed data is at most 3/2 of the pixels (LZW), and the
+	// uncompressed data is the pixels
+	rows := p.chunkRows(i)
+	size := p.chunkBytes(rows)
+	if max := uint64(size + size/2 + 1024); count > max || p.compression == cNone && count > uint64(size) {
+		err = formatError("bad tile or strip byte count")
+		return
+	}
+	data = make([]byte, count)
+	if _, err = p.r.ReadAt(data, int64(offset)); err != nil {
+		if err == io.EOF {
+			err = formatError("short tile or strip data")
+		}
+		return
+	}
+	if data, err = decompress(data, p.compression, size); err != nil {
+		return
+	}
+
+	if len(data) < p.chunkBytes(rows) {
+		err = formatError("not enough pixel data")
+		return
+	}
+	if p.predictor == prNone {
+		return
+	}
+
+	stride := p.spp
+	if p.planar {
+		stride = 1
+	}
+	var tmp []byte
+	if p.predictor == prFloatingPoint {
+		tmp = make([]byte, p.rowBytes())
+	}
+	for y := 0; y < rows; y++ {
+		row := data[y*p.rowBytes() : (y+1)*p.rowBytes()]
+		if p.predictor == prHorizontal {
+			undoHorizontal(row, p.bpp, stride, p.order)
+		} else {
+			undoFloatingPoint(row, tmp, p.bpp, stride, p.order)
+		}
+	}
+	return
+}
+
+// storeChunk stores the pixels of the chunk at pt to dst, the pixels out
+// of dst are skipped.
+func (p *decoder) storeChunk(dst *pixels, data []byte, pt image.Point, plane int) {
+	r := image.Rect(pt.X, pt.Y, pt.X+p.chunkW, pt.Y+p.chunkH).Intersect(dst.Rect)
+	rowBytes := p.rowBytes()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := data[(y-pt.Y)*rowBytes:]
+		off := dst.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if p.planar {
+				p.storeSample(dst, off, plane, row, x-pt.X)
+			} else {
+				k := (x - pt.X) * p.spp
+				for c := 0; c < p.nc; c++ {
+					p.storeSample(dst, off, c, row, k+c)
+				}
+			}
+			off += dst.PixSize
+		}
+	}
+}
+
+// storeSample stores the sample k of the row to the channel c of the
+// pixel at off.
+func (p *decoder) storeSample(dst *pixels, off, c int, row []byte, k int) {
+	switch p.mode {
+	case mGray:
+		v := p.uintSample(row, k)
+		if p.bpp < 8 {
+			v = v * 0xff / (1<<uint(p.bpp) - 1)
+		}
+		if p.invert {
+			v = 0xff - v
+		}
+		dst.Pix[off] = uint8(v)
+	case mPaletted:
+		dst.Pix[off] = uint8(p.uintSample(row, k))
+	case mRGB, mRGBA, mNRGBA:
+		dst.Pix[off+c] = uint8(p.uintSample(row, k))
+	case mGray16, mRGB48, mRGBA64, mNRGBA64:
+		v := p.uintSample(row, k)
+		if p.invert {
+			v = 0xffff - v
+		}
+		dst.Pix[off+2*c+0] = uint8(v >> 8)
+		dst.Pix[off+2*c+1] = uint8(v)
+	default:
+		builtin.PutFloat32(dst.Pix[off+4*c:], p.floatSample(row, k))
+	}
+}
+
+// uintSample returns the sample k of the row, as an unsigned integer.
+func (p *decoder) uintSample(row []byte, k int) uint32 {
+	switch p.bpp {
+	case 8:
+		return uint32(row[k])
+	case 16:
+		return uint32(p.order.Uint16(row[2*k:]))
+	case 32:
+		return p.order.Uint32(row[4*k:])
+	}
+	bit := uint(k * p.bpp)
+	return uint32(row[bit/8]>>(8-bit%8-uint(p.bpp))) & (1<<uint(p.bpp) - 1)
+}
+
+// floatSample returns the sample k of the row, as a float.
+func (p *decoder) floatSample(row []byte, k int) float32 {
+	switch p.sampleFormat {
+	case sfFloat:
+		if p.bpp == 64 {
+			return float32(math.Float64frombits(p.order.Uint64(row[8*k:])))
+		}
+		return math.Float32frombits(p.order.Uint32(row[4*k:]))
+	case sfInt:
+		switch p.bpp {
+		case 8:
+			return float32(int8(row[k]))
+		case 16:
+			return float32(int16(p.order.Uint16(row[2*k:])))
+		default:
+			return float32(int32(p.order.Uint32(row[4*k:])))
+		}
+	}
+	return float32(p.uintSample(row, k))
+}
+
+// newImage returns a new image of the mode.
+func newImage(r image.Rectangle, mode imageMode, model color.Model) image.Image {
+	switch mode {
+	case mGray:
+		return image.NewGray(r)
+	case mPaletted:
+		return image.NewPaletted(r, model.(color.Palette))
+	case mGray16:
+		return image.NewGray16(r)
+	case mGray32f:
+		return image_ext.NewGray32f(r)
+	case mRGB:
+		return image_ext.NewRGB(r)
+	case mRGB48:
+		return image_ext.NewRGB48(r)
+	case mRGB96f:
+		return image_ext.NewRGB96f(r)
+	case mRGBA:
+		return image.NewRGBA(r)
+	case mNRGBA:
+		return image.NewNRGBA(r)
+	case mRGBA64:
+		return image.NewRGBA64(r)
+	case mNRGBA64:
+		return image.NewNRGBA64(r)
+	}
+	return image_ext.NewRGBA128f(r)
+}
+
+// premultiply converts the unassociated alpha to the associated alpha.
+func premultiply(m *image_ext.RGBA128f) {
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		off := m.PixOffset(m.Rect.Min.X, y)
+		for x := m.Rect.Min.X; x < m.Rect.Max.X; x++ {
+			a := builtin.Float32(m.Pix[off+12:]) / 0xffff
+			for c := 0; c < 3; c++ {
+				builtin.PutFloat32(m.Pix[off+4*c:], builtin.Float32(m.Pix[off+4*c:])*a)
+			}
+			off += 16
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+	_ "github.com/chai2010/gopkg/image/png"
+)
+
+const testdataDir = "../testdata/"
+
+func tLoad(t *testing.T, filename string) image.Image {
+	f, err := os.Open(testdataDir + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := Decode(f, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	return m
+}
+
+// tCompare returns the first pixel of a and b which are different.
+func tCompare(a, b image.Image) (pt image.Point, ok bool) {
+	if a.Bounds() != b.Bounds() {
+		return a.Bounds().Min, false
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r0, g0, b0, a0 := a.At(x, y).RGBA()
+			r1, g1, b1, a1 := b.At(x, y).RGBA()
+			if r0 != r1 || g0 != g1 || b0 != b1 || a0 != a1 {
+				return image.Pt(x, y), false
+			}
+		}
+	}
+	return image.Point{}, true
+}
+
+// TestDecode tests that the TIFF images of the different compressions and
+// layouts are decoded as the PNG images.
+func TestDecode(t *testing.T) {
+	for _, v := range []struct {
+		tiff, png string
+	}{
+		{"video-001.tiff", "video-001.png"},
+		{"video-001-uncompressed.tiff", "video-001.png"},
+		{"video-001-strip-64.tiff", "video-001.png"},
+		{"video-001-tile-64x64.tiff", "video-001.png"},
+		{"video-001-16bit.tiff", "video-001.png"},
+		{"video-001-gray.tiff", ""},
+		{"video-001-gray-16bit.tiff", ""},
+		{"video-001-paletted.tiff", ""},
+		{"bw-deflate.tiff", "bw-uncompressed.tiff"},
+		{"bw-packbits.tiff", "bw-uncompressed.tiff"},
+		{"blue-purple-pink.lzwcompressed.tiff", "blue-purple-pink.png"},
+		{"no_rps.tiff", ""},
+		{"no_compress.tiff", ""},
+	} {
+		m := tLoad(t, v.tiff)
+		if v.png == "" {
+			continue
+		}
+		var m0 image.Image
+		if v.png == "bw-uncompressed.tiff" {
+			m0 = tLoad(t, v.png)
+		} else {
+			var err error
+			if m0, _, err = image_ext.Load(testdataDir+v.png, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if v.tiff == "video-001-16bit.tiff" {
+			// the PNG image has 8 bits
+			m = convert.RGB(m)
+		}
+		if pt, ok := tCompare(m0, m); !ok {
+			t.Fatalf("%s: different pixel at %v", v.tiff, pt)
+		}
+	}
+}
+
+func TestDecodeType(t *testing.T) {
+	for _, v := range []struct {
+		filename string
+		image    image.Image
+	}{
+		{"video-001-gray.tiff", &image.Gray{}},
+		{"video-001-gray-16bit.tiff", &image.Gray16{}},
+		{"video-001-16bit.tiff", &image_ext.RGB48{}},
+		{"video-001-paletted.tiff", &image.Paletted{}},
+	} {
+		m := tLoad(t, v.filename)
+		if got, want := fmt.Sprintf("%T", m), fmt.Sprintf("%T", v.image); got != want {
+			t.Fatalf("%s: got %s, want %s", v.filename, got, want)
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001-tile-64x64.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, name, err := image_ext.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := tLoad(t, "video-001-tile-64x64.tiff")
+	if name != "tiff" || config.Width != m.Bounds().Dx() || config.Height != m.Bounds().Dy() {
+		t.Fatalf("bad config: %s, %v", name, config)
+	}
+
+	// the truncated data
+	if _, err := Decode(bytes.NewReader(data[:len(data)/2]), nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+// tEntry is an IFD entry of a single value.
+type tEntry struct {
+	tag   uint16
+	typ   dataType
+	value uint32
+}
+
+// tGrayTIFF returns a little endian TIFF of a gray image with one strip,
+// the strip data follows the IFD.
+func tGrayTIFF(width, height, compression, byteCount uint32, data []byte) []byte {
+	entries := []tEntry{
+		{tImageWidth, dtLong, width},
+		{tImageLength, dtLong, height},
+		{tBitsPerSample, dtShort, 8},
+		{tCompression, dtShort, compression},
+		{tPhotometricInterpretation, dtShort, pBlackIsZero},
+		{tStripOffsets, dtLong, 8 + 2 + 9*12 + 4},
+		{tSamplesPerPixel, dtShort, 1},
+		{tRowsPerStrip, dtLong, height},
+		{tStripByteCounts, dtLong, byteCount},
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []byte("II*\x00"))
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	binary.Write(&buf, binary.LittleEndian, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&buf, binary.LittleEndian, e.tag)
+		binary.Write(&buf, binary.LittleEndian, e.typ)
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+		if e.typ == dtShort {
+			binary.Write(&buf, binary.LittleEndian, [2]uint16{uint16(e.value)})
+		} else {
+			binary.Write(&buf, binary.LittleEndian, e.value)
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// TestDecodeTooLarge tests that the small IFDs of a huge image or of a
+// huge strip are rejected before the data is allocated.
+func TestDecodeTooLarge(t *testing.T) {
+	for i, data := range [][]byte{
+		tGrayTIFF(1<<30, 1<<30, cNone, 0, nil),
+		tGrayTIFF(64, 64, cPackBits, 1<<31, []byte{0x81, 0}),
+		tGrayTIFF(64, 64, cNone, 64*64, make([]byte, 100)), // short strip
+	} {
+		_, err := Decode(bytes.NewReader(data), nil)
+		if _, ok := err.(FormatError); !ok {
+			t.Fatalf("%d: expect a FormatError, got %v", i, err)
+		}
+	}
+
+	// the PackBits data is not decoded past the strip
+	data := tGrayTIFF(64, 64, cPackBits, 64, bytes.Repeat([]byte{0x81, 7}, 32))
+	m, err := Decode(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := m.(*image.Gray).GrayAt(63, 63); c.Y != 7 {
+		t.Fatalf("bad pixel: %v", c)
+	}
+}
+
+// TestDecodeRegion tests that the regions are decoded from the chunks as
+// they are taken from the whole image.
+func TestDecodeRegion(t *testing.T) {
+	for _, filename := range []string{
+		"video-001-tile-64x64.tiff",
+		"video-001-strip-64.tiff",
+		"video-001-16bit.tiff",
+		"video-001-paletted.tiff",
+	} {
+		m := tLoad(t, filename)
+		for _, region := range []*image_ext.Region{
+			{Rect: image.Rect(70, 10, 130, 75)},
+			{Rect: image.Rect(33, 65, 1000, 1000), Scale: 4},
+			{Scale: 2},
+		} {
+			data, err := ioutil.ReadFile(testdataDir + filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m0, err := region.Apply(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m1, err := Decode(bytes.NewReader(data), &Options{Region: region})
+			if err != nil {
+				t.Fatalf("%s: %v", filename, err)
+			}
+			if !tEqual(m0, m1) {
+				t.Fatalf("%s, %v: different image", filename, region)
+			}
+		}
+	}
+	data, err := ioutil.ReadFile(testdataDir + "video-001.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(bytes.NewReader(data), &Options{Region: &image_ext.Region{Scale: 3}}); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestLZW(t *testing.T) {
+	src := make([]byte, 100<<10)
+	for i := range src {
+		src[i] = byte(i*i>>7) ^ byte(i>>9)
+	}
+	dst, err := lzwDecode(lzwEncode(src), len(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, dst) {
+		t.Fatalf("bad LZW data")
+	}
+}
+
+func TestPackBits(t *testing.T) {
+	src := []byte{1, 1, 1, 1, 2, 3, 4, 4, 5, 5, 5, 6}
+	src = append(src, make([]byte, 300)...)
+	dst, err := unpackBits(packBits(nil, src), len(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, dst) {
+		t.Fatalf("bad PackBits data: %v", dst)
+	}
+}
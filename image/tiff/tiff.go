@@ -4,44 +4,118 @@
 
 // Package tiff implements a TIFF image decoder and encoder.
 //
+// The decoder reads the strips and the tiles, the chunky and the planar
+// configurations, the uncompressed, LZW, Deflate and PackBits data, the
+// horizontal and floating point predictors, and the BigTIFF files. The
+// images are decoded as:
+//
+//	Gray of 1/2/4/8 bits  -> *image.Gray
+//	Palette               -> *image.Paletted
+//	Gray of 16 bits       -> *image.Gray16
+//	Gray of int/float     -> *image_ext.Gray32f
+//	RGB of 8/16 bits      -> *image_ext.RGB/*image_ext.RGB48
+//	RGB of int/float      -> *image_ext.RGB96f
+//	RGBA of 8/16 bits     -> *image.RGBA/*image.RGBA64 (associated alpha)
+//	                      -> *image.NRGBA/*image.NRGBA64 (unassociated alpha)
+//	RGBA of int/float     -> *image_ext.RGBA128f
+//
+// The encoder writes the little-endian files of the same types, in strips
+// or tiles.
+//
+// The GeoTIFF tags (and the GDAL_NODATA and GDAL_METADATA tags) are read by
+// DecodeGeo and written by EncodeGeo, as a GeoInfo.
+//
 // The TIFF specification is at http://partners.adobe.com/public/developer/en/tiff/TIFF6.pdf
 package tiff
 
-// BUG(chai2010): support Gray32f/RGB/RGB48/RGB96f/RGBA128f.
-
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"io"
 
-	"code.google.com/p/go.image/tiff"
 	image_ext "github.com/chai2010/gopkg/image"
 	"github.com/chai2010/gopkg/image/convert"
 )
 
-const (
-	leHeader = "II\x2A\x00" // Header for little-endian files.
-	beHeader = "MM\x00\x2A" // Header for big-endian files.
-)
-
 // Options are the encoding and decoding parameters.
 type Options struct {
-	*tiff.Options
-	ColorModel color.Model
+	Compression CompressionType
+	Predictor   bool        // horizontal or floating point predictor, for the compressed data
+	TileSize    image.Point // tile size (multiples of 16), zero for the strips
+	BigTIFF     bool
+	ColorModel  color.Model
+	Region      *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a TIFF image without
 // decoding the entire image.
 func DecodeConfig(r io.Reader) (config image.Config, err error) {
-	return tiff.DecodeConfig(r)
+	rd, err := newReader(r)
+	if err != nil {
+		return
+	}
+	ds, err := rd.decoders(1)
+	if err != nil {
+		return
+	}
+	config = ds[0].config
+	return
 }
 
-// Decode reads a TIFF image from r and returns it as an image.Image.
-// The type of Image returned depends on the contents of the TIFF.
+// Decode reads the first image of a TIFF file from r and returns it as an
+// image.Image. The type of Image returned depends on the contents of the TIFF.
 func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
-	if m, err = tiff.Decode(r); err != nil {
+	rd, err := newReader(r)
+	if err != nil {
+		return
+	}
+	ds, err := rd.decoders(1)
+	if err != nil {
+		return
+	}
+	return decodeImage(ds[0], opt)
+}
+
+// DecodeAll reads all the images of a multi-page TIFF file from r.
+func DecodeAll(r io.Reader, opt *Options) (m []image.Image, err error) {
+	rd, err := newReader(r)
+	if err != nil {
+		return
+	}
+	ds, err := rd.decoders(-1)
+	if err != nil {
+		return
+	}
+	for _, d := range ds {
+		var mi image.Image
+		if mi, err = decodeImage(d, opt); err != nil {
+			return nil, err
+		}
+		m = append(m, mi)
+	}
+	return
+}
+
+// decodeImage decodes the image of d, only the chunks intersecting the
+// region are read.
+func decodeImage(d *decoder, opt *Options) (m image.Image, err error) {
+	var region *image_ext.Region
+	if opt != nil {
+		region = opt.Region
+	}
+	r, scale, err := region.Adjust(image.Rect(0, 0, d.config.Width, d.config.Height))
+	if err != nil {
+		return
+	}
+	if m, err = d.decode(r); err != nil {
 		return
 	}
+	if scale > 1 || r.Min != (image.Point{}) {
+		if m, err = image_ext.ScaleDown(m, scale); err != nil {
+			return
+		}
+	}
 	if opt != nil && opt.ColorModel != nil {
 		m = convert.ColorModel(m, opt.ColorModel)
 	}
@@ -52,14 +126,28 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 // encoding, such as the compression type. If opt is nil, an uncompressed
 // image is written.
 func Encode(w io.Writer, m image.Image, opt *Options) error {
-	if opt != nil && opt.ColorModel != nil {
-		m = convert.ColorModel(m, opt.ColorModel)
+	return EncodeAll(w, []image.Image{m}, opt)
+}
+
+// EncodeAll writes the images to w, as a multi-page TIFF file.
+func EncodeAll(w io.Writer, m []image.Image, opt *Options) (err error) {
+	if len(m) == 0 {
+		return fmt.Errorf("image/tiff: EncodeAll, no image")
 	}
-	if opt != nil && opt.Options != nil {
-		return tiff.Encode(w, m, opt.Options)
-	} else {
-		return tiff.Encode(w, m, nil)
+	wr, err := newWriter(w, opt != nil && opt.BigTIFF)
+	if err != nil {
+		return
 	}
+	for i := 0; i < len(m); i++ {
+		var e *encoder
+		if e, err = newEncoder(m[i], opt); err != nil {
+			return
+		}
+		if err = wr.writePage(e, i == len(m)-1); err != nil {
+			return
+		}
+	}
+	return
 }
 
 func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
@@ -82,7 +170,7 @@ func init() {
 	image_ext.RegisterFormat(image_ext.Format{
 		Name:         "tiff",
 		Extensions:   []string{".tiff", ".tif"},
-		Magics:       []string{leHeader, beHeader},
+		Magics:       []string{leHeader, beHeader, leBigHeader, beBigHeader},
 		DecodeConfig: DecodeConfig,
 		Decode:       imageExtDecode,
 		Encode:       imageExtEncode,
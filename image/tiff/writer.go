@@ -0,0 +1,382 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/chai2010/gopkg/builtin"
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+// stripSize is the max size of a strip, if it has more than one row.
+const stripSize = 64 << 10
+
+// pixels is the pixel buffer of an image.
+type pixels struct {
+	Pix     []byte
+	Stride  int
+	Rect    image.Rectangle
+	PixSize int
+}
+
+// newPixels returns the pixel buffer of m, the PixSize of RGB96f is 16
+// (not 12).
+func newPixels(m image.Image) pixels {
+	switch m := m.(type) {
+	case *image.Gray:
+		return pixels{m.Pix, m.Stride, m.Rect, 1}
+	case *image.Paletted:
+		return pixels{m.Pix, m.Stride, m.Rect, 1}
+	case *image.Gray16:
+		return pixels{m.Pix, m.Stride, m.Rect, 2}
+	case *image_ext.Gray32f:
+		return pixels{m.Pix, m.Stride, m.Rect, 4}
+	case *image_ext.RGB:
+		return pixels{m.Pix, m.Stride, m.Rect, 3}
+	case *image_ext.RGB48:
+		return pixels{m.Pix, m.Stride, m.Rect, 6}
+	case *image_ext.RGB96f:
+		return pixels{m.Pix, m.Stride, m.Rect, 16}
+	case *image.RGBA:
+		return pixels{m.Pix, m.Stride, m.Rect, 4}
+	case *image.NRGBA:
+		return pixels{m.Pix, m.Stride, m.Rect, 4}
+	case *image.RGBA64:
+		return pixels{m.Pix, m.Stride, m.Rect, 8}
+	case *image.NRGBA64:
+		return pixels{m.Pix, m.Stride, m.Rect, 8}
+	case *image_ext.RGBA128f:
+		return pixels{m.Pix, m.Stride, m.Rect, 16}
+	}
+	return pixels{}
+}
+
+func (p *pixels) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*p.PixSize
+}
+
+// encoder encodes an image to the chunks and the fields of an IFD.
+type encoder struct {
+	m   image.Image
+	pix pixels
+	big bool
+
+	photometric  int
+	bpp, spp     int
+	sampleFormat int
+	extraSamples int // -1 if none
+	predictor    int
+	compression  CompressionType
+
+	tiled          bool
+	chunkW, chunkH int
+
+	extra []field // the GeoTIFF fields
+}
+
+func newEncoder(m image.Image, opt *Options) (p *encoder, err error) {
+	p = &encoder{
+		photometric:  pBlackIsZero,
+		sampleFormat: sfUint,
+		extraSamples: -1,
+		predictor:    prNone,
+	}
+	if opt != nil {
+		if opt.ColorModel != nil {
+			m = convert.ColorModel(m, opt.ColorModel)
+		}
+		p.big = opt.BigTIFF
+		p.compression = opt.Compression
+	}
+
+	switch m.(type) {
+	case *image.Gray:
+		p.bpp, p.spp = 8, 1
+	case *image.Paletted:
+		if n := len(m.(*image.Paletted).Palette); n > 256 {
+			err = fmt.Errorf("image/tiff: Encode, bad palette length: %d", n)
+			return
+		}
+		p.bpp, p.spp = 8, 1
+		p.photometric = pPaletted
+	case *image.Gray16:
+		p.bpp, p.spp = 16, 1
+	case *image_ext.Gray32f:
+		p.bpp, p.spp = 32, 1
+		p.sampleFormat = sfFloat
+	case *image_ext.RGB:
+		p.bpp, p.spp = 8, 3
+		p.photometric = pRGB
+	case *image_ext.RGB48:
+		p.bpp, p.spp = 16, 3
+		p.photometric = pRGB
+	case *image_ext.RGB96f:
+		p.bpp, p.spp = 32, 3
+		p.photometric, p.sampleFormat = pRGB, sfFloat
+	case *image.RGBA:
+		p.bpp, p.spp = 8, 4
+		p.photometric, p.extraSamples = pRGB, esAssociated
+	case *image.NRGBA:
+		p.bpp, p.spp = 8, 4
+		p.photometric, p.extraSamples = pRGB, esUnassociated
+	case *image.RGBA64:
+		p.bpp, p.spp = 16, 4
+		p.photometric, p.extraSamples = pRGB, esAssociated
+	case *image.NRGBA64:
+		p.bpp, p.spp = 16, 4
+		p.photometric, p.extraSamples = pRGB, esUnassociated
+	case *image_ext.RGBA128f:
+		p.bpp, p.spp = 32, 4
+		p.photometric, p.sampleFormat, p.extraSamples = pRGB, sfFloat, esAssociated
+	default:
+		if isOpaque(m) {
+			m = convert.RGB(m)
+			p.bpp, p.spp = 8, 3
+			p.photometric = pRGB
+		} else {
+			m = convert.RGBA(m)
+			p.bpp, p.spp = 8, 4
+			p.photometric, p.extraSamples = pRGB, esAssociated
+		}
+	}
+	p.m, p.pix = m, newPixels(m)
+	if p.pix.Rect.Empty() {
+		err = fmt.Errorf("image/tiff: Encode, empty image")
+		return
+	}
+
+	if opt != nil && opt.Predictor && p.compression != Uncompressed && p.photometric != pPaletted {
+		if p.sampleFormat == sfFloat {
+			p.predictor = prFloatingPoint
+		} else {
+			p.predictor = prHorizontal
+		}
+	}
+
+	if opt != nil && opt.TileSize != (image.Point{}) {
+		if opt.TileSize.X <= 0 || opt.TileSize.Y <= 0 || opt.TileSize.X%16 != 0 || opt.TileSize.Y%16 != 0 {
+			err = fmt.Errorf("image/tiff: Encode, tile size must be multiples of 16: %v", opt.TileSize)
+			return
+		}
+		p.tiled = true
+		p.chunkW, p.chunkH = opt.TileSize.X, opt.TileSize.Y
+	} else {
+		p.chunkW = p.pix.Rect.Dx()
+		p.chunkH = stripSize / p.rowBytes()
+		if p.chunkH < 1 {
+			p.chunkH = 1
+		}
+		if p.chunkH > p.pix.Rect.Dy() {
+			p.chunkH = p.pix.Rect.Dy()
+		}
+	}
+	return
+}
+
+func isOpaque(m image.Image) bool {
+	if m, ok := m.(interface {
+		Opaque() bool
+	}); ok {
+		return m.Opaque()
+	}
+	return false
+}
+
+func (p *encoder) rowBytes() int {
+	return p.chunkW * p.spp * p.bpp / 8
+}
+
+// chunks returns the compressed tiles or strips.
+func (p *encoder) chunks() (chunks [][]byte, err error) {
+	r := p.pix.Rect
+	rowBytes := p.rowBytes()
+	var tmp []byte
+	if p.predictor == prFloatingPoint {
+		tmp = make([]byte, rowBytes)
+	}
+	for y := 0; y < r.Dy(); y += p.chunkH {
+		for x := 0; x < r.Dx(); x += p.chunkW {
+			rows := p.chunkH
+			if !p.tiled && y+rows > r.Dy() {
+				rows = r.Dy() - y
+			}
+			data := make([]byte, rowBytes*rows)
+			for j := 0; j < rows && y+j < r.Dy(); j++ {
+				row := data[j*rowBytes : (j+1)*rowBytes]
+				p.readRow(row, x, y+j)
+				switch p.predictor {
+				case prHorizontal:
+					doHorizontal(row, p.bpp, p.spp, binary.LittleEndian)
+				case prFloatingPoint:
+					doFloatingPoint(row, tmp, p.bpp, p.spp)
+				}
+			}
+			var chunk []byte
+			if chunk, err = compress(data, p.compression, rowBytes); err != nil {
+				return
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return
+}
+
+// readRow reads the little-endian samples of the row of the chunk at
+// (x, y), the pixels out of the image are zero.
+func (p *encoder) readRow(row []byte, x, y int) {
+	n := p.chunkW
+	if x+n > p.pix.Rect.Dx() {
+		n = p.pix.Rect.Dx() - x
+	}
+	off := p.pix.PixOffset(p.pix.Rect.Min.X+x, p.pix.Rect.Min.Y+y)
+	switch p.bpp {
+	case 8:
+		copy(row, p.pix.Pix[off:off+n*p.spp])
+		return
+	case 16:
+		for i := 0; i < n*p.spp; i++ {
+			row[2*i+0] = p.pix.Pix[off+2*i+1]
+			row[2*i+1] = p.pix.Pix[off+2*i+0]
+		}
+		return
+	}
+	// float samples, the pixels can be padded
+	for i := 0; i < n; i++ {
+		for c := 0; c < p.spp; c++ {
+			v := builtin.Float32(p.pix.Pix[off+4*c:])
+			binary.LittleEndian.PutUint32(row[4*(i*p.spp+c):], math.Float32bits(v))
+		}
+		off += p.pix.PixSize
+	}
+}
+
+// fields returns the fields of the image, with the offsets and the byte
+// counts of the chunks.
+func (p *encoder) fields(offsets, counts []uint64) []field {
+	r := p.pix.Rect
+	bps := make([]uint16, p.spp)
+	sf := make([]uint16, p.spp)
+	for i := range bps {
+		bps[i], sf[i] = uint16(p.bpp), uint16(p.sampleFormat)
+	}
+	fields := []field{
+		newField(tImageWidth, []uint32{uint32(r.Dx())}),
+		newField(tImageLength, []uint32{uint32(r.Dy())}),
+		newField(tBitsPerSample, bps),
+		newField(tCompression, []uint16{uint16(p.compression.specValue())}),
+		newField(tPhotometricInterpretation, []uint16{uint16(p.photometric)}),
+		newField(tSamplesPerPixel, []uint16{uint16(p.spp)}),
+		newField(tXResolution, [][2]uint32{{72, 1}}),
+		newField(tYResolution, [][2]uint32{{72, 1}}),
+		newField(tPlanarConfiguration, []uint16{pcChunky}),
+		newField(tResolutionUnit, []uint16{resPerInch}),
+		newField(tSampleFormat, sf),
+	}
+	if p.tiled {
+		fields = append(fields,
+			newField(tTileWidth, []uint32{uint32(p.chunkW)}),
+			newField(tTileLength, []uint32{uint32(p.chunkH)}),
+			offsetsField(tTileOffsets, offsets, p.big),
+			offsetsField(tTileByteCounts, counts, p.big),
+		)
+	} else {
+		fields = append(fields,
+			newField(tRowsPerStrip, []uint32{uint32(p.chunkH)}),
+			offsetsField(tStripOffsets, offsets, p.big),
+			offsetsField(tStripByteCounts, counts, p.big),
+		)
+	}
+	if p.predictor != prNone {
+		fields = append(fields, newField(tPredictor, []uint16{uint16(p.predictor)}))
+	}
+	if p.extraSamples >= 0 {
+		fields = append(fields, newField(tExtraSamples, []uint16{uint16(p.extraSamples)}))
+	}
+	if m, ok := p.m.(*image.Paletted); ok {
+		cmap := make([]uint16, 3*256)
+		for i, c := range m.Palette {
+			r, g, b, _ := c.RGBA()
+			cmap[i+0*256] = uint16(r)
+			cmap[i+1*256] = uint16(g)
+			cmap[i+2*256] = uint16(b)
+		}
+		fields = append(fields, newField(tColorMap, cmap))
+	}
+	return append(fields, p.extra...)
+}
+
+// writer writes the pages of a TIFF file.
+type writer struct {
+	w      io.Writer
+	big    bool
+	offset int64 // offset of the next IFD
+}
+
+func newWriter(w io.Writer, big bool) (p *writer, err error) {
+	p = &writer{w: w, big: big}
+	var hdr []byte
+	if big {
+		hdr = make([]byte, 16)
+		copy(hdr, leBigHeader)
+		binary.LittleEndian.PutUint16(hdr[4:], 8)
+		binary.LittleEndian.PutUint64(hdr[8:], 16)
+	} else {
+		hdr = make([]byte, 8)
+		copy(hdr, leHeader)
+		binary.LittleEndian.PutUint32(hdr[4:], 8)
+	}
+	p.offset = int64(len(hdr))
+	_, err = w.Write(hdr)
+	return
+}
+
+// writePage writes the IFD and the data of the encoder, last reports
+// whether it is the last page.
+func (p *writer) writePage(e *encoder, last bool) (err error) {
+	chunks, err := e.chunks()
+	if err != nil {
+		return
+	}
+	offsets := make([]uint64, len(chunks))
+	counts := make([]uint64, len(chunks))
+
+	size, valueSize := ifdSize(e.fields(offsets, counts), p.big)
+	end := p.offset + size + valueSize
+	for i, chunk := range chunks {
+		offsets[i], counts[i] = uint64(end), uint64(len(chunk))
+		end += int64(len(chunk))
+	}
+	pad := end % 2 // the IFD begins on a word boundary
+	end += pad
+	if !p.big && end > math.MaxUint32 {
+		return fmt.Errorf("image/tiff: Encode, the file is larger than 4GB, use BigTIFF")
+	}
+
+	next := end
+	if last {
+		next = 0
+	}
+	if err = writeIFD(p.w, e.fields(offsets, counts), p.offset, next, p.big); err != nil {
+		return
+	}
+	for _, chunk := range chunks {
+		if _, err = p.w.Write(chunk); err != nil {
+			return
+		}
+	}
+	if pad != 0 {
+		if _, err = p.w.Write([]byte{0}); err != nil {
+			return
+		}
+	}
+	p.offset = end
+	return
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// tNewImages returns the images of the types which are written without
+// the conversion.
+func tNewImages(r image.Rectangle) []image.Image {
+	ms := []image.Image{
+		image.NewGray(r),
+		image.NewGray16(r),
+		image_ext.NewGray32f(r),
+		image_ext.NewRGB(r),
+		image_ext.NewRGB48(r),
+		image_ext.NewRGB96f(r),
+		image.NewRGBA(r),
+		image.NewNRGBA(r),
+		image.NewRGBA64(r),
+		image.NewNRGBA64(r),
+		image_ext.NewRGBA128f(r),
+		image.NewPaletted(r, color.Palette{color.Black, color.White, color.RGBA{0xff, 0, 0, 0xff}}),
+	}
+	for _, m := range ms {
+		m := m.(interface {
+			Set(x, y int, c color.Color)
+		})
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				v := uint16(x*1237 + y*4133)
+				m.Set(x, y, color.RGBA64{v, v / 2, v / 3, 0xffff - v/5})
+			}
+		}
+	}
+	return ms
+}
+
+// tEqual reports whether the images are the same, the palette of the
+// decoded image has 256 colors.
+func tEqual(a, b image.Image) bool {
+	if a, ok := a.(*image.Paletted); ok {
+		b, ok := b.(*image.Paletted)
+		if !ok || !bytes.Equal(a.Pix, b.Pix) {
+			return false
+		}
+		_, ok = tCompare(a, b)
+		return ok
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	ms := tNewImages(image.Rect(0, 0, 37, 45))
+	for _, opt := range []*Options{
+		nil,
+		{Compression: Deflate},
+		{Compression: Deflate, Predictor: true},
+		{Compression: LZW, Predictor: true},
+		{Compression: PackBits},
+		{Compression: Deflate, Predictor: true, TileSize: image.Pt(16, 32)},
+		{Compression: LZW, TileSize: image.Pt(32, 16), BigTIFF: true},
+	} {
+		for _, m := range ms {
+			var buf bytes.Buffer
+			if err := Encode(&buf, m, opt); err != nil {
+				t.Fatalf("%T, %v: %v", m, opt, err)
+			}
+			m1, err := Decode(bytes.NewReader(buf.Bytes()), nil)
+			if err != nil {
+				t.Fatalf("%T, %v: %v", m, opt, err)
+			}
+			if fmt.Sprintf("%T", m) != fmt.Sprintf("%T", m1) {
+				t.Fatalf("%T, %v: bad type %T", m, opt, m1)
+			}
+			if !tEqual(m, m1) {
+				t.Fatalf("%T, %v: different image", m, opt)
+			}
+		}
+	}
+}
+
+// TestEncodeDem tests a float32 tiled DEM, with a sub image.
+func TestEncodeDem(t *testing.T) {
+	m := image_ext.NewGray32f(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			v := 1000 * math.Sin(float64(x)/31) * math.Cos(float64(y)/17)
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: float32(v)})
+		}
+	}
+	sub := m.SubImage(image.Rect(10, 20, 290, 190)).(*image_ext.Gray32f)
+
+	var buf bytes.Buffer
+	opt := &Options{Compression: Deflate, Predictor: true, TileSize: image.Pt(128, 128)}
+	if err := Encode(&buf, sub, opt); err != nil {
+		t.Fatal(err)
+	}
+	m1, err := Decode(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := m1.(*image_ext.Gray32f)
+	if g.Bounds() != image.Rect(0, 0, 280, 170) {
+		t.Fatalf("bad bounds: %v", g.Bounds())
+	}
+	for y := 0; y < 170; y++ {
+		for x := 0; x < 280; x++ {
+			if a, b := sub.Gray32fAt(x+10, y+20), g.Gray32fAt(x, y); a != b {
+				t.Fatalf("(%d, %d): expect = %v, got = %v", x, y, a, b)
+			}
+		}
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	ms := tNewImages(image.Rect(0, 0, 19, 7))
+	for _, big := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := EncodeAll(&buf, ms, &Options{Compression: Deflate, BigTIFF: big}); err != nil {
+			t.Fatal(err)
+		}
+		config, err := DecodeConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.ColorModel != color.GrayModel || config.Width != 19 || config.Height != 7 {
+			t.Fatalf("bad config: %v", config)
+		}
+		ms1, err := DecodeAll(bytes.NewReader(buf.Bytes()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ms1) != len(ms) {
+			t.Fatalf("BigTIFF = %v: bad image count: %d", big, len(ms1))
+		}
+		for i := range ms {
+			if !tEqual(ms[i], ms1[i]) {
+				t.Fatalf("BigTIFF = %v: %T: different image", big, ms[i])
+			}
+		}
+	}
+}
+
+func TestEncodeOther(t *testing.T) {
+	m := image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420)
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	m1, err := Decode(&buf, &Options{ColorModel: color.Gray16Model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m1.(*image.Gray16); !ok {
+		t.Fatalf("bad type: %T", m1)
+	}
+}
+
+func TestEncodeLargePalette(t *testing.T) {
+	palette := make(color.Palette, 257)
+	for i := range palette {
+		palette[i] = color.Gray16{uint16(i)}
+	}
+	m := image.NewPaletted(image.Rect(0, 0, 8, 8), palette)
+	if err := Encode(ioutil.Discard, m, nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
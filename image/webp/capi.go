@@ -6,6 +6,7 @@ package webp
 
 /*
 #cgo CFLAGS: -I./libwebp/include  -I./libwebp/src -DWEBP_EXPERIMENTAL_FEATURES
+#cgo LDFLAGS: -lm
 
 #include "webp.h"
 */
@@ -97,6 +98,32 @@ func webpDecodeRGBA(data []byte) (pix []byte, width, height int, err error) {
 	return
 }
 
+func webpDecodeRegion(
+	data []byte, has_alpha bool,
+	x, y, width, height, scaled_width, scaled_height int,
+) (pix []byte, err error) {
+	if len(data) == 0 || width <= 0 || height <= 0 || scaled_width <= 0 || scaled_height <= 0 {
+		err = errors.New("webpDecodeRegion: bad arguments")
+		return
+	}
+	channels, c_has_alpha := 3, C.int(0)
+	if has_alpha {
+		channels, c_has_alpha = 4, C.int(1)
+	}
+	pix = make([]byte, scaled_width*scaled_height*channels)
+	rv := C.webpDecodeRegion(
+		(*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), c_has_alpha,
+		C.int(x), C.int(y), C.int(width), C.int(height),
+		C.int(scaled_width), C.int(scaled_height),
+		(*C.uint8_t)(unsafe.Pointer(&pix[0])), C.int(scaled_width*channels),
+	)
+	if rv == 0 {
+		pix, err = nil, errors.New("webpDecodeRegion: failed")
+		return
+	}
+	return
+}
+
 func webpEncodeGray(
 	pix []byte, width, height, stride int,
 	quality_factor float32,
@@ -5,6 +5,7 @@
 #ifndef WEBP_H_
 #define WEBP_H_
 
+#include <stddef.h>
 #include <stdint.h>
 
 #ifdef __cplusplus
@@ -30,6 +31,13 @@ uint8_t* webpDecodeRGBA(
 	int* width, int* height
 );
 
+int webpDecodeRegion(
+	const uint8_t* data, size_t data_size, int has_alpha,
+	int x, int y, int width, int height,
+	int scaled_width, int scaled_height,
+	uint8_t* output, int stride
+);
+
 size_t webpEncodeGray(
 	const uint8_t* gray, int width, int height, int stride, float quality_factor,
 	uint8_t** output
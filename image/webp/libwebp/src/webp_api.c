@@ -85,6 +85,45 @@ uint8_t* webpDecodeRGBA(
 	return WebPDecodeRGBA(data, data_size, width, height);
 }
 
+int webpDecodeRegion(
+	const uint8_t* data, size_t data_size, int has_alpha,
+	int x, int y, int width, int height,
+	int scaled_width, int scaled_height,
+	uint8_t* output, int stride
+) {
+	WebPDecoderConfig config;
+
+	if(!WebPInitDecoderConfig(&config)) {
+		return 0;
+	}
+
+	// cropping is applied first, then scaling
+	config.options.use_cropping = 1;
+	config.options.crop_left = x;
+	config.options.crop_top = y;
+	config.options.crop_width = width;
+	config.options.crop_height = height;
+	if(scaled_width != width || scaled_height != height) {
+		config.options.use_scaling = 1;
+		config.options.scaled_width = scaled_width;
+		config.options.scaled_height = scaled_height;
+	}
+
+	// decode into the caller's buffer
+	config.output.colorspace = has_alpha? MODE_RGBA: MODE_RGB;
+	config.output.is_external_memory = 1;
+	config.output.u.RGBA.rgba = output;
+	config.output.u.RGBA.stride = stride;
+	config.output.u.RGBA.size = (size_t)stride*scaled_height;
+
+	if(WebPDecode(data, data_size, &config) != VP8_STATUS_OK) {
+		WebPFreeDecBuffer(&config.output);
+		return 0;
+	}
+	WebPFreeDecBuffer(&config.output);
+	return 1;
+}
+
 size_t webpEncodeGray(
 	const uint8_t* gray, int width, int height, int stride, float quality_factor,
 	uint8_t** output
@@ -17,11 +17,12 @@ import (
 
 const DefaulQuality = 90
 
-// Options are the encoding parameters.
+// Options are the encoding and decoding parameters.
 type Options struct {
 	ColorModel color.Model
 	Lossless   bool
-	Quality    float32 // 0 ~ 100
+	Quality    float32           // 0 ~ 100
+	Region     *image_ext.Region // the decoded region, nil for the whole image
 }
 
 // DecodeConfig returns the color model and dimensions of a WEBP image without
@@ -56,7 +57,14 @@ func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
 		return
 	}
 
-	if opt != nil && opt.ColorModel == color.GrayModel {
+	if opt != nil && opt.Region != nil {
+		// the region is decoded as RGB or RGBA, then converted
+		if hasAlpha || opt.ColorModel == color.RGBAModel {
+			m, err = DecodeRGBARegion(data, opt.Region)
+		} else {
+			m, err = DecodeRGBRegion(data, opt.Region)
+		}
+	} else if opt != nil && opt.ColorModel == color.GrayModel {
 		m, err = DecodeGray(data)
 	} else if opt != nil && opt.ColorModel == color_ext.RGBModel {
 		m, err = DecodeRGB(data)
@@ -5,7 +5,9 @@
 package webp
 
 import (
+	"bytes"
 	"image"
+	"io/ioutil"
 	"testing"
 
 	image_ext "github.com/chai2010/gopkg/image"
@@ -34,6 +36,52 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+// TestDecodeRegion tests that the regions of libwebp are near the regions
+// taken from the whole image.
+func TestDecodeRegion(t *testing.T) {
+	for _, filename := range []string{"video-001.webp", "tux.lossless.webp"} {
+		data, err := ioutil.ReadFile(testdataDir + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := Decode(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, region := range []*image_ext.Region{
+			{Rect: image.Rect(11, 20, 90, 77)},
+			{Rect: image.Rect(30, 15, 1000, 1000), Scale: 2},
+			{Scale: 4},
+		} {
+			m0, err := region.Apply(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := region.Rect; region.Scale > 1 && (r.Min.X&1 != 0 || r.Min.Y&1 != 0) {
+				// a scaled region of an odd origin starts from the even origin
+				even := &image_ext.Region{Rect: image.Rect(r.Min.X&^1, r.Min.Y&^1, r.Max.X, r.Max.Y), Scale: region.Scale}
+				if m0, err = even.Apply(m); err != nil {
+					t.Fatal(err)
+				}
+				b := region.Rect.Intersect(m.Bounds())
+				m0 = m0.(interface {
+					SubImage(r image.Rectangle) image.Image
+				}).SubImage(image.Rect(0, 0, (b.Dx()+region.Scale-1)/region.Scale, (b.Dy()+region.Scale-1)/region.Scale))
+			}
+			m1, err := Decode(bytes.NewReader(data), &Options{Region: region})
+			if err != nil {
+				t.Fatalf("%s, %v: %v", filename, region, err)
+			}
+			if m0.Bounds() != m1.Bounds() {
+				t.Fatalf("%s, %v: bad bounds %v", filename, region, m1.Bounds())
+			}
+			if got, want := averageDelta(m0, m1), int64(8<<8); got > want {
+				t.Fatalf("%s, %v: average delta too high; got %d, want <= %d", filename, region, got, want)
+			}
+		}
+	}
+}
+
 // averageDelta returns the average delta in RGB space. The two images must
 // have the same bounds.
 func averageDelta(m0, m1 image.Image) int64 {
@@ -45,6 +45,78 @@ func DecodeRGBA(data []byte) (m *image.RGBA, err error) {
 	return
 }
 
+// DecodeRGBRegion decodes the region of an image, with the cropping and the
+// scaling of libwebp. A scaled region of an odd origin starts from the even
+// origin before it.
+func DecodeRGBRegion(data []byte, region *image_ext.Region) (m *image_ext.RGB, err error) {
+	pix, r, err := decodeRegion(data, 3, region)
+	if err != nil {
+		return
+	}
+	m = &image_ext.RGB{Pix: pix, Stride: 3 * r.Dx(), Rect: r}
+	return
+}
+
+// DecodeRGBARegion decodes the region of an image, with the cropping and the
+// scaling of libwebp, like DecodeRGBRegion.
+func DecodeRGBARegion(data []byte, region *image_ext.Region) (m *image.RGBA, err error) {
+	pix, r, err := decodeRegion(data, 4, region)
+	if err != nil {
+		return
+	}
+	m = &image.RGBA{Pix: pix, Stride: 4 * r.Dx(), Rect: r}
+	return
+}
+
+func decodeRegion(data []byte, channels int, region *image_ext.Region) (pix []byte, r image.Rectangle, err error) {
+	w, h, _, err := webpGetInfo(data)
+	if err != nil {
+		return
+	}
+	src, scale, err := region.Adjust(image.Rect(0, 0, w, h))
+	if err != nil {
+		return
+	}
+	if src.Min.X&1 != 0 || src.Min.Y&1 != 0 {
+		// libwebp takes the chroma of an odd crop origin from the next
+		// pixel, so the crop starts from the even origin. A crop of full
+		// size is cut to the region, a scaled crop is cut to the size of
+		// the region: it is shifted by less than a scaled pixel.
+		even := image.Rect(src.Min.X&^1, src.Min.Y&^1, src.Max.X, src.Max.Y)
+		dr := image.Rect(0, 0, (even.Dx()+scale-1)/scale, (even.Dy()+scale-1)/scale)
+		if pix, err = webpDecodeRegion(data, channels == 4,
+			even.Min.X, even.Min.Y, even.Dx(), even.Dy(), dr.Dx(), dr.Dy(),
+		); err != nil {
+			return
+		}
+		var m image.Image
+		if channels == 4 {
+			m = &image.RGBA{Pix: pix, Stride: 4 * dr.Dx(), Rect: dr}
+		} else {
+			m = &image_ext.RGB{Pix: pix, Stride: 3 * dr.Dx(), Rect: dr}
+		}
+		crop := src.Sub(even.Min)
+		if scale > 1 {
+			crop = image.Rect(0, 0, (src.Dx()+scale-1)/scale, (src.Dy()+scale-1)/scale)
+		}
+		if m, err = (&image_ext.Region{Rect: crop}).Apply(m); err != nil {
+			return
+		}
+		switch m := m.(type) {
+		case *image.RGBA:
+			pix, r = m.Pix, m.Rect
+		case *image_ext.RGB:
+			pix, r = m.Pix, m.Rect
+		}
+		return
+	}
+	r = image.Rect(0, 0, (src.Dx()+scale-1)/scale, (src.Dy()+scale-1)/scale)
+	pix, err = webpDecodeRegion(data, channels == 4,
+		src.Min.X, src.Min.Y, src.Dx(), src.Dy(), r.Dx(), r.Dy(),
+	)
+	return
+}
+
 func EncodeGray(m *image.Gray, quality float32) (data []byte, err error) {
 	return webpEncodeGray(m.Pix, m.Rect.Dx(), m.Rect.Dy(), m.Stride, quality)
 }
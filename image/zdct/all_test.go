@@ -3,3 +3,200 @@
 // license that can be found in the LICENSE file.
 
 package zdct
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// TestDCT checks the integer DCT, its error is about 1/8192 of the range
+// for the 16-bit samples (the constants of idct have 11 bits).
+func TestDCT(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, max := range []int32{0xff, 0xffff} {
+		for n := 0; n < 100; n++ {
+			var b, src block
+			for i := range b {
+				b[i] = r.Int31n(max+1) - (max+1)/2
+			}
+			src = b
+			fdct(&b)
+			for i := range b {
+				b[i] = div(b[i], 8)
+			}
+			idct(&b)
+			for i := range b {
+				if d := math.Abs(float64(b[i] - src[i])); d > 2+float64(max)/4096 {
+					t.Fatalf("max = %d, %d: expect = %d, got = %d", max, i, src[i], b[i])
+				}
+			}
+		}
+	}
+}
+
+// tNewImage returns a smooth image with some noise, of the depth.
+func tNewImage(r image.Rectangle, channels, depth int) image.Image {
+	var m image.Image
+	switch {
+	case channels == 1 && depth == 8:
+		m = image.NewGray(r)
+	case channels == 1 && depth == 16:
+		m = image.NewGray16(r)
+	case channels == 3 && depth == 8:
+		m = image_ext.NewRGB(r)
+	default:
+		m = image_ext.NewRGB48(r)
+	}
+	p, _ := newPixels(m)
+	rnd := rand.New(rand.NewSource(1))
+	max := float64(int(1)<<uint(depth) - 1)
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			for c := 0; c < channels; c++ {
+				v := 0.5 + 0.4*math.Sin(float64(x+c*7)/9)*math.Cos(float64(y)/13) + rnd.Float64()*0.02
+				p.set(x, y, c, int32(v*max))
+			}
+		}
+	}
+	return m
+}
+
+// tMaxError returns the max sample error, relative to the max value.
+func tMaxError(t *testing.T, a, b image.Image) float64 {
+	pa, _ := newPixels(a)
+	pb, ok := newPixels(b)
+	if !ok || pa.Channels != pb.Channels || pa.Depth != pb.Depth || pa.Rect != pb.Rect {
+		t.Fatalf("bad image: %T, %v", b, b.Bounds())
+	}
+	var maxErr float64
+	for y := 0; y < pa.Rect.Dy(); y++ {
+		for x := 0; x < pa.Rect.Dx(); x++ {
+			for c := 0; c < pa.Channels; c++ {
+				if d := math.Abs(float64(pa.at(x, y, c) - pb.at(x, y, c))); d > maxErr {
+					maxErr = d
+				}
+			}
+		}
+	}
+	return maxErr / float64(int(1)<<uint(pa.Depth)-1)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, v := range []struct {
+		channels, depth int
+	}{
+		{1, 8}, {1, 16}, {3, 8}, {3, 16},
+	} {
+		m := tNewImage(image.Rect(0, 0, 37, 21), v.channels, v.depth)
+
+		var lastSize int
+		for _, q := range []struct {
+			quality  int
+			maxError float64
+		}{
+			{100, 0.01},
+			{90, 0.05},
+			{50, 0.15},
+		} {
+			var buf bytes.Buffer
+			if err := Encode(&buf, m, &Options{Quality: q.quality}); err != nil {
+				t.Fatal(err)
+			}
+			if lastSize != 0 && buf.Len() > lastSize {
+				t.Fatalf("%v, quality = %d: size %d > %d", v, q.quality, buf.Len(), lastSize)
+			}
+			lastSize = buf.Len()
+
+			m2, err := Decode(bytes.NewReader(buf.Bytes()), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e := tMaxError(t, m, m2); e > q.maxError {
+				t.Fatalf("%v, quality = %d: max error = %v", v, q.quality, e)
+			}
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	m := tNewImage(image.Rect(0, 0, 10, 9), 3, 16)
+	var buf bytes.Buffer
+	if err := image_ext.Encode("zdct", &buf, m, nil); err != nil {
+		t.Fatal(err)
+	}
+	config, name, err := image_ext.DecodeConfig(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "zdct" || config.ColorModel != color_ext.RGB48Model || config.Width != 10 || config.Height != 9 {
+		t.Fatalf("bad config: %s, %v", name, config)
+	}
+
+	// the truncated data
+	if _, err := Decode(bytes.NewReader(buf.Bytes()[:buf.Len()-8]), nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+
+	// the image too large to be allocated
+	hdr := zdctHeader{Version: zdctVersion, Channels: 3, Depth: 16, Quality: 90, Width: 1 << 24, Height: 1 << 24}
+	copy(hdr.Sig[:], zdctSig)
+	var big bytes.Buffer
+	binary.Write(&big, binary.LittleEndian, &hdr)
+	for i := 0; i < 2*blockSize; i++ {
+		binary.Write(&big, binary.LittleEndian, uint16(1))
+	}
+	if _, err := Decode(bytes.NewReader(big.Bytes()), nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	hdr := zdctHeader{Version: zdctVersion, Channels: 1, Depth: 8, Quality: 90, Width: 8, Height: 8}
+	copy(hdr.Sig[:], zdctSig)
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &hdr)
+	for i := 0; i < blockSize; i++ {
+		binary.Write(&buf, binary.LittleEndian, uint16(1))
+	}
+
+	// the DC value, and a run which overflows the zig-zag index
+	var data []byte
+	var tmp [binary.MaxVarintLen64]byte
+	data = append(data, tmp[:binary.PutVarint(tmp[:], 0)]...)
+	data = append(data, tmp[:binary.PutUvarint(tmp[:], math.MaxUint64-4)]...)
+	data = append(data, tmp[:binary.PutVarint(tmp[:], 1)]...)
+	zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	zw.Write(data)
+	zw.Close()
+
+	if _, err := Decode(bytes.NewReader(buf.Bytes()), nil); err == nil {
+		t.Fatalf("expect an error")
+	}
+}
+
+func TestEncodeOther(t *testing.T) {
+	m := image.NewRGBA64(image.Rect(0, 0, 4, 4))
+	for i := range m.Pix {
+		m.Pix[i] = 0x80
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, &Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := Decode(&buf, &Options{ColorModel: color.Gray16Model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := m2.(*image.Gray16).Gray16At(1, 1); c.Y != 0x8080 {
+		t.Fatalf("bad pixel: %v", c)
+	}
+}
@@ -2,5 +2,24 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package zdct implements a dct for 8bit/16bit image.
+// Package zdct implements a lossy DCT codec for the 8bit/16bit images.
+//
+// The Gray, Gray16, RGB and RGB48 images are coded as 8x8 DCT blocks
+// (the RGB images after the reversible color transform of JPEG 2000),
+// quantized by the JPEG tables scaled with the quality (and by 256 for
+// the 16-bit images), and compressed by deflate.
+//
+// ZDCT Image Structs (Little Endian):
+//
+//	type ZDCTImage struct {
+//		Sig      [4]byte        // 4Bytes, ZDCT
+//		Version  byte           // 1Bytes, 1
+//		Channels byte           // 1Bytes, 1=Gray, 3=RGB
+//		Depth    byte           // 1Bytes, 8/16 bits
+//		Quality  byte           // 1Bytes, 1-100
+//		Width    uint32         // 4Bytes, image Width
+//		Height   uint32         // 4Bytes, image Height
+//		Quant    [][64]uint16   // 128/256Bytes, Y (and CbCr) tables in zig-zag order
+//		Data     []byte         // ?Bytes, deflate of the quantized coefficients
+//	}
 package zdct
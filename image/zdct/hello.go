@@ -7,11 +7,30 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"log"
 
-	_ "common/zdct"
+	"github.com/chai2010/gopkg/image/zdct"
 )
 
 func main() {
-	fmt.Printf("TODO")
+	m := image.NewGray16(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			m.Pix[y*m.Stride+x*2] = uint8(x)
+			m.Pix[y*m.Stride+x*2+1] = uint8(y)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := zdct.Encode(&buf, m, &zdct.Options{Quality: 90}); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("size: %d -> %d\n", len(m.Pix), buf.Len())
+
+	if _, err := zdct.Decode(&buf, nil); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -3,3 +3,182 @@
 // license that can be found in the LICENSE file.
 
 package zdct
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+func DecodeConfig(r io.Reader) (config image.Config, err error) {
+	hdr, err := readHeader(r)
+	if err != nil {
+		return
+	}
+	config = image.Config{
+		ColorModel: hdr.colorModel(),
+		Width:      int(hdr.Width),
+		Height:     int(hdr.Height),
+	}
+	return
+}
+
+func Decode(r io.Reader, opt *Options) (m image.Image, err error) {
+	hdr, err := readHeader(r)
+	if err != nil {
+		return
+	}
+	var quant [nQuantIndex]quantTable
+	for i := 0; i < hdr.nQuant(); i++ {
+		if err = binary.Read(r, binary.LittleEndian, &quant[i]); err != nil {
+			return
+		}
+		for _, v := range quant[i] {
+			if v == 0 {
+				err = fmt.Errorf("image/zdct: Decode, bad quantization table")
+				return
+			}
+		}
+	}
+
+	m = newImage(image.Rect(0, 0, int(hdr.Width), int(hdr.Height)), &hdr)
+	p, _ := newPixels(m)
+
+	zr := flate.NewReader(r)
+	defer zr.Close()
+	d := &decoder{r: bufio.NewReader(zr)}
+
+	var b [3]block
+	var dc [3]int32
+	center := int32(1) << uint(p.Depth-1)
+	for by := 0; by < p.Rect.Dy(); by += 8 {
+		for bx := 0; bx < p.Rect.Dx(); bx += 8 {
+			for c := 0; c < p.Channels; c++ {
+				q := &quant[quantIndexLuminance]
+				if c > 0 {
+					q = &quant[quantIndexChrominance]
+				}
+				if dc[c], err = d.readBlock(&b[c], q, dc[c]); err != nil {
+					m = nil
+					return
+				}
+				idct(&b[c])
+			}
+			writeBlocks(&p, bx, by, center, &b)
+		}
+	}
+
+	if opt != nil && opt.Region != nil {
+		if m, err = opt.Region.Apply(m); err != nil {
+			return
+		}
+	}
+	if opt != nil && opt.ColorModel != nil {
+		m = convert.ColorModel(m, opt.ColorModel)
+	}
+	return
+}
+
+// writeBlocks writes the 8x8 blocks of the components at (bx, by), the
+// pixels out of the image are skipped.
+func writeBlocks(p *pixels, bx, by int, center int32, b *[3]block) {
+	for j := 0; j < 8 && by+j < p.Rect.Dy(); j++ {
+		for i := 0; i < 8 && bx+i < p.Rect.Dx(); i++ {
+			x, y := bx+i, by+j
+			if p.Channels == 1 {
+				p.set(x, y, 0, b[0][j*8+i]+center)
+				continue
+			}
+			r, g, bb := inverseRCT(b[0][j*8+i]+center, b[1][j*8+i], b[2][j*8+i])
+			p.set(x, y, 0, r)
+			p.set(x, y, 1, g)
+			p.set(x, y, 2, bb)
+		}
+	}
+}
+
+// decoder reads the quantized coefficients of the encoder.
+type decoder struct {
+	r *bufio.Reader
+}
+
+// readBlock reads and dequantizes a block, and returns its quantized DC
+// value.
+func (p *decoder) readBlock(b *block, q *quantTable, prevDC int32) (dc int32, err error) {
+	*b = block{}
+
+	v, err := binary.ReadVarint(p.r)
+	if err != nil {
+		err = badData(err)
+		return
+	}
+	dc = prevDC + int32(v)
+	b[0] = dc * int32(q[0])
+
+	for zig := 1; ; zig++ {
+		var run uint64
+		if run, err = binary.ReadUvarint(p.r); err != nil {
+			err = badData(err)
+			return
+		}
+		if run == eobRun {
+			return
+		}
+		if run >= uint64(blockSize-zig) {
+			err = fmt.Errorf("image/zdct: Decode, bad data")
+			return
+		}
+		zig += int(run)
+		if v, err = binary.ReadVarint(p.r); err != nil {
+			err = badData(err)
+			return
+		}
+		b[unzig[zig]] = int32(v) * int32(q[zig])
+	}
+}
+
+func badData(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("image/zdct: Decode, bad data: %v", err)
+}
+
+func imageDecode(r io.Reader) (image.Image, error) {
+	return Decode(r, nil)
+}
+
+func imageExtDecode(r io.Reader, opt interface{}) (image.Image, error) {
+	if opt, ok := opt.(*Options); ok {
+		return Decode(r, opt)
+	} else {
+		return Decode(r, nil)
+	}
+}
+
+func imageExtEncode(w io.Writer, m image.Image, opt interface{}) error {
+	if opt, ok := opt.(*Options); ok {
+		return Encode(w, m, opt)
+	} else {
+		return Encode(w, m, nil)
+	}
+}
+
+func init() {
+	image.RegisterFormat("zdct", zdctSig, imageDecode, DecodeConfig)
+
+	image_ext.RegisterFormat(image_ext.Format{
+		Name:         "zdct",
+		Extensions:   []string{".zdct"},
+		Magics:       []string{zdctSig},
+		DecodeConfig: DecodeConfig,
+		Decode:       imageExtDecode,
+		Encode:       imageExtEncode,
+	})
+}
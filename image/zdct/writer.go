@@ -3,3 +3,192 @@
 // license that can be found in the LICENSE file.
 
 package zdct
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/convert"
+)
+
+// DefaultQuality is the default quality encoding parameter.
+const DefaultQuality = 90
+
+// Options are the encoding and decoding parameters.
+type Options struct {
+	Quality    int // 1 ~ 100, 100 is near lossless
+	ColorModel color.Model
+	Region     *image_ext.Region // the decoded region, nil for the whole image
+}
+
+func Encode(w io.Writer, m image.Image, opt *Options) (err error) {
+	quality := DefaultQuality
+	if opt != nil {
+		if opt.ColorModel != nil {
+			m = convert.ColorModel(m, opt.ColorModel)
+		}
+		if opt.Quality > 0 {
+			quality = opt.Quality
+		}
+	}
+	if quality > 100 {
+		quality = 100
+	}
+	p, _ := newPixels(adjustImage(m))
+	if p.Rect.Empty() {
+		return fmt.Errorf("image/zdct: Encode, empty image")
+	}
+
+	hdr := zdctHeader{
+		Version:  zdctVersion,
+		Channels: byte(p.Channels),
+		Depth:    byte(p.Depth),
+		Quality:  byte(quality),
+		Width:    uint32(p.Rect.Dx()),
+		Height:   uint32(p.Rect.Dy()),
+	}
+	copy(hdr.Sig[:], zdctSig)
+	if err = binary.Write(w, binary.LittleEndian, &hdr); err != nil {
+		return
+	}
+
+	var quant [nQuantIndex]quantTable
+	for i := 0; i < hdr.nQuant(); i++ {
+		quant[i] = newQuantTable(quantIndex(i), quality, p.Depth)
+		if err = binary.Write(w, binary.LittleEndian, &quant[i]); err != nil {
+			return
+		}
+	}
+
+	zw, err := flate.NewWriter(w, flate.BestCompression)
+	if err != nil {
+		return
+	}
+	e := &encoder{w: bufio.NewWriter(zw)}
+
+	var b [3]block
+	var dc [3]int32
+	center := int32(1) << uint(p.Depth-1)
+	for by := 0; by < p.Rect.Dy(); by += 8 {
+		for bx := 0; bx < p.Rect.Dx(); bx += 8 {
+			readBlocks(&p, bx, by, center, &b)
+			for c := 0; c < p.Channels; c++ {
+				q := &quant[quantIndexLuminance]
+				if c > 0 {
+					q = &quant[quantIndexChrominance]
+				}
+				fdct(&b[c])
+				dc[c] = e.writeBlock(&b[c], q, dc[c])
+			}
+		}
+	}
+	if e.err != nil {
+		return e.err
+	}
+	if err = e.w.Flush(); err != nil {
+		return
+	}
+	return zw.Close()
+}
+
+// readBlocks reads the 8x8 blocks of the components at (bx, by), the
+// pixels out of the image are the edge pixels.
+func readBlocks(p *pixels, bx, by int, center int32, b *[3]block) {
+	maxX, maxY := p.Rect.Dx()-1, p.Rect.Dy()-1
+	for j := 0; j < 8; j++ {
+		y := by + j
+		if y > maxY {
+			y = maxY
+		}
+		for i := 0; i < 8; i++ {
+			x := bx + i
+			if x > maxX {
+				x = maxX
+			}
+			if p.Channels == 1 {
+				b[0][j*8+i] = p.at(x, y, 0) - center
+				continue
+			}
+			cy, cb, cr := forwardRCT(p.at(x, y, 0), p.at(x, y, 1), p.at(x, y, 2))
+			b[0][j*8+i] = cy - center
+			b[1][j*8+i] = cb
+			b[2][j*8+i] = cr
+		}
+	}
+}
+
+// encoder writes the quantized coefficients, in zig-zag order:
+//
+//	the DC difference to the previous block, a signed varint
+//	the runs of zeros and the AC values, an uvarint and a signed varint
+//	the end of block, the run 63
+//
+// The stream is compressed by deflate, as the entropy-coding stage.
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+const eobRun = 63
+
+func (p *encoder) writeUvarint(v uint64) {
+	if p.err == nil {
+		_, p.err = p.w.Write(p.buf[:binary.PutUvarint(p.buf[:], v)])
+	}
+}
+
+func (p *encoder) writeVarint(v int64) {
+	if p.err == nil {
+		_, p.err = p.w.Write(p.buf[:binary.PutVarint(p.buf[:], v)])
+	}
+}
+
+// writeBlock writes the DCT block b (which is scaled up by 8), and
+// returns its quantized DC value.
+func (p *encoder) writeBlock(b *block, q *quantTable, prevDC int32) int32 {
+	dc := div(b[0], 8*int32(q[0]))
+	p.writeVarint(int64(dc - prevDC))
+
+	run := 0
+	for zig := 1; zig < blockSize; zig++ {
+		ac := div(b[unzig[zig]], 8*int32(q[zig]))
+		if ac == 0 {
+			run++
+			continue
+		}
+		p.writeUvarint(uint64(run))
+		p.writeVarint(int64(ac))
+		run = 0
+	}
+	p.writeUvarint(eobRun)
+	return dc
+}
+
+// div returns a/b, rounded to the nearest integer, where b is positive.
+func div(a, b int32) int32 {
+	if a >= 0 {
+		return (a + b>>1) / b
+	}
+	return -((-a + b>>1) / b)
+}
+
+// adjustImage converts m to a Gray, Gray16, RGB or RGB48 image, the
+// images of more than 8 bits are converted to the 16-bit images.
+func adjustImage(m image.Image) image.Image {
+	switch m.(type) {
+	case *image.Gray, *image.Gray16, *image_ext.RGB, *image_ext.RGB48:
+		return m
+	case *image_ext.Gray32f:
+		return convert.Gray16(m)
+	case *image.RGBA64, *image.NRGBA64, *image_ext.RGB96f, *image_ext.RGBA128f:
+		return convert.RGB48(m)
+	}
+	return convert.RGB(m)
+}
@@ -0,0 +1,202 @@
+// Copyright 2013 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zdct
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+const (
+	zdctHeaderSize = 16
+	zdctSig        = "ZDCT"
+	zdctVersion    = 1
+
+	// maxPixelsSize is the maximum size of the pixels of a decoded image.
+	maxPixelsSize = 1 << 30
+)
+
+// ZDCT Image Spec (Little Endian), 16Bytes.
+type zdctHeader struct {
+	Sig      [4]byte // 4Bytes, ZDCT
+	Version  byte    // 1Bytes, 1
+	Channels byte    // 1Bytes, 1=Gray, 3=RGB
+	Depth    byte    // 1Bytes, 8/16 bits
+	Quality  byte    // 1Bytes, 1-100
+	Width    uint32  // 4Bytes, image Width
+	Height   uint32  // 4Bytes, image Height
+}
+
+func (p *zdctHeader) check() error {
+	if string(p.Sig[:]) != zdctSig || p.Version != zdctVersion {
+		return fmt.Errorf("image/zdct: bad header, sig = %q, version = %d", p.Sig[:], p.Version)
+	}
+	if p.Channels != 1 && p.Channels != 3 {
+		return fmt.Errorf("image/zdct: bad header, channels = %d", p.Channels)
+	}
+	if p.Depth != 8 && p.Depth != 16 {
+		return fmt.Errorf("image/zdct: bad header, depth = %d", p.Depth)
+	}
+	if p.Width == 0 || p.Height == 0 || p.Width > 1<<24 || p.Height > 1<<24 {
+		return fmt.Errorf("image/zdct: bad header, size = %dx%d", p.Width, p.Height)
+	}
+	if uint64(p.Width)*uint64(p.Height)*uint64(p.Channels)*uint64(p.Depth/8) > maxPixelsSize {
+		return fmt.Errorf("image/zdct: image too large, size = %dx%d", p.Width, p.Height)
+	}
+	return nil
+}
+
+// nQuant returns the number of the quantization tables.
+func (p *zdctHeader) nQuant() int {
+	if p.Channels == 3 {
+		return 2
+	}
+	return 1
+}
+
+func (p *zdctHeader) colorModel() color.Model {
+	switch {
+	case p.Channels == 1 && p.Depth == 8:
+		return color.GrayModel
+	case p.Channels == 1 && p.Depth == 16:
+		return color.Gray16Model
+	case p.Channels == 3 && p.Depth == 8:
+		return color_ext.RGBModel
+	}
+	return color_ext.RGB48Model
+}
+
+func readHeader(r io.Reader) (hdr zdctHeader, err error) {
+	if err = binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return
+	}
+	err = hdr.check()
+	return
+}
+
+// quantTable is a quantization table in zig-zag order.
+type quantTable [blockSize]uint16
+
+// newQuantTable returns the table of the unscaled table i, scaled with
+// the quality like the JPEG tables. The 16-bit tables are 256 times of
+// the 8-bit tables, except for the quality 100, which has the step 1.
+func newQuantTable(i quantIndex, quality, depth int) (q quantTable) {
+	if quality < 1 {
+		quality = 1
+	} else if quality > 100 {
+		quality = 100
+	}
+	var scale int
+	if quality < 50 {
+		scale = 5000 / quality
+	} else {
+		scale = 200 - quality*2
+	}
+	mul := 1
+	if depth == 16 {
+		mul = 256
+	}
+	for j := range q {
+		x := (int(unscaledQuant[i][j])*scale*mul + 50) / 100
+		if x < 1 {
+			x = 1
+		} else if x > 0xffff {
+			x = 0xffff
+		}
+		q[j] = uint16(x)
+	}
+	return
+}
+
+// pixels is the pixel layout of the Gray, Gray16, RGB and RGB48 images,
+// the 16-bit samples are big endian.
+type pixels struct {
+	Pix      []byte
+	Stride   int
+	Rect     image.Rectangle
+	Channels int
+	Depth    int
+}
+
+func newPixels(m image.Image) (p pixels, ok bool) {
+	switch m := m.(type) {
+	case *image.Gray:
+		p = pixels{m.Pix, m.Stride, m.Rect, 1, 8}
+	case *image.Gray16:
+		p = pixels{m.Pix, m.Stride, m.Rect, 1, 16}
+	case *image_ext.RGB:
+		p = pixels{m.Pix, m.Stride, m.Rect, 3, 8}
+	case *image_ext.RGB48:
+		p = pixels{m.Pix, m.Stride, m.Rect, 3, 16}
+	default:
+		return
+	}
+	ok = true
+	return
+}
+
+func newImage(r image.Rectangle, hdr *zdctHeader) image.Image {
+	switch {
+	case hdr.Channels == 1 && hdr.Depth == 8:
+		return image.NewGray(r)
+	case hdr.Channels == 1 && hdr.Depth == 16:
+		return image.NewGray16(r)
+	case hdr.Channels == 3 && hdr.Depth == 8:
+		return image_ext.NewRGB(r)
+	}
+	return image_ext.NewRGB48(r)
+}
+
+// at returns the sample c of the pixel (x, y), which is relative to
+// Rect.Min.
+func (p *pixels) at(x, y, c int) int32 {
+	if p.Depth == 8 {
+		return int32(p.Pix[y*p.Stride+x*p.Channels+c])
+	}
+	i := y*p.Stride + (x*p.Channels+c)*2
+	return int32(p.Pix[i])<<8 | int32(p.Pix[i+1])
+}
+
+// set sets the sample c of the pixel (x, y), the value is clamped.
+func (p *pixels) set(x, y, c int, v int32) {
+	if v < 0 {
+		v = 0
+	}
+	if p.Depth == 8 {
+		if v > 0xff {
+			v = 0xff
+		}
+		p.Pix[y*p.Stride+x*p.Channels+c] = uint8(v)
+		return
+	}
+	if v > 0xffff {
+		v = 0xffff
+	}
+	i := y*p.Stride + (x*p.Channels+c)*2
+	p.Pix[i], p.Pix[i+1] = uint8(v>>8), uint8(v)
+}
+
+// The RGB images are coded as the reversible color transform of JPEG 2000:
+//
+//	Y  = (R + 2G + B) >> 2
+//	Cb = B - G
+//	Cr = R - G
+//
+// The Y (and gray) samples are level shifted by the center of the depth.
+
+func forwardRCT(r, g, b int32) (y, cb, cr int32) {
+	return (r + 2*g + b) >> 2, b - g, r - g
+}
+
+func inverseRCT(y, cb, cr int32) (r, g, b int32) {
+	g = y - (cb+cr)>>2
+	return cr + g, g, cb + g
+}
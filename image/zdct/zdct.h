@@ -1,18 +0,0 @@
-// Copyright 2013 <chaishushan{AT}gmail.com>. All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
-
-#ifndef ZDCT_H_
-#define ZDCT_H_
-
-#ifdef __cplusplus
-extern "C" {
-#endif
-
-// TODO
-
-#ifdef __cplusplus
-}
-#endif
-#endif // ZDCT_H_
-
@@ -0,0 +1,32 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package dxf reads and writes ASCII DXF drawings.
+
+The HEADER, TABLES (only the LAYER table), BLOCKS and ENTITIES sections
+are supported. Other sections and tables are skipped when reading.
+
+The LINE, LWPOLYLINE, POLYLINE, CIRCLE, ARC, TEXT and INSERT entities are
+decoded into typed values, other entities are kept as Unknown with their
+raw group codes, so that they survive a round trip.
+
+Example:
+
+	d, err := dxf.Load("survey.dxf")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range d.Entities {
+		switch e := e.(type) {
+		case *dxf.Line:
+			fmt.Println(e.Layer, e.Start, e.End)
+		case *dxf.Circle:
+			fmt.Println(e.Layer, e.Center, e.Radius)
+		}
+	}
+
+The DXF reference is at http://images.autodesk.com/adsk/files/acad_dxf0.pdf
+*/
+package dxf
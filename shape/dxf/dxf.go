@@ -0,0 +1,104 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dxf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Drawing is a DXF document.
+type Drawing struct {
+	Header   []HeaderVar
+	Layers   []Layer
+	Blocks   []*Block
+	Entities []Entity
+}
+
+// Tag is a DXF group: a group code and its value.
+type Tag struct {
+	Code  int
+	Value string
+}
+
+func (t Tag) Float() (v float64, err error) {
+	if v, err = strconv.ParseFloat(strings.TrimSpace(t.Value), 64); err != nil {
+		err = fmt.Errorf("shape/dxf: bad float value, code = %d, value = %q", t.Code, t.Value)
+	}
+	return
+}
+
+func (t Tag) Int() (v int, err error) {
+	if v, err = strconv.Atoi(strings.TrimSpace(t.Value)); err != nil {
+		err = fmt.Errorf("shape/dxf: bad int value, code = %d, value = %q", t.Code, t.Value)
+	}
+	return
+}
+
+func floatTag(code int, v float64) Tag {
+	return Tag{code, strconv.FormatFloat(v, 'f', -1, 64)}
+}
+
+func intTag(code int, v int) Tag {
+	return Tag{code, strconv.Itoa(v)}
+}
+
+// HeaderVar is a header variable, like $ACADVER or $EXTMIN.
+type HeaderVar struct {
+	Name string // with the leading '$'
+	Tags []Tag
+}
+
+// HeaderVar returns the named header variable.
+func (p *Drawing) HeaderVar(name string) (v HeaderVar, ok bool) {
+	for _, v := range p.Header {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return
+}
+
+// Layer is an entry of the LAYER table.
+type Layer struct {
+	Name     string
+	Flags    int
+	Color    int // negative if the layer is off
+	LineType string
+}
+
+// Layer returns the named layer.
+func (p *Drawing) Layer(name string) (layer Layer, ok bool) {
+	for _, v := range p.Layers {
+		if strings.EqualFold(v.Name, name) {
+			return v, true
+		}
+	}
+	return
+}
+
+// Block is a named group of entities, referenced by INSERT entities.
+type Block struct {
+	Name      string
+	Layer     string
+	Flags     int
+	BasePoint Point
+	Entities  []Entity
+}
+
+// Block returns the named block.
+func (p *Drawing) Block(name string) (block *Block, ok bool) {
+	for _, v := range p.Blocks {
+		if strings.EqualFold(v.Name, name) {
+			return v, true
+		}
+	}
+	return
+}
+
+type Point struct {
+	X, Y, Z float64
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dxf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	d, err := Load("testdata/simple.dxf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := d.HeaderVar("$ACADVER"); !ok || v.Tags[0].Value != "AC1009" {
+		t.Fatalf("bad $ACADVER: %v", v)
+	}
+	if v, ok := d.HeaderVar("$EXTMAX"); !ok || len(v.Tags) != 3 {
+		t.Fatalf("bad $EXTMAX: %v", v)
+	}
+
+	if len(d.Layers) != 3 {
+		t.Fatalf("bad layers: %v", d.Layers)
+	}
+	if layer, ok := d.Layer("contours"); !ok || layer.Color != -3 || layer.LineType != "DASHED" {
+		t.Fatalf("bad layer: %v", layer)
+	}
+
+	if b, ok := d.Block("TREE"); !ok || len(b.Entities) != 2 {
+		t.Fatalf("bad block: %v", b)
+	}
+
+	if len(d.Entities) != 9 {
+		t.Fatalf("bad entities number: %d", len(d.Entities))
+	}
+	if e, ok := d.Entities[0].(*Line); !ok || e.Handle != "2A" || e.Layer != "ROADS" || e.End != (Point{100, 80, 0}) {
+		t.Fatalf("bad LINE: %#v", d.Entities[0])
+	}
+	if e, ok := d.Entities[1].(*LwPolyline); !ok || !e.Closed() || e.Color != 5 ||
+		!reflect.DeepEqual(e.Vertices, []LwVertex{{10, 10, 0}, {20, 10, 0.5}, {20, 20, 0}, {10, 20, 0}}) {
+		t.Fatalf("bad LWPOLYLINE: %#v", d.Entities[1])
+	}
+	if e, ok := d.Entities[2].(*Polyline); !ok || e.Flags != 8 || len(e.Vertices) != 3 ||
+		e.Vertices[2].Point != (Point{5, 7.5, 50}) || e.Vertices[2].Flags != 32 {
+		t.Fatalf("bad POLYLINE: %#v", d.Entities[2])
+	}
+	if e, ok := d.Entities[3].(*Circle); !ok || e.Center != (Point{50, 40, 0}) || e.Radius != 12.5 {
+		t.Fatalf("bad CIRCLE: %#v", d.Entities[3])
+	}
+	if e, ok := d.Entities[4].(*Arc); !ok || e.LineType != "DASHED" || e.EndAngle != 90 {
+		t.Fatalf("bad ARC: %#v", d.Entities[4])
+	}
+	if e, ok := d.Entities[5].(*Text); !ok || e.Value != "Survey Point A" || e.Height != 2.5 || e.Rotation != 45 {
+		t.Fatalf("bad TEXT: %#v", d.Entities[5])
+	}
+	if e, ok := d.Entities[6].(*Insert); !ok || e.Block != "TREE" || e.Scale != (Point{1, 1, 1}) {
+		t.Fatalf("bad INSERT: %#v", d.Entities[6])
+	}
+	if e, ok := d.Entities[7].(*Insert); !ok || e.Scale != (Point{2, 2, 1}) || e.Rotation != 30 {
+		t.Fatalf("bad INSERT: %#v", d.Entities[7])
+	}
+	if e, ok := d.Entities[8].(*Unknown); !ok || e.Type() != "POINT" || len(e.Tags) != 3 {
+		t.Fatalf("bad POINT: %#v", d.Entities[8])
+	}
+}
+
+func TestEncode_roundTrip(t *testing.T) {
+	d0, err := Load("testdata/simple.dxf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, d0); err != nil {
+		t.Fatal(err)
+	}
+	d1, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d0, d1) {
+		t.Fatalf("round trip failed:\n%s", buf.String())
+	}
+
+	// the output is stable
+	var buf2 bytes.Buffer
+	if err := Encode(&buf2, d1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Fatal("unstable output")
+	}
+}
+
+func TestEncode_entitiesOnly(t *testing.T) {
+	d0 := &Drawing{
+		Entities: []Entity{
+			&Line{EntityBase{Layer: "A"}, Point{0, 0, 0}, Point{1, 1, 0}},
+			&Polyline{EntityBase{Layer: "B"}, 1, []Vertex{
+				{Point: Point{0, 0, 0}},
+				{Point: Point{1, 0, 0}, Bulge: 1},
+				{Point: Point{1, 1, 0}},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, d0); err != nil {
+		t.Fatal(err)
+	}
+	d1, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d0, d1) {
+		t.Fatalf("round trip failed: %#v", d1)
+	}
+}
+
+func TestDecode_errors(t *testing.T) {
+	for i, s := range []string{
+		"",
+		"  0\nSECTION\n  2\nENTITIES\n 10\n1.0\n",
+		"  0\nSECTION\n  2\nENTITIES\n  0\nLINE\n 10\nabc\n  0\nENDSEC\n  0\nEOF\n",
+		"abc\nSECTION\n",
+	} {
+		if _, err := Decode(strings.NewReader(s)); err == nil {
+			t.Fatalf("%d: expect error", i)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dxf
+
+// Entity is the interface implemented by all drawing entities.
+type Entity interface {
+	Type() string // LINE, CIRCLE, ...
+	Base() *EntityBase
+	decode(t Tag) error
+	encode() []Tag
+}
+
+// EntityBase holds the common entity properties.
+type EntityBase struct {
+	Handle   string
+	Layer    string
+	LineType string
+	Color    int // 0: BYBLOCK, 256: BYLAYER
+}
+
+func (p *EntityBase) Base() *EntityBase {
+	return p
+}
+
+// decodeBase decodes a common tag, and reports whether t was consumed.
+func (p *EntityBase) decodeBase(t Tag) (ok bool, err error) {
+	switch t.Code {
+	case 5:
+		p.Handle = t.Value
+	case 6:
+		p.LineType = t.Value
+	case 8:
+		p.Layer = t.Value
+	case 62:
+		p.Color, err = t.Int()
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func (p *EntityBase) encodeBase() (tags []Tag) {
+	if p.Handle != "" {
+		tags = append(tags, Tag{5, p.Handle})
+	}
+	layer := p.Layer
+	if layer == "" {
+		layer = "0"
+	}
+	tags = append(tags, Tag{8, layer})
+	if p.LineType != "" {
+		tags = append(tags, Tag{6, p.LineType})
+	}
+	if p.Color != 0 {
+		tags = append(tags, intTag(62, p.Color))
+	}
+	return
+}
+
+// decodePoint decodes a coordinate tag of the point with the base code
+// (10 for 10/20/30, 11 for 11/21/31, ...), and reports whether t was consumed.
+func decodePoint(pt *Point, base int, t Tag) (ok bool, err error) {
+	switch t.Code {
+	case base:
+		pt.X, err = t.Float()
+	case base + 10:
+		pt.Y, err = t.Float()
+	case base + 20:
+		pt.Z, err = t.Float()
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func encodePoint(base int, pt Point) []Tag {
+	return []Tag{
+		floatTag(base, pt.X),
+		floatTag(base+10, pt.Y),
+		floatTag(base+20, pt.Z),
+	}
+}
+
+// Line is a LINE entity.
+type Line struct {
+	EntityBase
+	Start, End Point
+}
+
+func (p *Line) Type() string { return "LINE" }
+
+func (p *Line) decode(t Tag) (err error) {
+	if ok, err := decodePoint(&p.Start, 10, t); ok {
+		return err
+	}
+	if ok, err := decodePoint(&p.End, 11, t); ok {
+		return err
+	}
+	return
+}
+
+func (p *Line) encode() (tags []Tag) {
+	tags = append(tags, encodePoint(10, p.Start)...)
+	tags = append(tags, encodePoint(11, p.End)...)
+	return
+}
+
+// Circle is a CIRCLE entity.
+type Circle struct {
+	EntityBase
+	Center Point
+	Radius float64
+}
+
+func (p *Circle) Type() string { return "CIRCLE" }
+
+func (p *Circle) decode(t Tag) (err error) {
+	if ok, err := decodePoint(&p.Center, 10, t); ok {
+		return err
+	}
+	if t.Code == 40 {
+		p.Radius, err = t.Float()
+	}
+	return
+}
+
+func (p *Circle) encode() (tags []Tag) {
+	tags = append(tags, encodePoint(10, p.Center)...)
+	tags = append(tags, floatTag(40, p.Radius))
+	return
+}
+
+// Arc is an ARC entity. The angles are in degrees, counterclockwise.
+type Arc struct {
+	EntityBase
+	Center     Point
+	Radius     float64
+	StartAngle float64
+	EndAngle   float64
+}
+
+func (p *Arc) Type() string { return "ARC" }
+
+func (p *Arc) decode(t Tag) (err error) {
+	if ok, err := decodePoint(&p.Center, 10, t); ok {
+		return err
+	}
+	switch t.Code {
+	case 40:
+		p.Radius, err = t.Float()
+	case 50:
+		p.StartAngle, err = t.Float()
+	case 51:
+		p.EndAngle, err = t.Float()
+	}
+	return
+}
+
+func (p *Arc) encode() (tags []Tag) {
+	tags = append(tags, encodePoint(10, p.Center)...)
+	tags = append(tags,
+		floatTag(40, p.Radius),
+		floatTag(50, p.StartAngle),
+		floatTag(51, p.EndAngle),
+	)
+	return
+}
+
+// Text is a TEXT entity.
+type Text struct {
+	EntityBase
+	Position Point
+	Height   float64
+	Value    string
+	Rotation float64 // degrees
+	Style    string
+}
+
+func (p *Text) Type() string { return "TEXT" }
+
+func (p *Text) decode(t Tag) (err error) {
+	if ok, err := decodePoint(&p.Position, 10, t); ok {
+		return err
+	}
+	switch t.Code {
+	case 1:
+		p.Value = t.Value
+	case 7:
+		p.Style = t.Value
+	case 40:
+		p.Height, err = t.Float()
+	case 50:
+		p.Rotation, err = t.Float()
+	}
+	return
+}
+
+func (p *Text) encode() (tags []Tag) {
+	tags = append(tags, encodePoint(10, p.Position)...)
+	tags = append(tags, floatTag(40, p.Height), Tag{1, p.Value})
+	if p.Rotation != 0 {
+		tags = append(tags, floatTag(50, p.Rotation))
+	}
+	if p.Style != "" {
+		tags = append(tags, Tag{7, p.Style})
+	}
+	return
+}
+
+// Insert is an INSERT entity, a reference to a block.
+type Insert struct {
+	EntityBase
+	Block    string
+	Position Point
+	Scale    Point   // 1 if not present
+	Rotation float64 // degrees
+}
+
+func (p *Insert) Type() string { return "INSERT" }
+
+func (p *Insert) decode(t Tag) (err error) {
+	if ok, err := decodePoint(&p.Position, 10, t); ok {
+		return err
+	}
+	switch t.Code {
+	case 2:
+		p.Block = t.Value
+	case 41:
+		p.Scale.X, err = t.Float()
+	case 42:
+		p.Scale.Y, err = t.Float()
+	case 43:
+		p.Scale.Z, err = t.Float()
+	case 50:
+		p.Rotation, err = t.Float()
+	}
+	return
+}
+
+func (p *Insert) encode() (tags []Tag) {
+	tags = append(tags, Tag{2, p.Block})
+	tags = append(tags, encodePoint(10, p.Position)...)
+	tags = append(tags,
+		floatTag(41, p.Scale.X),
+		floatTag(42, p.Scale.Y),
+		floatTag(43, p.Scale.Z),
+	)
+	if p.Rotation != 0 {
+		tags = append(tags, floatTag(50, p.Rotation))
+	}
+	return
+}
+
+// LwVertex is a vertex of a LWPOLYLINE.
+type LwVertex struct {
+	X, Y  float64
+	Bulge float64
+}
+
+// LwPolyline is a LWPOLYLINE entity, a 2D polyline.
+type LwPolyline struct {
+	EntityBase
+	Flags     int // 1: closed
+	Elevation float64
+	Vertices  []LwVertex
+}
+
+func (p *LwPolyline) Type() string { return "LWPOLYLINE" }
+
+func (p *LwPolyline) Closed() bool { return p.Flags&1 != 0 }
+
+func (p *LwPolyline) decode(t Tag) (err error) {
+	var last *LwVertex
+	if n := len(p.Vertices); n > 0 {
+		last = &p.Vertices[n-1]
+	}
+	switch t.Code {
+	case 10:
+		var x float64
+		if x, err = t.Float(); err == nil {
+			p.Vertices = append(p.Vertices, LwVertex{X: x})
+		}
+	case 20:
+		if last != nil {
+			last.Y, err = t.Float()
+		}
+	case 42:
+		if last != nil {
+			last.Bulge, err = t.Float()
+		}
+	case 38:
+		p.Elevation, err = t.Float()
+	case 70:
+		p.Flags, err = t.Int()
+	}
+	return
+}
+
+func (p *LwPolyline) encode() (tags []Tag) {
+	tags = append(tags, intTag(90, len(p.Vertices)), intTag(70, p.Flags))
+	if p.Elevation != 0 {
+		tags = append(tags, floatTag(38, p.Elevation))
+	}
+	for _, v := range p.Vertices {
+		tags = append(tags, floatTag(10, v.X), floatTag(20, v.Y))
+		if v.Bulge != 0 {
+			tags = append(tags, floatTag(42, v.Bulge))
+		}
+	}
+	return
+}
+
+// Vertex is a vertex of a POLYLINE.
+type Vertex struct {
+	Point
+	Bulge float64
+	Flags int
+}
+
+// Polyline is a POLYLINE entity, a 2D or 3D polyline. The vertices are
+// stored as VERTEX entities after the POLYLINE, terminated by a SEQEND.
+type Polyline struct {
+	EntityBase
+	Flags    int // 1: closed, 8: 3D polyline
+	Vertices []Vertex
+}
+
+func (p *Polyline) Type() string { return "POLYLINE" }
+
+func (p *Polyline) Closed() bool { return p.Flags&1 != 0 }
+
+func (p *Polyline) decode(t Tag) (err error) {
+	if t.Code == 70 {
+		p.Flags, err = t.Int()
+	}
+	return
+}
+
+func (p *Polyline) decodeVertex(tags []Tag) (err error) {
+	var v Vertex
+	for _, t := range tags {
+		if ok, err := decodePoint(&v.Point, 10, t); ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		switch t.Code {
+		case 42:
+			v.Bulge, err = t.Float()
+		case 70:
+			v.Flags, err = t.Int()
+		}
+		if err != nil {
+			return
+		}
+	}
+	p.Vertices = append(p.Vertices, v)
+	return
+}
+
+func (p *Polyline) encode() (tags []Tag) {
+	tags = append(tags, intTag(66, 1))
+	tags = append(tags, encodePoint(10, Point{})...)
+	tags = append(tags, intTag(70, p.Flags))
+
+	layer := Tag{8, p.Layer}
+	if p.Layer == "" {
+		layer.Value = "0"
+	}
+	for _, v := range p.Vertices {
+		tags = append(tags, Tag{0, "VERTEX"}, layer)
+		tags = append(tags, encodePoint(10, v.Point)...)
+		if v.Bulge != 0 {
+			tags = append(tags, floatTag(42, v.Bulge))
+		}
+		if v.Flags != 0 {
+			tags = append(tags, intTag(70, v.Flags))
+		}
+	}
+	tags = append(tags, Tag{0, "SEQEND"}, layer)
+	return
+}
+
+// Unknown is an entity without a typed representation.
+// Tags holds the group codes other than the common properties.
+type Unknown struct {
+	EntityBase
+	Name string
+	Tags []Tag
+}
+
+func (p *Unknown) Type() string { return p.Name }
+
+func (p *Unknown) decode(t Tag) error {
+	p.Tags = append(p.Tags, t)
+	return nil
+}
+
+func (p *Unknown) encode() []Tag {
+	return p.Tags
+}
+
+func newEntity(name string) Entity {
+	switch name {
+	case "LINE":
+		return new(Line)
+	case "CIRCLE":
+		return new(Circle)
+	case "ARC":
+		return new(Arc)
+	case "TEXT":
+		return new(Text)
+	case "INSERT":
+		return &Insert{Scale: Point{1, 1, 1}}
+	case "LWPOLYLINE":
+		return new(LwPolyline)
+	case "POLYLINE":
+		return new(Polyline)
+	}
+	return &Unknown{Name: name}
+}
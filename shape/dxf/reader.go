@@ -0,0 +1,372 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dxf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Load reads a DXF drawing from the named file.
+func Load(filename string) (d *Drawing, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Decode reads an ASCII DXF drawing from r.
+func Decode(r io.Reader) (d *Drawing, err error) {
+	p := &decoder{r: newTagReader(r), d: new(Drawing)}
+	if err = p.decode(); err != nil {
+		return
+	}
+	d = p.d
+	return
+}
+
+// tagReader reads the code/value line pairs.
+type tagReader struct {
+	s      *bufio.Scanner
+	line   int
+	peeked *Tag
+}
+
+func newTagReader(r io.Reader) *tagReader {
+	return &tagReader{s: bufio.NewScanner(r)}
+}
+
+func (p *tagReader) readLine() (s string, err error) {
+	if !p.s.Scan() {
+		if err = p.s.Err(); err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	p.line++
+	s = strings.TrimRight(p.s.Text(), "\r")
+	return
+}
+
+func (p *tagReader) Next() (t Tag, err error) {
+	if p.peeked != nil {
+		t, p.peeked = *p.peeked, nil
+		return
+	}
+	s, err := p.readLine()
+	if err != nil {
+		return
+	}
+	if t.Code, err = strconv.Atoi(strings.TrimSpace(s)); err != nil {
+		err = fmt.Errorf("shape/dxf: bad group code %q, line = %d", s, p.line)
+		return
+	}
+	if t.Value, err = p.readLine(); err != nil {
+		return
+	}
+	if t.Code != 1 {
+		t.Value = strings.TrimSpace(t.Value)
+	}
+	return
+}
+
+func (p *tagReader) Peek() (t Tag, err error) {
+	if p.peeked == nil {
+		if t, err = p.Next(); err != nil {
+			return
+		}
+		p.peeked = &t
+	}
+	return *p.peeked, nil
+}
+
+// ReadGroup reads the tags up to the next 0 code.
+func (p *tagReader) ReadGroup() (tags []Tag, err error) {
+	for {
+		var t Tag
+		if t, err = p.Peek(); err != nil {
+			return
+		}
+		if t.Code == 0 {
+			return
+		}
+		p.Next()
+		tags = append(tags, t)
+	}
+}
+
+type decoder struct {
+	r *tagReader
+	d *Drawing
+}
+
+func (p *decoder) decode() (err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				if p.r.line == 0 {
+					err = fmt.Errorf("shape/dxf: empty file")
+				} else {
+					err = nil // missing EOF marker
+				}
+			}
+			return
+		}
+		switch {
+		case t.Code == 0 && t.Value == "EOF":
+			return
+		case t.Code == 0 && t.Value == "SECTION":
+			if err = p.decodeSection(); err != nil {
+				return
+			}
+		case t.Code == 999: // comment
+		default:
+			return fmt.Errorf("shape/dxf: unexpected tag (%d, %q), line = %d", t.Code, t.Value, p.r.line)
+		}
+	}
+}
+
+func (p *decoder) decodeSection() (err error) {
+	t, err := p.r.Next()
+	if err != nil {
+		return
+	}
+	if t.Code != 2 {
+		return fmt.Errorf("shape/dxf: bad section name, line = %d", p.r.line)
+	}
+	switch t.Value {
+	case "HEADER":
+		err = p.decodeHeader()
+	case "TABLES":
+		err = p.decodeTables()
+	case "BLOCKS":
+		err = p.decodeBlocks()
+	case "ENTITIES":
+		p.d.Entities, err = p.decodeEntities("ENDSEC")
+	default:
+		err = p.skipTo("ENDSEC")
+	}
+	return
+}
+
+// skipTo skips the tags up to and including (0, name).
+func (p *decoder) skipTo(name string) (err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		if t.Code == 0 && t.Value == name {
+			return
+		}
+	}
+}
+
+func (p *decoder) decodeHeader() (err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		switch t.Code {
+		case 0:
+			if t.Value != "ENDSEC" {
+				return fmt.Errorf("shape/dxf: bad HEADER section, line = %d", p.r.line)
+			}
+			return
+		case 9:
+			p.d.Header = append(p.d.Header, HeaderVar{Name: t.Value})
+		default:
+			if n := len(p.d.Header); n > 0 {
+				p.d.Header[n-1].Tags = append(p.d.Header[n-1].Tags, t)
+			}
+		}
+	}
+}
+
+func (p *decoder) decodeTables() (err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		if t.Code != 0 {
+			continue
+		}
+		switch t.Value {
+		case "ENDSEC":
+			return
+		case "TABLE":
+			var tags []Tag
+			if tags, err = p.r.ReadGroup(); err != nil {
+				return
+			}
+			if len(tags) > 0 && tags[0].Code == 2 && tags[0].Value == "LAYER" {
+				err = p.decodeLayerTable()
+			} else {
+				err = p.skipTo("ENDTAB")
+			}
+			if err != nil {
+				return
+			}
+		default:
+			return fmt.Errorf("shape/dxf: bad TABLES section, line = %d", p.r.line)
+		}
+	}
+}
+
+func (p *decoder) decodeLayerTable() (err error) {
+	for {
+		var t Tag
+		var tags []Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		if tags, err = p.r.ReadGroup(); err != nil {
+			return
+		}
+		switch t.Value {
+		case "ENDTAB":
+			return
+		case "LAYER":
+			var layer Layer
+			for _, t := range tags {
+				switch t.Code {
+				case 2:
+					layer.Name = t.Value
+				case 6:
+					layer.LineType = t.Value
+				case 62:
+					layer.Color, err = t.Int()
+				case 70:
+					layer.Flags, err = t.Int()
+				}
+				if err != nil {
+					return
+				}
+			}
+			p.d.Layers = append(p.d.Layers, layer)
+		}
+	}
+}
+
+func (p *decoder) decodeBlocks() (err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		switch {
+		case t.Code == 0 && t.Value == "ENDSEC":
+			return
+		case t.Code == 0 && t.Value == "BLOCK":
+			var tags []Tag
+			if tags, err = p.r.ReadGroup(); err != nil {
+				return
+			}
+			b := new(Block)
+			for _, t := range tags {
+				if ok, err := decodePoint(&b.BasePoint, 10, t); ok {
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				switch t.Code {
+				case 2:
+					b.Name = t.Value
+				case 8:
+					b.Layer = t.Value
+				case 70:
+					b.Flags, err = t.Int()
+				}
+				if err != nil {
+					return
+				}
+			}
+			if b.Entities, err = p.decodeEntities("ENDBLK"); err != nil {
+				return
+			}
+			if _, err = p.r.ReadGroup(); err != nil {
+				return
+			}
+			p.d.Blocks = append(p.d.Blocks, b)
+		default:
+			return fmt.Errorf("shape/dxf: bad BLOCKS section, line = %d", p.r.line)
+		}
+	}
+}
+
+// decodeEntities reads the entities up to and including (0, end).
+func (p *decoder) decodeEntities(end string) (entities []Entity, err error) {
+	for {
+		var t Tag
+		if t, err = p.r.Next(); err != nil {
+			return
+		}
+		if t.Code != 0 {
+			err = fmt.Errorf("shape/dxf: expect entity, got (%d, %q), line = %d", t.Code, t.Value, p.r.line)
+			return
+		}
+		if t.Value == end {
+			return
+		}
+
+		var e Entity
+		if e, err = p.decodeEntity(t.Value); err != nil {
+			return
+		}
+		entities = append(entities, e)
+	}
+}
+
+func (p *decoder) decodeEntity(name string) (e Entity, err error) {
+	tags, err := p.r.ReadGroup()
+	if err != nil {
+		return
+	}
+	e = newEntity(name)
+	for _, t := range tags {
+		var ok bool
+		if ok, err = e.Base().decodeBase(t); err != nil {
+			return
+		}
+		if !ok {
+			if err = e.decode(t); err != nil {
+				return
+			}
+		}
+	}
+
+	if pl, ok := e.(*Polyline); ok {
+		for {
+			var t Tag
+			if t, err = p.r.Next(); err != nil {
+				return
+			}
+			if tags, err = p.r.ReadGroup(); err != nil {
+				return
+			}
+			if t.Value == "SEQEND" {
+				break
+			}
+			if t.Value != "VERTEX" {
+				err = fmt.Errorf("shape/dxf: expect VERTEX, got %q, line = %d", t.Value, p.r.line)
+				return
+			}
+			if err = pl.decodeVertex(tags); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
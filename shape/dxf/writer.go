@@ -0,0 +1,115 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dxf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Save writes the drawing d to the named file.
+func Save(filename string, d *Drawing) (err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return Encode(f, d)
+}
+
+// Encode writes the drawing d to w in ASCII DXF format.
+func Encode(w io.Writer, d *Drawing) (err error) {
+	p := &encoder{w: bufio.NewWriter(w)}
+	p.encode(d)
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (p *encoder) writeTag(t Tag) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, "%3d\n%s\n", t.Code, t.Value)
+	}
+}
+
+func (p *encoder) writeTags(tags ...Tag) {
+	for _, t := range tags {
+		p.writeTag(t)
+	}
+}
+
+func (p *encoder) encode(d *Drawing) {
+	if len(d.Header) > 0 {
+		p.writeTags(Tag{0, "SECTION"}, Tag{2, "HEADER"})
+		for _, v := range d.Header {
+			p.writeTag(Tag{9, v.Name})
+			p.writeTags(v.Tags...)
+		}
+		p.writeTag(Tag{0, "ENDSEC"})
+	}
+
+	if len(d.Layers) > 0 {
+		p.writeTags(Tag{0, "SECTION"}, Tag{2, "TABLES"})
+		p.writeTags(Tag{0, "TABLE"}, Tag{2, "LAYER"}, intTag(70, len(d.Layers)))
+		for _, v := range d.Layers {
+			lineType := v.LineType
+			if lineType == "" {
+				lineType = "CONTINUOUS"
+			}
+			p.writeTags(
+				Tag{0, "LAYER"},
+				Tag{2, v.Name},
+				intTag(70, v.Flags),
+				intTag(62, v.Color),
+				Tag{6, lineType},
+			)
+		}
+		p.writeTag(Tag{0, "ENDTAB"})
+		p.writeTag(Tag{0, "ENDSEC"})
+	}
+
+	if len(d.Blocks) > 0 {
+		p.writeTags(Tag{0, "SECTION"}, Tag{2, "BLOCKS"})
+		for _, b := range d.Blocks {
+			layer := b.Layer
+			if layer == "" {
+				layer = "0"
+			}
+			p.writeTags(
+				Tag{0, "BLOCK"},
+				Tag{8, layer},
+				Tag{2, b.Name},
+				intTag(70, b.Flags),
+			)
+			p.writeTags(encodePoint(10, b.BasePoint)...)
+			p.writeTag(Tag{3, b.Name})
+			p.encodeEntities(b.Entities)
+			p.writeTags(Tag{0, "ENDBLK"}, Tag{8, layer})
+		}
+		p.writeTag(Tag{0, "ENDSEC"})
+	}
+
+	p.writeTags(Tag{0, "SECTION"}, Tag{2, "ENTITIES"})
+	p.encodeEntities(d.Entities)
+	p.writeTag(Tag{0, "ENDSEC"})
+
+	p.writeTag(Tag{0, "EOF"})
+}
+
+func (p *encoder) encodeEntities(entities []Entity) {
+	for _, e := range entities {
+		p.writeTag(Tag{0, e.Type()})
+		p.writeTags(e.Base().encodeBase()...)
+		p.writeTags(e.encode()...)
+	}
+}
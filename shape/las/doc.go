@@ -2,5 +2,43 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package las reads and writes comma-separated values (LAS) files.
+/*
+Package las reads and writes ASPRS LAS (LiDAR point cloud) files.
+
+The versions 1.0 to 1.4 and the point data formats 0 to 10 are supported.
+The Reader and Writer stream the point records, so files larger than
+memory can be processed:
+
+	r, err := las.Open("tile.las")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	for r.Next() {
+		pt := r.Point()
+		fmt.Println(pt.X, pt.Y, pt.Z, pt.Classification)
+	}
+	if err := r.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+The points can be selected with filters (bounding box, Z range,
+classification codes) and thinned to one point per grid cell or voxel:
+
+	fr := las.NewFilterReader(r,
+		las.BBoxFilter(minX, minY, maxX, maxY),
+		las.ClassFilter(2), // ground
+		las.GridThinFilter(1.0),
+	)
+	for fr.Next() {
+		pt := fr.Point()
+		...
+	}
+
+The GeoKeys of the LASF_Projection VLRs are decoded by Reader.GeoKeys, with
+the same key model as the GeoTIFF files (see image/tiff).
+
+The LAS specification is at http://www.asprs.org/Committee-General/LASer-LAS-File-Format-Exchange-Activities.html
+*/
 package las
@@ -0,0 +1,163 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"fmt"
+	"math"
+)
+
+// Filter reports whether a point should be kept.
+//
+// Some filters (the thinning filters) have state, they must be used for
+// one point stream only.
+type Filter func(pt *Point) bool
+
+// BBoxFilter keeps the points inside [minX,maxX]x[minY,maxY].
+func BBoxFilter(minX, minY, maxX, maxY float64) Filter {
+	return func(pt *Point) bool {
+		return pt.X >= minX && pt.X <= maxX && pt.Y >= minY && pt.Y <= maxY
+	}
+}
+
+// ZRangeFilter keeps the points with minZ <= Z <= maxZ.
+func ZRangeFilter(minZ, maxZ float64) Filter {
+	return func(pt *Point) bool {
+		return pt.Z >= minZ && pt.Z <= maxZ
+	}
+}
+
+// ClassFilter keeps the points with one of the classification codes.
+func ClassFilter(classes ...uint8) Filter {
+	var set [256]bool
+	for _, c := range classes {
+		set[c] = true
+	}
+	return func(pt *Point) bool {
+		return set[pt.Classification]
+	}
+}
+
+// AndFilter keeps the points kept by all the filters.
+// The filters are called in order, and the first rejection stops.
+func AndFilter(filters ...Filter) Filter {
+	return func(pt *Point) bool {
+		for _, f := range filters {
+			if !f(pt) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NotFilter keeps the points rejected by f.
+func NotFilter(f Filter) Filter {
+	return func(pt *Point) bool {
+		return !f(pt)
+	}
+}
+
+type gridCell struct {
+	X, Y, Z int64
+}
+
+// GridThinFilter keeps the first point of every cellSize x cellSize cell
+// on the XY plane.
+//
+// The memory used is proportional to the number of non-empty cells, not
+// to the number of points.
+func GridThinFilter(cellSize float64) Filter {
+	if cellSize <= 0 {
+		panic(fmt.Sprintf("shape/las: GridThinFilter, bad cell size: %v", cellSize))
+	}
+	seen := make(map[gridCell]bool)
+	return func(pt *Point) bool {
+		key := gridCell{
+			X: int64(math.Floor(pt.X / cellSize)),
+			Y: int64(math.Floor(pt.Y / cellSize)),
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+}
+
+// VoxelThinFilter keeps the first point of every cube voxel.
+//
+// The memory used is proportional to the number of non-empty voxels, not
+// to the number of points.
+func VoxelThinFilter(voxelSize float64) Filter {
+	if voxelSize <= 0 {
+		panic(fmt.Sprintf("shape/las: VoxelThinFilter, bad voxel size: %v", voxelSize))
+	}
+	seen := make(map[gridCell]bool)
+	return func(pt *Point) bool {
+		key := gridCell{
+			X: int64(math.Floor(pt.X / voxelSize)),
+			Y: int64(math.Floor(pt.Y / voxelSize)),
+			Z: int64(math.Floor(pt.Z / voxelSize)),
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+}
+
+// FilterReader reads the points of a Reader which are kept by a filter.
+type FilterReader struct {
+	*Reader
+	filter Filter
+}
+
+// NewFilterReader returns a FilterReader which keeps the points kept by
+// all the filters.
+func NewFilterReader(r *Reader, filters ...Filter) *FilterReader {
+	return &FilterReader{
+		Reader: r,
+		filter: AndFilter(filters...),
+	}
+}
+
+// Next reads the next kept point record and reports whether there was one.
+func (p *FilterReader) Next() bool {
+	for p.Reader.Next() {
+		if p.filter(p.Reader.Point()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Read reads the next kept point record into pt.
+// It returns io.EOF after the last point.
+func (p *FilterReader) Read(pt *Point) (err error) {
+	for {
+		if err = p.Reader.Read(pt); err != nil {
+			return
+		}
+		if p.filter(pt) {
+			return
+		}
+	}
+}
+
+// CopyPoints writes the points of r kept by all the filters to w, and
+// returns the number of points written.
+func CopyPoints(w *Writer, r *Reader, filters ...Filter) (n uint64, err error) {
+	fr := NewFilterReader(r, filters...)
+	for fr.Next() {
+		if err = w.Write(fr.Point()); err != nil {
+			return
+		}
+		n++
+	}
+	err = fr.Err()
+	return
+}
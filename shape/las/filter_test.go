@@ -0,0 +1,116 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"testing"
+)
+
+func tCreateGridFile(t *testing.T, filename string) {
+	hdr := &HeaderV14{}
+	hdr.PointDataFormatId = 0
+	w, err := Create(filename, hdr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10x10 points on a 0.5 grid, 4 points per 1x1 cell
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			pt := Point{
+				X:              float64(x) * 0.5,
+				Y:              float64(y) * 0.5,
+				Z:              float64(x + y),
+				ReturnNumber:   1,
+				Classification: uint8(x % 3),
+			}
+			if err := w.Write(&pt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tCountPoints(t *testing.T, filename string, filters ...Filter) (n int) {
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	fr := NewFilterReader(r, filters...)
+	for fr.Next() {
+		n++
+	}
+	if err := fr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestFilterReader(t *testing.T) {
+	filename, cleanup := tempLasFile(t)
+	defer cleanup()
+	tCreateGridFile(t, filename)
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		n       int
+	}{
+		{"none", nil, 100},
+		{"bbox", []Filter{BBoxFilter(0, 0, 1, 1)}, 9},
+		{"zrange", []Filter{ZRangeFilter(0, 1)}, 3},
+		{"class", []Filter{ClassFilter(0, 2)}, 70},
+		{"not-class", []Filter{NotFilter(ClassFilter(0, 2))}, 30},
+		{"bbox+class", []Filter{BBoxFilter(0, 0, 1, 1), ClassFilter(1)}, 3},
+		{"grid", []Filter{GridThinFilter(1)}, 25},
+		{"voxel", []Filter{VoxelThinFilter(1)}, 75},
+		{"bbox+grid", []Filter{BBoxFilter(0, 0, 1.9, 1.9), GridThinFilter(1)}, 4},
+	}
+	for _, tt := range tests {
+		if n := tCountPoints(t, filename, tt.filters...); n != tt.n {
+			t.Fatalf("%s: expect = %d, got = %d", tt.name, tt.n, n)
+		}
+	}
+}
+
+func TestCopyPoints(t *testing.T) {
+	filename, cleanup := tempLasFile(t)
+	defer cleanup()
+	tCreateGridFile(t, filename)
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	w, err := Create(filename+".thin", &r.Header, r.VLRs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := CopyPoints(w, r, GridThinFilter(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Fatalf("expect = 9, got = %d", n)
+	}
+
+	r2, err := Open(filename + ".thin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	if r2.NumPoints() != n || r2.Header.MaxX != 4 || r2.Header.MaxY != 4 {
+		t.Fatalf("bad header: %v", r2.Header.Header)
+	}
+}
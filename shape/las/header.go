@@ -0,0 +1,174 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	lasSig          = "LASF"
+	headerSizeV12   = 227
+	headerSizeV13   = 235
+	headerSizeV14   = 375
+	vlrHeaderSize   = 54
+	maxPointFormat  = 10
+	legacyMaxPoints = 1<<32 - 1
+)
+
+func headerSize(minorVersion int8) int {
+	switch {
+	case minorVersion >= 4:
+		return headerSizeV14
+	case minorVersion == 3:
+		return headerSizeV13
+	}
+	return headerSizeV12
+}
+
+// NumPoints returns the number of point records, using the 64-bit
+// counter of LAS 1.4 if needed.
+func (p *HeaderV14) NumPoints() uint64 {
+	if p.MinorVersion >= 4 && p.ExtNumberOfPointRecords != 0 {
+		return p.ExtNumberOfPointRecords
+	}
+	return uint64(p.NumberOfPointRecords)
+}
+
+// readHeader reads the public header block and skips the unknown
+// trailing bytes (if HeaderSize is bigger than the known header size).
+func readHeader(r io.Reader) (hdr HeaderV14, err error) {
+	if err = binary.Read(r, binary.LittleEndian, &hdr.Header); err != nil {
+		err = fmt.Errorf("shape/las: read header failed, err = %v", err)
+		return
+	}
+	if string(hdr.FileSignature[:]) != lasSig {
+		err = fmt.Errorf("shape/las: bad file signature, %q", hdr.FileSignature[:])
+		return
+	}
+	if hdr.MajorVersion != 1 || hdr.MinorVersion < 0 || hdr.MinorVersion > 4 {
+		err = fmt.Errorf("shape/las: unsupported version, %d.%d", hdr.MajorVersion, hdr.MinorVersion)
+		return
+	}
+	if hdr.PointDataFormatId > maxPointFormat {
+		err = fmt.Errorf("shape/las: unsupported point data format, %d", hdr.PointDataFormatId)
+		return
+	}
+	if int(hdr.PointDataRecordLen) < PointDataRecordLen(hdr.PointDataFormatId) {
+		err = fmt.Errorf("shape/las: bad point data record length, %d", hdr.PointDataRecordLen)
+		return
+	}
+
+	size := headerSize(hdr.MinorVersion)
+	if int(hdr.HeaderSize) < size {
+		err = fmt.Errorf("shape/las: bad header size, %d", hdr.HeaderSize)
+		return
+	}
+	if hdr.MinorVersion >= 3 {
+		if err = binary.Read(r, binary.LittleEndian, &hdr.OffsetWaveform); err != nil {
+			return
+		}
+	}
+	if hdr.MinorVersion >= 4 {
+		ext := []interface{}{
+			&hdr.OffsetToFirstEVLR,
+			&hdr.NumberOfEVLRs,
+			&hdr.ExtNumberOfPointRecords,
+			&hdr.ExtNumberOfPointsByReturn,
+		}
+		for _, v := range ext {
+			if err = binary.Read(r, binary.LittleEndian, v); err != nil {
+				return
+			}
+		}
+	}
+	if n := int64(hdr.HeaderSize) - int64(size); n > 0 {
+		if _, err = io.CopyN(ioutil.Discard, r, n); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeHeader(w io.Writer, hdr *HeaderV14) (err error) {
+	if err = binary.Write(w, binary.LittleEndian, &hdr.Header); err != nil {
+		return
+	}
+	if hdr.MinorVersion >= 3 {
+		if err = binary.Write(w, binary.LittleEndian, hdr.OffsetWaveform); err != nil {
+			return
+		}
+	}
+	if hdr.MinorVersion >= 4 {
+		ext := []interface{}{
+			hdr.OffsetToFirstEVLR,
+			hdr.NumberOfEVLRs,
+			hdr.ExtNumberOfPointRecords,
+			hdr.ExtNumberOfPointsByReturn,
+		}
+		for _, v := range ext {
+			if err = binary.Write(w, binary.LittleEndian, v); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// readVLR reads a variable length record and returns the number of
+// bytes read.
+func readVLR(r io.Reader) (vlr VLR, n int, err error) {
+	var buf [vlrHeaderSize]byte
+	if _, err = io.ReadFull(r, buf[:]); err != nil {
+		err = fmt.Errorf("shape/las: read VLR failed, err = %v", err)
+		return
+	}
+	vlr.Reserved = binary.LittleEndian.Uint16(buf[0:])
+	for i := 0; i < 16; i++ {
+		vlr.UserId[i] = int8(buf[2+i])
+	}
+	vlr.RecordId = binary.LittleEndian.Uint16(buf[18:])
+	vlr.RecordLengthAfterHeader = binary.LittleEndian.Uint16(buf[20:])
+	copy(vlr.Description[:], buf[22:54])
+
+	vlr.Data = make([]byte, vlr.RecordLengthAfterHeader)
+	if _, err = io.ReadFull(r, vlr.Data); err != nil {
+		err = fmt.Errorf("shape/las: read VLR data failed, err = %v", err)
+		return
+	}
+	n = vlrHeaderSize + len(vlr.Data)
+	return
+}
+
+func writeVLR(w io.Writer, vlr *VLR) (err error) {
+	var buf [vlrHeaderSize]byte
+	binary.LittleEndian.PutUint16(buf[0:], vlr.Reserved)
+	for i := 0; i < 16; i++ {
+		buf[2+i] = byte(vlr.UserId[i])
+	}
+	binary.LittleEndian.PutUint16(buf[18:], vlr.RecordId)
+	binary.LittleEndian.PutUint16(buf[20:], uint16(len(vlr.Data)))
+	copy(buf[22:54], vlr.Description[:])
+	if _, err = w.Write(buf[:]); err != nil {
+		return
+	}
+	_, err = w.Write(vlr.Data)
+	return
+}
+
+// UserIdString returns the user id as a string.
+func (p *VLR) UserIdString() string {
+	var b []byte
+	for _, c := range p.UserId {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
@@ -0,0 +1,202 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempLasFile(t *testing.T) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "test.las"), func() { os.RemoveAll(dir) }
+}
+
+func tMakePoint(format uint8, i int) Point {
+	f := pointFormats[format]
+	pt := Point{
+		X:               1000 + float64(i)*0.25,
+		Y:               2000 - float64(i)*0.5,
+		Z:               float64(i%7) * 1.5,
+		Intensity:       uint16(i * 3),
+		UserData:        uint8(i),
+		PointSourceId:   uint16(i % 5),
+		ReturnNumber:    uint8(i%3 + 1),
+		NumberOfReturns: 3,
+		Classification:  uint8(i % 10),
+	}
+	if f.Extended {
+		pt.ScanAngle = int16(-i * 10)
+		pt.ScannerChannel = uint8(i % 4)
+		pt.ClassificationFlags = uint8(i % 16)
+	} else {
+		pt.ScanAngleRank = int8(-i % 90)
+		pt.ClassificationFlags = uint8(i % 8)
+	}
+	if f.GPSTime {
+		pt.GPSTime = 1e5 + float64(i)/8
+	}
+	if f.RGB {
+		pt.Red, pt.Green, pt.Blue = uint16(i), uint16(i*2), uint16(i*3)
+	}
+	if f.NIR {
+		pt.NIR = uint16(i * 4)
+	}
+	if f.Wave {
+		pt.WavePacket = WavePacket{1, uint64(i * 100), 64, 1.5, 0.25, 0.5, 0.75}
+	}
+	return pt
+}
+
+func TestReaderWriter_allFormats(t *testing.T) {
+	filename, cleanup := tempLasFile(t)
+	defer cleanup()
+
+	const numPoints = 100
+	for format := uint8(0); format <= maxPointFormat; format++ {
+		hdr := &HeaderV14{}
+		hdr.PointDataFormatId = format
+		hdr.XOffset, hdr.YOffset = 1000, 2000
+
+		w, err := Create(filename, hdr, nil)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		for i := 0; i < numPoints; i++ {
+			pt := tMakePoint(format, i)
+			if err := w.Write(&pt); err != nil {
+				t.Fatalf("format %d: %v", format, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+
+		r, err := Open(filename)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		h := &r.Header
+		if h.PointDataFormatId != format || int(h.PointDataRecordLen) != PointDataRecordLen(format) {
+			t.Fatalf("format %d: bad header: %v, %v", format, h.PointDataFormatId, h.PointDataRecordLen)
+		}
+		if n := r.NumPoints(); n != numPoints {
+			t.Fatalf("format %d: bad NumPoints: %d", format, n)
+		}
+		if h.MinX != 1000 || h.MaxX != 1000+99*0.25 || h.MinZ != 0 || h.MaxZ != 9 {
+			t.Fatalf("format %d: bad bounds: %v", format, h.Header)
+		}
+		if pointFormats[format].Extended {
+			if h.MinorVersion != 4 || h.ExtNumberOfPointsByReturn[0] != 34 || h.NumberOfPointRecords != 0 {
+				t.Fatalf("format %d: bad counters: %v", format, h)
+			}
+		} else {
+			if h.MinorVersion != 2 || h.NumberOfPointsByReturn != [5]uint32{34, 33, 33, 0, 0} {
+				t.Fatalf("format %d: bad counters: %v", format, h.NumberOfPointsByReturn)
+			}
+		}
+
+		i := 0
+		for r.Next() {
+			got, want := *r.Point(), tMakePoint(format, i)
+			if math.Abs(got.X-want.X) > 0.005 || math.Abs(got.Y-want.Y) > 0.005 || math.Abs(got.Z-want.Z) > 0.005 {
+				t.Fatalf("format %d, point %d: bad xyz: %v", format, i, got)
+			}
+			got.X, got.Y, got.Z, got.BitFields = want.X, want.Y, want.Z, 0
+			if got != want {
+				t.Fatalf("format %d, point %d:\ngot  %+v\nwant %+v", format, i, got, want)
+			}
+			i++
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if i != numPoints {
+			t.Fatalf("format %d: bad count: %d", format, i)
+		}
+		r.Close()
+	}
+}
+
+func TestReader_vlrs(t *testing.T) {
+	filename, cleanup := tempLasFile(t)
+	defer cleanup()
+
+	keys := []VLR_GeoKeysEntry{
+		{1, 1, 0, 2},        // directory header, 2 keys
+		{1024, 0, 1, 1},     // GTModelTypeGeoKey = projected
+		{3072, 0, 1, 32650}, // ProjectedCSTypeGeoKey = WGS84 / UTM 50N
+	}
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, keys)
+
+	vlr := VLR{RecordId: uint16(VLRRecordId_GeoKeyDirectory), Data: data.Bytes()}
+	for i, c := range "LASF_Projection" {
+		vlr.UserId[i] = int8(c)
+	}
+	copy(vlr.Description[:], "GeoKeyDirectoryTag")
+
+	hdr := &HeaderV14{}
+	hdr.PointDataFormatId = 1
+	w, err := Create(filename, hdr, []VLR{vlr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := Point{X: 1, Y: 2, Z: 3, ReturnNumber: 1}
+	w.Write(&pt)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read without seeking
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := r.VLR("LASF_Projection", uint16(VLRRecordId_GeoKeyDirectory))
+	if !ok {
+		t.Fatalf("VLR not found: %v", r.VLRs)
+	}
+	if !bytes.Equal(v.Data, data.Bytes()) {
+		t.Fatalf("bad VLR data: %v", v.Data)
+	}
+	geoKeys, err := r.GeoKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(geoKeys) != 2 || geoKeys[1].Id != 3072 || geoKeys[1].Shorts[0] != 32650 {
+		t.Fatalf("bad geo keys: %v", geoKeys)
+	}
+	if !r.Next() || r.Point().Z != 3 || r.Next() {
+		t.Fatalf("bad points, err = %v", r.Err())
+	}
+}
+
+func TestReader_badFile(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("LASX"))); err == nil {
+		t.Fatal("expect error")
+	}
+	var hdr Header
+	copy(hdr.FileSignature[:], "LASF")
+	hdr.MajorVersion, hdr.MinorVersion = 1, 2
+	hdr.PointDataFormatId = 11
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &hdr)
+	if _, err := NewReader(&buf); err == nil {
+		t.Fatal("expect error for bad point data format")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// pointFormat describes the layout of a point data format.
+type pointFormat struct {
+	Size     int  // minimal record length
+	Extended bool // 1.4 layout (6-10)
+	GPSTime  bool
+	RGB      bool
+	NIR      bool
+	Wave     bool
+}
+
+var pointFormats = []pointFormat{
+	0:  {Size: 20},
+	1:  {Size: 28, GPSTime: true},
+	2:  {Size: 26, RGB: true},
+	3:  {Size: 34, GPSTime: true, RGB: true},
+	4:  {Size: 57, GPSTime: true, Wave: true},
+	5:  {Size: 63, GPSTime: true, RGB: true, Wave: true},
+	6:  {Size: 30, Extended: true, GPSTime: true},
+	7:  {Size: 36, Extended: true, GPSTime: true, RGB: true},
+	8:  {Size: 38, Extended: true, GPSTime: true, RGB: true, NIR: true},
+	9:  {Size: 59, Extended: true, GPSTime: true, Wave: true},
+	10: {Size: 67, Extended: true, GPSTime: true, RGB: true, NIR: true, Wave: true},
+}
+
+// PointDataRecordLen returns the minimal record length of the point data
+// format, or 0 if the format is unknown.
+func PointDataRecordLen(format uint8) int {
+	if int(format) < len(pointFormats) {
+		return pointFormats[format].Size
+	}
+	return 0
+}
+
+// pointCodec converts between Point and the raw point records.
+type pointCodec struct {
+	format                 pointFormat
+	xScale, yScale, zScale float64
+	xOffset, yOffset       float64
+	zOffset                float64
+}
+
+func newPointCodec(hdr *Header) *pointCodec {
+	return &pointCodec{
+		format:  pointFormats[hdr.PointDataFormatId],
+		xScale:  hdr.XScaleFactor,
+		yScale:  hdr.YScaleFactor,
+		zScale:  hdr.ZScaleFactor,
+		xOffset: hdr.XOffset,
+		yOffset: hdr.YOffset,
+		zOffset: hdr.ZOffset,
+	}
+}
+
+func (p *pointCodec) Decode(b []byte, pt *Point) {
+	le := binary.LittleEndian
+
+	*pt = Point{}
+	pt.X = float64(int32(le.Uint32(b[0:])))*p.xScale + p.xOffset
+	pt.Y = float64(int32(le.Uint32(b[4:])))*p.yScale + p.yOffset
+	pt.Z = float64(int32(le.Uint32(b[8:])))*p.zScale + p.zOffset
+	pt.Intensity = le.Uint16(b[12:])
+	pt.BitFields = le.Uint16(b[14:])
+
+	off := 0
+	if !p.format.Extended {
+		pt.ReturnNumber = b[14] & 0x07
+		pt.NumberOfReturns = (b[14] >> 3) & 0x07
+		pt.ScanDirectionFlag = (b[14] >> 6) & 0x01
+		pt.EdgeOfFlightLine = (b[14] >> 7) & 0x01
+		pt.Classification = b[15] & 0x1F
+		pt.ClassificationFlags = b[15] >> 5
+		pt.ScanAngleRank = int8(b[16])
+		pt.UserData = b[17]
+		pt.PointSourceId = le.Uint16(b[18:])
+		off = 20
+		if p.format.GPSTime {
+			pt.GPSTime = math.Float64frombits(le.Uint64(b[off:]))
+			off += 8
+		}
+	} else {
+		pt.ReturnNumber = b[14] & 0x0F
+		pt.NumberOfReturns = b[14] >> 4
+		pt.ClassificationFlags = b[15] & 0x0F
+		pt.ScannerChannel = (b[15] >> 4) & 0x03
+		pt.ScanDirectionFlag = (b[15] >> 6) & 0x01
+		pt.EdgeOfFlightLine = (b[15] >> 7) & 0x01
+		pt.Classification = b[16]
+		pt.UserData = b[17]
+		pt.ScanAngle = int16(le.Uint16(b[18:]))
+		pt.PointSourceId = le.Uint16(b[20:])
+		pt.GPSTime = math.Float64frombits(le.Uint64(b[22:]))
+		off = 30
+	}
+	if p.format.RGB {
+		pt.Red = le.Uint16(b[off+0:])
+		pt.Green = le.Uint16(b[off+2:])
+		pt.Blue = le.Uint16(b[off+4:])
+		off += 6
+	}
+	if p.format.NIR {
+		pt.NIR = le.Uint16(b[off:])
+		off += 2
+	}
+	if p.format.Wave {
+		w := &pt.WavePacket
+		w.DescriptorIndex = b[off]
+		w.OffsetToData = le.Uint64(b[off+1:])
+		w.PacketSize = le.Uint32(b[off+9:])
+		w.ReturnPointLocation = math.Float32frombits(le.Uint32(b[off+13:]))
+		w.Xt = math.Float32frombits(le.Uint32(b[off+17:]))
+		w.Yt = math.Float32frombits(le.Uint32(b[off+21:]))
+		w.Zt = math.Float32frombits(le.Uint32(b[off+25:]))
+		off += 29
+	}
+}
+
+// Encode encodes pt into b, len(b) must be the record length.
+func (p *pointCodec) Encode(b []byte, pt *Point) {
+	le := binary.LittleEndian
+
+	for i := range b {
+		b[i] = 0
+	}
+	le.PutUint32(b[0:], uint32(quantize(pt.X, p.xScale, p.xOffset)))
+	le.PutUint32(b[4:], uint32(quantize(pt.Y, p.yScale, p.yOffset)))
+	le.PutUint32(b[8:], uint32(quantize(pt.Z, p.zScale, p.zOffset)))
+	le.PutUint16(b[12:], pt.Intensity)
+
+	off := 0
+	if !p.format.Extended {
+		b[14] = (pt.ReturnNumber & 0x07) |
+			(pt.NumberOfReturns&0x07)<<3 |
+			(pt.ScanDirectionFlag&0x01)<<6 |
+			(pt.EdgeOfFlightLine&0x01)<<7
+		b[15] = (pt.Classification & 0x1F) | (pt.ClassificationFlags&0x07)<<5
+		b[16] = uint8(pt.ScanAngleRank)
+		b[17] = pt.UserData
+		le.PutUint16(b[18:], pt.PointSourceId)
+		off = 20
+		if p.format.GPSTime {
+			le.PutUint64(b[off:], math.Float64bits(pt.GPSTime))
+			off += 8
+		}
+	} else {
+		b[14] = (pt.ReturnNumber & 0x0F) | (pt.NumberOfReturns&0x0F)<<4
+		b[15] = (pt.ClassificationFlags & 0x0F) |
+			(pt.ScannerChannel&0x03)<<4 |
+			(pt.ScanDirectionFlag&0x01)<<6 |
+			(pt.EdgeOfFlightLine&0x01)<<7
+		b[16] = pt.Classification
+		b[17] = pt.UserData
+		le.PutUint16(b[18:], uint16(pt.ScanAngle))
+		le.PutUint16(b[20:], pt.PointSourceId)
+		le.PutUint64(b[22:], math.Float64bits(pt.GPSTime))
+		off = 30
+	}
+	if p.format.RGB {
+		le.PutUint16(b[off+0:], pt.Red)
+		le.PutUint16(b[off+2:], pt.Green)
+		le.PutUint16(b[off+4:], pt.Blue)
+		off += 6
+	}
+	if p.format.NIR {
+		le.PutUint16(b[off:], pt.NIR)
+		off += 2
+	}
+	if p.format.Wave {
+		w := &pt.WavePacket
+		b[off] = w.DescriptorIndex
+		le.PutUint64(b[off+1:], w.OffsetToData)
+		le.PutUint32(b[off+9:], w.PacketSize)
+		le.PutUint32(b[off+13:], math.Float32bits(w.ReturnPointLocation))
+		le.PutUint32(b[off+17:], math.Float32bits(w.Xt))
+		le.PutUint32(b[off+21:], math.Float32bits(w.Yt))
+		le.PutUint32(b[off+25:], math.Float32bits(w.Zt))
+		off += 29
+	}
+}
+
+func quantize(v, scale, offset float64) int32 {
+	return int32(math.Floor((v-offset)/scale + 0.5))
+}
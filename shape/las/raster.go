@@ -0,0 +1,328 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	image_ext "github.com/chai2010/gopkg/image"
+	"github.com/chai2010/gopkg/image/big"
+	color_ext "github.com/chai2010/gopkg/image/color"
+)
+
+// PointReader is a stream of point records, like Reader and FilterReader.
+type PointReader interface {
+	Next() bool
+	Point() *Point
+	Err() error
+}
+
+// Aggregate is the method to compute the value of a cell from its points.
+type Aggregate int
+
+const (
+	Aggregate_Min  Aggregate = iota // lowest Z
+	Aggregate_Max                   // highest Z
+	Aggregate_Mean                  // mean Z
+	Aggregate_IDW                   // inverse distance weighted Z of the points near the cell center
+)
+
+// RasterOptions are the raster grid and the rasterization parameters.
+//
+// The cell (col, row) covers the area
+// [MinX+col*CellSize, MinX+(col+1)*CellSize) x (MaxY-(row+1)*CellSize, MaxY-row*CellSize].
+type RasterOptions struct {
+	MinX, MaxY    float64 // upper left corner of the grid
+	CellSize      float64
+	Width, Height int
+
+	Aggregate Aggregate
+	IDWPower  float64 // IDW power, default 2
+	IDWRadius float64 // IDW search radius, default CellSize
+
+	FillVoids   bool // interpolate the empty cells from the nearest cells
+	MaxVoidDist int  // max search distance in cells for FillVoids, 0 means no limit
+
+	NoData   float32     // value of the empty cells
+	TileSize image.Point // tile size of the Dem, default 256x256
+
+	// NewStore returns the store of the Dem ("dem") or of a scratch grid
+	// ("value" and "weight", which are not used after Rasterize returns).
+	// The grids are kept in memory if NewStore is nil, the grids larger
+	// than the memory need the stores of files (like a big.FileTileStore
+	// per name).
+	NewStore func(name string) (big.TileStore, error)
+}
+
+func (p *RasterOptions) newDem(name string, tileSize image.Point, zeroValue float32) (m *big.Dem, err error) {
+	var store big.TileStore = big.NewMemoryTileStore()
+	if p.NewStore != nil {
+		if store, err = p.NewStore(name); err != nil {
+			return
+		}
+	}
+	m = big.NewDemWithStore(image.Rect(0, 0, p.Width, p.Height), tileSize, color_ext.Gray32f{Y: zeroValue}, store)
+	return
+}
+
+// NewRasterOptions returns the options for a grid covering the bounds of
+// the header, with the NoData value -9999.
+func NewRasterOptions(hdr *Header, cellSize float64) *RasterOptions {
+	if cellSize <= 0 {
+		panic(fmt.Sprintf("shape/las: NewRasterOptions, bad cell size: %v", cellSize))
+	}
+	return &RasterOptions{
+		MinX:     hdr.MinX,
+		MaxY:     hdr.MaxY,
+		CellSize: cellSize,
+		Width:    int(math.Floor((hdr.MaxX-hdr.MinX)/cellSize)) + 1,
+		Height:   int(math.Floor((hdr.MaxY-hdr.MinY)/cellSize)) + 1,
+		NoData:   -9999,
+	}
+}
+
+// Rasterize bins the points of r into the cells of the grid and returns
+// the elevation model. The points outside the grid are ignored.
+//
+// The points are read one by one, and the grid is accumulated in the tiles
+// of two scratch Dems (two float32 per cell), so it is not limited by the
+// memory if opt.NewStore returns the stores of files. The points sorted by
+// area need less tiles to be paged in and out.
+func Rasterize(r PointReader, opt *RasterOptions) (m *big.Dem, err error) {
+	if opt.CellSize <= 0 || opt.Width <= 0 || opt.Height <= 0 {
+		err = fmt.Errorf("shape/las: Rasterize, bad grid: %vx%v, cell size = %v", opt.Width, opt.Height, opt.CellSize)
+		return
+	}
+	tileSize := opt.TileSize
+	if tileSize.X <= 0 || tileSize.Y <= 0 {
+		tileSize = image.Pt(256, 256)
+	}
+
+	g, err := newRasterGrid(opt, tileSize)
+	if err != nil {
+		return
+	}
+	for r.Next() {
+		g.Add(r.Point())
+	}
+	if err = r.Err(); err != nil {
+		return
+	}
+	if err = g.Err(); err != nil {
+		return
+	}
+
+	if m, err = opt.newDem("dem", tileSize, opt.NoData); err != nil {
+		return
+	}
+	for y0 := 0; y0 < opt.Height; y0 += tileSize.Y {
+		for x0 := 0; x0 < opt.Width; x0 += tileSize.X {
+			rect := image.Rect(x0, y0, x0+tileSize.X, y0+tileSize.Y).Intersect(m.Bounds())
+			var tile *image_ext.Gray32f
+			if tile, err = g.ReadRect(rect); err != nil {
+				return
+			}
+			if err = m.WriteRect(-1, rect, tile); err != nil {
+				return
+			}
+		}
+	}
+	if err = g.Err(); err != nil {
+		return
+	}
+	err = m.Err()
+	return
+}
+
+// rasterGrid accumulates the points of the cells.
+// For Min/Max, value is the current value and weight is the count;
+// for Mean/IDW, value is the weighted mean of Z and weight is the sum of
+// the weights. The cells of weight 0 are empty.
+type rasterGrid struct {
+	opt    *RasterOptions
+	value  *big.Dem
+	weight *big.Dem
+	power  float64
+	radius float64
+}
+
+func newRasterGrid(opt *RasterOptions, tileSize image.Point) (g *rasterGrid, err error) {
+	g = &rasterGrid{
+		opt:    opt,
+		power:  opt.IDWPower,
+		radius: opt.IDWRadius,
+	}
+	if g.value, err = opt.newDem("value", tileSize, 0); err != nil {
+		return
+	}
+	if g.weight, err = opt.newDem("weight", tileSize, 0); err != nil {
+		return
+	}
+	if g.power <= 0 {
+		g.power = 2
+	}
+	if g.radius <= 0 {
+		g.radius = opt.CellSize
+	}
+	return
+}
+
+// Err returns the first store error of the grids.
+func (p *rasterGrid) Err() error {
+	if err := p.value.Err(); err != nil {
+		return err
+	}
+	return p.weight.Err()
+}
+
+func (p *rasterGrid) Add(pt *Point) {
+	opt := p.opt
+	if opt.Aggregate == Aggregate_IDW {
+		p.addIDW(pt)
+		return
+	}
+
+	col := int(math.Floor((pt.X - opt.MinX) / opt.CellSize))
+	row := int(math.Floor((opt.MaxY - pt.Y) / opt.CellSize))
+	if col < 0 || col >= opt.Width || row < 0 || row >= opt.Height {
+		return
+	}
+
+	v, n := p.value.Gray32fAt(col, row).Y, p.weight.Gray32fAt(col, row).Y
+	z := float32(pt.Z)
+	switch opt.Aggregate {
+	case Aggregate_Min:
+		if n == 0 || z < v {
+			v = z
+		}
+	case Aggregate_Max:
+		if n == 0 || z > v {
+			v = z
+		}
+	default:
+		v += (z - v) / (n + 1)
+	}
+	p.value.SetGray32f(col, row, color_ext.Gray32f{Y: v})
+	p.weight.SetGray32f(col, row, color_ext.Gray32f{Y: n + 1})
+}
+
+func (p *rasterGrid) addIDW(pt *Point) {
+	opt := p.opt
+	fx := (pt.X - opt.MinX) / opt.CellSize
+	fy := (opt.MaxY - pt.Y) / opt.CellSize
+	rc := p.radius / opt.CellSize
+
+	minCol := maxInt(int(math.Floor(fx-rc-0.5)), 0)
+	maxCol := minInt(int(math.Ceil(fx+rc-0.5)), opt.Width-1)
+	minRow := maxInt(int(math.Floor(fy-rc-0.5)), 0)
+	maxRow := minInt(int(math.Ceil(fy+rc-0.5)), opt.Height-1)
+
+	for row := minRow; row <= maxRow; row++ {
+		for col := minCol; col <= maxCol; col++ {
+			dx := (float64(col) + 0.5 - fx) * opt.CellSize
+			dy := (float64(row) + 0.5 - fy) * opt.CellSize
+			d := math.Hypot(dx, dy)
+			if d > p.radius {
+				continue
+			}
+			w := 1 / math.Pow(math.Max(d, opt.CellSize*1e-3), p.power)
+
+			// the running weighted mean
+			v := float64(p.value.Gray32fAt(col, row).Y)
+			sw := float64(p.weight.Gray32fAt(col, row).Y) + w
+			v += (pt.Z - v) * w / sw
+			p.value.SetGray32f(col, row, color_ext.Gray32f{Y: float32(v)})
+			p.weight.SetGray32f(col, row, color_ext.Gray32f{Y: float32(sw)})
+		}
+	}
+}
+
+// at returns the value of the cell, and false for the empty cells.
+func (p *rasterGrid) at(x, y int) (v float64, ok bool) {
+	if p.weight.Gray32fAt(x, y).Y == 0 {
+		return
+	}
+	return float64(p.value.Gray32fAt(x, y).Y), true
+}
+
+// ReadRect returns the cell values of r, NoData for the empty cells, or
+// the interpolated values if opt.FillVoids is set.
+func (p *rasterGrid) ReadRect(r image.Rectangle) (m *image_ext.Gray32f, err error) {
+	value, err := p.value.ReadRect(-1, r, nil)
+	if err != nil {
+		return
+	}
+	weight, err := p.weight.ReadRect(-1, r, nil)
+	if err != nil {
+		return
+	}
+	m = value
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			if weight.Gray32fAt(x, y).Y != 0 {
+				continue
+			}
+			v := p.opt.NoData
+			if p.opt.FillVoids {
+				if z, ok := p.fillVoid(r.Min.X+x, r.Min.Y+y); ok {
+					v = float32(z)
+				}
+			}
+			m.SetGray32f(x, y, color_ext.Gray32f{Y: v})
+		}
+	}
+	return
+}
+
+var voidFillDirs = [8][2]int{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+// fillVoid interpolates the empty cell (x, y) with the inverse distance
+// weighted mean of the nearest valid cell in each of the 8 directions.
+func (p *rasterGrid) fillVoid(x, y int) (v float64, ok bool) {
+	width, height, maxDist := p.opt.Width, p.opt.Height, p.opt.MaxVoidDist
+	if maxDist <= 0 {
+		maxDist = maxInt(width, height)
+	}
+	var sum, weight float64
+	for _, dir := range voidFillDirs {
+		for d := 1; d <= maxDist; d++ {
+			xx, yy := x+dir[0]*d, y+dir[1]*d
+			if xx < 0 || xx >= width || yy < 0 || yy >= height {
+				break
+			}
+			if z, ok := p.at(xx, yy); ok {
+				dist := float64(d) * math.Hypot(float64(dir[0]), float64(dir[1]))
+				w := 1 / (dist * dist)
+				sum += w * z
+				weight += w
+				break
+			}
+		}
+	}
+	if weight == 0 {
+		return
+	}
+	return sum / weight, true
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,166 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chai2010/gopkg/image/big"
+)
+
+type tPointSlice struct {
+	points []Point
+	pos    int
+}
+
+func (p *tPointSlice) Next() bool {
+	p.pos++
+	return p.pos <= len(p.points)
+}
+
+func (p *tPointSlice) Point() *Point { return &p.points[p.pos-1] }
+func (p *tPointSlice) Err() error    { return nil }
+
+func TestRasterize(t *testing.T) {
+	// 2 points in the cell (0,0), 1 point in (2,1), the others are empty
+	points := []Point{
+		{X: 0.2, Y: 2.8, Z: 10},
+		{X: 0.7, Y: 2.3, Z: 20},
+		{X: 2.5, Y: 1.5, Z: 30},
+	}
+	opt := &RasterOptions{
+		MinX: 0, MaxY: 3, CellSize: 1, Width: 3, Height: 3,
+		NoData:   -9999,
+		TileSize: image.Pt(2, 2),
+	}
+	tests := []struct {
+		aggregate Aggregate
+		v00, v21  float32
+	}{
+		{Aggregate_Min, 10, 30},
+		{Aggregate_Max, 20, 30},
+		{Aggregate_Mean, 15, 30},
+	}
+	for _, tt := range tests {
+		opt.Aggregate = tt.aggregate
+		m, err := Rasterize(&tPointSlice{points: points}, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Bounds() != image.Rect(0, 0, 3, 3) {
+			t.Fatalf("bad bounds: %v", m.Bounds())
+		}
+		if v := m.Gray32fAt(0, 0).Y; v != tt.v00 {
+			t.Fatalf("aggregate %d: (0,0): expect = %v, got = %v", tt.aggregate, tt.v00, v)
+		}
+		if v := m.Gray32fAt(2, 1).Y; v != tt.v21 {
+			t.Fatalf("aggregate %d: (2,1): expect = %v, got = %v", tt.aggregate, tt.v21, v)
+		}
+		if v := m.Gray32fAt(1, 1).Y; v != -9999 {
+			t.Fatalf("aggregate %d: (1,1): expect no data, got = %v", tt.aggregate, v)
+		}
+	}
+
+	// voids are filled between (0,0) and (2,1)
+	opt.Aggregate = Aggregate_Min
+	opt.FillVoids = true
+	m, err := Rasterize(&tPointSlice{points: points}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			if v := m.Gray32fAt(x, y).Y; v < 10 || v > 30 {
+				t.Fatalf("(%d,%d): bad filled value: %v", x, y, v)
+			}
+		}
+	}
+	// (0,0) at distance 1, (2,1) at distance sqrt(2)
+	if v := m.Gray32fAt(1, 0).Y; math.Abs(float64(v)-(10*1+30*0.5)/1.5) > 1e-4 {
+		t.Fatalf("(1,0): bad filled value: %v", v)
+	}
+}
+
+func TestRasterize_idw(t *testing.T) {
+	points := []Point{
+		{X: 1.5, Y: 1.5, Z: 10}, // center of (1,1)
+		{X: 2.0, Y: 1.5, Z: 20}, // between (1,1) and (2,1)
+	}
+	opt := &RasterOptions{
+		MinX: 0, MaxY: 3, CellSize: 1, Width: 3, Height: 3,
+		Aggregate: Aggregate_IDW,
+		IDWRadius: 0.6,
+		NoData:    -1,
+	}
+	m, err := Rasterize(&tPointSlice{points: points}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the point at the center dominates
+	if v := m.Gray32fAt(1, 1).Y; v < 10 || v > 10.1 {
+		t.Fatalf("(1,1): bad value: %v", v)
+	}
+	if v := m.Gray32fAt(2, 1).Y; v != 20 {
+		t.Fatalf("(2,1): bad value: %v", v)
+	}
+	if v := m.Gray32fAt(0, 0).Y; v != -1 {
+		t.Fatalf("(0,0): expect no data, got = %v", v)
+	}
+}
+
+func TestRasterize_fileStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "las")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var points []Point
+	for i := 0; i < 200; i++ {
+		x, y := float64(i%17)*0.6, float64(i%13)*0.7
+		points = append(points, Point{X: x, Y: y, Z: x*3 + y})
+	}
+	opt := &RasterOptions{
+		MinX: 0, MaxY: 9, CellSize: 0.5, Width: 21, Height: 19,
+		Aggregate: Aggregate_Mean,
+		FillVoids: true,
+		NoData:    -9999,
+		TileSize:  image.Pt(4, 4),
+	}
+	m0, err := Rasterize(&tPointSlice{points: points}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	opt.NewStore = func(name string) (big.TileStore, error) {
+		names[name] = true
+		return big.NewFileTileStore(filepath.Join(dir, name), false)
+	}
+	m1, err := Rasterize(&tPointSlice{points: points}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m1.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dem", "value", "weight"} {
+		if !names[name] {
+			t.Fatalf("the store %q is not used", name)
+		}
+	}
+	for y := 0; y < opt.Height; y++ {
+		for x := 0; x < opt.Width; x++ {
+			if a, b := m0.Gray32fAt(x, y), m1.Gray32fAt(x, y); a != b {
+				t.Fatalf("(%d,%d): expect = %v, got = %v", x, y, a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/chai2010/gopkg/image/tiff"
+)
+
+// Reader reads the point records of a LAS file one by one.
+// Only one record is kept in memory.
+type Reader struct {
+	Header HeaderV14
+	VLRs   []VLR
+
+	r      io.Reader
+	closer io.Closer
+	codec  *pointCodec
+	buf    []byte
+	num    uint64
+	total  uint64
+	point  Point
+	err    error
+}
+
+// Open opens the named LAS file.
+func Open(filename string) (p *Reader, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	if p, err = NewReader(f); err != nil {
+		f.Close()
+		return
+	}
+	p.closer = f
+	return
+}
+
+// NewReader reads the header and the VLRs from r, and returns a Reader
+// positioned at the first point record. r is read sequentially.
+func NewReader(r io.Reader) (p *Reader, err error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	hdr, err := readHeader(br)
+	if err != nil {
+		return
+	}
+	offset := int64(hdr.HeaderSize)
+
+	var vlrs []VLR
+	for i := 0; i < int(hdr.NumberOfVariableLengthRecords); i++ {
+		var vlr VLR
+		var n int
+		if vlr, n, err = readVLR(br); err != nil {
+			return
+		}
+		vlrs = append(vlrs, vlr)
+		offset += int64(n)
+	}
+
+	if n := int64(hdr.OffsetToPointData) - offset; n > 0 {
+		if _, err = io.CopyN(ioutil.Discard, br, n); err != nil {
+			return
+		}
+	} else if n < 0 {
+		err = fmt.Errorf("shape/las: bad offset to point data, %d", hdr.OffsetToPointData)
+		return
+	}
+
+	p = &Reader{
+		Header: hdr,
+		VLRs:   vlrs,
+		r:      br,
+		codec:  newPointCodec(&hdr.Header),
+		buf:    make([]byte, hdr.PointDataRecordLen),
+		total:  hdr.NumPoints(),
+	}
+	return
+}
+
+// Close closes the file opened by Open.
+func (p *Reader) Close() (err error) {
+	if p.closer != nil {
+		err = p.closer.Close()
+		p.closer = nil
+	}
+	return
+}
+
+// NumPoints returns the number of point records.
+func (p *Reader) NumPoints() uint64 {
+	return p.total
+}
+
+// Next reads the next point record and reports whether there was one.
+func (p *Reader) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	if p.err = p.Read(&p.point); p.err != nil {
+		if p.err == io.EOF {
+			p.err = nil
+		}
+		return false
+	}
+	return true
+}
+
+// Point returns the current point record. The returned value is
+// overwritten by the next call to Next.
+func (p *Reader) Point() *Point {
+	return &p.point
+}
+
+// Err returns the first error that was encountered by Next.
+func (p *Reader) Err() error {
+	return p.err
+}
+
+// Read reads the next point record into pt.
+// It returns io.EOF after the last point.
+func (p *Reader) Read(pt *Point) (err error) {
+	if p.num >= p.total {
+		return io.EOF
+	}
+	if _, err = io.ReadFull(p.r, p.buf); err != nil {
+		err = fmt.Errorf("shape/las: read point %d failed, err = %v", p.num, err)
+		return
+	}
+	p.codec.Decode(p.buf, pt)
+	p.num++
+	return
+}
+
+// VLR returns the first VLR with the user id and the record id.
+func (p *Reader) VLR(userId string, recordId uint16) (vlr *VLR, ok bool) {
+	for i := range p.VLRs {
+		if p.VLRs[i].UserIdString() == userId && p.VLRs[i].RecordId == recordId {
+			return &p.VLRs[i], true
+		}
+	}
+	return
+}
+
+// GeoKeys decodes the GeoKeys of the LASF_Projection VLRs, with the key
+// model of the GeoTIFF files (see image/tiff).
+func (p *Reader) GeoKeys() (keys []tiff.GeoKey, err error) {
+	vlr, ok := p.VLR("LASF_Projection", uint16(VLRRecordId_GeoKeyDirectory))
+	if !ok {
+		err = fmt.Errorf("shape/las: Reader.GeoKeys, no GeoKeyDirectory VLR")
+		return
+	}
+	if len(vlr.Data)%2 != 0 {
+		err = fmt.Errorf("shape/las: Reader.GeoKeys, bad data size: %d", len(vlr.Data))
+		return
+	}
+	dir := make([]uint16, len(vlr.Data)/2)
+	for i := range dir {
+		dir[i] = binary.LittleEndian.Uint16(vlr.Data[2*i:])
+	}
+	var doubles []float64
+	if vlr, ok := p.VLR("LASF_Projection", uint16(VLRRecordId_GeoDoubleParam)); ok {
+		doubles = make([]float64, len(vlr.Data)/8)
+		for i := range doubles {
+			doubles[i] = math.Float64frombits(binary.LittleEndian.Uint64(vlr.Data[8*i:]))
+		}
+	}
+	var ascii string
+	if vlr, ok := p.VLR("LASF_Projection", uint16(VLRRecordId_GeoAsciiParam)); ok {
+		ascii = string(vlr.Data)
+	}
+	if keys, err = tiff.ParseGeoKeys(dir, doubles, ascii); err != nil {
+		err = fmt.Errorf("shape/las: Reader.GeoKeys, %v", err)
+	}
+	return
+}
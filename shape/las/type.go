@@ -59,7 +59,11 @@ type Header struct {
 // Las Header V14
 type HeaderV14 struct {
 	Header
-	OffsetWaveform uint64
+	OffsetWaveform            uint64 // 1.3
+	OffsetToFirstEVLR         uint64 // 1.4
+	NumberOfEVLRs             uint32 // 1.4
+	ExtNumberOfPointRecords   uint64 // 1.4
+	ExtNumberOfPointsByReturn [15]uint64
 }
 
 type VLRRecordIdType uint16
@@ -70,7 +74,7 @@ const (
 	VLRRecordId_Histogram            VLRRecordIdType = 2
 	VLRRecordId_TextAreaDesc         VLRRecordIdType = 3
 	VLRRecordId_GeoKeyDirectory      VLRRecordIdType = 34735
-	VLRRecordId_GeoDoubleParam       VLRRecordIdType = 34735
+	VLRRecordId_GeoDoubleParam       VLRRecordIdType = 34736
 	VLRRecordId_GeoAsciiParam        VLRRecordIdType = 34737
 )
 
@@ -90,11 +94,36 @@ type VLR_GeoKeysEntry struct {
 	ValueOffset     uint16
 }
 
+// Point is a point record of any point data format (0-10).
+// Fields not present in the point data format are zero.
 type Point struct {
 	X, Y, Z       float64
 	Intensity     uint16
-	BitFields     uint16
-	ScanAngleRank int8
+	BitFields     uint16 // raw bytes 14-15 of the record, ignored by Writer
+	ScanAngleRank int8   // 0-5
 	UserData      uint8
 	PointSourceId uint16
+
+	ReturnNumber        uint8
+	NumberOfReturns     uint8
+	ScanDirectionFlag   uint8
+	EdgeOfFlightLine    uint8
+	Classification      uint8
+	ClassificationFlags uint8 // bit0: synthetic, bit1: key-point, bit2: withheld, bit3: overlap (6-10)
+	ScannerChannel      uint8 // 6-10
+	ScanAngle           int16 // 6-10, 0.006 degree increments
+
+	GPSTime          float64 // 1, 3-10
+	Red, Green, Blue uint16  // 2, 3, 5, 7, 8, 10
+	NIR              uint16  // 8, 10
+	WavePacket       WavePacket
+}
+
+// WavePacket is the waveform packet of the point data formats 4, 5, 9 and 10.
+type WavePacket struct {
+	DescriptorIndex     uint8
+	OffsetToData        uint64
+	PacketSize          uint32
+	ReturnPointLocation float32
+	Xt, Yt, Zt          float32
 }
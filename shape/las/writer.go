@@ -0,0 +1,202 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package las
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Writer writes the point records of a LAS file one by one.
+// The header statistics (point counts and bounds) are updated by Write
+// and stored when the Writer is closed.
+type Writer struct {
+	Header HeaderV14
+	VLRs   []VLR
+
+	w      io.WriteSeeker
+	bw     *bufio.Writer
+	closer io.Closer
+	codec  *pointCodec
+	buf    []byte
+}
+
+// Create creates the named LAS file. See NewWriter for hdr and vlrs.
+func Create(filename string, hdr *HeaderV14, vlrs []VLR) (p *Writer, err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+	if p, err = NewWriter(f, hdr, vlrs); err != nil {
+		f.Close()
+		return
+	}
+	p.closer = f
+	return
+}
+
+// NewWriter writes the header and the VLRs to w, and returns a Writer for
+// the point records.
+//
+// Only PointDataFormatId, the scale factors and the offsets of hdr must be
+// set. The version defaults to 1.2 (1.4 for the point data formats 6-10),
+// the scale factors default to 0.01. The counters and the bounds are
+// computed by Write.
+func NewWriter(w io.WriteSeeker, hdr *HeaderV14, vlrs []VLR) (p *Writer, err error) {
+	if hdr.PointDataFormatId > maxPointFormat {
+		err = fmt.Errorf("shape/las: NewWriter, unsupported point data format: %d", hdr.PointDataFormatId)
+		return
+	}
+
+	p = &Writer{
+		Header: *hdr,
+		VLRs:   vlrs,
+		w:      w,
+		bw:     bufio.NewWriterSize(w, 64<<10),
+	}
+	h := &p.Header
+
+	copy(h.FileSignature[:], lasSig)
+	if h.MajorVersion == 0 {
+		h.MajorVersion, h.MinorVersion = 1, 2
+		if pointFormats[h.PointDataFormatId].Extended {
+			h.MinorVersion = 4
+		}
+	}
+	if h.MajorVersion != 1 || h.MinorVersion < 0 || h.MinorVersion > 4 {
+		err = fmt.Errorf("shape/las: NewWriter, unsupported version: %d.%d", h.MajorVersion, h.MinorVersion)
+		return
+	}
+	if pointFormats[h.PointDataFormatId].Extended && h.MinorVersion < 4 {
+		err = fmt.Errorf("shape/las: NewWriter, point data format %d needs version 1.4", h.PointDataFormatId)
+		return
+	}
+	if h.FileCreationYear == 0 {
+		now := time.Now()
+		h.FileCreationYear = uint16(now.Year())
+		h.FileCreationDay = uint16(now.YearDay())
+	}
+	if h.XScaleFactor == 0 {
+		h.XScaleFactor = 0.01
+	}
+	if h.YScaleFactor == 0 {
+		h.YScaleFactor = 0.01
+	}
+	if h.ZScaleFactor == 0 {
+		h.ZScaleFactor = 0.01
+	}
+	if size := PointDataRecordLen(h.PointDataFormatId); int(h.PointDataRecordLen) < size {
+		h.PointDataRecordLen = uint16(size)
+	}
+
+	h.HeaderSize = uint16(headerSize(h.MinorVersion))
+	h.NumberOfVariableLengthRecords = uint32(len(vlrs))
+	h.OffsetToPointData = uint32(h.HeaderSize)
+	for i := range vlrs {
+		h.OffsetToPointData += vlrHeaderSize + uint32(len(vlrs[i].Data))
+	}
+	h.OffsetToFirstEVLR = 0
+	h.NumberOfEVLRs = 0
+	h.NumberOfPointRecords = 0
+	h.NumberOfPointsByReturn = [5]uint32{}
+	h.ExtNumberOfPointRecords = 0
+	h.ExtNumberOfPointsByReturn = [15]uint64{}
+	h.MinX, h.MinY, h.MinZ = 0, 0, 0
+	h.MaxX, h.MaxY, h.MaxZ = 0, 0, 0
+
+	if err = writeHeader(p.bw, h); err != nil {
+		return
+	}
+	for i := range vlrs {
+		if err = writeVLR(p.bw, &vlrs[i]); err != nil {
+			return
+		}
+	}
+
+	p.codec = newPointCodec(&h.Header)
+	p.buf = make([]byte, h.PointDataRecordLen)
+	return
+}
+
+// Write writes a point record and updates the header statistics.
+func (p *Writer) Write(pt *Point) (err error) {
+	p.codec.Encode(p.buf, pt)
+	if _, err = p.bw.Write(p.buf); err != nil {
+		return
+	}
+
+	h := &p.Header
+	n := h.ExtNumberOfPointRecords
+	if n == 0 {
+		h.MinX, h.MaxX = pt.X, pt.X
+		h.MinY, h.MaxY = pt.Y, pt.Y
+		h.MinZ, h.MaxZ = pt.Z, pt.Z
+	} else {
+		h.MinX, h.MaxX = math.Min(h.MinX, pt.X), math.Max(h.MaxX, pt.X)
+		h.MinY, h.MaxY = math.Min(h.MinY, pt.Y), math.Max(h.MaxY, pt.Y)
+		h.MinZ, h.MaxZ = math.Min(h.MinZ, pt.Z), math.Max(h.MaxZ, pt.Z)
+	}
+
+	// the 64-bit counters are written only for the version 1.4, the
+	// legacy counters are zero for the 1.4 formats or if they overflow.
+	h.ExtNumberOfPointRecords++
+	r := int(pt.ReturnNumber)
+	if r >= 1 && r <= 15 {
+		h.ExtNumberOfPointsByReturn[r-1]++
+	}
+	if !p.codec.format.Extended {
+		if h.ExtNumberOfPointRecords <= legacyMaxPoints {
+			h.NumberOfPointRecords = uint32(h.ExtNumberOfPointRecords)
+		} else {
+			h.NumberOfPointRecords = 0
+		}
+		if r >= 1 && r <= 5 {
+			h.NumberOfPointsByReturn[r-1]++
+		}
+	}
+	return
+}
+
+// Close writes the remaining records, updates the header and closes the
+// file opened by Create.
+func (p *Writer) Close() (err error) {
+	defer func() {
+		if p.closer != nil {
+			if e := p.closer.Close(); e != nil && err == nil {
+				err = e
+			}
+			p.closer = nil
+		}
+	}()
+
+	if err = p.bw.Flush(); err != nil {
+		return
+	}
+
+	h := p.Header
+	if h.MinorVersion < 4 {
+		if h.ExtNumberOfPointRecords > legacyMaxPoints {
+			err = fmt.Errorf("shape/las: Writer.Close, too many points for version 1.%d: %d", h.MinorVersion, h.ExtNumberOfPointRecords)
+			return
+		}
+		h.ExtNumberOfPointRecords = 0
+		h.ExtNumberOfPointsByReturn = [15]uint64{}
+	}
+
+	if _, err = p.w.Seek(0, 0); err != nil {
+		return
+	}
+	if err = writeHeader(p.w, &h); err != nil {
+		return
+	}
+	if _, err = p.w.Seek(0, 2); err != nil {
+		return
+	}
+	return
+}
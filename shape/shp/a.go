@@ -1,7 +0,0 @@
-// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
-// Use of this source code is governed by a BSD-style
-// license that can be found in the LICENSE file.
-
-// +build ingore
-
-package dxf
@@ -0,0 +1,337 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dbfVersion         = 0x03
+	dbfHeaderSize      = 32
+	dbfFieldDescSize   = 32
+	dbfHeaderTerm      = 0x0D
+	dbfEOF             = 0x1A
+	dbfRecordValid     = ' '
+	dbfRecordDeleted   = '*'
+	dbfMaxFieldNameLen = 10
+)
+
+// Field is a column of the dBASE attribute table.
+//
+// Type is one of:
+//
+//	'C': string
+//	'N': number (integer or fixed point)
+//	'F': float
+//	'L': logical
+//	'D': date (YYYYMMDD)
+type Field struct {
+	Name     string
+	Type     byte
+	Length   uint8
+	Decimals uint8
+}
+
+func StringField(name string, length uint8) Field {
+	return Field{Name: name, Type: 'C', Length: length}
+}
+
+func NumberField(name string, length, decimals uint8) Field {
+	return Field{Name: name, Type: 'N', Length: length, Decimals: decimals}
+}
+
+func FloatField(name string, length, decimals uint8) Field {
+	return Field{Name: name, Type: 'F', Length: length, Decimals: decimals}
+}
+
+func LogicalField(name string) Field {
+	return Field{Name: name, Type: 'L', Length: 1}
+}
+
+func DateField(name string) Field {
+	return Field{Name: name, Type: 'D', Length: 8}
+}
+
+// Table is a dBASE III attribute table (.dbf).
+// Records are fixed size, so they can be read by index.
+type Table struct {
+	Fields     []Field
+	NumRecords int
+	headerLen  int
+	recordLen  int
+	r          io.ReadSeeker
+}
+
+func NewTable(r io.ReadSeeker) (p *Table, err error) {
+	var hdr [dbfHeaderSize]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		err = fmt.Errorf("shape/shp: read dbf header failed, err = %v", err)
+		return
+	}
+	p = &Table{
+		NumRecords: int(binary.LittleEndian.Uint32(hdr[4:])),
+		headerLen:  int(binary.LittleEndian.Uint16(hdr[8:])),
+		recordLen:  int(binary.LittleEndian.Uint16(hdr[10:])),
+		r:          r,
+	}
+	numFields := (p.headerLen - dbfHeaderSize - 1) / dbfFieldDescSize
+	if numFields < 0 || p.recordLen <= 0 {
+		err = fmt.Errorf("shape/shp: bad dbf header, headerLen = %d, recordLen = %d", p.headerLen, p.recordLen)
+		return
+	}
+
+	desc := make([]byte, numFields*dbfFieldDescSize)
+	if _, err = io.ReadFull(r, desc); err != nil {
+		err = fmt.Errorf("shape/shp: read dbf fields failed, err = %v", err)
+		return
+	}
+	recordLen := 1 // deletion flag
+	for i := 0; i < numFields; i++ {
+		d := desc[i*dbfFieldDescSize:]
+		name := d[:11]
+		if idx := bytes.IndexByte(name, 0); idx >= 0 {
+			name = name[:idx]
+		}
+		p.Fields = append(p.Fields, Field{
+			Name:     string(name),
+			Type:     d[11],
+			Length:   d[16],
+			Decimals: d[17],
+		})
+		recordLen += int(d[16])
+	}
+	if recordLen != p.recordLen {
+		err = fmt.Errorf("shape/shp: bad dbf record length, %d != %d", recordLen, p.recordLen)
+		return
+	}
+	return
+}
+
+// FieldIndex returns the index of the named field, or -1 if not found.
+// The comparison is case insensitive.
+func (p *Table) FieldIndex(name string) int {
+	for i, f := range p.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReadRecord returns the trimmed values of all fields of the i-th record.
+func (p *Table) ReadRecord(i int) (values []string, err error) {
+	raw, err := p.readRawRecord(i)
+	if err != nil {
+		return
+	}
+	values = make([]string, len(p.Fields))
+	off := 1
+	for k, f := range p.Fields {
+		values[k] = strings.TrimSpace(string(raw[off : off+int(f.Length)]))
+		off += int(f.Length)
+	}
+	return
+}
+
+// ReadField returns the trimmed value of a field of the i-th record.
+func (p *Table) ReadField(i, field int) (value string, err error) {
+	if field < 0 || field >= len(p.Fields) {
+		err = fmt.Errorf("shape/shp: Table.ReadField, bad field index: %d", field)
+		return
+	}
+	raw, err := p.readRawRecord(i)
+	if err != nil {
+		return
+	}
+	off := 1
+	for k := 0; k < field; k++ {
+		off += int(p.Fields[k].Length)
+	}
+	value = strings.TrimSpace(string(raw[off : off+int(p.Fields[field].Length)]))
+	return
+}
+
+// IsDeleted reports whether the i-th record is marked as deleted.
+func (p *Table) IsDeleted(i int) (deleted bool, err error) {
+	raw, err := p.readRawRecord(i)
+	if err != nil {
+		return
+	}
+	deleted = raw[0] == dbfRecordDeleted
+	return
+}
+
+func (p *Table) readRawRecord(i int) (raw []byte, err error) {
+	if i < 0 || i >= p.NumRecords {
+		err = fmt.Errorf("shape/shp: Table, bad record index: %d", i)
+		return
+	}
+	if _, err = p.r.Seek(int64(p.headerLen+i*p.recordLen), 0); err != nil {
+		return
+	}
+	raw = make([]byte, p.recordLen)
+	if _, err = io.ReadFull(p.r, raw); err != nil {
+		err = fmt.Errorf("shape/shp: Table, read record %d failed, err = %v", i, err)
+		return
+	}
+	return
+}
+
+type tableWriter struct {
+	w          io.WriteSeeker
+	fields     []Field
+	numRecords int
+	recordLen  int
+}
+
+func newTableWriter(w io.WriteSeeker, fields []Field) (p *tableWriter, err error) {
+	p = &tableWriter{
+		w:         w,
+		fields:    append([]Field(nil), fields...),
+		recordLen: 1,
+	}
+	for _, f := range fields {
+		if len(f.Name) == 0 || len(f.Name) > dbfMaxFieldNameLen {
+			err = fmt.Errorf("shape/shp: bad field name, %q", f.Name)
+			return
+		}
+		switch f.Type {
+		case 'C', 'N', 'F', 'L', 'D':
+		default:
+			err = fmt.Errorf("shape/shp: bad field type, %q", f.Type)
+			return
+		}
+		if f.Length == 0 {
+			err = fmt.Errorf("shape/shp: bad field length, %s", f.Name)
+			return
+		}
+		p.recordLen += int(f.Length)
+	}
+	err = p.writeHeader()
+	return
+}
+
+func (p *tableWriter) writeHeader() (err error) {
+	headerLen := dbfHeaderSize + len(p.fields)*dbfFieldDescSize + 1
+	buf := make([]byte, headerLen)
+
+	now := time.Now()
+	buf[0] = dbfVersion
+	buf[1] = byte(now.Year() - 1900)
+	buf[2] = byte(now.Month())
+	buf[3] = byte(now.Day())
+	binary.LittleEndian.PutUint32(buf[4:], uint32(p.numRecords))
+	binary.LittleEndian.PutUint16(buf[8:], uint16(headerLen))
+	binary.LittleEndian.PutUint16(buf[10:], uint16(p.recordLen))
+
+	for i, f := range p.fields {
+		d := buf[dbfHeaderSize+i*dbfFieldDescSize:]
+		copy(d[:11], f.Name)
+		d[11] = f.Type
+		d[16] = f.Length
+		d[17] = f.Decimals
+	}
+	buf[headerLen-1] = dbfHeaderTerm
+
+	if _, err = p.w.Seek(0, 0); err != nil {
+		return
+	}
+	_, err = p.w.Write(buf)
+	return
+}
+
+// formatRecord returns the record of values, without writing it.
+func (p *tableWriter) formatRecord(values []interface{}) (buf []byte, err error) {
+	if len(values) > len(p.fields) {
+		err = fmt.Errorf("shape/shp: too many attributes, %d > %d", len(values), len(p.fields))
+		return
+	}
+	buf = make([]byte, 0, p.recordLen)
+	buf = append(buf, dbfRecordValid)
+	for i, f := range p.fields {
+		var v interface{}
+		if i < len(values) {
+			v = values[i]
+		}
+		s, err := formatFieldValue(f, v)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, s...)
+	}
+	return
+}
+
+func (p *tableWriter) writeRecord(buf []byte) (err error) {
+	if _, err = p.w.Write(buf); err != nil {
+		return
+	}
+	p.numRecords++
+	return
+}
+
+func (p *tableWriter) Close() (err error) {
+	if _, err = p.w.Write([]byte{dbfEOF}); err != nil {
+		return
+	}
+	return p.writeHeader()
+}
+
+// formatFieldValue formats v as a fixed size field value.
+func formatFieldValue(f Field, v interface{}) (s string, err error) {
+	n := int(f.Length)
+	switch v := v.(type) {
+	case nil:
+		s = ""
+	case string:
+		s = v
+	case bool:
+		if v {
+			s = "T"
+		} else {
+			s = "F"
+		}
+	case time.Time:
+		s = v.Format("20060102")
+	case int:
+		s = strconv.FormatInt(int64(v), 10)
+	case int32:
+		s = strconv.FormatInt(int64(v), 10)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		s = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', int(f.Decimals), 64)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', int(f.Decimals), 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if len(s) > n {
+		if f.Type != 'C' {
+			err = fmt.Errorf("shape/shp: value %q overflows field %s", s, f.Name)
+			return
+		}
+		s = s[:n]
+	}
+	if f.Type == 'N' || f.Type == 'F' {
+		s = strings.Repeat(" ", n-len(s)) + s
+	} else {
+		s = s + strings.Repeat(" ", n-len(s))
+	}
+	return
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package shp reads and writes ESRI Shapefiles.
+
+A shapefile is a triple of files sharing the same base name:
+
+	name.shp // the shape records
+	name.shx // the record index (offset and length of each record)
+	name.dbf // the attribute table (dBASE III)
+
+Supported shape types are Point, MultiPoint, PolyLine, Polygon and their
+Z and M variants.
+
+Read a shapefile:
+
+	r, err := shp.Open("roads.shp")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	for r.Next() {
+		n, s := r.Shape()
+		name, _ := r.Attribute(n, r.FieldIndex("NAME"))
+		fmt.Println(n, s.BBox(), name)
+	}
+	if err := r.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+Write a shapefile:
+
+	w, err := shp.Create("cities.shp", shp.ShapeType_Point,
+		shp.StringField("NAME", 32),
+		shp.NumberField("POP", 10, 0),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.Write(&shp.Point{116.4, 39.9}, "Beijing", 21500000)
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+The ESRI Shapefile specification is at
+http://www.esri.com/library/whitepapers/pdfs/shapefile.pdf
+*/
+package shp
@@ -0,0 +1,87 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	shpHeaderSize       = 100
+	shpFileCode         = 9994
+	shpVersion          = 1000
+	shpRecordHeaderSize = 8
+	shxRecordSize       = 8
+)
+
+// Header is the main file header of the .shp and .shx files.
+//
+//	+---------+--------------+---------+--------+
+//	| Byte    | Field        | Type    | Order  |
+//	+---------+--------------+---------+--------+
+//	| 0       | File Code    | Integer | Big    |
+//	| 24      | File Length  | Integer | Big    |
+//	| 28      | Version      | Integer | Little |
+//	| 32      | Shape Type   | Integer | Little |
+//	| 36      | Bounding Box | Double  | Little |
+//	| 68      | Zmin, Zmax   | Double  | Little |
+//	| 84      | Mmin, Mmax   | Double  | Little |
+//	+---------+--------------+---------+--------+
+type Header struct {
+	FileLength int64 // bytes, including the header
+	ShapeType  ShapeType
+	BBox       Box
+	ZRange     [2]float64
+	MRange     [2]float64
+}
+
+func readHeader(r io.Reader) (hdr Header, err error) {
+	var buf [shpHeaderSize]byte
+	if _, err = io.ReadFull(r, buf[:]); err != nil {
+		err = fmt.Errorf("shape/shp: read header failed, err = %v", err)
+		return
+	}
+	if code := int32(binary.BigEndian.Uint32(buf[0:])); code != shpFileCode {
+		err = fmt.Errorf("shape/shp: bad file code, %d", code)
+		return
+	}
+	if version := int32(binary.LittleEndian.Uint32(buf[28:])); version != shpVersion {
+		err = fmt.Errorf("shape/shp: bad version, %d", version)
+		return
+	}
+	f64 := func(off int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf[off:]))
+	}
+	hdr.FileLength = int64(binary.BigEndian.Uint32(buf[24:])) * 2
+	hdr.ShapeType = ShapeType(binary.LittleEndian.Uint32(buf[32:]))
+	hdr.BBox = Box{f64(36), f64(44), f64(52), f64(60)}
+	hdr.ZRange = [2]float64{f64(68), f64(76)}
+	hdr.MRange = [2]float64{f64(84), f64(92)}
+	return
+}
+
+func writeHeader(w io.Writer, hdr *Header) (err error) {
+	var buf [shpHeaderSize]byte
+	putF64 := func(off int, v float64) {
+		binary.LittleEndian.PutUint64(buf[off:], math.Float64bits(v))
+	}
+	binary.BigEndian.PutUint32(buf[0:], shpFileCode)
+	binary.BigEndian.PutUint32(buf[24:], uint32(hdr.FileLength/2))
+	binary.LittleEndian.PutUint32(buf[28:], shpVersion)
+	binary.LittleEndian.PutUint32(buf[32:], uint32(hdr.ShapeType))
+	putF64(36, hdr.BBox.MinX)
+	putF64(44, hdr.BBox.MinY)
+	putF64(52, hdr.BBox.MaxX)
+	putF64(60, hdr.BBox.MaxY)
+	putF64(68, hdr.ZRange[0])
+	putF64(76, hdr.ZRange[1])
+	putF64(84, hdr.MRange[0])
+	putF64(92, hdr.MRange[1])
+	_, err = w.Write(buf[:])
+	return
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Reader reads the records of a shapefile.
+//
+// Records can be read sequentially with Next, or by index with ReadShape
+// when the .shx index is available.
+type Reader struct {
+	Header Header
+	Table  *Table // nil if no .dbf file
+
+	shp     io.ReadSeeker
+	shx     io.ReadSeeker
+	shxLen  int64
+	closers []io.Closer
+
+	pos   int64 // offset of the next record for Next
+	num   int
+	shape Shape
+	err   error
+}
+
+// Open opens the shapefile filename and its .shx and .dbf siblings.
+// The .shx and .dbf files are optional.
+func Open(filename string) (p *Reader, err error) {
+	base := strings.TrimSuffix(filename, ".shp")
+	base = strings.TrimSuffix(base, ".SHP")
+
+	var closers []io.Closer
+	defer func() {
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+		}
+	}()
+
+	shp, err := os.Open(base + ".shp")
+	if err != nil {
+		return
+	}
+	closers = append(closers, shp)
+
+	var shx, dbf io.ReadSeeker
+	if f, err := os.Open(base + ".shx"); err == nil {
+		closers = append(closers, f)
+		shx = f
+	}
+	if f, err := os.Open(base + ".dbf"); err == nil {
+		closers = append(closers, f)
+		dbf = f
+	}
+
+	if p, err = NewReader(shp, shx, dbf); err != nil {
+		return
+	}
+	p.closers = closers
+	return
+}
+
+// NewReader returns a Reader reading from shp. shx and dbf may be nil.
+func NewReader(shp, shx, dbf io.ReadSeeker) (p *Reader, err error) {
+	hdr, err := readHeader(shp)
+	if err != nil {
+		return
+	}
+	p = &Reader{
+		Header: hdr,
+		shp:    shp,
+		shx:    shx,
+		pos:    shpHeaderSize,
+		num:    -1,
+	}
+	if shx != nil {
+		if _, err = shx.Seek(0, 0); err != nil {
+			return
+		}
+		var shxHdr Header
+		if shxHdr, err = readHeader(shx); err != nil {
+			return
+		}
+		p.shxLen = shxHdr.FileLength
+	}
+	if dbf != nil {
+		if p.Table, err = NewTable(dbf); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Close closes the files opened by Open.
+func (p *Reader) Close() (err error) {
+	for _, c := range p.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	p.closers = nil
+	return
+}
+
+// Next reads the next record and reports whether there was one.
+func (p *Reader) Next() bool {
+	if p.err != nil || p.pos >= p.Header.FileLength {
+		return false
+	}
+	if _, p.err = p.shp.Seek(p.pos, 0); p.err != nil {
+		return false
+	}
+	var size int64
+	if _, p.shape, size, p.err = p.readRecord(); p.err != nil {
+		return false
+	}
+	p.num++
+	p.pos += shpRecordHeaderSize + size
+	return true
+}
+
+// Shape returns the index and the shape of the current record.
+func (p *Reader) Shape() (n int, s Shape) {
+	return p.num, p.shape
+}
+
+// Err returns the first error that was encountered by Next.
+func (p *Reader) Err() error {
+	return p.err
+}
+
+// NumRecords returns the number of records, or -1 if neither
+// the .shx nor the .dbf file is available.
+func (p *Reader) NumRecords() int {
+	if p.shx != nil {
+		return int((p.shxLen - shpHeaderSize) / shxRecordSize)
+	}
+	if p.Table != nil {
+		return p.Table.NumRecords
+	}
+	return -1
+}
+
+// ReadShape reads the i-th record by the .shx index.
+func (p *Reader) ReadShape(i int) (s Shape, err error) {
+	if p.shx == nil {
+		err = fmt.Errorf("shape/shp: Reader.ReadShape, no shx index")
+		return
+	}
+	if i < 0 || i >= p.NumRecords() {
+		err = fmt.Errorf("shape/shp: Reader.ReadShape, bad index: %d", i)
+		return
+	}
+	if _, err = p.shx.Seek(shpHeaderSize+int64(i)*shxRecordSize, 0); err != nil {
+		return
+	}
+	var buf [shxRecordSize]byte
+	if _, err = io.ReadFull(p.shx, buf[:]); err != nil {
+		return
+	}
+	offset := int64(binary.BigEndian.Uint32(buf[0:])) * 2
+	if _, err = p.shp.Seek(offset, 0); err != nil {
+		return
+	}
+	_, s, _, err = p.readRecord()
+	return
+}
+
+// Fields returns the attribute fields, or nil if there is no .dbf file.
+func (p *Reader) Fields() []Field {
+	if p.Table == nil {
+		return nil
+	}
+	return p.Table.Fields
+}
+
+// FieldIndex returns the index of the named field, or -1 if not found.
+func (p *Reader) FieldIndex(name string) int {
+	if p.Table == nil {
+		return -1
+	}
+	return p.Table.FieldIndex(name)
+}
+
+// Attribute returns the value of a field of the i-th record.
+func (p *Reader) Attribute(i, field int) (value string, err error) {
+	if p.Table == nil {
+		err = fmt.Errorf("shape/shp: Reader.Attribute, no dbf table")
+		return
+	}
+	return p.Table.ReadField(i, field)
+}
+
+// Attributes returns the values of all fields of the i-th record.
+func (p *Reader) Attributes(i int) (values []string, err error) {
+	if p.Table == nil {
+		err = fmt.Errorf("shape/shp: Reader.Attributes, no dbf table")
+		return
+	}
+	return p.Table.ReadRecord(i)
+}
+
+// readRecord reads a record at the current offset of the shp file.
+func (p *Reader) readRecord() (n int, s Shape, size int64, err error) {
+	var hdr [shpRecordHeaderSize]byte
+	if _, err = io.ReadFull(p.shp, hdr[:]); err != nil {
+		err = fmt.Errorf("shape/shp: read record header failed, err = %v", err)
+		return
+	}
+	n = int(int32(binary.BigEndian.Uint32(hdr[0:])))
+	size = int64(binary.BigEndian.Uint32(hdr[4:])) * 2
+	if size < 4 || shpHeaderSize+size > p.Header.FileLength {
+		err = fmt.Errorf("shape/shp: bad record content length, %d", size)
+		return
+	}
+	content := make([]byte, size)
+	if _, err = io.ReadFull(p.shp, content); err != nil {
+		err = fmt.Errorf("shape/shp: read record %d failed, err = %v", n, err)
+		return
+	}
+	if s, err = decodeShape(content); err != nil {
+		return
+	}
+	return
+}
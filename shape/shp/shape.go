@@ -0,0 +1,602 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+type ShapeType int32
+
+const (
+	ShapeType_Null        ShapeType = 0
+	ShapeType_Point       ShapeType = 1
+	ShapeType_PolyLine    ShapeType = 3
+	ShapeType_Polygon     ShapeType = 5
+	ShapeType_MultiPoint  ShapeType = 8
+	ShapeType_PointZ      ShapeType = 11
+	ShapeType_PolyLineZ   ShapeType = 13
+	ShapeType_PolygonZ    ShapeType = 15
+	ShapeType_MultiPointZ ShapeType = 18
+	ShapeType_PointM      ShapeType = 21
+	ShapeType_PolyLineM   ShapeType = 23
+	ShapeType_PolygonM    ShapeType = 25
+	ShapeType_MultiPointM ShapeType = 28
+)
+
+func (t ShapeType) String() string {
+	switch t {
+	case ShapeType_Null:
+		return "Null"
+	case ShapeType_Point:
+		return "Point"
+	case ShapeType_PolyLine:
+		return "PolyLine"
+	case ShapeType_Polygon:
+		return "Polygon"
+	case ShapeType_MultiPoint:
+		return "MultiPoint"
+	case ShapeType_PointZ:
+		return "PointZ"
+	case ShapeType_PolyLineZ:
+		return "PolyLineZ"
+	case ShapeType_PolygonZ:
+		return "PolygonZ"
+	case ShapeType_MultiPointZ:
+		return "MultiPointZ"
+	case ShapeType_PointM:
+		return "PointM"
+	case ShapeType_PolyLineM:
+		return "PolyLineM"
+	case ShapeType_PolygonM:
+		return "PolygonM"
+	case ShapeType_MultiPointM:
+		return "MultiPointM"
+	}
+	return fmt.Sprintf("ShapeType(%d)", int32(t))
+}
+
+// HasZ reports whether the shape type carries Z values.
+func (t ShapeType) HasZ() bool {
+	return t == ShapeType_PointZ || t == ShapeType_PolyLineZ ||
+		t == ShapeType_PolygonZ || t == ShapeType_MultiPointZ
+}
+
+// HasM reports whether the shape type carries M values.
+// Z types may carry M values too, but they are optional.
+func (t ShapeType) HasM() bool {
+	return t == ShapeType_PointM || t == ShapeType_PolyLineM ||
+		t == ShapeType_PolygonM || t == ShapeType_MultiPointM
+}
+
+// Values less than NoDataM are treated as "no data" in M arrays.
+const NoDataM = -1e38
+
+// Box is the bounding box of a shape in the XY plane.
+type Box struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Extend returns the smallest box that contains both p and b.
+func (p Box) Extend(b Box) Box {
+	return Box{
+		MinX: math.Min(p.MinX, b.MinX),
+		MinY: math.Min(p.MinY, b.MinY),
+		MaxX: math.Max(p.MaxX, b.MaxX),
+		MaxY: math.Max(p.MaxY, b.MaxY),
+	}
+}
+
+// Shape is the interface implemented by all shape record types.
+type Shape interface {
+	Type() ShapeType
+	BBox() Box
+	encode(b *shapeBuffer)
+	decode(b *shapeBuffer) error
+}
+
+// Null is a shape with no geometric data.
+type Null struct{}
+
+type Point struct {
+	X, Y float64
+}
+
+type PointM struct {
+	X, Y, M float64
+}
+
+type PointZ struct {
+	X, Y, Z, M float64
+}
+
+type MultiPoint struct {
+	Points []Point
+}
+
+type MultiPointM struct {
+	MultiPoint
+	M []float64
+}
+
+type MultiPointZ struct {
+	MultiPoint
+	Z []float64
+	M []float64 // optional
+}
+
+// PolyLine is an ordered set of vertices that consists of one or more parts.
+// Parts holds the index in Points of the first point of each part.
+type PolyLine struct {
+	Parts  []int32
+	Points []Point
+}
+
+type PolyLineM struct {
+	PolyLine
+	M []float64
+}
+
+type PolyLineZ struct {
+	PolyLine
+	Z []float64
+	M []float64 // optional
+}
+
+// Polygon consists of one or more rings. A ring is a closed loop whose first
+// and last points are equal. Outer rings are clockwise, holes are
+// counterclockwise.
+type Polygon struct {
+	Parts  []int32
+	Points []Point
+}
+
+type PolygonM struct {
+	Polygon
+	M []float64
+}
+
+type PolygonZ struct {
+	Polygon
+	Z []float64
+	M []float64 // optional
+}
+
+func (p *Null) Type() ShapeType        { return ShapeType_Null }
+func (p *Point) Type() ShapeType       { return ShapeType_Point }
+func (p *PointM) Type() ShapeType      { return ShapeType_PointM }
+func (p *PointZ) Type() ShapeType      { return ShapeType_PointZ }
+func (p *MultiPoint) Type() ShapeType  { return ShapeType_MultiPoint }
+func (p *MultiPointM) Type() ShapeType { return ShapeType_MultiPointM }
+func (p *MultiPointZ) Type() ShapeType { return ShapeType_MultiPointZ }
+func (p *PolyLine) Type() ShapeType    { return ShapeType_PolyLine }
+func (p *PolyLineM) Type() ShapeType   { return ShapeType_PolyLineM }
+func (p *PolyLineZ) Type() ShapeType   { return ShapeType_PolyLineZ }
+func (p *Polygon) Type() ShapeType     { return ShapeType_Polygon }
+func (p *PolygonM) Type() ShapeType    { return ShapeType_PolygonM }
+func (p *PolygonZ) Type() ShapeType    { return ShapeType_PolygonZ }
+
+func (p *Null) BBox() Box       { return Box{} }
+func (p *Point) BBox() Box      { return Box{p.X, p.Y, p.X, p.Y} }
+func (p *PointM) BBox() Box     { return Box{p.X, p.Y, p.X, p.Y} }
+func (p *PointZ) BBox() Box     { return Box{p.X, p.Y, p.X, p.Y} }
+func (p *MultiPoint) BBox() Box { return pointsBBox(p.Points) }
+func (p *PolyLine) BBox() Box   { return pointsBBox(p.Points) }
+func (p *Polygon) BBox() Box    { return pointsBBox(p.Points) }
+
+func (p *Null) encode(b *shapeBuffer) {}
+
+func (p *Null) decode(b *shapeBuffer) error {
+	return nil
+}
+
+func (p *Point) encode(b *shapeBuffer) {
+	b.PutFloat64(p.X)
+	b.PutFloat64(p.Y)
+}
+
+func (p *Point) decode(b *shapeBuffer) error {
+	p.X = b.Float64()
+	p.Y = b.Float64()
+	return b.Err()
+}
+
+func (p *PointM) encode(b *shapeBuffer) {
+	b.PutFloat64(p.X)
+	b.PutFloat64(p.Y)
+	b.PutFloat64(p.M)
+}
+
+func (p *PointM) decode(b *shapeBuffer) error {
+	p.X = b.Float64()
+	p.Y = b.Float64()
+	p.M = b.Float64()
+	return b.Err()
+}
+
+func (p *PointZ) encode(b *shapeBuffer) {
+	b.PutFloat64(p.X)
+	b.PutFloat64(p.Y)
+	b.PutFloat64(p.Z)
+	b.PutFloat64(p.M)
+}
+
+func (p *PointZ) decode(b *shapeBuffer) error {
+	p.X = b.Float64()
+	p.Y = b.Float64()
+	p.Z = b.Float64()
+	if b.Len() >= 8 {
+		p.M = b.Float64()
+	} else {
+		p.M = NoDataM * 10
+	}
+	return b.Err()
+}
+
+func (p *MultiPoint) encode(b *shapeBuffer) {
+	b.PutBox(p.BBox())
+	b.PutInt32(int32(len(p.Points)))
+	b.PutPoints(p.Points)
+}
+
+func (p *MultiPoint) decode(b *shapeBuffer) error {
+	b.Box()
+	n := int(b.Int32())
+	p.Points = b.Points(n)
+	return b.Err()
+}
+
+func (p *MultiPointM) encode(b *shapeBuffer) {
+	p.MultiPoint.encode(b)
+	b.PutMeasures(p.M, len(p.Points))
+}
+
+func (p *MultiPointM) decode(b *shapeBuffer) error {
+	if err := p.MultiPoint.decode(b); err != nil {
+		return err
+	}
+	p.M = b.Measures(len(p.Points))
+	return b.Err()
+}
+
+func (p *MultiPointZ) encode(b *shapeBuffer) {
+	p.MultiPoint.encode(b)
+	b.PutMeasures(p.Z, len(p.Points))
+	if p.M != nil {
+		b.PutMeasures(p.M, len(p.Points))
+	}
+}
+
+func (p *MultiPointZ) decode(b *shapeBuffer) error {
+	if err := p.MultiPoint.decode(b); err != nil {
+		return err
+	}
+	p.Z = b.Measures(len(p.Points))
+	if b.Len() > 0 {
+		p.M = b.Measures(len(p.Points))
+	}
+	return b.Err()
+}
+
+func (p *PolyLine) encode(b *shapeBuffer) {
+	b.PutBox(p.BBox())
+	b.PutParts(p.Parts, p.Points)
+}
+
+func (p *PolyLine) decode(b *shapeBuffer) error {
+	b.Box()
+	p.Parts, p.Points = b.Parts()
+	return b.Err()
+}
+
+func (p *PolyLineM) encode(b *shapeBuffer) {
+	p.PolyLine.encode(b)
+	b.PutMeasures(p.M, len(p.Points))
+}
+
+func (p *PolyLineM) decode(b *shapeBuffer) error {
+	if err := p.PolyLine.decode(b); err != nil {
+		return err
+	}
+	p.M = b.Measures(len(p.Points))
+	return b.Err()
+}
+
+func (p *PolyLineZ) encode(b *shapeBuffer) {
+	p.PolyLine.encode(b)
+	b.PutMeasures(p.Z, len(p.Points))
+	if p.M != nil {
+		b.PutMeasures(p.M, len(p.Points))
+	}
+}
+
+func (p *PolyLineZ) decode(b *shapeBuffer) error {
+	if err := p.PolyLine.decode(b); err != nil {
+		return err
+	}
+	p.Z = b.Measures(len(p.Points))
+	if b.Len() > 0 {
+		p.M = b.Measures(len(p.Points))
+	}
+	return b.Err()
+}
+
+func (p *Polygon) encode(b *shapeBuffer) {
+	b.PutBox(p.BBox())
+	b.PutParts(p.Parts, p.Points)
+}
+
+func (p *Polygon) decode(b *shapeBuffer) error {
+	b.Box()
+	p.Parts, p.Points = b.Parts()
+	return b.Err()
+}
+
+func (p *PolygonM) encode(b *shapeBuffer) {
+	p.Polygon.encode(b)
+	b.PutMeasures(p.M, len(p.Points))
+}
+
+func (p *PolygonM) decode(b *shapeBuffer) error {
+	if err := p.Polygon.decode(b); err != nil {
+		return err
+	}
+	p.M = b.Measures(len(p.Points))
+	return b.Err()
+}
+
+func (p *PolygonZ) encode(b *shapeBuffer) {
+	p.Polygon.encode(b)
+	b.PutMeasures(p.Z, len(p.Points))
+	if p.M != nil {
+		b.PutMeasures(p.M, len(p.Points))
+	}
+}
+
+func (p *PolygonZ) decode(b *shapeBuffer) error {
+	if err := p.Polygon.decode(b); err != nil {
+		return err
+	}
+	p.Z = b.Measures(len(p.Points))
+	if b.Len() > 0 {
+		p.M = b.Measures(len(p.Points))
+	}
+	return b.Err()
+}
+
+func newShape(t ShapeType) (s Shape, err error) {
+	switch t {
+	case ShapeType_Null:
+		s = new(Null)
+	case ShapeType_Point:
+		s = new(Point)
+	case ShapeType_PolyLine:
+		s = new(PolyLine)
+	case ShapeType_Polygon:
+		s = new(Polygon)
+	case ShapeType_MultiPoint:
+		s = new(MultiPoint)
+	case ShapeType_PointZ:
+		s = new(PointZ)
+	case ShapeType_PolyLineZ:
+		s = new(PolyLineZ)
+	case ShapeType_PolygonZ:
+		s = new(PolygonZ)
+	case ShapeType_MultiPointZ:
+		s = new(MultiPointZ)
+	case ShapeType_PointM:
+		s = new(PointM)
+	case ShapeType_PolyLineM:
+		s = new(PolyLineM)
+	case ShapeType_PolygonM:
+		s = new(PolygonM)
+	case ShapeType_MultiPointM:
+		s = new(MultiPointM)
+	default:
+		err = fmt.Errorf("shape/shp: bad shape type, %d", int32(t))
+	}
+	return
+}
+
+// decodeShape decodes a record content (shape type and shape data).
+func decodeShape(content []byte) (s Shape, err error) {
+	b := &shapeBuffer{buf: content}
+	t := ShapeType(b.Int32())
+	if err = b.Err(); err != nil {
+		return
+	}
+	if s, err = newShape(t); err != nil {
+		return
+	}
+	if err = s.decode(b); err != nil {
+		return
+	}
+	return
+}
+
+// encodeShape encodes the record content (shape type and shape data).
+func encodeShape(s Shape) []byte {
+	b := new(shapeBuffer)
+	b.PutInt32(int32(s.Type()))
+	s.encode(b)
+	return b.buf
+}
+
+func pointsBBox(points []Point) Box {
+	if len(points) == 0 {
+		return Box{}
+	}
+	box := Box{points[0].X, points[0].Y, points[0].X, points[0].Y}
+	for _, pt := range points[1:] {
+		box.MinX = math.Min(box.MinX, pt.X)
+		box.MinY = math.Min(box.MinY, pt.Y)
+		box.MaxX = math.Max(box.MaxX, pt.X)
+		box.MaxY = math.Max(box.MaxY, pt.Y)
+	}
+	return box
+}
+
+func valuesRange(values []float64) (min, max float64) {
+	if len(values) == 0 {
+		return
+	}
+	min, max = values[0], values[0]
+	for _, v := range values[1:] {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return
+}
+
+// shapeBuffer reads and writes the little endian shape record content.
+type shapeBuffer struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (b *shapeBuffer) Len() int {
+	return len(b.buf) - b.off
+}
+
+func (b *shapeBuffer) Err() error {
+	return b.err
+}
+
+func (b *shapeBuffer) next(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if b.Len() < n {
+		b.err = fmt.Errorf("shape/shp: short record content, need %d, have %d", n, b.Len())
+		return nil
+	}
+	v := b.buf[b.off : b.off+n]
+	b.off += n
+	return v
+}
+
+func (b *shapeBuffer) Int32() int32 {
+	if v := b.next(4); v != nil {
+		return int32(binary.LittleEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (b *shapeBuffer) Float64() float64 {
+	if v := b.next(8); v != nil {
+		return math.Float64frombits(binary.LittleEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (b *shapeBuffer) Box() Box {
+	return Box{b.Float64(), b.Float64(), b.Float64(), b.Float64()}
+}
+
+func (b *shapeBuffer) Points(n int) []Point {
+	if n < 0 || n*16 > b.Len() {
+		b.err = fmt.Errorf("shape/shp: bad number of points, %d", n)
+		return nil
+	}
+	points := make([]Point, n)
+	for i := 0; i < n; i++ {
+		points[i].X = b.Float64()
+		points[i].Y = b.Float64()
+	}
+	return points
+}
+
+// Measures reads the Z or M range followed by n values.
+// The range is ignored, it is always recomputed from the values.
+func (b *shapeBuffer) Measures(n int) []float64 {
+	b.Float64()
+	b.Float64()
+	if n < 0 || n*8 > b.Len() {
+		b.err = fmt.Errorf("shape/shp: bad number of measures, %d", n)
+		return nil
+	}
+	values := make([]float64, n)
+	for i := 0; i < n; i++ {
+		values[i] = b.Float64()
+	}
+	return values
+}
+
+func (b *shapeBuffer) Parts() (parts []int32, points []Point) {
+	numParts := int(b.Int32())
+	numPoints := int(b.Int32())
+	if numParts < 0 || numParts*4 > b.Len() {
+		b.err = fmt.Errorf("shape/shp: bad number of parts, %d", numParts)
+		return
+	}
+	parts = make([]int32, numParts)
+	for i := 0; i < numParts; i++ {
+		if parts[i] = b.Int32(); parts[i] < 0 || int(parts[i]) >= numPoints {
+			b.err = fmt.Errorf("shape/shp: bad part index, %d", parts[i])
+			return
+		}
+	}
+	points = b.Points(numPoints)
+	return
+}
+
+func (b *shapeBuffer) PutInt32(v int32) {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+	b.buf = append(b.buf, tmp[:]...)
+}
+
+func (b *shapeBuffer) PutFloat64(v float64) {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(v))
+	b.buf = append(b.buf, tmp[:]...)
+}
+
+func (b *shapeBuffer) PutBox(box Box) {
+	b.PutFloat64(box.MinX)
+	b.PutFloat64(box.MinY)
+	b.PutFloat64(box.MaxX)
+	b.PutFloat64(box.MaxY)
+}
+
+func (b *shapeBuffer) PutPoints(points []Point) {
+	for _, pt := range points {
+		b.PutFloat64(pt.X)
+		b.PutFloat64(pt.Y)
+	}
+}
+
+func (b *shapeBuffer) PutParts(parts []int32, points []Point) {
+	if len(parts) == 0 && len(points) > 0 {
+		parts = []int32{0}
+	}
+	b.PutInt32(int32(len(parts)))
+	b.PutInt32(int32(len(points)))
+	for _, v := range parts {
+		b.PutInt32(v)
+	}
+	b.PutPoints(points)
+}
+
+// PutMeasures writes the range and n values, missing values are
+// written as "no data".
+func (b *shapeBuffer) PutMeasures(values []float64, n int) {
+	if len(values) > n {
+		values = values[:n]
+	}
+	min, max := valuesRange(values)
+	b.PutFloat64(min)
+	b.PutFloat64(max)
+	for i := 0; i < n; i++ {
+		if i < len(values) {
+			b.PutFloat64(values[i])
+		} else {
+			b.PutFloat64(NoDataM * 10)
+		}
+	}
+}
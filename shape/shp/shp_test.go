@@ -0,0 +1,263 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempShapefile(t *testing.T, name string) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "shp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, name), func() { os.RemoveAll(dir) }
+}
+
+func TestPoint_withAttributes(t *testing.T) {
+	filename, cleanup := tempShapefile(t, "cities.shp")
+	defer cleanup()
+
+	type city struct {
+		Point
+		Name string
+		Pop  int
+	}
+	cities := []city{
+		{Point{116.40, 39.90}, "Beijing", 21500000},
+		{Point{121.47, 31.23}, "Shanghai", 24200000},
+		{Point{113.26, 23.13}, "Guangzhou", 14000000},
+	}
+
+	w, err := Create(filename, ShapeType_Point,
+		StringField("NAME", 16),
+		NumberField("POP", 10, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range cities {
+		pt := v.Point
+		if n, err := w.Write(&pt, v.Name, v.Pop); err != nil || n != i {
+			t.Fatalf("%d: Write failed: n = %d, err = %v", i, n, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.Header.ShapeType != ShapeType_Point {
+		t.Fatalf("bad shape type: %v", r.Header.ShapeType)
+	}
+	if box := (Box{113.26, 23.13, 121.47, 39.90}); r.Header.BBox != box {
+		t.Fatalf("bad bbox: %v", r.Header.BBox)
+	}
+	if n := r.NumRecords(); n != len(cities) {
+		t.Fatalf("bad NumRecords: %d", n)
+	}
+	if idx := r.FieldIndex("name"); idx != 0 {
+		t.Fatalf("bad FieldIndex: %d", idx)
+	}
+
+	count := 0
+	for r.Next() {
+		n, s := r.Shape()
+		if n != count {
+			t.Fatalf("bad record index: %d", n)
+		}
+		if pt, ok := s.(*Point); !ok || *pt != cities[n].Point {
+			t.Fatalf("%d: bad shape: %v", n, s)
+		}
+		values, err := r.Attributes(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values[0] != cities[n].Name {
+			t.Fatalf("%d: bad name: %q", n, values[0])
+		}
+		if pop, _ := r.Attribute(n, 1); pop != []string{"21500000", "24200000", "14000000"}[n] {
+			t.Fatalf("%d: bad pop: %q", n, pop)
+		}
+		count++
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(cities) {
+		t.Fatalf("bad count: %d", count)
+	}
+}
+
+func TestWriter_badAttributes(t *testing.T) {
+	filename, cleanup := tempShapefile(t, "bad.shp")
+	defer cleanup()
+
+	w, err := Create(filename, ShapeType_Point, NumberField("POP", 4, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(&Point{1, 2}, 1000); err != nil {
+		t.Fatal(err)
+	}
+	// the value overflows the field, nothing is written
+	if _, err = w.Write(&Point{3, 4}, 100000); err == nil {
+		t.Fatalf("expect an overflow error")
+	}
+	if n, err := w.Write(&Point{5, 6}, 2000); err != nil || n != 1 {
+		t.Fatalf("Write failed: n = %d, err = %v", n, err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if n := r.NumRecords(); n != 2 {
+		t.Fatalf("bad NumRecords: %d", n)
+	}
+	for i, v := range []Point{{1, 2}, {5, 6}} {
+		r.Next()
+		n, s := r.Shape()
+		if pt, ok := s.(*Point); n != i || !ok || *pt != v {
+			t.Fatalf("%d: bad shape: %v", i, s)
+		}
+		if pop, _ := r.Attribute(n, 0); pop != []string{"1000", "2000"}[i] {
+			t.Fatalf("%d: bad pop: %q", i, pop)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolygonZ_readShape(t *testing.T) {
+	filename, cleanup := tempShapefile(t, "parcels.shp")
+	defer cleanup()
+
+	shapes := []*PolygonZ{
+		&PolygonZ{
+			Polygon: Polygon{
+				Parts:  []int32{0},
+				Points: []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+			},
+			Z: []float64{1, 2, 3, 4, 1},
+			M: []float64{0, 1, 2, 3, 4},
+		},
+		&PolygonZ{
+			Polygon: Polygon{
+				Parts: []int32{0, 5},
+				Points: []Point{
+					{20, 20}, {20, 40}, {40, 40}, {40, 20}, {20, 20},
+					{25, 25}, {35, 25}, {35, 35}, {25, 35}, {25, 25},
+				},
+			},
+			Z: []float64{5, 5, 5, 5, 5, 6, 6, 6, 6, 6},
+		},
+	}
+
+	w, err := Create(filename, ShapeType_PolygonZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shapes {
+		if _, err := w.Write(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Write(&Null{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(&Point{1, 2}); err == nil {
+		t.Fatal("expect error for bad shape type")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.Table != nil {
+		t.Fatal("expect no dbf table")
+	}
+	if r.Header.ZRange != [2]float64{1, 6} {
+		t.Fatalf("bad z range: %v", r.Header.ZRange)
+	}
+	if n := r.NumRecords(); n != 3 {
+		t.Fatalf("bad NumRecords: %d", n)
+	}
+
+	// read backward by index
+	if s, err := r.ReadShape(2); err != nil || s.Type() != ShapeType_Null {
+		t.Fatalf("bad null shape: %v, %v", s, err)
+	}
+	for i := len(shapes) - 1; i >= 0; i-- {
+		s, err := r.ReadShape(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s, shapes[i]) {
+			t.Fatalf("%d: bad shape: %v", i, s)
+		}
+	}
+	if _, err := r.ReadShape(3); err == nil {
+		t.Fatal("expect error for bad index")
+	}
+
+	// random access must not break the sequential read
+	count := 0
+	for r.Next() {
+		count++
+	}
+	if err := r.Err(); err != nil || count != 3 {
+		t.Fatalf("bad count: %d, err = %v", count, err)
+	}
+}
+
+func TestShapes_roundTrip(t *testing.T) {
+	line := PolyLine{
+		Parts:  []int32{0, 2},
+		Points: []Point{{0, 0}, {1, 1}, {2, 2}, {3, 5}},
+	}
+	multi := MultiPoint{Points: []Point{{1, 2}, {3, 4}}}
+	for i, s := range []Shape{
+		&Point{1, 2},
+		&PointM{1, 2, 3},
+		&PointZ{1, 2, 3, 4},
+		&multi,
+		&MultiPointM{multi, []float64{7, 8}},
+		&MultiPointZ{multi, []float64{5, 6}, []float64{7, 8}},
+		&line,
+		&PolyLineM{line, []float64{1, 2, 3, 4}},
+		&PolyLineZ{line, []float64{1, 2, 3, 4}, nil},
+		&Polygon{line.Parts, line.Points},
+		&PolygonM{Polygon{line.Parts, line.Points}, []float64{1, 2, 3, 4}},
+	} {
+		got, err := decodeShape(encodeShape(s))
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Fatalf("%d: got %v, want %v", i, got, s)
+		}
+	}
+}
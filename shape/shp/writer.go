@@ -0,0 +1,268 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package shp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Writer writes the records of a shapefile.
+// The headers are updated when the Writer is closed.
+type Writer struct {
+	shp     io.WriteSeeker
+	shx     io.WriteSeeker
+	dbf     *tableWriter
+	closers []io.Closer
+
+	hdr        Header
+	numRecords int
+	err        error // the first write error, the files are inconsistent
+	hasBox     bool
+	hasZ, hasM bool
+}
+
+// Create creates the shapefile filename and its .shx and .dbf siblings.
+// The .dbf file is only created if fields is not empty.
+func Create(filename string, shapeType ShapeType, fields ...Field) (p *Writer, err error) {
+	base := strings.TrimSuffix(filename, ".shp")
+	base = strings.TrimSuffix(base, ".SHP")
+
+	var closers []io.Closer
+	defer func() {
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+		}
+	}()
+
+	shp, err := os.Create(base + ".shp")
+	if err != nil {
+		return
+	}
+	closers = append(closers, shp)
+	shx, err := os.Create(base + ".shx")
+	if err != nil {
+		return
+	}
+	closers = append(closers, shx)
+
+	var dbf io.WriteSeeker
+	if len(fields) > 0 {
+		var f *os.File
+		if f, err = os.Create(base + ".dbf"); err != nil {
+			return
+		}
+		closers = append(closers, f)
+		dbf = f
+	}
+
+	if p, err = NewWriter(shp, shx, dbf, shapeType, fields...); err != nil {
+		return
+	}
+	p.closers = closers
+	return
+}
+
+// NewWriter returns a Writer writing to shp and shx.
+// dbf may be nil if there is no attribute.
+func NewWriter(shp, shx, dbf io.WriteSeeker, shapeType ShapeType, fields ...Field) (p *Writer, err error) {
+	if _, err = newShape(shapeType); err != nil {
+		return
+	}
+	if dbf == nil && len(fields) > 0 {
+		err = fmt.Errorf("shape/shp: NewWriter, fields without dbf writer")
+		return
+	}
+	p = &Writer{
+		shp: shp,
+		shx: shx,
+		hdr: Header{
+			FileLength: shpHeaderSize,
+			ShapeType:  shapeType,
+		},
+	}
+	if err = p.writeHeaders(); err != nil {
+		return
+	}
+	if dbf != nil {
+		if p.dbf, err = newTableWriter(dbf, fields); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Write writes a shape record and its attributes, and returns the index
+// of the record. The shape must be of the Writer's shape type or Null.
+//
+// The attributes are checked before anything is written, so a bad record
+// leaves the files unchanged. After a failed write of the files, Write and
+// Close return that error.
+func (p *Writer) Write(s Shape, attrs ...interface{}) (n int, err error) {
+	if p.err != nil {
+		err = p.err
+		return
+	}
+	if s.Type() != p.hdr.ShapeType && s.Type() != ShapeType_Null {
+		err = fmt.Errorf("shape/shp: Writer.Write, bad shape type: %v", s.Type())
+		return
+	}
+
+	var record []byte
+	if p.dbf != nil {
+		if record, err = p.dbf.formatRecord(attrs); err != nil {
+			return
+		}
+	}
+
+	content := encodeShape(s)
+	offset := p.hdr.FileLength
+
+	defer func() {
+		if err != nil {
+			p.err = err
+		}
+	}()
+
+	var hdr [shpRecordHeaderSize]byte
+	binary.BigEndian.PutUint32(hdr[0:], uint32(p.numRecords+1))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(content)/2))
+	if _, err = p.shp.Write(hdr[:]); err != nil {
+		return
+	}
+	if _, err = p.shp.Write(content); err != nil {
+		return
+	}
+
+	var idx [shxRecordSize]byte
+	binary.BigEndian.PutUint32(idx[0:], uint32(offset/2))
+	binary.BigEndian.PutUint32(idx[4:], uint32(len(content)/2))
+	if _, err = p.shx.Write(idx[:]); err != nil {
+		return
+	}
+
+	if p.dbf != nil {
+		if err = p.dbf.writeRecord(record); err != nil {
+			return
+		}
+	}
+
+	p.hdr.FileLength += shpRecordHeaderSize + int64(len(content))
+	p.updateRange(s)
+
+	n = p.numRecords
+	p.numRecords++
+	return
+}
+
+// Close updates the file headers and closes the files opened by Create.
+func (p *Writer) Close() (err error) {
+	defer func() {
+		for _, c := range p.closers {
+			if e := c.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		p.closers = nil
+	}()
+
+	if p.err != nil {
+		return p.err
+	}
+	if err = p.writeHeaders(); err != nil {
+		return
+	}
+	if p.dbf != nil {
+		if err = p.dbf.Close(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *Writer) writeHeaders() (err error) {
+	if _, err = p.shp.Seek(0, 0); err != nil {
+		return
+	}
+	if err = writeHeader(p.shp, &p.hdr); err != nil {
+		return
+	}
+	if _, err = p.shp.Seek(p.hdr.FileLength, 0); err != nil {
+		return
+	}
+
+	shxHdr := p.hdr
+	shxHdr.FileLength = shpHeaderSize + int64(p.numRecords)*shxRecordSize
+	if _, err = p.shx.Seek(0, 0); err != nil {
+		return
+	}
+	if err = writeHeader(p.shx, &shxHdr); err != nil {
+		return
+	}
+	if _, err = p.shx.Seek(shxHdr.FileLength, 0); err != nil {
+		return
+	}
+	return
+}
+
+func (p *Writer) updateRange(s Shape) {
+	if s.Type() == ShapeType_Null {
+		return
+	}
+	if p.hasBox {
+		p.hdr.BBox = p.hdr.BBox.Extend(s.BBox())
+	} else {
+		p.hdr.BBox = s.BBox()
+		p.hasBox = true
+	}
+
+	z, m := shapeValuesZM(s)
+	if len(z) > 0 {
+		min, max := valuesRange(z)
+		if p.hasZ {
+			min = math.Min(min, p.hdr.ZRange[0])
+			max = math.Max(max, p.hdr.ZRange[1])
+		}
+		p.hdr.ZRange = [2]float64{min, max}
+		p.hasZ = true
+	}
+	if len(m) > 0 {
+		min, max := valuesRange(m)
+		if p.hasM {
+			min = math.Min(min, p.hdr.MRange[0])
+			max = math.Max(max, p.hdr.MRange[1])
+		}
+		p.hdr.MRange = [2]float64{min, max}
+		p.hasM = true
+	}
+}
+
+func shapeValuesZM(s Shape) (z, m []float64) {
+	switch s := s.(type) {
+	case *PointZ:
+		return []float64{s.Z}, []float64{s.M}
+	case *PointM:
+		return nil, []float64{s.M}
+	case *MultiPointZ:
+		return s.Z, s.M
+	case *MultiPointM:
+		return nil, s.M
+	case *PolyLineZ:
+		return s.Z, s.M
+	case *PolyLineM:
+		return nil, s.M
+	case *PolygonZ:
+		return s.Z, s.M
+	case *PolygonM:
+		return nil, s.M
+	}
+	return nil, nil
+}